@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// alertRule selects the findings to alert on. Every condition that is set
+// has to match; the globs are case-insensitive.
+type alertRule struct {
+	id         string
+	autologger string
+	// provider is a provider name glob or GUID.
+	provider string
+	// severity is the lowest severity that matches.
+	severity string
+}
+
+// severityRank orders the severities from low to high.
+var severityRank = map[string]int{SeverityLow: 1, SeverityMedium: 2, SeverityHigh: 3}
+
+// parseAlertRule parses a rule such as
+// "autologger=*Defender*,severity=medium".
+func parseAlertRule(s string) (alertRule, error) {
+	var rule alertRule
+	for _, cond := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(cond), "=")
+		if !ok || value == "" {
+			return rule, fmt.Errorf("invalid alert condition %q (expected key=value)", cond)
+		}
+		switch strings.ToLower(key) {
+		case "id":
+			rule.id = value
+		case "autologger":
+			rule.autologger = value
+		case "provider":
+			rule.provider = value
+		case "severity":
+			if severityRank[strings.ToLower(value)] == 0 {
+				return rule, fmt.Errorf("invalid alert severity %q (expected low, medium or high)", value)
+			}
+			rule.severity = strings.ToLower(value)
+		default:
+			return rule, fmt.Errorf("unknown alert condition %q (expected id, autologger, provider or severity)", key)
+		}
+	}
+	for _, glob := range []string{rule.id, rule.autologger, rule.provider} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return rule, fmt.Errorf("invalid alert pattern %q: %v", glob, err)
+		}
+	}
+	return rule, nil
+}
+
+// globMatch reports whether s matches the case-insensitive glob pattern.
+func globMatch(pattern, s string) bool {
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(s))
+	return ok
+}
+
+func (r alertRule) matches(f Finding) bool {
+	if r.id != "" && !globMatch(r.id, f.ID) {
+		return false
+	}
+	if r.autologger != "" && !globMatch(r.autologger, f.Autologger) {
+		return false
+	}
+	if r.provider != "" && !sameGUID(r.provider, f.ProviderGUID) && !globMatch(r.provider, f.ProviderName) {
+		return false
+	}
+	if r.severity != "" && severityRank[f.Severity] < severityRank[r.severity] {
+		return false
+	}
+	return true
+}
+
+// alertRules is a flag that can be repeated, one rule per flag. A finding
+// matches when it matches any rule, or when there are no rules.
+type alertRules []alertRule
+
+func (r *alertRules) String() string {
+	if len(*r) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d rules", len(*r))
+}
+
+func (r *alertRules) Set(value string) error {
+	rule, err := parseAlertRule(value)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+func (r alertRules) matches(f Finding) bool {
+	if len(r) == 0 {
+		return true
+	}
+	for _, rule := range r {
+		if rule.matches(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the findings that match.
+func (r alertRules) filter(findings []Finding) []Finding {
+	var matched []Finding
+	for _, f := range findings {
+		if r.matches(f) {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
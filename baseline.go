@@ -0,0 +1,253 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// baselineCheckCommand describes baseline check for its help text.
+var baselineCheckCommand = &command{
+	name:    "baseline check",
+	args:    "[flags]",
+	summary: "Compare the stock autologgers with a snapshot of a clean install",
+}
+
+// baselinePath is the snapshot of a clean install -baseline selects. No
+// baselines are embedded, so the autologgers are only compared with a
+// baseline when it is given.
+var baselinePath string
+
+// registerBaselineFlags adds the flag selecting a baseline file to a
+// command.
+func registerBaselineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&baselinePath, "baseline", "", "Compare the stock autologgers with this snapshot of a clean install")
+}
+
+// BaselineReport lists where the autologgers of this host deviate from the
+// baseline of its Windows build.
+type BaselineReport struct {
+	// Build is the Windows build of this host, and Baseline that of the
+	// host the baseline was taken on.
+	Build    string `json:"build"`
+	Baseline string `json:"baseline"`
+	// Source is the -baseline file.
+	Source     string              `json:"source"`
+	Deviations []BaselineDeviation `json:"deviations"`
+}
+
+// BaselineDeviation is a setting of a stock autologger that differs from the
+// baseline.
+type BaselineDeviation struct {
+	Autologger string `json:"autologger"`
+	Setting    string `json:"setting"`
+	// Provider is the GUID of the provider the setting belongs to, or ""
+	// for settings of the autologger.
+	Provider     string `json:"provider,omitempty"`
+	ProviderName string `json:"provider_name,omitempty"`
+	Baseline     string `json:"baseline"`
+	Host         string `json:"host"`
+}
+
+// getBaselineBuild returns the build number of the host a baseline was taken
+// on, e.g. "19045" for "19045.4291".
+func getBaselineBuild(snapshot *Snapshot) string {
+	if snapshot.Host == nil {
+		return ""
+	}
+	build, _, _ := strings.Cut(snapshot.Host.OSBuild, ".")
+	return build
+}
+
+// checkBaseline compares the autologgers of this host with those of a
+// baseline: missing autologgers and every setting that differs, providers
+// that were added or removed included. Autologgers the baseline doesn't have
+// aren't stock and aren't compared.
+func checkBaseline(baseline *Snapshot) ([]BaselineDeviation, int) {
+	var reports []*AutologgerReport
+	skipped := make(map[string]bool)
+	for _, expected := range baseline.Autologgers {
+		name := expected.Config.Name
+		// A missing autologger is a deviation, not an error.
+		key, err := openKey(registry.LOCAL_MACHINE, baseAutologgerPath+`\`+name, registry.QUERY_VALUE)
+		if err == registry.ErrNotExist {
+			continue
+		}
+		if err == nil {
+			key.Close()
+		}
+		report, err := analyzeAutologger(name, analyzeOptions{noResolve: true})
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			skipped[strings.ToLower(name)] = true
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	diff := diffSnapshots(baseline, newSnapshot(nil, reports))
+	deviations := []BaselineDeviation{}
+	for _, name := range diff.Removed {
+		if !skipped[strings.ToLower(name)] {
+			deviations = append(deviations, BaselineDeviation{Autologger: name, Setting: "Autologger", Baseline: "present", Host: "missing"})
+		}
+	}
+	for _, change := range diff.Changed {
+		for _, row := range change.Differences {
+			deviations = append(deviations, BaselineDeviation{
+				Autologger:   change.Autologger,
+				Setting:      row.Setting,
+				Provider:     row.Provider,
+				ProviderName: row.ProviderName,
+				Baseline:     row.A,
+				Host:         row.B,
+			})
+		}
+	}
+	return deviations, len(skipped)
+}
+
+// hostBaseline is the -baseline snapshot findings compare the autologgers
+// with, loaded on first use.
+var hostBaseline struct {
+	once   sync.Once
+	err    error
+	build  string
+	byName map[string]*AutologgerReport
+}
+
+// loadHostBaseline loads the -baseline snapshot. Without -baseline there is
+// no baseline and nothing is loaded.
+func loadHostBaseline() error {
+	hostBaseline.once.Do(func() {
+		if baselinePath == "" {
+			return
+		}
+		snapshot, err := loadSnapshot(baselinePath)
+		if err != nil {
+			hostBaseline.err = err
+			return
+		}
+		hostBaseline.build = getBaselineBuild(snapshot)
+		hostBaseline.byName = make(map[string]*AutologgerReport)
+		for _, report := range snapshot.Autologgers {
+			hostBaseline.byName[strings.ToLower(report.Config.Name)] = report
+		}
+	})
+	return hostBaseline.err
+}
+
+// getBaselineAutologger returns an autologger of the host's baseline and the
+// build of the baseline, or nil if there is no baseline or the autologger
+// isn't stock.
+func getBaselineAutologger(name string) (*AutologgerReport, string) {
+	if err := loadHostBaseline(); err != nil {
+		slog.Debug("not comparing with a baseline", "error", err)
+		return nil, ""
+	}
+	return hostBaseline.byName[strings.ToLower(name)], hostBaseline.build
+}
+
+// weakensCollection reports whether a difference from the baseline makes an
+// autologger collect less: it no longer starts at boot, or one of its
+// providers was removed, disabled or given an event ID filter.
+func weakensCollection(row DiffRow) bool {
+	switch row.Setting {
+	case "Start":
+		return row.B == getStartStatus(0)
+	case "Provider":
+		return row.B == "-"
+	case "Enabled":
+		return row.B == enabledOff
+	case "EventIds":
+		return row.B != "-"
+	}
+	return false
+}
+
+// getDeviationDescription describes a difference from the baseline, e.g.
+// "Start is Disabled (0) instead of Enabled (1)".
+func getDeviationDescription(row DiffRow) string {
+	setting := row.Setting
+	if row.Provider != "" {
+		setting = fmt.Sprintf("%s of %s (%s)", row.Setting, cmp.Or(row.ProviderName, "unknown"), row.Provider)
+	}
+	return fmt.Sprintf("%s is %s instead of %s", setting, row.B, row.A)
+}
+
+func runBaseline(cmd *command, args []string) error {
+	if len(args) > 0 && args[0] == "check" {
+		return runBaselineCheck(args[1:])
+	}
+	fs := newFlagSet(cmd)
+	fs.Usage()
+	return fmt.Errorf("a baseline subcommand is required: check")
+}
+
+// runBaselineCheck implements baseline check.
+func runBaselineCheck(args []string) error {
+	var format string
+	var output outputOptions
+
+	fs := newFlagSet(baselineCheckCommand)
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	registerBaselineFlags(fs)
+	parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if baselinePath == "" {
+		fs.Usage()
+		return fmt.Errorf("-baseline is required: take a snapshot of a clean install of this Windows build and pass it")
+	}
+
+	baseline, err := loadSnapshot(baselinePath)
+	if err != nil {
+		return err
+	}
+	deviations, skipped := checkBaseline(baseline)
+	report := &BaselineReport{
+		Build:      collectHostMetadata().OSBuild,
+		Baseline:   getBaselineBuild(baseline),
+		Source:     baselinePath,
+		Deviations: deviations,
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		displayBaselineReport(os.Stdout, report, len(baseline.Autologgers), newPalette(os.Stdout, output.color))
+	}
+	return reportSummary{findings: len(deviations), skipped: skipped}.exitStatus()
+}
+
+// displayBaselineReport prints the deviations from a baseline.
+func displayBaselineReport(w io.Writer, report *BaselineReport, checked int, pal palette) {
+	fmt.Fprintf(w, "Baseline %s of Windows build %s (%d stock autologgers):\n", report.Source, cmp.Or(report.Baseline, "unknown"), checked)
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	if len(report.Deviations) == 0 {
+		fmt.Fprintf(w, "The stock autologgers match the baseline\n")
+		return
+	}
+	for _, d := range report.Deviations {
+		setting := d.Setting
+		if d.Provider != "" {
+			setting = fmt.Sprintf("%s of %s (%s)", d.Setting, d.ProviderName, d.Provider)
+		}
+		fmt.Fprintf(w, "- %s: %s: baseline %s, host %s\n", d.Autologger, setting, d.Baseline, pal.yellow(d.Host))
+	}
+}
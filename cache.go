@@ -0,0 +1,36 @@
+package main
+
+import "sync"
+
+// onceCache runs a lookup once per key for the whole run. Only finding the
+// entry of a key is serialized, so lookups of different keys run
+// concurrently and callers of a key being looked up wait for its result.
+type onceCache[T any] struct {
+	sync.Mutex
+	entries map[string]*onceEntry[T]
+}
+
+type onceEntry[T any] struct {
+	once  sync.Once
+	value T
+}
+
+// get returns the cached value of key, calling lookup to produce it on first
+// use.
+func (c *onceCache[T]) get(key string, lookup func() T) T {
+	c.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*onceEntry[T])
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &onceEntry[T]{}
+		c.entries[key] = entry
+	}
+	c.Unlock()
+
+	entry.once.Do(func() {
+		entry.value = lookup()
+	})
+	return entry.value
+}
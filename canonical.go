@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// canonicalAutologger turns a snapshot report into the document unified
+// diffs and JSON Patches are computed on: its JSON form with the providers
+// keyed by GUID instead of in a list, so a provider keeps its path when
+// others are added or removed.
+func canonicalAutologger(report *AutologgerReport) (map[string]any, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Numbers are kept as written, so 64-bit keywords don't lose precision.
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	providers := make(map[string]any)
+	if list, ok := doc["providers"].([]any); ok {
+		for i, p := range list {
+			providers[canonicalGUID(report.Providers[i].GUID)] = p
+		}
+	}
+	doc["providers"] = providers
+	return doc, nil
+}
+
+// canonicalSnapshot is the document of a snapshot: its autologgers keyed by
+// name. The host is left out, as it differs between any two snapshots.
+func canonicalSnapshot(snapshot *Snapshot) (map[string]any, error) {
+	doc := make(map[string]any)
+	for _, report := range snapshot.Autologgers {
+		autologger, err := canonicalAutologger(report)
+		if err != nil {
+			return nil, err
+		}
+		doc[report.Config.Name] = autologger
+	}
+	return doc, nil
+}
+
+// renderYAML renders a document decoded from JSON as YAML with the keys of
+// every object sorted, so equal documents render to the same text.
+func renderYAML(doc any) string {
+	var b strings.Builder
+	writeYAML(&b, doc, 0)
+	return b.String()
+}
+
+func writeYAML(b *strings.Builder, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			fmt.Fprintf(b, "%s{}\n", pad)
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			writeYAMLEntry(b, pad+yamlString(k)+":", v[k], indent)
+		}
+	case []any:
+		if len(v) == 0 {
+			fmt.Fprintf(b, "%s[]\n", pad)
+			return
+		}
+		for _, item := range v {
+			// Objects start on the line of their dash.
+			if m, ok := item.(map[string]any); ok && len(m) > 0 {
+				var nested strings.Builder
+				writeYAML(&nested, m, indent+1)
+				b.WriteString(pad + "- " + strings.TrimPrefix(nested.String(), pad+"  "))
+				continue
+			}
+			writeYAMLEntry(b, pad+"-", item, indent)
+		}
+	default:
+		fmt.Fprintf(b, "%s%s\n", pad, yamlScalar(v))
+	}
+}
+
+// writeYAMLEntry writes a key or list item: scalars and empty collections
+// on the same line, others indented below it.
+func writeYAMLEntry(b *strings.Builder, prefix string, v any, indent int) {
+	switch c := v.(type) {
+	case map[string]any:
+		if len(c) > 0 {
+			b.WriteString(prefix + "\n")
+			writeYAML(b, c, indent+1)
+			return
+		}
+		b.WriteString(prefix + " {}\n")
+	case []any:
+		if len(c) > 0 {
+			b.WriteString(prefix + "\n")
+			writeYAML(b, c, indent+1)
+			return
+		}
+		b.WriteString(prefix + " []\n")
+	default:
+		b.WriteString(prefix + " " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return yamlString(v)
+	}
+	return yamlString(fmt.Sprint(v))
+}
+
+// yamlString quotes strings that YAML would read as something else, such
+// as numbers, booleans, GUIDs in braces or paths with special characters.
+func yamlString(s string) string {
+	plain := s != "" && s == strings.TrimSpace(s) &&
+		!strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") &&
+		!strings.Contains(s, ": ") && !strings.HasSuffix(s, ":") && !strings.Contains(s, " #") &&
+		!strings.ContainsFunc(s, func(r rune) bool { return r < ' ' })
+	if plain {
+		switch strings.ToLower(s) {
+		case "null", "~", "true", "false", "yes", "no", "on", "off":
+			plain = false
+		}
+	}
+	if plain {
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			plain = false
+		}
+	}
+	if plain {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	siemVendor  = "olafhartong"
+	siemProduct = "autologgerAnalyzer"
+)
+
+// cefSeverity maps finding severities onto the 0-10 CEF scale.
+var cefSeverity = map[string]int{
+	SeverityLow:    3,
+	SeverityMedium: 5,
+	SeverityHigh:   8,
+}
+
+// cefReportWriter emits one ArcSight Common Event Format line per finding.
+type cefReportWriter struct {
+	w    io.Writer
+	host *HostMetadata
+}
+
+func (c *cefReportWriter) WriteReport(report *AutologgerReport) error {
+	for _, f := range report.Findings {
+		ext := []string{
+			"rt=" + fmt.Sprintf("%d", c.host.CollectedAt.UnixMilli()),
+			"dvchost=" + cefExtensionEscape(c.host.Hostname),
+			"deviceNtDomain=" + cefExtensionEscape(c.host.Domain),
+			"cs1Label=Autologger",
+			"cs1=" + cefExtensionEscape(f.Autologger),
+		}
+		if f.ProviderGUID != "" {
+			ext = append(ext,
+				"cs2Label=ProviderGUID",
+				"cs2="+cefExtensionEscape(f.ProviderGUID),
+				"cs3Label=ProviderName",
+				"cs3="+cefExtensionEscape(f.ProviderName))
+		}
+		ext = append(ext, "msg="+cefExtensionEscape(f.Message))
+
+		_, err := fmt.Fprintf(c.w, "CEF:0|%s|%s|%s|%s|%s|%d|%s\n",
+			cefHeaderEscape(siemVendor),
+			cefHeaderEscape(siemProduct),
+			cefHeaderEscape(version),
+			cefHeaderEscape(f.ID),
+			cefHeaderEscape(f.Message),
+			cefSeverity[f.Severity],
+			strings.Join(ext, " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cefReportWriter) Close() error { return nil }
+
+func cefHeaderEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func cefExtensionEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "=", `\=`)
+	s = strings.ReplaceAll(s, "\r", `\r`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// leefReportWriter emits one IBM QRadar LEEF 1.0 line per finding.
+type leefReportWriter struct {
+	w    io.Writer
+	host *HostMetadata
+}
+
+func (l *leefReportWriter) WriteReport(report *AutologgerReport) error {
+	for _, f := range report.Findings {
+		attrs := []string{
+			"devTime=" + l.host.CollectedAt.Format(time.RFC3339),
+			"devTimeFormat=yyyy-MM-dd'T'HH:mm:ssX",
+			"sev=" + fmt.Sprintf("%d", cefSeverity[f.Severity]),
+			"identHostName=" + leefValueEscape(l.host.Hostname),
+			"autologger=" + leefValueEscape(f.Autologger),
+		}
+		if f.ProviderGUID != "" {
+			attrs = append(attrs,
+				"providerGuid="+leefValueEscape(f.ProviderGUID),
+				"providerName="+leefValueEscape(f.ProviderName))
+		}
+		attrs = append(attrs, "msg="+leefValueEscape(f.Message))
+
+		_, err := fmt.Fprintf(l.w, "LEEF:1.0|%s|%s|%s|%s|%s\n",
+			cefHeaderEscape(siemVendor),
+			cefHeaderEscape(siemProduct),
+			cefHeaderEscape(version),
+			cefHeaderEscape(f.ID),
+			strings.Join(attrs, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *leefReportWriter) Close() error { return nil }
+
+func leefValueEscape(s string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	publishersPath = `SOFTWARE\Microsoft\Windows\CurrentVersion\WINEVT\Publishers`
+	channelsPath   = `SOFTWARE\Microsoft\Windows\CurrentVersion\WINEVT\Channels`
+)
+
+// ProviderChannel is an event log channel a provider writes to, as
+// configured for the Event Log service.
+type ProviderChannel struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Isolation string `json:"isolation"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// channelTypes and channelIsolations name the Type and Isolation values of a
+// channel key.
+var (
+	channelTypes      = []string{"Admin", "Operational", "Analytic", "Debug"}
+	channelIsolations = []string{"Application", "System", "Custom"}
+)
+
+// isEventLogSession reports whether an autologger feeds the Event Log
+// service, like EventLog-Application, EventLog-System and EventLog-Security.
+func isEventLogSession(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "eventlog-")
+}
+
+// eventLogIsolation returns the channel isolation whose events an EventLog-*
+// session delivers, or "" if it isn't tied to one.
+func eventLogIsolation(autologgerName string) string {
+	switch strings.ToLower(autologgerName) {
+	case "eventlog-application":
+		return "Application"
+	case "eventlog-system":
+		return "System"
+	}
+	return ""
+}
+
+// getProviderChannels returns the Admin and Operational channels of a
+// provider that an EventLog-* session delivers to: those with the isolation
+// of the session, or all of them if the session isn't tied to one. Analytic
+// and Debug channels have sessions of their own.
+func getProviderChannels(autologgerName, guid string) ([]ProviderChannel, error) {
+	refs, err := openKey(registry.LOCAL_MACHINE, publishersPath+`\`+canonicalGUID(guid)+`\ChannelReferences`, registry.READ)
+	if err != nil {
+		if err == registry.ErrNotExist {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading channel references: %v", err)
+	}
+	defer refs.Close()
+
+	names, err := refs.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("reading channel references: %v", err)
+	}
+
+	isolation := eventLogIsolation(autologgerName)
+	var channels []ProviderChannel
+	for _, name := range names {
+		ref, err := openKey(refs, name, registry.READ)
+		if err != nil {
+			slog.Debug("cannot open channel reference", "provider", guid, "reference", name, "error", err)
+			continue
+		}
+		channelName, _, err := ref.GetStringValue("")
+		ref.Close()
+		if err != nil || channelName == "" {
+			continue
+		}
+
+		channel, err := getChannel(channelName)
+		if err != nil {
+			slog.Debug("cannot read channel", "channel", channelName, "error", err)
+			continue
+		}
+		if channel.Type != "Admin" && channel.Type != "Operational" {
+			continue
+		}
+		if isolation != "" && channel.Isolation != isolation {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+// getChannel reads the configuration of an event log channel. Channels
+// without Type or Isolation values default to Admin and Application, except
+// the classic System and Security logs which have isolations of their own.
+func getChannel(name string) (ProviderChannel, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, channelsPath+`\`+name, registry.READ)
+	if err != nil {
+		return ProviderChannel{}, err
+	}
+	defer key.Close()
+
+	channel := ProviderChannel{Name: name, Type: "Admin", Isolation: "Application"}
+	switch strings.ToLower(name) {
+	case "system":
+		channel.Isolation = "System"
+	case "security":
+		channel.Isolation = "Custom"
+	}
+	if enabled, _, err := key.GetIntegerValue("Enabled"); err == nil {
+		channel.Enabled = enabled != 0
+	}
+	if t, _, err := key.GetIntegerValue("Type"); err == nil {
+		channel.Type = nameOrNumber(channelTypes, t)
+	}
+	if iso, _, err := key.GetIntegerValue("Isolation"); err == nil {
+		channel.Isolation = nameOrNumber(channelIsolations, iso)
+	}
+	return channel, nil
+}
+
+// nameOrNumber returns names[i], or i as text if it is out of range.
+func nameOrNumber(names []string, i uint64) string {
+	if i < uint64(len(names)) {
+		return names[i]
+	}
+	return fmt.Sprintf("%d", i)
+}
+
+// getChannelDescription renders a channel as a single line.
+func getChannelDescription(c ProviderChannel) string {
+	state := "Disabled"
+	if c.Enabled {
+		state = "Enabled"
+	}
+	return fmt.Sprintf("%s (%s, %s isolation, %s)", c.Name, c.Type, c.Isolation, state)
+}
+
+// providersWithChannels returns the providers with event log channels.
+func providersWithChannels(providers []ETWProvider) []ETWProvider {
+	var with []ETWProvider
+	for _, p := range providers {
+		if len(p.Channels) > 0 {
+			with = append(with, p)
+		}
+	}
+	return with
+}
+
+// allChannelsDisabled reports whether a provider has channels and all of them
+// are disabled, so the Event Log service drops its events.
+func allChannelsDisabled(p ETWProvider) bool {
+	for _, c := range p.Channels {
+		if c.Enabled {
+			return false
+		}
+	}
+	return len(p.Channels) > 0
+}
+
+// displayChannels lists the event log channels the providers of an EventLog-*
+// session deliver to.
+func displayChannels(w io.Writer, providers []ETWProvider, pal palette) {
+	with := providersWithChannels(providers)
+	if len(with) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n\nEvent Log Channels:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, provider := range with {
+		fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+		for _, c := range provider.Channels {
+			desc := getChannelDescription(c)
+			if !c.Enabled {
+				desc = pal.yellow(desc)
+			}
+			fmt.Fprintf(w, "- %s\n", desc)
+		}
+	}
+}
+
+// EventChannelRoute is an event log channel that events passing the
+// autologger's filters are written to, with the IDs of those events.
+type EventChannelRoute struct {
+	ProviderChannel
+	EventIDs []int `json:"event_ids"`
+}
+
+// getChannelReferenceIDs maps the channel values of a provider's events to
+// the channel names, from the Id value of its ChannelReferences.
+func getChannelReferenceIDs(guid string) map[uint64]string {
+	refs, err := openKey(registry.LOCAL_MACHINE, publishersPath+`\`+canonicalGUID(guid)+`\ChannelReferences`, registry.READ)
+	if err != nil {
+		return nil
+	}
+	defer refs.Close()
+	names, err := refs.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil
+	}
+	ids := make(map[uint64]string, len(names))
+	for _, name := range names {
+		ref, err := openKey(refs, name, registry.READ)
+		if err != nil {
+			continue
+		}
+		channelName, _, err := ref.GetStringValue("")
+		id, _, idErr := ref.GetIntegerValue("Id")
+		ref.Close()
+		if err == nil && idErr == nil && channelName != "" {
+			ids[id] = channelName
+		}
+	}
+	return ids
+}
+
+// resolveEventChannels groups the manifest events that pass a provider's
+// filters by the channel they are written to. Events without a channel only
+// reach ETW sessions and are left out.
+func resolveEventChannels(p ETWProvider) []EventChannelRoute {
+	events := getProviderManifest(p.GUID).events
+	if len(events) == 0 {
+		return nil
+	}
+	refs := getChannelReferenceIDs(p.GUID)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]*EventChannelRoute)
+	for _, event := range events {
+		if event.Channel == 0 || !eventCaptured(p, event) {
+			continue
+		}
+		name, ok := refs[uint64(event.Channel)]
+		if !ok {
+			slog.Debug("event refers to an unknown channel", "provider", p.GUID, "event", event.Id, "channel", event.Channel)
+			continue
+		}
+		route, ok := byName[name]
+		if !ok {
+			channel, err := getChannel(name)
+			if err != nil {
+				slog.Debug("cannot read channel", "channel", name, "error", err)
+				continue
+			}
+			route = &EventChannelRoute{ProviderChannel: channel}
+			byName[name] = route
+		}
+		route.EventIDs = append(route.EventIDs, int(event.Id))
+	}
+
+	routes := make([]EventChannelRoute, 0, len(byName))
+	for _, route := range byName {
+		routes = append(routes, *route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Name < routes[j].Name
+	})
+	return routes
+}
+
+// getEventChannelRouteDescription renders a channel with the events written
+// to it, e.g. "Microsoft-Windows-DNS-Client/Operational (Operational,
+// Application isolation, Disabled): events 3006-3020".
+func getEventChannelRouteDescription(r EventChannelRoute) string {
+	return fmt.Sprintf("%s: events %s", getChannelDescription(r.ProviderChannel), formatEventIDRanges(r.EventIDs))
+}
+
+// providersWithEventChannels returns the providers whose captured events are
+// written to event log channels.
+func providersWithEventChannels(providers []ETWProvider) []ETWProvider {
+	var with []ETWProvider
+	for _, p := range providers {
+		if len(p.EventChannels) > 0 {
+			with = append(with, p)
+		}
+	}
+	return with
+}
+
+// displayEventChannels lists the channels the captured events of every
+// provider are written to, highlighting disabled channels.
+func displayEventChannels(w io.Writer, providers []ETWProvider, pal palette) {
+	with := providersWithEventChannels(providers)
+	if len(with) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n\nEvent Channel Routing:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, provider := range with {
+		fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+		for _, r := range provider.EventChannels {
+			desc := getEventChannelRouteDescription(r)
+			if !r.Enabled {
+				desc = pal.yellow(desc)
+			}
+			fmt.Fprintf(w, "- %s\n", desc)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+)
+
+// palette colors text with ANSI escapes when enabled. The zero value leaves
+// text untouched.
+type palette struct {
+	enabled bool
+}
+
+func (p palette) wrap(code, s string) string {
+	if !p.enabled {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+func (p palette) red(s string) string    { return p.wrap(ansiRed, s) }
+func (p palette) yellow(s string) string { return p.wrap(ansiYellow, s) }
+
+// severity colors s by finding severity: high red, medium yellow.
+func (p palette) severity(sev, s string) string {
+	switch sev {
+	case SeverityHigh:
+		return p.red(s)
+	case SeverityMedium:
+		return p.yellow(s)
+	default:
+		return s
+	}
+}
+
+// newPalette decides whether to color output written to w. mode is auto,
+// always or never; auto colors consoles unless NO_COLOR is set
+// (https://no-color.org).
+func newPalette(w io.Writer, mode string) palette {
+	switch mode {
+	case "always":
+		enableVirtualTerminal(w)
+		return palette{enabled: true}
+	case "never":
+		return palette{}
+	}
+	if _, set := os.LookupEnv("NO_COLOR"); set || os.Getenv("TERM") == "dumb" {
+		return palette{}
+	}
+	return palette{enabled: enableVirtualTerminal(w)}
+}
+
+// enableVirtualTerminal turns on ANSI escape processing for the console w
+// writes to. It reports false if w is not a console or escapes aren't
+// supported.
+func enableVirtualTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	handle := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
+
+func validateColorMode(mode string) error {
+	switch mode {
+	case "auto", "always", "never":
+		return nil
+	}
+	return fmt.Errorf("invalid -color value %q (expected auto, always or never)", mode)
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// command is a subcommand of the tool with its own flags and help text.
+type command struct {
+	name    string
+	args    string // synopsis shown after the command name
+	summary string
+	run     func(cmd *command, args []string) error
+	hidden  bool // not listed in the usage, e.g. __complete
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "list", args: "[flags]", summary: "List all available autologgers", run: runList},
+		{name: "show", args: "[flags] [autologger...] | provider [flags] <autologger> <guid>", summary: "Analyze autologgers and report their configuration, providers and findings", run: runShow},
+		{name: "stats", args: "[flags]", summary: "Summarize sessions, providers and buffer memory across all autologgers", run: runStats},
+		{name: "sessions", args: "[flags]", summary: "List the running trace sessions and the autologgers they were started from", run: runSessions},
+		{name: "verify", args: "[flags] <autologger...>", summary: "Compare autologgers with their live sessions and report drift", run: runVerify},
+		{name: "sample", args: "-autologger <name> [flags]", summary: "Consume an autologger's events for a while and report per provider and event ID rates", run: runSample},
+		{name: "estimate", args: "[flags] <autologger...>", summary: "Estimate the event volume of autologgers and the risk of losing events", run: runEstimate},
+		{name: "session", args: "start [flags] <autologger> | stop <autologger> | flush [flags] <autologger...> | tune [flags] <autologger>", summary: "Start, stop, flush or tune the session of an autologger without a reboot", run: runSession},
+		{name: "etl", args: "analyze [flags] <autologger|file.etl...>", summary: "Count the providers and event IDs an autologger's log file actually recorded", run: runETL},
+		{name: "watch", args: "[flags] [autologger...]", summary: "Poll the live sessions of autologgers and report lost events, buffer growth and registry changes", run: runWatch},
+		{name: "diff", args: "[flags] <autologgerA> <autologgerB>", summary: "Compare the session settings and providers of two autologgers", run: runDiff},
+		{name: "snapshot", args: "[flags]", summary: "Write the registry state of all autologgers to a JSON snapshot", run: runSnapshot},
+		{name: "diff-snapshots", args: "[flags] <old.json> <new.json> | -gold <gold.json> <snapshot|dir...>", summary: "Report the autologgers and providers added, removed or changed between two snapshots, or how hosts drifted from a gold image", run: runDiffSnapshots},
+		{name: "baseline", args: "check [flags]", summary: "Compare the stock autologgers with a snapshot of a clean install", run: runBaseline},
+		{name: "vss", args: "list [flags] | timeline [flags] <autologger>", summary: "Show how an autologger's configuration changed across the volume shadow copies", run: runVSS},
+		{name: "find", args: "[flags] <guid>", summary: "Find every autologger that references a provider GUID", run: runFind},
+		{name: "search", args: "[flags] <name>", summary: "Search providers by name and show the autologgers that reference them", run: runSearch},
+		{name: "create", args: "-interactive [name]", summary: "Create a new autologger with a guided wizard", run: runCreate},
+		{name: "providers", args: "dump-db [flags] | export-schema [flags] <guid>", summary: "Write a provider database from the providers registered on this machine, or the manifest schema of a provider", run: runProviders},
+		{name: "export", args: "[flags] <autologger>", summary: "Export the registry subtree of an autologger to a .reg file", run: runExport},
+		{name: "serve", args: "[flags]", summary: "Run continuously and serve Prometheus metrics", run: runServe},
+		{name: "daemon", args: "[flags]", summary: "Snapshot all autologgers on a schedule and send changes and new findings to sinks", run: runDaemon},
+		{name: "history", args: "[flags] [autologger]", summary: "Show the changes daemon recorded in its history database", run: runHistory},
+		{name: "service", args: "install [flags] [-- daemon flags] | uninstall", summary: "Install or uninstall daemon as a Windows service", run: runService},
+		{name: "completion", args: "<bash|powershell>", summary: "Print a shell completion script", run: runCompletion},
+		{name: "__complete", args: "<index> [word...]", summary: "Print completion candidates (used by the completion scripts)", run: runComplete, hidden: true},
+		{name: "version", args: "", summary: "Print the version", run: runVersion},
+		{name: "help", args: "[command]", summary: "Show help for a command", run: runHelp},
+	}
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// runCommand dispatches to the subcommand named by the first argument. The
+// flat -list and -autologger flags of earlier versions are still accepted and
+// mapped onto list and show.
+func runCommand(args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	name, rest := args[0], args[1:]
+	switch {
+	case name == "-h" || name == "-help" || name == "--help":
+		printUsage(os.Stdout)
+		return nil
+	case name == "-list" || name == "--list":
+		name = "list"
+	case strings.HasPrefix(name, "-"):
+		name, rest = "show", args
+	}
+
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(cmd, rest)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: autologgerAnalyzer <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+		}
+	}
+	fmt.Fprintln(w, "\nRun 'autologgerAnalyzer help <command>' for the flags of a command.")
+	fmt.Fprintln(w, "\nExamples:")
+	fmt.Fprintln(w, "  autologgerAnalyzer list")
+	fmt.Fprintln(w, "  autologgerAnalyzer show DefenderApiLogger")
+	fmt.Fprintln(w, "  autologgerAnalyzer show -all -format jsonl")
+	fmt.Fprintln(w, "  autologgerAnalyzer export -out DefenderApiLogger.reg DefenderApiLogger")
+}
+
+// newFlagSet returns a flag set whose help text describes cmd.
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	registerLogFlags(fs)
+	registerRegistryFlags(fs)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: autologgerAnalyzer %s %s\n\n%s\n", cmd.name, cmd.args, cmd.summary)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(out, "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parseArgs parses args with fs, allowing flags to follow positional
+// arguments (show DefenderApiLogger -format json), and returns the
+// positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...)
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func runList(cmd *command, args []string) error {
+	var startedOnly bool
+
+	fs := newFlagSet(cmd)
+	fs.BoolVar(&startedOnly, "started-only", false, "Only list autologgers with Start set to 1")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+	return listAutologgers(startedOnly)
+}
+
+func runShow(cmd *command, args []string) error {
+	var autologgerNames stringList
+	var allMode bool
+	var output outputOptions
+	var sinkOpts sinkOptions
+	var manifest manifestOptions
+	var filter providerFilter
+	var order providerSort
+	var quiet bool
+	var analyze analyzeOptions
+	var noProgress bool
+
+	// An autologger that is itself named provider is shown with
+	// show -- provider or show -autologger provider.
+	if len(args) > 0 && args[0] == "provider" {
+		return runShowProvider(args[1:])
+	}
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.Var(&autologgerNames, "autologger", "Autologger to analyze; comma separated or repeated for several")
+	fs.BoolVar(&allMode, "all", false, "Analyze every autologger on the system")
+	fs.BoolVar(&analyze.noResolve, "no-resolve", false, "Don't resolve provider names; faster when only GUIDs and filters are needed")
+	fs.BoolVar(&analyze.rawFilters, "raw-filters", false, "Show an annotated hex dump of every provider Filters value")
+	fs.BoolVar(&analyze.security, "security", false, "Decode the WMI\\Security descriptor of every provider: who may enable it or log to it")
+	fs.BoolVar(&noProgress, "no-progress", false, "Don't show a progress line on stderr when analyzing several autologgers")
+	fs.BoolVar(&quiet, "quiet", false, "Don't print the report to stdout; only the exit code (and -out or sinks) carry the result")
+	filter.register(fs)
+	order.register(fs)
+	output.register(fs)
+	sinkOpts.register(fs)
+	manifest.register(fs)
+	registerBaselineFlags(fs)
+	names := parseArgs(fs, args)
+
+	if err := output.validate(); err != nil {
+		return err
+	}
+	if err := manifest.validate(); err != nil {
+		return err
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+	if err := order.validate(); err != nil {
+		return err
+	}
+	if analyze.noResolve && filter.nameMatch != "" {
+		return fmt.Errorf("-provider-name-match needs provider names and can't be combined with -no-resolve")
+	}
+	if baselinePath != "" {
+		if err := loadHostBaseline(); err != nil {
+			return err
+		}
+	}
+
+	names = append(autologgerNames, names...)
+	if allMode && len(names) > 0 {
+		return fmt.Errorf("-all can't be combined with autologger names")
+	}
+	if allMode {
+		var err error
+		names, err = getAutologgerNames()
+		if err != nil {
+			return err
+		}
+	}
+	if len(names) == 0 {
+		fs.Usage()
+		return fmt.Errorf("an autologger name or -all is required")
+	}
+
+	// Several autologgers are combined into one report, e.g. a JSON array.
+	multi := allMode || len(names) > 1
+
+	host := collectHostMetadata()
+	// With -all, autologgers that can't be read are skipped, while an
+	// autologger named explicitly must be readable.
+	reportOpts := reportOptions{skipErrors: allMode, host: host, analyze: analyze, filter: filter, sort: order}
+	if !noProgress && !quiet {
+		reportOpts.progress = newProgress(len(names))
+	}
+	sinks, err := sinkOpts.build(host)
+	if err != nil {
+		return err
+	}
+
+	if output.format == "sqlite" {
+		// SQLite is transactional and always accumulates snapshots, so it is
+		// written in place rather than through a temporary file.
+		writer, err := newSQLiteReportWriter(output.outPath, host)
+		if err != nil {
+			return fmt.Errorf("opening SQLite output: %v", err)
+		}
+		summary, err := writeReports(withSinks(writer, sinks), names, reportOpts)
+		if err != nil {
+			return fmt.Errorf("writing %s output: %v", output.format, err)
+		}
+		if err := manifest.finish(output.outPath); err != nil {
+			return err
+		}
+		return summary.exitStatus()
+	}
+
+	if output.outPath == "" {
+		var stdout io.Writer = os.Stdout
+		if quiet {
+			stdout = io.Discard
+		}
+		writer := newReportWriter(output.format, stdout, multi, host, output.tableWidth(stdout), newPalette(stdout, output.color))
+		summary, err := writeReports(withSinks(writer, sinks), names, reportOpts)
+		if err != nil {
+			return fmt.Errorf("writing %s output: %v", output.format, err)
+		}
+		return summary.exitStatus()
+	}
+
+	out, err := createAtomicFile(output.outPath, output.appendMode)
+	if err != nil {
+		return fmt.Errorf("creating output file: %v", err)
+	}
+	writer := newReportWriter(output.format, out, multi, host, output.tableWidth(out), newPalette(out, output.color))
+	summary, err := writeReports(withSinks(writer, sinks), names, reportOpts)
+	if err != nil {
+		out.Abort()
+		return fmt.Errorf("writing %s output: %v", output.format, err)
+	}
+	if err := out.Commit(); err != nil {
+		return fmt.Errorf("writing output file: %v", err)
+	}
+	if err := manifest.finish(output.outPath); err != nil {
+		return err
+	}
+	return summary.exitStatus()
+}
+
+func runExport(cmd *command, args []string) error {
+	var outPath string
+	var manifest manifestOptions
+
+	fs := newFlagSet(cmd)
+	fs.StringVar(&outPath, "out", "", "Path of the .reg file to write (default <autologger>.reg)")
+	manifest.register(fs)
+	positional := parseArgs(fs, args)
+
+	if err := manifest.validate(); err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one autologger name is required")
+	}
+	autologgerName := positional[0]
+	if outPath == "" {
+		outPath = autologgerName + ".reg"
+	}
+
+	if err := exportAutologgerReg(autologgerName, outPath); err != nil {
+		return fmt.Errorf("exporting autologger: %v", err)
+	}
+	fmt.Printf("Exported %s to %s\n", autologgerName, outPath)
+
+	return manifest.finish(outPath)
+}
+
+func runServe(cmd *command, args []string) error {
+	var addr string
+	var interval time.Duration
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&addr, "metrics-addr", ":9464", "Address to serve Prometheus metrics on")
+	fs.DurationVar(&interval, "interval", 5*time.Minute, "Scan interval")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+	if interval <= 0 {
+		return fmt.Errorf("-interval must be positive")
+	}
+
+	return runMetricsDaemon(addr, interval)
+}
+
+func runVersion(cmd *command, args []string) error {
+	fmt.Printf("autologgerAnalyzer %s\n", version)
+	return nil
+}
+
+func runHelp(cmd *command, args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return nil
+	}
+
+	target := lookupCommand(args[0])
+	if target == nil {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	// Every command prints its own help when asked for it.
+	return target.run(target, []string{"-h"})
+}
+
+// stringList is a flag that can be repeated and accepts comma separated
+// values, collecting all of them in order.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s = append(*s, v)
+		}
+	}
+	return nil
+}
+
+// outputOptions are the flags selecting the report format and destination.
+type outputOptions struct {
+	format     string
+	outPath    string
+	appendMode bool
+	wide       bool
+	color      string
+}
+
+func (o *outputOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.format, "format", "table", "Output format: table, tsv, json, jsonl, markdown, html, sqlite, parquet, cef or leef")
+	fs.StringVar(&o.outPath, "out", "", "Write report output to this file instead of stdout")
+	fs.BoolVar(&o.appendMode, "append", false, "Append to the -out file instead of replacing it")
+	fs.BoolVar(&o.wide, "wide", false, "Don't truncate table columns to the terminal width")
+	fs.BoolVar(&o.wide, "no-truncate", false, "Alias for -wide")
+	fs.StringVar(&o.color, "color", "auto", "Color table output: auto, always or never (auto honors NO_COLOR)")
+}
+
+// tableWidth returns the width table output to w is limited to: the console
+// width when w is a console, unlimited with -wide.
+func (o *outputOptions) tableWidth(w io.Writer) int {
+	if o.wide {
+		return 0
+	}
+	if width := terminalWidth(w); width > 0 {
+		return width
+	}
+	return defaultTableWidth
+}
+
+// outputFormats are the values accepted by show -format.
+var outputFormats = []string{"table", "tsv", "json", "jsonl", "markdown", "html", "sqlite", "parquet", "cef", "leef"}
+
+func (o *outputOptions) validate() error {
+	if err := validateColorMode(o.color); err != nil {
+		return err
+	}
+	if !slices.Contains(outputFormats, o.format) {
+		return fmt.Errorf("unknown output format %q (expected table, tsv, json, jsonl, markdown, html, sqlite, parquet, cef or leef)", o.format)
+	}
+	if o.appendMode && o.outPath == "" {
+		return fmt.Errorf("-append requires -out <path>")
+	}
+	if o.appendMode && (o.format == "html" || o.format == "parquet") {
+		return fmt.Errorf("-append is not supported with -format %s", o.format)
+	}
+	// Appending a JSON document to another doesn't give valid JSON.
+	if o.appendMode && o.format == "json" {
+		return fmt.Errorf("-append is not supported with -format json, use -format jsonl to append records")
+	}
+	// SQLite output always accumulates snapshots in the database.
+	if o.appendMode && o.format == "sqlite" {
+		return fmt.Errorf("-append is not supported with -format sqlite, which always adds to the database")
+	}
+	if o.outPath == "" && (o.format == "sqlite" || o.format == "parquet") {
+		return fmt.Errorf("-format %s requires -out <path>", o.format)
+	}
+	return nil
+}
+
+// sinkOptions are the flags for the log sinks that receive results in
+// addition to the regular output.
+type sinkOptions struct {
+	splunkURL, splunkToken, splunkIndex, splunkSourcetype string
+	esURL, esIndex, esAPIKey, esCACert                    string
+	esInsecure                                            bool
+	syslogTarget, syslogCACert                            string
+	eventLog                                              bool
+	otlpEndpoint, otlpHeaders                             string
+	otlpInsecure                                          bool
+	webhook                                               webhookOptions
+}
+
+func (s *sinkOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.splunkURL, "splunk-hec", "", "Also send records and findings to this Splunk HTTP Event Collector URL")
+	fs.StringVar(&s.splunkToken, "splunk-token", "", "Splunk HEC token (default $SPLUNK_HEC_TOKEN)")
+	fs.StringVar(&s.splunkIndex, "splunk-index", "", "Splunk index for HEC events (default: token's default index)")
+	fs.StringVar(&s.splunkSourcetype, "splunk-sourcetype", "autologger:analyzer", "Splunk sourcetype for HEC events")
+	fs.StringVar(&s.esURL, "es-url", "", "Also index records and findings into this Elasticsearch URL")
+	fs.StringVar(&s.esIndex, "es-index", "autologger-YYYY.MM", "Elasticsearch index pattern (YYYY, MM and DD are expanded)")
+	fs.StringVar(&s.esAPIKey, "es-api-key", "", "Elasticsearch API key (default $ES_API_KEY)")
+	fs.StringVar(&s.esCACert, "es-ca-cert", "", "PEM file with the CA certificate for the Elasticsearch TLS connection")
+	fs.BoolVar(&s.esInsecure, "es-insecure", false, "Skip TLS certificate verification for Elasticsearch")
+	fs.StringVar(&s.syslogTarget, "syslog", "", "Also forward findings to syslog: udp://, tcp:// or tls://host:port")
+	fs.StringVar(&s.syslogCACert, "syslog-ca-cert", "", "PEM file with the CA certificate for syslog over TLS")
+	fs.BoolVar(&s.eventLog, "eventlog", false, "Also write findings to the Application event log")
+	fs.StringVar(&s.otlpEndpoint, "otlp-endpoint", "", "Also export records and findings as OTLP/gRPC logs to this host:port")
+	fs.StringVar(&s.otlpHeaders, "otlp-headers", "", "Comma separated key=value headers for OTLP exports")
+	fs.BoolVar(&s.otlpInsecure, "otlp-insecure", false, "Use a plaintext connection for OTLP instead of TLS")
+	s.webhook.register(fs)
+}
+
+func (s *sinkOptions) build(host *HostMetadata) ([]reportWriter, error) {
+	var sinks []reportWriter
+	// The sinks already created are closed when a later one fails.
+	fail := func(err error) ([]reportWriter, error) {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+		return nil, err
+	}
+
+	if s.splunkURL != "" {
+		token := s.splunkToken
+		if token == "" {
+			token = os.Getenv("SPLUNK_HEC_TOKEN")
+		}
+		sinks = append(sinks, newSplunkHECSink(s.splunkURL, token, s.splunkIndex, s.splunkSourcetype, host))
+	}
+	if s.esURL != "" {
+		apiKey := s.esAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("ES_API_KEY")
+		}
+		sink, err := newElasticsearchSink(s.esURL, s.esIndex, apiKey, s.esCACert, s.esInsecure, host)
+		if err != nil {
+			return fail(fmt.Errorf("configuring Elasticsearch sink: %v", err))
+		}
+		sinks = append(sinks, sink)
+	}
+	if s.syslogTarget != "" {
+		sink, err := newSyslogSink(s.syslogTarget, s.syslogCACert, host)
+		if err != nil {
+			return fail(fmt.Errorf("configuring syslog sink: %v", err))
+		}
+		sinks = append(sinks, sink)
+	}
+	if s.eventLog {
+		sink, err := newEventLogSink()
+		if err != nil {
+			return fail(fmt.Errorf("configuring event log sink: %v", err))
+		}
+		sinks = append(sinks, sink)
+	}
+	if s.otlpEndpoint != "" {
+		sink, err := newOTLPSink(s.otlpEndpoint, s.otlpHeaders, s.otlpInsecure, host)
+		if err != nil {
+			return fail(fmt.Errorf("configuring OTLP sink: %v", err))
+		}
+		sinks = append(sinks, sink)
+	}
+	sink, err := s.webhook.build(host)
+	if err != nil {
+		return fail(fmt.Errorf("configuring webhook sink: %v", err))
+	}
+	if sink != nil {
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+// manifestOptions are the flags for hashing and signing generated files.
+type manifestOptions struct {
+	path    string
+	signKey string
+}
+
+func (m *manifestOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&m.path, "manifest", "", "Write a SHA-256 manifest of the generated files to this path")
+	fs.StringVar(&m.signKey, "sign-key", "", "Sign the manifest with this Ed25519 PKCS#8 PEM key (minisign format)")
+}
+
+func (m *manifestOptions) validate() error {
+	if m.signKey != "" && m.path == "" {
+		return fmt.Errorf("-sign-key requires -manifest <path>")
+	}
+	return nil
+}
+
+// finish writes and optionally signs the manifest for the given artifacts.
+func (m *manifestOptions) finish(artifacts ...string) error {
+	if m.path == "" {
+		return nil
+	}
+	if err := writeManifest(m.path, artifacts); err != nil {
+		return fmt.Errorf("writing manifest: %v", err)
+	}
+	if m.signKey != "" {
+		pub, err := signManifest(m.path, m.signKey)
+		if err != nil {
+			return fmt.Errorf("signing manifest: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Signed %s, verify with: minisign -Vm %s -P %s\n", m.path, m.path, pub)
+	}
+	return nil
+}
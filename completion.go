@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Completion scripts call back into the tool through the hidden __complete
+// command, so suggestions always reflect the registry of the machine:
+//
+//	autologgerAnalyzer __complete <index> <word>...
+//
+// index is the position of the word being completed; when it is past the
+// last word, the word being completed is empty. Candidates are printed one
+// per line, optionally followed by a tab and a description.
+
+const bashCompletion = `# bash completion for %[1]s
+_%[1]s() {
+    local value
+    COMPREPLY=()
+    while IFS=$'\t' read -r value _; do
+        COMPREPLY+=("$(printf '%%q' "$value")")
+    done < <("${COMP_WORDS[0]}" __complete $((COMP_CWORD - 1)) "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)
+}
+complete -o default -F _%[1]s %[1]s %[1]s.exe
+`
+
+const powershellCompletion = `# PowerShell completion for %[1]s
+Register-ArgumentCompleter -Native -CommandName %[1]s, %[1]s.exe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements |
+        Select-Object -Skip 1 |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString().Trim("'", '"') })
+    $index = if ($wordToComplete -eq '') { $words.Count } else { $words.Count - 1 }
+    & $commandAst.CommandElements[0].ToString() __complete $index @words 2>$null | ForEach-Object {
+        $value, $description = $_ -split "` + "`" + `t", 2
+        if (-not $description) { $description = $value }
+        $text = if ($value -match '[\s{}]') { "'" + $value + "'" } else { $value }
+        [System.Management.Automation.CompletionResult]::new($text, $value, 'ParameterValue', $description)
+    }
+}
+`
+
+func runCompletion(cmd *command, args []string) error {
+	fs := newFlagSet(cmd)
+	positional := parseArgs(fs, args)
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("a shell is required: bash or powershell")
+	}
+
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	switch positional[0] {
+	case "bash":
+		fmt.Printf(bashCompletion, name)
+	case "powershell", "pwsh":
+		fmt.Printf(powershellCompletion, name)
+	default:
+		return fmt.Errorf("unsupported shell %q (expected bash or powershell)", positional[0])
+	}
+	return nil
+}
+
+func runComplete(cmd *command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	index, err := strconv.Atoi(args[0])
+	if err != nil || index < 0 {
+		return nil
+	}
+	words := args[1:]
+	for len(words) <= index {
+		words = append(words, "")
+	}
+
+	for _, candidate := range completions(words[:index], words[index]) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// completions returns the candidates for cur, given the words before it.
+func completions(before []string, cur string) []string {
+	if len(before) == 0 {
+		var names []string
+		for _, cmd := range commands {
+			if !cmd.hidden {
+				names = append(names, cmd.name+"\t"+cmd.summary)
+			}
+		}
+		return filterPrefix(names, cur)
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		return nil
+	}
+
+	cmdName, prev := before[0], before[len(before)-1]
+	switch {
+	case prev == "-autologger" || prev == "--autologger":
+		return completeAutologgers(cur)
+	case prev == "-guid" || prev == "--guid":
+		return completeProviderGUIDs(cur)
+	case (prev == "-format" || prev == "--format") && cmdName == "show":
+		return filterPrefix(outputFormats, cur)
+	}
+
+	if cmdName == "show" && len(before) > 1 && before[1] == "provider" {
+		switch len(before) {
+		case 2:
+			if strings.HasPrefix(cur, "{") {
+				return completeProviderGUIDs(cur)
+			}
+			return completeAutologgers(cur)
+		case 3:
+			guids, _ := getProviderGUIDs(before[2])
+			return filterPrefix(guids, cur)
+		}
+		return nil
+	}
+
+	switch cmdName {
+	case "show", "export", "verify", "watch", "diff", "history":
+		return completeAutologgers(cur)
+	case "find":
+		return completeProviderGUIDs(cur)
+	case "session":
+		if len(before) == 1 {
+			return filterPrefix([]string{"start", "stop", "flush", "tune"}, cur)
+		}
+		return completeAutologgers(cur)
+	case "etl":
+		if len(before) == 1 {
+			return filterPrefix([]string{"analyze"}, cur)
+		}
+		return completeAutologgers(cur)
+	case "baseline":
+		if len(before) == 1 {
+			return filterPrefix([]string{"check"}, cur)
+		}
+	case "vss":
+		if len(before) == 1 {
+			return filterPrefix([]string{"list", "timeline"}, cur)
+		}
+		if before[1] == "timeline" {
+			return completeAutologgers(cur)
+		}
+	case "service":
+		if len(before) == 1 {
+			return filterPrefix([]string{"install", "uninstall"}, cur)
+		}
+	case "providers":
+		if len(before) == 1 {
+			return filterPrefix([]string{"dump-db", "export-schema"}, cur)
+		}
+		if len(before) == 2 && before[1] == "export-schema" {
+			return completeProviderGUIDs(cur)
+		}
+	case "help":
+		return completions(nil, cur)
+	case "completion":
+		return filterPrefix([]string{"bash", "powershell"}, cur)
+	}
+	return nil
+}
+
+func completeAutologgers(cur string) []string {
+	names, err := getAutologgerNames()
+	if err != nil {
+		return nil
+	}
+	return filterPrefix(names, cur)
+}
+
+// completeProviderGUIDs suggests the GUIDs of registered publishers and of
+// providers referenced by autologgers. Names are only resolved once the list
+// is short, resolving every publisher would make completion sluggish.
+func completeProviderGUIDs(cur string) []string {
+	seen := make(map[string]bool)
+	var guids []string
+	add := func(guid string) {
+		if !seen[guid] {
+			seen[guid] = true
+			guids = append(guids, guid)
+		}
+	}
+
+	if publishers, err := getPublisherGUIDs(); err == nil {
+		for _, guid := range publishers {
+			add(guid)
+		}
+	}
+	if names, err := getAutologgerNames(); err == nil {
+		for _, name := range names {
+			providers, _ := getProviderGUIDs(name)
+			for _, guid := range providers {
+				add(guid)
+			}
+		}
+	}
+
+	prefix := strings.ToLower(strings.TrimPrefix(cur, "{"))
+	var matches []string
+	for _, guid := range guids {
+		if strings.HasPrefix(strings.ToLower(strings.TrimPrefix(guid, "{")), prefix) {
+			matches = append(matches, guid)
+		}
+	}
+
+	if len(matches) <= 50 {
+		for i, guid := range matches {
+			matches[i] = guid + "\t" + resolveProviderName(guid)
+		}
+	}
+	return matches
+}
+
+// filterPrefix returns the candidates starting with prefix, ignoring case.
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(c), strings.ToLower(prefix)) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procOpenTrace    = modadvapi32.NewProc("OpenTraceW")
+	procProcessTrace = modadvapi32.NewProc("ProcessTrace")
+	procCloseTrace   = modadvapi32.NewProc("CloseTrace")
+)
+
+const (
+	processTraceModeRealTime    = 0x00000100
+	processTraceModeEventRecord = 0x10000000
+
+	// invalidProcessTraceHandle is INVALID_PROCESSTRACE_HANDLE.
+	invalidProcessTraceHandle = ^uint64(0)
+
+	eventHeaderFlag32BitHeader   = 0x0020
+	eventHeaderFlagClassicHeader = 0x0100
+	eventHeaderFlagTraceMessage  = 0x0200
+)
+
+// eventTraceGUID is EventTraceGuid, the pseudo-provider of the header event
+// that starts every log file and real-time stream.
+var eventTraceGUID = windows.GUID{Data1: 0x68fdd900, Data2: 0x4a3e, Data3: 0x11d1,
+	Data4: [8]byte{0x84, 0xf4, 0x00, 0x00, 0xf8, 0x04, 0x64, 0xe3}}
+
+// eventTraceLogfile mirrors EVENT_TRACE_LOGFILEW. The current event and the
+// log file header are filled in by ETW and not read, so they are kept as
+// opaque bytes; EVENT_TRACE is 88 bytes on every architecture, while the
+// size of the header depends on the pointer size.
+type eventTraceLogfile struct {
+	LogFileName         *uint16
+	LoggerName          *uint16
+	CurrentTime         int64
+	BuffersRead         uint32
+	ProcessTraceMode    uint32
+	CurrentEvent        [88]byte
+	LogfileHeader       [traceLogfileHeaderSize]byte
+	BufferCallback      uintptr
+	BufferSize          uint32
+	Filled              uint32
+	EventsLost          uint32
+	EventRecordCallback uintptr
+	IsKernelTrace       uint32
+	Context             uintptr
+}
+
+// eventHeader mirrors EVENT_HEADER.
+type eventHeader struct {
+	Size            uint16
+	HeaderType      uint16
+	Flags           uint16
+	EventProperty   uint16
+	ThreadId        uint32
+	ProcessId       uint32
+	TimeStamp       int64
+	ProviderId      windows.GUID
+	EventDescriptor eventDescriptor
+	ProcessorTime   uint64
+	ActivityId      windows.GUID
+}
+
+// eventRecord mirrors EVENT_RECORD.
+type eventRecord struct {
+	EventHeader       eventHeader
+	BufferContext     uint32
+	ExtendedDataCount uint16
+	UserDataLength    uint16
+	ExtendedData      unsafe.Pointer
+	UserData          unsafe.Pointer
+	UserContext       unsafe.Pointer
+}
+
+// eventKey identifies the events of a provider with the same ID.
+type eventKey struct {
+	provider windows.GUID
+	id       uint16
+}
+
+// eventCount is the number of events received with the same key, and their
+// size as logged, headers included.
+type eventCount struct {
+	events uint64
+	bytes  uint64
+}
+
+// eventTally is what the record callback collected from a trace.
+type eventTally struct {
+	counts map[eventKey]eventCount
+	// classic counts the MOF and WPP events. They carry the GUID of their
+	// event class or message instead of their provider, so they can't be
+	// attributed to a provider.
+	classic eventCount
+	// first and last are the FILETIME timestamps of the oldest and newest
+	// event, 0 without events.
+	first, last int64
+}
+
+// eventCounter tallies the events delivered to the record callback.
+// Callbacks created with NewCallback are never freed, so the process has a
+// single one that counts into the active tally.
+var eventCounter struct {
+	sync.Mutex
+	callback uintptr
+	tally    *eventTally
+}
+
+func onEventRecord(record *eventRecord) uintptr {
+	if record.EventHeader.ProviderId == eventTraceGUID {
+		return 0
+	}
+	key := eventKey{provider: record.EventHeader.ProviderId, id: record.EventHeader.EventDescriptor.Id}
+	classic := record.EventHeader.Flags&(eventHeaderFlagClassicHeader|eventHeaderFlagTraceMessage) != 0
+	eventCounter.Lock()
+	if t := eventCounter.tally; t != nil {
+		// The size of the event covers its header and payload.
+		size := uint64(record.EventHeader.Size)
+		if classic {
+			t.classic.events++
+			t.classic.bytes += size
+		} else {
+			c := t.counts[key]
+			c.events++
+			c.bytes += size
+			t.counts[key] = c
+		}
+		if ts := record.EventHeader.TimeStamp; ts > 0 {
+			if t.first == 0 || ts < t.first {
+				t.first = ts
+			}
+			t.last = max(t.last, ts)
+		}
+	}
+	eventCounter.Unlock()
+	return 0
+}
+
+// openCountedTrace opens a real-time session or a log file with OpenTrace,
+// with its events counted into a new tally.
+func openCountedTrace(logfile *eventTraceLogfile, name string) (uint64, error) {
+	eventCounter.Lock()
+	if eventCounter.callback == 0 {
+		eventCounter.callback = windows.NewCallback(onEventRecord)
+	}
+	eventCounter.tally = &eventTally{counts: make(map[eventKey]eventCount)}
+	logfile.ProcessTraceMode |= processTraceModeEventRecord
+	logfile.EventRecordCallback = eventCounter.callback
+	eventCounter.Unlock()
+
+	handle, err := openTrace(logfile, name)
+	if err != nil {
+		takeTally()
+		return 0, err
+	}
+	return handle, nil
+}
+
+// openTrace opens a real-time session or a log file with OpenTrace.
+func openTrace(logfile *eventTraceLogfile, name string) (uint64, error) {
+	handle, lastErr := sysOpenTrace(logfile)
+	if handle == invalidProcessTraceHandle {
+		return 0, fmt.Errorf("failed to open %s: %v", name, lastErr)
+	}
+	return handle, nil
+}
+
+// takeTally ends counting and returns what was counted.
+func takeTally() *eventTally {
+	eventCounter.Lock()
+	defer eventCounter.Unlock()
+	t := eventCounter.tally
+	eventCounter.tally = nil
+	return t
+}
+
+// processTrace delivers the events of an opened trace to the callback until
+// the trace ends or its handle is closed.
+func processTrace(handle *uint64) error {
+	r, _, _ := procProcessTrace.Call(uintptr(unsafe.Pointer(handle)), 1, 0, 0)
+	if r != 0 && windows.Errno(r) != windows.ERROR_CANCELLED {
+		return windows.Errno(r)
+	}
+	return nil
+}
+
+// countLogFileEvents reads an ETL file and returns the events it holds per
+// provider and event ID.
+func countLogFileEvents(path string) (*eventTally, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := openCountedTrace(&eventTraceLogfile{LogFileName: name}, path)
+	if err != nil {
+		return nil, err
+	}
+	err = processTrace(&handle)
+	closeTrace(handle)
+	tally := takeTally()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return tally, nil
+}
+
+// countSessionEvents consumes a real-time session until the duration has
+// passed, ctx is canceled or the session stops, and returns the number of
+// events and bytes received per provider and event ID with how long it
+// consumed.
+func countSessionEvents(ctx context.Context, session string, duration time.Duration) (*eventTally, time.Duration, error) {
+	name, err := windows.UTF16PtrFromString(session)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	logfile := &eventTraceLogfile{LoggerName: name, ProcessTraceMode: processTraceModeRealTime}
+	handle, err := openCountedTrace(logfile, "session "+session)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- processTrace(&handle)
+	}()
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	case err = <-done:
+		// The session stopped, or couldn't be consumed at all.
+		done <- err
+	}
+	// Closing the handle makes ProcessTrace return once it has delivered
+	// the buffers it holds.
+	closeTrace(handle)
+	err = <-done
+	elapsed := time.Since(start)
+
+	tally := takeTally()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to consume session %s: %v", session, err)
+	}
+	return tally, elapsed, nil
+}
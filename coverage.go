@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// EventCoverage tells how many of the events a provider's manifest defines
+// pass the event ID filter, level and keywords of an autologger.
+type EventCoverage struct {
+	Captured int `json:"captured"`
+	Total    int `json:"total"`
+}
+
+// lowCoveragePercent is the share of events below which a provider is
+// highlighted as almost disabled by its filters.
+const lowCoveragePercent = 10
+
+// Percent returns the share of the manifest's events that are captured.
+func (c EventCoverage) Percent() int {
+	if c.Total == 0 {
+		return 0
+	}
+	return c.Captured * 100 / c.Total
+}
+
+// Low reports whether the filters leave almost nothing of the provider.
+func (c EventCoverage) Low() bool {
+	return c.Percent() < lowCoveragePercent
+}
+
+// computeCoverage compares every event of a provider's manifest with the
+// autologger's filters. It returns nil for providers without a manifest.
+func computeCoverage(p ETWProvider) *EventCoverage {
+	events := getProviderManifest(p.GUID).events
+	if len(events) == 0 {
+		return nil
+	}
+	coverage := &EventCoverage{Total: len(events)}
+	for _, event := range events {
+		if eventCaptured(p, event) {
+			coverage.Captured++
+		}
+	}
+	return coverage
+}
+
+// eventCaptured applies the checks ETW applies before writing an event: the
+// event ID filter, the level and the keyword masks. Events with level 0 or
+// without keywords pass the level and keyword checks. An event must be in one
+// of the lists that include events, if there are any, and in none of those
+// that exclude events; a single DWORD event ID includes it.
+func eventCaptured(p ETWProvider, event eventDescriptor) bool {
+	if p.HasFilters {
+		included, hasInclude := false, false
+		for _, l := range p.EventIDLists {
+			if len(l.EventIDs) == 0 {
+				continue
+			}
+			listed := slices.Contains(l.EventIDs, int(event.Id))
+			if l.Mode == eventIDFilterExclude {
+				if listed {
+					return false
+				}
+				continue
+			}
+			hasInclude = true
+			included = included || listed
+		}
+		if hasInclude && !included {
+			return false
+		}
+	}
+	if p.EnableLevel != 0 && event.Level != 0 && uint64(event.Level) > p.EnableLevel {
+		return false
+	}
+	if event.Keyword != 0 {
+		if p.MatchAnyKeyword != 0 && event.Keyword&p.MatchAnyKeyword == 0 {
+			return false
+		}
+		if event.Keyword&p.MatchAllKeyword != p.MatchAllKeyword {
+			return false
+		}
+	}
+	return true
+}
+
+// getCoverageDescription renders the coverage of a provider, e.g.
+// "capturing 12 of 87 possible events (13%)".
+func getCoverageDescription(c *EventCoverage) string {
+	return fmt.Sprintf("capturing %d of %d possible events (%d%%)", c.Captured, c.Total, c.Percent())
+}
+
+// providersWithCoverage returns the providers whose coverage is known.
+func providersWithCoverage(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if p.Coverage != nil {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displayCoverage lists the event coverage of every provider with a
+// manifest, highlighting the ones that are almost disabled by filters.
+func displayCoverage(w io.Writer, providers []ETWProvider, pal palette) {
+	providers = providersWithCoverage(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nEvent Coverage:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		desc := getCoverageDescription(p.Coverage)
+		if p.Coverage.Low() {
+			desc = pal.yellow(desc)
+		}
+		fmt.Fprintf(w, "- %s (%s): %s\n", p.Name, p.GUID, desc)
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Session modes offered by the wizard, using the EVENT_TRACE_* values ETW
+// expects in LogFileMode.
+var wizardFileModes = []struct {
+	label string
+	mode  uint64
+	file  bool
+}{
+	{"Real-time only (EVENT_TRACE_REAL_TIME_MODE)", 0x00000100, false},
+	{"Sequential file (EVENT_TRACE_FILE_MODE_SEQUENTIAL)", 0x00000001, true},
+	{"Circular file (EVENT_TRACE_FILE_MODE_CIRCULAR)", 0x00000002, true},
+	{"Sequential file and real-time", 0x00000101, true},
+}
+
+var wizardClockTypes = []struct {
+	label string
+	value uint64
+}{
+	{"QueryPerformanceCounter (recommended)", 1},
+	{"SystemTime", 2},
+	{"CPU cycle counter", 3},
+}
+
+// regValue is a registry value to be written.
+type regValue struct {
+	name    string
+	valType uint32
+	data    []byte
+}
+
+func dwordValue(name string, v uint64) regValue {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, uint32(v))
+	return regValue{name, registry.DWORD, data}
+}
+
+func qwordValue(name string, v uint64) regValue {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, v)
+	return regValue{name, registry.QWORD, data}
+}
+
+func stringValue(name, v string) regValue {
+	encoded := utf16.Encode([]rune(v + "\x00"))
+	data := make([]byte, 2*len(encoded))
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(data[2*i:], c)
+	}
+	return regValue{name, registry.SZ, data}
+}
+
+// regKeyChange is a key under HKLM that is created with the given values.
+type regKeyChange struct {
+	path   string
+	values []regValue
+}
+
+// previewRegChanges renders changes in .reg syntax, so the preview matches
+// what export would produce after the changes are applied.
+func previewRegChanges(w io.Writer, changes []regKeyChange) {
+	fmt.Fprintln(w, regFileHeader)
+	for _, change := range changes {
+		fmt.Fprintf(w, "\n[HKEY_LOCAL_MACHINE\\%s]\n", change.path)
+		for _, v := range change.values {
+			fmt.Fprint(w, strings.ReplaceAll(formatRegValue(v.name, v.valType, v.data), "\r\n", "\n"))
+		}
+	}
+}
+
+// applyRegChanges creates the keys and sets their values.
+func applyRegChanges(changes []regKeyChange) error {
+	for _, change := range changes {
+		key, _, err := createKey(registry.LOCAL_MACHINE, change.path, registry.SET_VALUE)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", change.path, err)
+		}
+		for _, v := range change.values {
+			if err := setRawValue(key, v); err != nil {
+				key.Close()
+				return fmt.Errorf("failed to set %s of %s: %v", v.name, change.path, err)
+			}
+		}
+		key.Close()
+	}
+	return nil
+}
+
+func setRawValue(key registry.Key, v regValue) error {
+	switch v.valType {
+	case registry.DWORD:
+		return key.SetDWordValue(v.name, binary.LittleEndian.Uint32(v.data))
+	case registry.QWORD:
+		return key.SetQWordValue(v.name, binary.LittleEndian.Uint64(v.data))
+	case registry.SZ:
+		return key.SetStringValue(v.name, decodeUTF16(v.data))
+	default:
+		return key.SetBinaryValue(v.name, v.data)
+	}
+}
+
+// prompter asks questions on w and reads the answers from r.
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func (p *prompter) ask(label, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", label)
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// askUint accepts decimal or 0x-prefixed hexadecimal numbers.
+func (p *prompter) askUint(label string, def uint64, hex bool) (uint64, error) {
+	defStr := strconv.FormatUint(def, 10)
+	if hex {
+		defStr = fmt.Sprintf("0x%X", def)
+	}
+	for {
+		answer, err := p.ask(label, defStr)
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseUint(answer, 0, 64)
+		if err == nil {
+			return v, nil
+		}
+		fmt.Fprintf(p.w, "  %q is not a number\n", answer)
+	}
+}
+
+func (p *prompter) askYesNo(label string, def bool) (bool, error) {
+	defStr := "y/N"
+	if def {
+		defStr = "Y/n"
+	}
+	answer, err := p.ask(label+" ("+defStr+")", "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// askChoice shows a numbered menu and returns the zero based index picked.
+func (p *prompter) askChoice(label string, options []string, def int) (int, error) {
+	fmt.Fprintf(p.w, "%s:\n", label)
+	for i, option := range options {
+		fmt.Fprintf(p.w, "  %d) %s\n", i+1, option)
+	}
+	for {
+		n, err := p.askUint("Choice", uint64(def+1), false)
+		if err != nil {
+			return 0, err
+		}
+		if n >= 1 && int(n) <= len(options) {
+			return int(n) - 1, nil
+		}
+		fmt.Fprintf(p.w, "  Pick a number between 1 and %d\n", len(options))
+	}
+}
+
+func runCreate(cmd *command, args []string) error {
+	var interactive bool
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.BoolVar(&interactive, "interactive", false, "Walk through the session parameters and provider selection")
+	positional := parseArgs(fs, args)
+
+	if !interactive {
+		fs.Usage()
+		return fmt.Errorf("only -interactive creation is supported")
+	}
+
+	var name string
+	if len(positional) > 0 {
+		name = positional[0]
+	}
+
+	p := &prompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+	changes, err := runCreateWizard(p, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print("\nThe following registry changes will be made:\n\n")
+	previewRegChanges(os.Stdout, changes)
+	fmt.Println()
+
+	ok, err := p.askYesNo("Write these changes to the registry?", false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborted, nothing was written.")
+		return nil
+	}
+
+	if err := applyRegChanges(changes); err != nil {
+		return err
+	}
+	fmt.Printf("Created %s. The session starts at the next boot.\n", changes[0].path)
+	return nil
+}
+
+// runCreateWizard asks for the session parameters and providers of a new
+// autologger and returns the registry changes that create it.
+func runCreateWizard(p *prompter, name string) ([]regKeyChange, error) {
+	name, err := askNewAutologgerName(p, name)
+	if err != nil {
+		return nil, err
+	}
+
+	guid, err := windows.GenerateGUID()
+	if err != nil {
+		return nil, fmt.Errorf("generating session GUID: %v", err)
+	}
+
+	session := regKeyChange{path: baseAutologgerPath + `\` + name}
+	session.values = append(session.values, stringValue("Guid", guid.String()))
+
+	start, err := p.askYesNo("Start the session at boot?", true)
+	if err != nil {
+		return nil, err
+	}
+	session.values = append(session.values, dwordValue("Start", uint64(boolToInt(start))))
+
+	bufferSize, err := p.askUint("Buffer size in KB", 64, false)
+	if err != nil {
+		return nil, err
+	}
+	minBuffers, err := p.askUint("Minimum buffers", 4, false)
+	if err != nil {
+		return nil, err
+	}
+	maxBuffers, err := p.askUint("Maximum buffers", 16, false)
+	if err != nil {
+		return nil, err
+	}
+	flushTimer, err := p.askUint("Flush timer in seconds (0 = only when buffers are full)", 1, false)
+	if err != nil {
+		return nil, err
+	}
+	session.values = append(session.values,
+		dwordValue("BufferSize", bufferSize),
+		dwordValue("MinimumBuffers", minBuffers),
+		dwordValue("MaximumBuffers", maxBuffers),
+		dwordValue("FlushTimer", flushTimer))
+
+	labels := make([]string, len(wizardClockTypes))
+	for i, c := range wizardClockTypes {
+		labels[i] = c.label
+	}
+	clock, err := p.askChoice("Clock type", labels, 0)
+	if err != nil {
+		return nil, err
+	}
+	session.values = append(session.values, dwordValue("ClockType", wizardClockTypes[clock].value))
+
+	labels = make([]string, len(wizardFileModes)+1)
+	for i, m := range wizardFileModes {
+		labels[i] = m.label
+	}
+	labels[len(wizardFileModes)] = "Custom LogFileMode value"
+	modeChoice, err := p.askChoice("Logging mode", labels, 1)
+	if err != nil {
+		return nil, err
+	}
+	var mode uint64
+	needsFile := true
+	if modeChoice < len(wizardFileModes) {
+		mode, needsFile = wizardFileModes[modeChoice].mode, wizardFileModes[modeChoice].file
+	} else if mode, err = p.askUint("LogFileMode", 0x1, true); err != nil {
+		return nil, err
+	}
+	session.values = append(session.values, dwordValue("LogFileMode", mode))
+
+	if needsFile {
+		fileName, err := p.ask("Log file", `%SystemRoot%\System32\LogFiles\WMI\`+name+".etl")
+		if err != nil {
+			return nil, err
+		}
+		session.values = append(session.values, stringValue("FileName", fileName))
+	}
+
+	changes := []regKeyChange{session}
+	providers, err := askProviders(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, provider := range providers {
+		changes = append(changes, regKeyChange{
+			path: session.path + `\` + provider.GUID,
+			values: []regValue{
+				dwordValue("Enabled", 1),
+				dwordValue("EnableLevel", provider.EnableLevel),
+				qwordValue("MatchAnyKeyword", provider.MatchAnyKeyword),
+			},
+		})
+	}
+
+	return changes, nil
+}
+
+// askNewAutologgerName asks for a name until one is given that isn't taken.
+func askNewAutologgerName(p *prompter, name string) (string, error) {
+	existing, err := getAutologgerNames()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		name, err = p.ask("Autologger name", name)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case name == "" || strings.Contains(name, `\`):
+			fmt.Fprintln(p.w, "  A name without backslashes is required")
+		case containsFold(existing, name):
+			fmt.Fprintf(p.w, "  An autologger named %s already exists\n", name)
+		default:
+			return name, nil
+		}
+		name = ""
+	}
+}
+
+// askProviders lets the user search providers by name and pick the ones to
+// enable, along with their level and keywords.
+func askProviders(p *prompter) ([]ETWProvider, error) {
+	var selected []ETWProvider
+	for {
+		query, err := p.ask("\nSearch providers by name (empty to finish)", "")
+		if err != nil {
+			return nil, err
+		}
+		if query == "" {
+			if len(selected) == 0 {
+				fmt.Fprintln(p.w, "  Note: the autologger has no providers yet")
+			}
+			return selected, nil
+		}
+
+		matches, err := searchProviders(query)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(p.w, "  No providers match %q\n", query)
+			continue
+		}
+		for i, m := range matches {
+			fmt.Fprintf(p.w, "  %2d) %s %s\n", i+1, m.GUID, m.Name)
+		}
+
+		answer, err := p.ask("Providers to add (numbers, comma separated)", "")
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range strings.Split(answer, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(matches) {
+				fmt.Fprintf(p.w, "  Ignoring %q\n", field)
+				continue
+			}
+			m := matches[n-1]
+			fmt.Fprintf(p.w, "%s:\n", m.Name)
+			level, err := p.askUint("  Level (1 critical ... 5 verbose)", 5, false)
+			if err != nil {
+				return nil, err
+			}
+			keywords, err := p.askUint("  MatchAnyKeyword", 0xFFFFFFFFFFFFFFFF, true)
+			if err != nil {
+				return nil, err
+			}
+			selected = append(selected, ETWProvider{GUID: m.GUID, Name: m.Name, Enabled: true, EnabledState: enabledOn, EnableLevel: level, MatchAnyKeyword: keywords})
+		}
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
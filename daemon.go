@@ -0,0 +1,433 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+)
+
+// daemonOptions are the flags of daemon, which service install passes on to
+// the service.
+type daemonOptions struct {
+	interval    time.Duration
+	stateDir    string
+	keep        int
+	historyDB   string
+	historyKeep int
+	allFindings bool
+	sinks       sinkOptions
+}
+
+func (o *daemonOptions) register(fs *flag.FlagSet) {
+	registerProviderDBFlags(fs)
+	fs.DurationVar(&o.interval, "interval", time.Hour, "Scan interval")
+	fs.StringVar(&o.stateDir, "state-dir", defaultStateDir(), "Directory for the snapshot history, the change log and the service log")
+	fs.IntVar(&o.keep, "keep", 168, "Number of snapshots to keep in the history")
+	fs.StringVar(&o.historyDB, "history-db", "", "SQLite database that keeps the snapshots and changes (default history.db in -state-dir)")
+	fs.IntVar(&o.historyKeep, "history-keep", 8760, "Number of snapshots to keep in the history database, with their changes; 0 to keep all")
+	fs.BoolVar(&o.allFindings, "all-findings", false, "Send every finding on every scan instead of only new ones")
+	registerBaselineFlags(fs)
+	o.sinks.register(fs)
+}
+
+func (o *daemonOptions) validate() error {
+	if o.interval <= 0 {
+		return fmt.Errorf("-interval must be positive")
+	}
+	if o.keep < 1 {
+		return fmt.Errorf("-keep must be at least 1")
+	}
+	if o.historyKeep < 0 {
+		return fmt.Errorf("-history-keep must not be negative")
+	}
+	if o.historyDB == "" {
+		o.historyDB = filepath.Join(o.stateDir, "history.db")
+	}
+	if baselinePath != "" {
+		if err := loadHostBaseline(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultStateDir is %ProgramData%\autologgerAnalyzer.
+func defaultStateDir() string {
+	return filepath.Join(cmp.Or(os.Getenv("ProgramData"), `C:\ProgramData`), "autologgerAnalyzer")
+}
+
+// stateDirSDDL gives SYSTEM and the Administrators full control of the
+// state directory and everything in it, and nobody else any access.
+const stateDirSDDL = "D:P(A;OICI;FA;;;SY)(A;OICI;FA;;;BA)"
+
+// createStateDir creates the state directory, or secures an existing one,
+// with a DACL that only allows SYSTEM and the Administrators: the daemon
+// trusts the snapshots it finds there. A directory owned by anyone else may
+// have been planted by an unprivileged user and is refused.
+func createStateDir(dir string) error {
+	sd, err := windows.SecurityDescriptorFromString(stateDirSDDL)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", filepath.Dir(dir), err)
+		}
+		path, err := windows.UTF16PtrFromString(dir)
+		if err != nil {
+			return err
+		}
+		sa := &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{})), SecurityDescriptor: sd}
+		if err := windows.CreateDirectory(path, sa); err != nil && err != windows.ERROR_ALREADY_EXISTS {
+			return fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	current, err := windows.GetNamedSecurityInfo(dir, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return fmt.Errorf("failed to read the owner of %s: %v", dir, err)
+	}
+	owner, _, err := current.Owner()
+	if err != nil {
+		return fmt.Errorf("failed to read the owner of %s: %v", dir, err)
+	}
+	if !owner.IsWellKnown(windows.WinLocalSystemSid) && !owner.IsWellKnown(windows.WinBuiltinAdministratorsSid) {
+		name := getAccountName(owner)
+		if name == "" {
+			name = owner.String()
+		}
+		return fmt.Errorf("%s is owned by %s instead of SYSTEM or the Administrators, refusing to use it", dir, name)
+	}
+
+	// Directories created by older versions inherited the permissions of
+	// their parent.
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	err = windows.SetNamedSecurityInfo(dir, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+	if err != nil {
+		return fmt.Errorf("failed to restrict access to %s: %v", dir, err)
+	}
+	return nil
+}
+
+// daemon scans the autologgers on a schedule and remembers what it
+// reported.
+type daemon struct {
+	opts *daemonOptions
+	// previous is the snapshot of the last scan, or the newest one in the
+	// history after a restart.
+	previous *Snapshot
+	// reported holds the findings of the last scan that were delivered, so
+	// that only new ones are sent.
+	reported map[string]bool
+}
+
+// findingKey identifies a finding across scans.
+func findingKey(f Finding) string {
+	return strings.Join([]string{f.ID, strings.ToLower(f.Autologger), strings.ToLower(canonicalGUID(f.ProviderGUID))}, "|")
+}
+
+// scan analyzes every autologger, sends new findings and the changes since
+// the previous scan to the sinks, and adds the snapshot to the history.
+func (d *daemon) scan() error {
+	names, err := getAutologgerNames()
+	if err != nil {
+		return err
+	}
+	host := collectHostMetadata()
+
+	var reports, copies []*AutologgerReport
+	skipped := 0
+	for _, name := range names {
+		report, err := analyzeAutologger(name, analyzeOptions{})
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			skipped++
+			continue
+		}
+		report.Host = host
+		reports = append(reports, report)
+		// The snapshot strips the findings and the session, so it is
+		// built from copies.
+		config := *report.Config
+		copies = append(copies, &AutologgerReport{Config: &config, Providers: slices.Clone(report.Providers), InvalidSubkeys: report.InvalidSubkeys})
+	}
+	snapshot := newSnapshot(host, copies)
+
+	current := make(map[string]bool)
+	byName := make(map[string]*AutologgerReport)
+	for _, report := range reports {
+		var findings []Finding
+		for _, f := range report.Findings {
+			key := findingKey(f)
+			if d.opts.allFindings || !d.reported[key] {
+				findings = append(findings, f)
+			}
+			current[key] = true
+		}
+		report.Findings = findings
+		byName[strings.ToLower(report.Config.Name)] = report
+	}
+
+	var diff *SnapshotDiff
+	var removed []*AutologgerReport
+	changes := 0
+	if d.previous != nil {
+		diff = diffSnapshots(d.previous, snapshot)
+		for _, f := range snapshotChangeFindings(diff) {
+			report, ok := byName[strings.ToLower(f.Autologger)]
+			if !ok {
+				// Removed autologgers only have their findings.
+				report = &AutologgerReport{Host: host, Config: &AutologgerConfig{Name: f.Autologger}}
+				byName[strings.ToLower(f.Autologger)] = report
+				removed = append(removed, report)
+			}
+			report.Findings = append(report.Findings, f)
+			changes++
+		}
+	}
+
+	delivered, err := d.send(host, slices.Concat(reports, removed))
+	if err != nil {
+		slog.Error("cannot send findings", "error", err)
+	}
+	// Only the findings the sinks took are reported, so the others are
+	// sent again on the next scan. Those reported before stay reported
+	// while they last.
+	reported := make(map[string]bool)
+	for key := range current {
+		if d.reported[key] {
+			reported[key] = true
+		}
+	}
+	sent := 0
+	for _, report := range delivered {
+		for _, f := range report.Findings {
+			reported[findingKey(f)] = true
+		}
+		sent += len(report.Findings)
+	}
+	d.reported = reported
+	if err := d.save(snapshot, diff); err != nil {
+		return err
+	}
+	if err := writeHistory(d.opts.historyDB, host, reports, diff, d.opts.historyKeep); err != nil {
+		slog.Error("cannot write history database", "path", d.opts.historyDB, "error", err)
+	}
+	d.previous = snapshot
+	slog.Info("scan complete", "autologgers", len(snapshot.Autologgers), "skipped", skipped, "changes", changes, "findings_sent", sent)
+	return nil
+}
+
+// send writes the reports with findings to the sinks and returns those that
+// were delivered: every sink wrote them and was closed without an error, as
+// sinks such as OTLP only flush on Close. The sinks are built per scan, so
+// their host metadata and connections are fresh.
+func (d *daemon) send(host *HostMetadata, reports []*AutologgerReport) ([]*AutologgerReport, error) {
+	sinks, err := d.opts.sinks.build(host)
+	if err != nil {
+		return nil, err
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	var pending []*AutologgerReport
+	for _, report := range reports {
+		if len(report.Findings) > 0 {
+			pending = append(pending, report)
+		}
+	}
+	failed := make(map[*AutologgerReport]bool)
+	var closeErr error
+	for _, s := range sinks {
+		for _, report := range pending {
+			if err := s.WriteReport(report); err != nil {
+				slog.Warn("cannot write to sink", "autologger", report.Config.Name, "error", err)
+				failed[report] = true
+			}
+		}
+		if err := s.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	if closeErr != nil {
+		return nil, fmt.Errorf("failed to close sink: %v", closeErr)
+	}
+
+	var delivered []*AutologgerReport
+	for _, report := range pending {
+		if !failed[report] {
+			delivered = append(delivered, report)
+		}
+	}
+	return delivered, nil
+}
+
+// save adds a snapshot to the history, appends the diff to the change log
+// if anything changed and removes the oldest snapshots beyond -keep.
+func (d *daemon) save(snapshot *Snapshot, diff *SnapshotDiff) error {
+	dir := filepath.Join(d.opts.stateDir, "snapshots")
+	path := filepath.Join(dir, "snapshot-"+snapshot.Host.CollectedAt.UTC().Format("20060102T150405Z")+".json")
+	out, err := createAtomicFile(path, false)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snapshot); err != nil {
+		out.Abort()
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := out.Commit(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	if diff != nil && len(diff.Added)+len(diff.Removed)+len(diff.Changed) > 0 {
+		f, err := os.OpenFile(filepath.Join(d.opts.stateDir, "changes.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open change log: %v", err)
+		}
+		err = json.NewEncoder(f).Encode(diff)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write change log: %v", err)
+		}
+	}
+
+	history, err := listSnapshotHistory(dir)
+	if err != nil {
+		return err
+	}
+	for len(history) > d.opts.keep {
+		if err := os.Remove(history[0]); err != nil {
+			slog.Warn("cannot remove old snapshot", "path", history[0], "error", err)
+		}
+		history = history[1:]
+	}
+	return nil
+}
+
+// listSnapshotHistory returns the snapshots in the history, oldest first.
+func listSnapshotHistory(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot history: %v", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && strings.HasPrefix(name, "snapshot-") && strings.HasSuffix(name, ".json") {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	// The names hold the UTC time, so they sort chronologically.
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// snapshotChangeFindings turns the differences between two snapshots into
+// findings: one per autologger added or removed and one per setting that
+// changed.
+func snapshotChangeFindings(diff *SnapshotDiff) []Finding {
+	var findings []Finding
+	finding := func(autologger, message string) Finding {
+		return Finding{ID: "AUTOLOGGER_CONFIG_CHANGED", Severity: SeverityMedium, Autologger: autologger, Message: message}
+	}
+	for _, name := range diff.Added {
+		findings = append(findings, finding(name, fmt.Sprintf("Autologger %s was added", name)))
+	}
+	for _, name := range diff.Removed {
+		findings = append(findings, finding(name, fmt.Sprintf("Autologger %s was removed", name)))
+	}
+	for _, change := range diff.Changed {
+		for _, row := range change.Differences {
+			setting := row.Setting
+			if row.Provider != "" {
+				setting = fmt.Sprintf("%s of provider %s (%s)", row.Setting, cmp.Or(row.ProviderName, "unknown"), row.Provider)
+			}
+			f := finding(change.Autologger, fmt.Sprintf("%s of autologger %s changed from %s to %s", setting, change.Autologger, row.A, row.B))
+			f.ProviderGUID, f.ProviderName = row.Provider, row.ProviderName
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// runDaemonLoop scans on every interval until ctx is done.
+func runDaemonLoop(ctx context.Context, opts *daemonOptions) error {
+	if err := createStateDir(opts.stateDir); err != nil {
+		return err
+	}
+	dir := filepath.Join(opts.stateDir, "snapshots")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	d := &daemon{opts: opts}
+	if history, err := listSnapshotHistory(dir); err == nil && len(history) > 0 {
+		previous, err := loadSnapshot(history[len(history)-1])
+		if err != nil {
+			slog.Warn("cannot load the last snapshot, changes are reported from the next scan on", "error", err)
+		}
+		d.previous = previous
+	}
+
+	slog.Info("daemon started", "interval", opts.interval, "state_dir", opts.stateDir)
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		if err := d.scan(); err != nil {
+			slog.Error("scan failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("daemon stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func runDaemon(cmd *command, args []string) error {
+	var opts daemonOptions
+
+	fs := newFlagSet(cmd)
+	opts.register(fs)
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return fmt.Errorf("cannot tell whether running as a service: %v", err)
+	}
+	if isService {
+		return runDaemonService(&opts)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return runDaemonLoop(ctx, &opts)
+}
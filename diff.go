@@ -0,0 +1,279 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// diffFormats are the values accepted by diff -format and diff-snapshots
+// -format.
+var diffFormats = []string{"table", "json", "unified", "json-patch"}
+
+// AutologgerDiff holds the differences between two autologgers.
+type AutologgerDiff struct {
+	A           string    `json:"a"`
+	B           string    `json:"b"`
+	Differences []DiffRow `json:"differences"`
+}
+
+// DiffRow is a setting with its value in both autologgers. Values of a
+// provider only one of them configures are "-" in the other.
+type DiffRow struct {
+	Setting string `json:"setting"`
+	// Provider is the GUID of the provider the setting belongs to, or ""
+	// for session settings.
+	Provider     string `json:"provider,omitempty"`
+	ProviderName string `json:"provider_name,omitempty"`
+	A            string `json:"a"`
+	B            string `json:"b"`
+}
+
+// differ collects the rows of a diff, only the differing ones unless all is
+// set.
+type differ struct {
+	all  bool
+	rows []DiffRow
+}
+
+func (d *differ) add(row DiffRow) {
+	if d.all || row.A != row.B {
+		d.rows = append(d.rows, row)
+	}
+}
+
+// diffAutologgers compares the session settings and providers of two
+// autologgers.
+func diffAutologgers(a, b *AutologgerReport, all bool) []DiffRow {
+	d := &differ{all: all}
+	diffSessionSettings(d, a.Config, b.Config)
+	diffProviders(d, a.Providers, b.Providers)
+	return d.rows
+}
+
+// diffSessionSettings compares the values of two autologger keys that
+// configure the session.
+func diffSessionSettings(d *differ, a, b *AutologgerConfig) {
+	session := func(setting, a, b string) {
+		d.add(DiffRow{Setting: setting, A: a, B: b})
+	}
+	number := func(v uint64) string { return fmt.Sprintf("%d", v) }
+	fileName := func(c *AutologgerConfig) string { return cmp.Or(c.FileName, "-") }
+
+	session("Start", getStartStatus(a.Start), getStartStatus(b.Start))
+	session("GUID", a.GUID, b.GUID)
+	session("LogFileMode", getLogFileModeDescription(a.LogFileMode), getLogFileModeDescription(b.LogFileMode))
+	session("ClockType", getClockTypeDescription(a.ClockType), getClockTypeDescription(b.ClockType))
+	session("BufferSize", number(a.BufferSize), number(b.BufferSize))
+	session("MinimumBuffers", number(a.MinimumBuffers), number(b.MinimumBuffers))
+	session("MaximumBuffers", number(a.MaximumBuffers), number(b.MaximumBuffers))
+	session("FlushTimer", number(a.FlushTimer), number(b.FlushTimer))
+	session("FileName", fileName(a), fileName(b))
+	session("MaxFileSize", number(a.MaxFileSize), number(b.MaxFileSize))
+	session("FileMax", number(a.FileMax), number(b.FileMax))
+	if a.EnableFlags != 0 || b.EnableFlags != 0 {
+		session("EnableFlags", getKernelFlagsDescription(a.EnableFlags), getKernelFlagsDescription(b.EnableFlags))
+	}
+}
+
+// diffProviders compares the providers of two autologgers by GUID, in the
+// order of the first one followed by those only the second one has.
+func diffProviders(d *differ, a, b []ETWProvider) {
+	byGUID := make(map[string]*ETWProvider)
+	for i := range b {
+		byGUID[canonicalGUID(b[i].GUID)] = &b[i]
+	}
+	seen := make(map[string]bool)
+	for i := range a {
+		guid := canonicalGUID(a[i].GUID)
+		seen[guid] = true
+		diffProvider(d, &a[i], byGUID[guid])
+	}
+	for i := range b {
+		if !seen[canonicalGUID(b[i].GUID)] {
+			diffProvider(d, nil, &b[i])
+		}
+	}
+}
+
+// diffProvider compares a provider in two autologgers, nil where it isn't
+// configured.
+func diffProvider(d *differ, a, b *ETWProvider) {
+	p := cmp.Or(a, b)
+	row := func(setting string, value func(*ETWProvider) string) {
+		va, vb := "-", "-"
+		if a != nil {
+			va = value(a)
+		}
+		if b != nil {
+			vb = value(b)
+		}
+		d.add(DiffRow{Setting: setting, Provider: p.GUID, ProviderName: p.Name, A: va, B: vb})
+	}
+
+	if a == nil || b == nil {
+		row("Provider", getProviderEnableDescription)
+		return
+	}
+	row("Enabled", func(p *ETWProvider) string { return p.EnabledState })
+	row("EnableLevel", func(p *ETWProvider) string { return getLevelName(p.EnableLevel) })
+	row("MatchAnyKeyword", func(p *ETWProvider) string { return formatKeyword(p.MatchAnyKeyword) })
+	row("MatchAllKeyword", func(p *ETWProvider) string { return formatKeyword(p.MatchAllKeyword) })
+	row("EnableProperty", func(p *ETWProvider) string { return getEnablePropertyDescription(p.EnableProperty) })
+	row("EventIds", func(p *ETWProvider) string {
+		if len(p.EventIDs) == 0 {
+			return "-"
+		}
+		return getEventIDFilterLabel(*p, formatEventIDRanges(p.EventIDs))
+	})
+}
+
+// getProviderEnableDescription summarizes how an autologger enables a
+// provider, e.g. "Level 4, Any 0x10, All 0x0", or "disabled".
+func getProviderEnableDescription(p *ETWProvider) string {
+	if p.EnabledState == enabledOff {
+		return "disabled"
+	}
+	return fmt.Sprintf("Level %d, Any %s, All %s", p.EnableLevel, formatKeyword(p.MatchAnyKeyword), formatKeyword(p.MatchAllKeyword))
+}
+
+func runDiff(cmd *command, args []string) error {
+	var format string
+	var all bool
+	var output outputOptions
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.BoolVar(&all, "all", false, "Also show the settings that are the same")
+	fs.StringVar(&format, "format", "table", "Output format: table, json, unified or json-patch")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	names := parseArgs(fs, args)
+
+	if !slices.Contains(diffFormats, format) {
+		return fmt.Errorf("unknown output format %q (expected table, json, unified or json-patch)", format)
+	}
+	if len(names) != 2 {
+		fs.Usage()
+		return fmt.Errorf("two autologger names are required")
+	}
+
+	// The documents of unified and json-patch only hold what the registry
+	// configures, so nothing else is resolved for them.
+	opts := analyzeOptions{noResolve: format == "unified" || format == "json-patch"}
+	var reports [2]*AutologgerReport
+	for i, name := range names {
+		report, err := analyzeAutologger(name, opts)
+		if err != nil {
+			return err
+		}
+		reports[i] = report
+	}
+	result := &AutologgerDiff{A: names[0], B: names[1], Differences: diffAutologgers(reports[0], reports[1], all)}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	case "unified", "json-patch":
+		// newSnapshot drops the live session, such as the logger IDs
+		// and live mismatches, and names the providers.
+		var docs [2]map[string]any
+		for i, report := range reports {
+			doc, err := canonicalAutologger(newSnapshot(nil, []*AutologgerReport{report}).Autologgers[0])
+			if err != nil {
+				return err
+			}
+			docs[i] = doc
+		}
+		if err := writeDocumentDiff(os.Stdout, format, names[0], names[1], docs[0], docs[1]); err != nil {
+			return err
+		}
+	default:
+		displayDiff(os.Stdout, result, newPalette(os.Stdout, output.color))
+	}
+
+	var summary reportSummary
+	for _, row := range result.Differences {
+		if row.A != row.B {
+			summary.findings++
+		}
+	}
+	return summary.exitStatus()
+}
+
+// displayDiff prints a diff as a table with a column per autologger.
+// Differing values are highlighted, which matters with -all.
+func displayDiff(w io.Writer, diff *AutologgerDiff, pal palette) {
+	if len(diff.Differences) == 0 {
+		fmt.Fprintf(w, "%s and %s are configured the same\n", diff.A, diff.B)
+		return
+	}
+
+	const settingWidth, valueWidth = 60, 40
+	fmt.Fprintf(w, "| %-*s | %-*s | %-*s |\n",
+		settingWidth, "Setting", valueWidth, truncateString(diff.A, valueWidth), valueWidth, truncateString(diff.B, valueWidth))
+	fmt.Fprintf(w, "|%s|%s|%s|\n",
+		strings.Repeat("-", settingWidth+2),
+		strings.Repeat("-", valueWidth+2),
+		strings.Repeat("-", valueWidth+2))
+	provider := ""
+	for _, row := range diff.Differences {
+		if row.Provider != provider {
+			provider = row.Provider
+			// The GUID takes 38 characters, the name gets the rest.
+			header := row.Provider
+			if row.ProviderName != "" {
+				header = truncateString(row.ProviderName, settingWidth-39) + " " + row.Provider
+			}
+			fmt.Fprintf(w, "| %-*s | %-*s | %-*s |\n", settingWidth, header, valueWidth, "", valueWidth, "")
+		}
+		setting := row.Setting
+		if row.Provider != "" {
+			setting = "  " + setting
+		}
+		// Long values, such as event ID lists, are wrapped so the
+		// difference isn't cut off.
+		linesA, linesB := wrapString(row.A, valueWidth), wrapString(row.B, valueWidth)
+		for i := range max(len(linesA), len(linesB)) {
+			var a, b string
+			if i < len(linesA) {
+				a = linesA[i]
+			}
+			if i < len(linesB) {
+				b = linesB[i]
+			}
+			a, b = fmt.Sprintf("%-*s", valueWidth, a), fmt.Sprintf("%-*s", valueWidth, b)
+			if row.A != row.B {
+				a, b = pal.yellow(a), pal.yellow(b)
+			}
+			fmt.Fprintf(w, "| %-*s | %s | %s |\n", settingWidth, setting, a, b)
+			setting = ""
+		}
+	}
+}
+
+// wrapString splits s into lines of at most width characters, breaking
+// after commas and spaces where possible.
+func wrapString(s string, width int) []string {
+	runes := []rune(s)
+	var lines []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if runes[i-1] == ',' || runes[i-1] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+		runes = runes[cut:]
+	}
+	return append(lines, string(runes))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GoldDriftReport compares the snapshots of many hosts with the snapshot of
+// a gold image.
+type GoldDriftReport struct {
+	Gold  *HostMetadata `json:"gold"`
+	Hosts []HostDrift   `json:"hosts"`
+	// Drifts are the differences from the gold image, each with the hosts
+	// that have it.
+	Drifts []GoldDrift `json:"drifts"`
+}
+
+// HostDrift is how the snapshot of a host differs from the gold image.
+type HostDrift struct {
+	Host *HostMetadata `json:"host"`
+	Path string        `json:"path"`
+	// Missing are autologgers of the gold image the host doesn't have,
+	// Extra those only the host has.
+	Missing []string         `json:"missing"`
+	Extra   []string         `json:"extra"`
+	Changed []SnapshotChange `json:"changed"`
+}
+
+// GoldDrift is a setting of an autologger that differs from the gold image on
+// some hosts. An autologger that is missing or extra is the setting
+// "Autologger", with "present" against "-".
+type GoldDrift struct {
+	Autologger   string          `json:"autologger"`
+	Setting      string          `json:"setting"`
+	Provider     string          `json:"provider,omitempty"`
+	ProviderName string          `json:"provider_name,omitempty"`
+	Gold         string          `json:"gold"`
+	Hosts        []GoldDriftHost `json:"hosts"`
+}
+
+// GoldDriftHost is the value a host has for a drifted setting.
+type GoldDriftHost struct {
+	Host  string `json:"host"`
+	Value string `json:"value"`
+}
+
+// snapshotFile is a snapshot with the path it was loaded from.
+type snapshotFile struct {
+	path     string
+	snapshot *Snapshot
+}
+
+// expandSnapshotPaths replaces directories with the JSON files in them.
+func expandSnapshotPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %v", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// getHostLabel names the host of a snapshot, or the file when it has no
+// host metadata.
+func getHostLabel(host *HostMetadata, path string) string {
+	if host != nil && host.Hostname != "" {
+		return host.Hostname
+	}
+	return filepath.Base(path)
+}
+
+// buildDriftReport compares every host snapshot with the gold snapshot.
+func buildDriftReport(gold *Snapshot, hosts []snapshotFile) *GoldDriftReport {
+	report := &GoldDriftReport{Gold: gold.Host, Hosts: []HostDrift{}, Drifts: []GoldDrift{}}
+	index := make(map[string]int)
+	addDrift := func(host string, d GoldDrift, value string) {
+		key := strings.Join([]string{strings.ToLower(d.Autologger), d.Provider, d.Setting, d.Gold}, "|")
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Drifts)
+			index[key] = i
+			report.Drifts = append(report.Drifts, d)
+		}
+		report.Drifts[i].Hosts = append(report.Drifts[i].Hosts, GoldDriftHost{Host: host, Value: value})
+	}
+
+	for _, h := range hosts {
+		diff := diffSnapshots(gold, h.snapshot)
+		label := getHostLabel(h.snapshot.Host, h.path)
+		report.Hosts = append(report.Hosts, HostDrift{
+			Host:    h.snapshot.Host,
+			Path:    h.path,
+			Missing: diff.Removed,
+			Extra:   diff.Added,
+			Changed: diff.Changed,
+		})
+		for _, name := range diff.Removed {
+			addDrift(label, GoldDrift{Autologger: name, Setting: "Autologger", Gold: "present"}, "-")
+		}
+		for _, name := range diff.Added {
+			addDrift(label, GoldDrift{Autologger: name, Setting: "Autologger", Gold: "-"}, "present")
+		}
+		for _, change := range diff.Changed {
+			for _, row := range change.Differences {
+				addDrift(label, GoldDrift{Autologger: change.Autologger, Setting: row.Setting, Provider: row.Provider, ProviderName: row.ProviderName, Gold: row.A}, row.B)
+			}
+		}
+	}
+	slices.SortStableFunc(report.Drifts, func(a, b GoldDrift) int {
+		return strings.Compare(strings.ToLower(a.Autologger), strings.ToLower(b.Autologger))
+	})
+	return report
+}
+
+// drifted reports whether a host differs from the gold image.
+func (h *HostDrift) drifted() bool {
+	return len(h.Missing)+len(h.Extra)+len(h.Changed) > 0
+}
+
+// displayDriftReport prints a row per host and the drifts with the hosts
+// that have them.
+func displayDriftReport(w io.Writer, report *GoldDriftReport, pal palette) {
+	fmt.Fprintf(w, "Drift from gold image %s across %d hosts:\n", getSnapshotLabel(report.Gold), len(report.Hosts))
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	fmt.Fprintf(w, "| %-30s | %-16s | %-7s | %-7s | %-7s |\n", "Host", "Collected", "Missing", "Extra", "Changed")
+	fmt.Fprintf(w, "|%s|%s|%s|%s|%s|\n", strings.Repeat("-", 32), strings.Repeat("-", 18), strings.Repeat("-", 9), strings.Repeat("-", 9), strings.Repeat("-", 9))
+	for _, h := range report.Hosts {
+		collected := "-"
+		if h.Host != nil {
+			collected = h.Host.CollectedAt.Format("2006-01-02 15:04")
+		}
+		host := fmt.Sprintf("%-30s", truncateString(getHostLabel(h.Host, h.Path), 30))
+		if h.drifted() {
+			host = pal.yellow(host)
+		}
+		fmt.Fprintf(w, "| %s | %-16s | %7d | %7d | %7d |\n", host, collected, len(h.Missing), len(h.Extra), len(h.Changed))
+	}
+
+	if len(report.Drifts) == 0 {
+		fmt.Fprintln(w, "\nEvery host matches the gold image")
+		return
+	}
+	fmt.Fprintln(w)
+	for _, d := range report.Drifts {
+		setting := d.Setting
+		if d.Provider != "" {
+			name := d.ProviderName
+			if name == "" {
+				name = "unknown"
+			}
+			setting = fmt.Sprintf("%s of provider %s (%s)", d.Setting, name, d.Provider)
+		}
+		line := fmt.Sprintf("%s: %s: gold %s, differs on %d of %d hosts", d.Autologger, setting, d.Gold, len(d.Hosts), len(report.Hosts))
+		if d.Setting == "Autologger" && d.Gold == "present" {
+			line = pal.red(line)
+		}
+		fmt.Fprintln(w, line)
+		for _, h := range d.Hosts {
+			fmt.Fprintf(w, "  %s: %s\n", h.Host, h.Value)
+		}
+	}
+}
+
+// writeDriftPatches writes a unified diff per host, or a JSON object with
+// the JSON Patch of every host keyed by its snapshot path.
+func writeDriftPatches(w io.Writer, format, goldPath string, gold *Snapshot, hosts []snapshotFile) error {
+	a, err := canonicalSnapshot(gold)
+	if err != nil {
+		return err
+	}
+	patches := make(map[string]any)
+	for _, h := range hosts {
+		b, err := canonicalSnapshot(h.snapshot)
+		if err != nil {
+			return err
+		}
+		if format == "json-patch" {
+			patches[h.path] = jsonPatch(a, b)
+			continue
+		}
+		if err := writeDocumentDiff(w, format, goldPath, h.path, a, b); err != nil {
+			return err
+		}
+	}
+	if format == "json-patch" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(patches)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// dumpDBCommand describes providers dump-db for its help text.
+var dumpDBCommand = &command{
+	name:    "providers dump-db",
+	args:    "[flags]",
+	summary: "Write a provider database with the providers registered on this machine, for -provider-db",
+}
+
+func runProviders(cmd *command, args []string) error {
+	if len(args) > 0 && args[0] == "dump-db" {
+		return runDumpDB(args[1:])
+	}
+	if len(args) > 0 && args[0] == "export-schema" {
+		return runExportSchema(args[1:])
+	}
+	fs := newFlagSet(cmd)
+	fs.Usage()
+	return fmt.Errorf("a providers subcommand is required: dump-db, export-schema")
+}
+
+// runDumpDB implements providers dump-db. The database holds the providers
+// TDH and the Publishers key know on this host, merged into the database in
+// use so entries for providers this build lacks are kept.
+func runDumpDB(args []string) error {
+	var outPath string
+	var hostOnly bool
+
+	fs := newFlagSet(dumpDBCommand)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&outPath, "out", "", "Write the database to this file instead of stdout")
+	fs.BoolVar(&hostOnly, "host-only", false, "Only write the providers registered on this machine, not the entries of the database in use")
+	parseArgs(fs, args)
+
+	entries, err := collectHostProviders()
+	if err != nil {
+		return err
+	}
+	found := len(entries)
+	if !hostOnly {
+		for guid, entry := range getProviderDB() {
+			if _, ok := entries[guid]; !ok {
+				entries[guid] = entry
+			}
+		}
+	}
+
+	host := collectHostMetadata()
+	comments := []string{
+		"ETW provider database: GUID, name and short description, tab separated.",
+		fmt.Sprintf("Written by autologgerAnalyzer %s on %s (%s, build %s) at %s.",
+			version, host.Hostname, host.OSName, host.OSBuild, host.CollectedAt.Format(time.RFC3339)),
+	}
+	// The source builds tell which installs the entries come from, the
+	// builds of the database in use included when its entries are kept.
+	sources := []string{fmt.Sprintf("%s (%s)", host.OSBuild, host.OSName)}
+	if !hostOnly {
+		for _, source := range providerDB.sources {
+			if !slices.Contains(sources, source) {
+				sources = append(sources, source)
+			}
+		}
+	}
+	for _, source := range sources {
+		comments = append(comments, strings.TrimPrefix(providerDBSourcePrefix, "# ")+source)
+	}
+
+	var w io.Writer = os.Stdout
+	var out *atomicFile
+	if outPath != "" {
+		if out, err = createAtomicFile(outPath, false); err != nil {
+			return err
+		}
+		w = out
+	}
+	if err := writeProviderDB(w, entries, comments); err != nil {
+		if out != nil {
+			out.Abort()
+		}
+		return fmt.Errorf("failed to write provider database: %v", err)
+	}
+	if out != nil {
+		if err := out.Commit(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", outPath, err)
+		}
+	}
+	slog.Info("wrote provider database", "providers", len(entries), "registered", found)
+	return nil
+}
+
+// collectHostProviders returns the providers registered on this host with
+// their names. Publisher names take precedence over TDH names, descriptions
+// are taken from the database in use.
+func collectHostProviders() (map[string]providerDBEntry, error) {
+	entries := make(map[string]providerDBEntry)
+
+	tdhProviders, err := enumerateTDHProviders()
+	if err != nil {
+		slog.Warn("cannot enumerate providers through TDH", "error", err)
+	}
+	for guid, p := range tdhProviders {
+		if p.name != "" {
+			entries[guid] = providerDBEntry{name: p.name}
+		}
+	}
+
+	publishers, err := getPublisherGUIDs()
+	if err != nil && len(entries) == 0 {
+		return nil, err
+	}
+	for _, guid := range publishers {
+		if name := readPublisherName(guid); name != "" {
+			entries[guid] = providerDBEntry{name: name}
+		}
+	}
+
+	for guid, entry := range entries {
+		entry.description = getProviderDescription(guid)
+		entries[guid] = entry
+	}
+	return entries, nil
+}
+
+// readPublisherName returns the name of a registered publisher, or "" if it
+// has none.
+func readPublisherName(guid string) string {
+	key, err := openKey(registry.LOCAL_MACHINE, publishersPath+`\`+guid, registry.READ)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	name, _, _ := key.GetStringValue("")
+	return name
+}
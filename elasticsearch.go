@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type esDocument struct {
+	Timestamp time.Time `json:"@timestamp"`
+	sinkRecord
+}
+
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// elasticsearchSink indexes every record of a report through the
+// Elasticsearch bulk API.
+type elasticsearchSink struct {
+	url    string
+	index  string
+	apiKey string
+	host   *HostMetadata
+	client *http.Client
+}
+
+// esIndexName expands the YYYY, MM and DD placeholders of an index pattern
+// using the collection time, e.g. autologger-YYYY.MM becomes autologger-2025.06.
+func esIndexName(pattern string, t time.Time) string {
+	return strings.NewReplacer(
+		"YYYY", t.Format("2006"),
+		"MM", t.Format("01"),
+		"DD", t.Format("02"),
+	).Replace(pattern)
+}
+
+func newElasticsearchSink(url, indexPattern, apiKey, caCertPath string, insecure bool, host *HostMetadata) (*elasticsearchSink, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if caCertPath != "" {
+		pem, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caCertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &elasticsearchSink{
+		url:    strings.TrimRight(url, "/") + "/_bulk",
+		index:  esIndexName(indexPattern, host.CollectedAt),
+		apiKey: apiKey,
+		host:   host,
+		client: &http.Client{
+			Timeout:   60 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (e *elasticsearchSink) WriteReport(report *AutologgerReport) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	action := map[string]map[string]string{"index": {"_index": e.index}}
+
+	for _, record := range reportRecords(e.host, report) {
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		doc := esDocument{Timestamp: e.host.CollectedAt, sinkRecord: record}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-ndjson")
+	if e.apiKey != "" {
+		header.Set("Authorization", "ApiKey "+e.apiKey)
+	}
+
+	resp, err := postWithRetryResponse(e.client, e.url, header, body.Bytes())
+	if err != nil {
+		return fmt.Errorf("elasticsearch: %v", err)
+	}
+
+	var bulk esBulkResponse
+	if err := json.Unmarshal(resp, &bulk); err != nil {
+		return fmt.Errorf("elasticsearch: unexpected bulk response: %v", err)
+	}
+	if bulk.Errors {
+		for _, item := range bulk.Items {
+			for _, result := range item {
+				if result.Error != nil {
+					return fmt.Errorf("elasticsearch: bulk indexing failed: %s: %s", result.Error.Type, result.Error.Reason)
+				}
+			}
+		}
+		return fmt.Errorf("elasticsearch: bulk indexing reported errors")
+	}
+
+	return nil
+}
+
+func (e *elasticsearchSink) Close() error { return nil }
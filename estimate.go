@@ -0,0 +1,373 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Sources of the rate of a provider in an estimate.
+const (
+	rateSourceSampled = "sampled"
+	rateSourceFile    = "file"
+	rateSourceUnknown = "unknown"
+)
+
+// Loss risks of an estimate.
+const (
+	lossRiskLow     = "low"
+	lossRiskMedium  = "medium"
+	lossRiskHigh    = "high"
+	lossRiskUnknown = "unknown"
+)
+
+// classicRateName names the rate of the MOF and WPP events in an estimate.
+const classicRateName = "(MOF and WPP events)"
+
+// ETW defaults for the buffer settings an autologger leaves at 0.
+const (
+	defaultBufferSizeKB = 64
+	// defaultExtraBuffers is how many buffers MaximumBuffers exceeds
+	// MinimumBuffers by.
+	defaultExtraBuffers = 20
+)
+
+// ProviderRate is the event rate assumed for a provider in an estimate.
+type ProviderRate struct {
+	// GUID is empty for the MOF and WPP events, which can't be attributed
+	// to a provider.
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+	// Source is "sampled" or "file", or "unknown" without rate data.
+	Source          string  `json:"source"`
+	EventsPerSecond float64 `json:"events_per_second"`
+	BytesPerEvent   float64 `json:"bytes_per_event"`
+}
+
+// VolumeEstimate is the volume an autologger is expected to log and whether
+// its buffers can keep up with it.
+type VolumeEstimate struct {
+	Autologger       string  `json:"autologger"`
+	EventsPerSecond  float64 `json:"events_per_second"`
+	BytesPerSecond   float64 `json:"bytes_per_second"`
+	MBPerHour        float64 `json:"mb_per_hour"`
+	BufferSizeKB     uint64  `json:"buffer_size_kb"`
+	MaximumBuffers   uint64  `json:"maximum_buffers"`
+	BuffersPerSecond float64 `json:"buffers_per_second"`
+	// BufferSeconds is how many seconds of events the maximum buffers
+	// hold when the disk or consumer stalls, 0 without rate data.
+	BufferSeconds float64 `json:"buffer_seconds"`
+	// FileHours is how many hours of events fit in MaximumFileSize, 0
+	// without a maximum file size or rate data.
+	FileHours      float64        `json:"file_hours,omitempty"`
+	LossRisk       string         `json:"loss_risk"`
+	LossRiskReason string         `json:"loss_risk_reason,omitempty"`
+	Providers      []ProviderRate `json:"providers"`
+}
+
+// sampleRates turns the providers of samples into rates, keyed by
+// normalized GUID. Providers sampled more than once keep their last rate.
+// The MOF and WPP events of a sample are keyed by its autologger with
+// classicRateKey, as they only tell about the session they were received in.
+func sampleRates(samples []*SampleReport, source string) map[string]ProviderRate {
+	rates := make(map[string]ProviderRate)
+	for _, sample := range samples {
+		var attributed uint64
+		for _, p := range sample.Providers {
+			rate := ProviderRate{GUID: p.GUID, Name: p.Name, Source: source, EventsPerSecond: p.EventsPerSecond}
+			if p.Events > 0 {
+				rate.BytesPerEvent = float64(p.Bytes) / float64(p.Events)
+			}
+			rates[canonicalGUID(p.GUID)] = rate
+			attributed += p.Bytes
+		}
+		if sample.ClassicEvents > 0 {
+			rate := ProviderRate{Name: classicRateName, Source: source, EventsPerSecond: eventRate(sample.ClassicEvents, sample.Seconds)}
+			if sample.Bytes > attributed {
+				rate.BytesPerEvent = float64(sample.Bytes-attributed) / float64(sample.ClassicEvents)
+			}
+			rates[classicRateKey(sample.Autologger)] = rate
+		}
+	}
+	return rates
+}
+
+// classicRateKey is the key of the rate of the MOF and WPP events of an
+// autologger in the rates of sampleRates. GUID keys start with a brace, so
+// they can't collide.
+func classicRateKey(autologger string) string {
+	return "classic:" + strings.ToLower(autologger)
+}
+
+// loadSampleFile reads the output of sample -format json, a single sample
+// or a list of them.
+func loadSampleFile(path string) ([]*SampleReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates: %v", err)
+	}
+	var samples []*SampleReport
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &samples)
+	} else {
+		var sample SampleReport
+		err = json.Unmarshal(data, &sample)
+		samples = append(samples, &sample)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading rates %s: %v", path, err)
+	}
+	return samples, nil
+}
+
+// estimateVolume estimates the volume of an autologger from the rates of its
+// providers, looked up in sampled, then in fromFile. Providers without a
+// rate in either count as silent. Providers of sampled that the autologger
+// doesn't configure, such as the members of provider groups, are counted as
+// well, and so are the MOF and WPP events sampled for the autologger.
+func estimateVolume(report *AutologgerReport, sampled, fromFile map[string]ProviderRate) *VolumeEstimate {
+	config := report.Config
+	estimate := &VolumeEstimate{
+		Autologger:     config.Name,
+		BufferSizeKB:   cmp.Or(config.BufferSize, defaultBufferSizeKB),
+		MaximumBuffers: config.MaximumBuffers,
+		Providers:      []ProviderRate{},
+	}
+	if estimate.MaximumBuffers == 0 {
+		estimate.MaximumBuffers = cmp.Or(config.MinimumBuffers, 2*uint64(runtime.NumCPU())) + defaultExtraBuffers
+	}
+
+	count := func(rate ProviderRate) {
+		estimate.Providers = append(estimate.Providers, rate)
+		estimate.EventsPerSecond += rate.EventsPerSecond
+		estimate.BytesPerSecond += rate.EventsPerSecond * rate.BytesPerEvent
+	}
+	seen := make(map[string]bool)
+	add := func(guid, name string) {
+		if seen[guid] {
+			return
+		}
+		seen[guid] = true
+		rate := ProviderRate{GUID: guid, Source: rateSourceUnknown}
+		if r, ok := sampled[guid]; ok {
+			rate = r
+		} else if r, ok := fromFile[guid]; ok {
+			rate = r
+		}
+		rate.GUID = guid
+		rate.Name = cmp.Or(name, rate.Name, resolveProviderName(guid))
+		count(rate)
+	}
+	for _, p := range report.Providers {
+		if p.EnabledState != enabledOff && p.ProviderType != providerTypeGroup {
+			add(canonicalGUID(p.GUID), p.Name)
+		}
+	}
+	extra := make([]string, 0, len(sampled))
+	for guid := range sampled {
+		if strings.HasPrefix(guid, "{") {
+			extra = append(extra, guid)
+		}
+	}
+	slices.Sort(extra)
+	for _, guid := range extra {
+		add(guid, sampled[guid].Name)
+	}
+	key := classicRateKey(config.Name)
+	if r, ok := sampled[key]; ok {
+		count(r)
+	} else if r, ok := fromFile[key]; ok {
+		count(r)
+	}
+
+	estimate.MBPerHour = estimate.BytesPerSecond * 3600 / (1024 * 1024)
+	if estimate.BytesPerSecond > 0 {
+		bufferBytes := float64(estimate.BufferSizeKB * 1024)
+		estimate.BuffersPerSecond = estimate.BytesPerSecond / bufferBytes
+		estimate.BufferSeconds = float64(estimate.MaximumBuffers) * bufferBytes / estimate.BytesPerSecond
+		if config.MaxFileSize > 0 {
+			estimate.FileHours = float64(config.MaxFileSize) / estimate.MBPerHour
+		}
+	}
+	estimate.LossRisk, estimate.LossRiskReason = assessLossRisk(config, estimate)
+	return estimate
+}
+
+// assessLossRisk judges how likely a session is to lose events from how
+// long its buffers hold the estimated rate: ETW drops events once every
+// buffer is full, so buffers that fill within a second lose events on any
+// hiccup of the disk or of a real-time consumer.
+func assessLossRisk(config *AutologgerConfig, estimate *VolumeEstimate) (string, string) {
+	if s := config.Session; s != nil && sessionLosing(s) {
+		return lossRiskHigh, fmt.Sprintf("the live session has already lost %d events", s.EventsLost)
+	}
+	if estimate.BytesPerSecond == 0 {
+		return lossRiskUnknown, "no rate data for its providers"
+	}
+	held := fmt.Sprintf("the buffers hold %s of events", formatSeconds(estimate.BufferSeconds))
+	switch {
+	case estimate.BufferSeconds < 1:
+		return lossRiskHigh, held
+	case estimate.BufferSeconds < 10:
+		return lossRiskMedium, held
+	}
+	return lossRiskLow, held
+}
+
+// formatSeconds renders a duration given in seconds with a readable unit,
+// e.g. "1.3s", "8.1 minutes" or "2.5 days".
+func formatSeconds(seconds float64) string {
+	switch {
+	case seconds >= 2*24*3600:
+		return fmt.Sprintf("%.1f days", seconds/(24*3600))
+	case seconds >= 2*3600:
+		return fmt.Sprintf("%.1f hours", seconds/3600)
+	case seconds >= 2*60:
+		return fmt.Sprintf("%.1f minutes", seconds/60)
+	default:
+		return fmt.Sprintf("%.1fs", seconds)
+	}
+}
+
+func runEstimate(cmd *command, args []string) error {
+	var format, ratesPath string
+	var sample time.Duration
+	var output outputOptions
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.DurationVar(&sample, "sample", 0, "Sample each autologger for this long to measure the rates of its providers")
+	fs.StringVar(&ratesPath, "rates", "", "Use the provider rates of a sample -format json output")
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	names := parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if len(names) == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one autologger name is required")
+	}
+
+	var fromFile map[string]ProviderRate
+	if ratesPath != "" {
+		samples, err := loadSampleFile(ratesPath)
+		if err != nil {
+			return err
+		}
+		fromFile = sampleRates(samples, rateSourceFile)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var estimates []*VolumeEstimate
+	var summary reportSummary
+	for _, name := range names {
+		report, err := analyzeAutologger(name, analyzeOptions{})
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			summary.skipped++
+			continue
+		}
+		var sampled map[string]ProviderRate
+		if sample > 0 {
+			result, err := sampleAutologger(ctx, name, sample, false)
+			if err != nil {
+				msg := "cannot sample autologger, its providers have no rate"
+				if fromFile != nil {
+					msg = "cannot sample autologger, using the -rates file"
+				}
+				slog.Warn(msg, "autologger", name, "error", err)
+				summary.partial++
+			} else {
+				sampled = sampleRates([]*SampleReport{result}, rateSourceSampled)
+			}
+		}
+		estimate := estimateVolume(report, sampled, fromFile)
+		if estimate.LossRisk == lossRiskHigh {
+			summary.findings++
+		}
+		estimates = append(estimates, estimate)
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(estimates); err != nil {
+			return err
+		}
+	} else {
+		pal := newPalette(os.Stdout, output.color)
+		for _, estimate := range estimates {
+			displayVolumeEstimate(os.Stdout, estimate, pal)
+		}
+	}
+	return summary.exitStatus()
+}
+
+// displayVolumeEstimate prints the expected volume of an autologger and the
+// rate assumed for each of its providers.
+func displayVolumeEstimate(w io.Writer, e *VolumeEstimate, pal palette) {
+	fmt.Fprintf(w, "Volume Estimate for %s:\n", e.Autologger)
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	fmt.Fprintf(w, "- Events: %.1f events/s, %s/s, %.1f MB/hour\n",
+		e.EventsPerSecond, formatKB(uint64(e.BytesPerSecond/1024)), e.MBPerHour)
+	fmt.Fprintf(w, "- Buffers: %.1f buffers/s of %s, %d buffers (%s)",
+		e.BuffersPerSecond, formatKB(e.BufferSizeKB), e.MaximumBuffers, formatKB(e.BufferSizeKB*e.MaximumBuffers))
+	if e.BufferSeconds > 0 {
+		fmt.Fprintf(w, " hold %s of events", formatSeconds(e.BufferSeconds))
+	}
+	fmt.Fprintln(w)
+	if e.FileHours > 0 {
+		fmt.Fprintf(w, "- Log File: holds %s of events\n", formatSeconds(e.FileHours*3600))
+	}
+	risk := e.LossRisk
+	if e.LossRiskReason != "" {
+		risk += ", " + e.LossRiskReason
+	}
+	switch e.LossRisk {
+	case lossRiskHigh:
+		risk = pal.red(risk)
+	case lossRiskMedium:
+		risk = pal.yellow(risk)
+	}
+	fmt.Fprintf(w, "- Loss Risk: %s\n", risk)
+
+	counts := make(map[string]int)
+	for _, p := range e.Providers {
+		counts[p.Source]++
+	}
+	fmt.Fprintf(w, "- Rate Data: %d sampled, %d from file, %d unknown\n\n",
+		counts[rateSourceSampled], counts[rateSourceFile], counts[rateSourceUnknown])
+
+	fmt.Fprintf(w, "| %-45s | %-8s | %-10s | %-11s | %-10s |\n", "Provider", "Source", "Events/s", "Bytes/Event", "MB/hour")
+	fmt.Fprintf(w, "|%s|%s|%s|%s|%s|\n",
+		strings.Repeat("-", 47),
+		strings.Repeat("-", 10),
+		strings.Repeat("-", 12),
+		strings.Repeat("-", 13),
+		strings.Repeat("-", 12))
+	for _, p := range e.Providers {
+		if p.Source == rateSourceUnknown {
+			fmt.Fprintf(w, "| %-45s | %s | %-10s | %-11s | %-10s |\n",
+				truncateString(p.Name, 45), pal.yellow(fmt.Sprintf("%-8s", p.Source)), "-", "-", "-")
+			continue
+		}
+		source := fmt.Sprintf("%-8s", p.Source)
+		fmt.Fprintf(w, "| %-45s | %s | %-10.1f | %-11.0f | %-10.1f |\n",
+			truncateString(p.Name, 45), source, p.EventsPerSecond, p.BytesPerEvent,
+			p.EventsPerSecond*p.BytesPerEvent*3600/(1024*1024))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// etlAnalyzeCommand describes etl analyze for its help text.
+var etlAnalyzeCommand = &command{
+	name:    "etl analyze",
+	args:    "[flags] <autologger|file.etl...>",
+	summary: "Count the providers and event IDs an autologger's log file actually recorded",
+}
+
+// ETLReport is what an ETL file holds, compared with the autologger that
+// wrote it.
+type ETLReport struct {
+	// Autologger is the autologger whose FileName was read, or "" for a
+	// file given by path.
+	Autologger string     `json:"autologger,omitempty"`
+	Path       string     `json:"path"`
+	Size       int64      `json:"size"`
+	Start      *time.Time `json:"start,omitempty"`
+	End        *time.Time `json:"end,omitempty"`
+	Events     uint64     `json:"events"`
+	// ClassicEvents are the MOF and WPP events recorded, which can't be
+	// attributed to a provider. They are included in Events.
+	ClassicEvents uint64 `json:"classic_events"`
+	// Providers are the providers recorded in the file and, for an
+	// autologger, its configured providers that recorded nothing.
+	Providers []ProviderSample `json:"providers"`
+}
+
+func runETL(cmd *command, args []string) error {
+	if len(args) > 0 && args[0] == "analyze" {
+		return runETLAnalyze(args[1:])
+	}
+	fs := newFlagSet(cmd)
+	fs.Usage()
+	return fmt.Errorf("an etl subcommand is required: analyze")
+}
+
+// analyzeETL reads an ETL file given by path, or the log file of the
+// autologger with the name, and counts its events.
+func analyzeETL(target string) (*ETLReport, error) {
+	report := &ETLReport{Path: target}
+	var providers []ETWProvider
+	if info, err := os.Stat(target); err != nil || info.IsDir() {
+		analysis, err := analyzeAutologger(target, analyzeOptions{})
+		if err != nil {
+			return nil, err
+		}
+		config := analysis.Config
+		if config.FilePath == "" {
+			return nil, fmt.Errorf("autologger %s has no FileName", target)
+		}
+		report.Autologger = target
+		report.Path = config.FilePath
+		providers = analysis.Providers
+	}
+
+	info, err := os.Stat(report.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log file: %v", err)
+	}
+	report.Size = info.Size()
+
+	tally, err := countLogFileEvents(report.Path)
+	if err != nil {
+		return nil, err
+	}
+	var seconds float64
+	if tally.first > 0 {
+		start, end := filetimeToTime(tally.first), filetimeToTime(tally.last)
+		report.Start, report.End = &start, &end
+		seconds = end.Sub(start).Seconds()
+	}
+	report.Providers = buildProviderSamples(providers, tally.counts, seconds)
+	report.ClassicEvents = tally.classic.events
+	report.Events = tally.classic.events
+	for _, p := range report.Providers {
+		report.Events += p.Events
+	}
+	return report, nil
+}
+
+// runETLAnalyze implements etl analyze.
+func runETLAnalyze(args []string) error {
+	var format string
+	var top int
+	var output outputOptions
+
+	fs := newFlagSet(etlAnalyzeCommand)
+	registerProviderDBFlags(fs)
+	fs.IntVar(&top, "top", 10, "Event IDs to show per provider, 0 for all")
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	targets := parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if len(targets) == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one autologger name or ETL file is required")
+	}
+
+	var reports []*ETLReport
+	var summary reportSummary
+	for _, target := range targets {
+		report, err := analyzeETL(target)
+		if err != nil {
+			slog.Warn("skipping log file", "target", target, "error", err)
+			summary.skipped++
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			return err
+		}
+	} else {
+		pal := newPalette(os.Stdout, output.color)
+		for _, report := range reports {
+			displayETLReport(os.Stdout, report, top, pal)
+		}
+	}
+	return summary.exitStatus()
+}
+
+// displayETLReport prints the providers and event IDs recorded in an ETL
+// file.
+func displayETLReport(w io.Writer, report *ETLReport, top int, pal palette) {
+	title := report.Path
+	if report.Autologger != "" {
+		title = fmt.Sprintf("%s (%s)", report.Path, report.Autologger)
+	}
+	fmt.Fprintf(w, "ETL File %s:\n", title)
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	fmt.Fprintf(w, "- Size: %s\n", formatKB(uint64(report.Size)/1024))
+	fmt.Fprintf(w, "- Events: %d\n", report.Events)
+	if report.ClassicEvents > 0 {
+		fmt.Fprintf(w, "- MOF or WPP Events: %d, not attributed to a provider\n", report.ClassicEvents)
+	}
+	if report.Start != nil {
+		fmt.Fprintf(w, "- Time Span: %s to %s (%s)\n",
+			report.Start.Format("2006-01-02 15:04:05 MST"), report.End.Format("2006-01-02 15:04:05 MST"),
+			formatSeconds(report.End.Sub(*report.Start).Seconds()))
+	}
+	fmt.Fprintln(w)
+
+	writeProviderSampleTable(w, report.Providers, pal)
+	writeEventSamples(w, report.Providers, top)
+	fmt.Fprintln(w)
+}
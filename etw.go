@@ -0,0 +1,587 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modadvapi32        = windows.NewLazySystemDLL("advapi32.dll")
+	procControlTrace   = modadvapi32.NewProc("ControlTraceW")
+	procQueryAllTraces = modadvapi32.NewProc("QueryAllTracesW")
+	procStartTrace     = modadvapi32.NewProc("StartTraceW")
+	procEnableTraceEx2 = modadvapi32.NewProc("EnableTraceEx2")
+
+	procEnumerateTraceGuidsEx = modadvapi32.NewProc("EnumerateTraceGuidsEx")
+)
+
+const (
+	eventTraceControlQuery  = 0
+	eventTraceControlStop   = 1
+	eventTraceControlUpdate = 2
+	eventTraceControlFlush  = 3
+
+	eventTraceRealTimeMode = 0x00000100
+
+	eventControlCodeEnableProvider = 1
+	enableTraceParametersVersion2  = 2
+
+	traceGuidQueryList  = 0  // TRACE_QUERY_INFO_CLASS TraceGuidQueryList
+	traceGuidQueryInfo  = 1  // TRACE_QUERY_INFO_CLASS TraceGuidQueryInfo
+	traceGroupQueryList = 12 // TRACE_QUERY_INFO_CLASS TraceGroupQueryList
+	traceGroupQueryInfo = 13 // TRACE_QUERY_INFO_CLASS TraceGroupQueryInfo
+
+	// traceProviderFlagPreEnable marks an instance that a session enabled
+	// before any process registered the provider.
+	traceProviderFlagPreEnable = 0x2
+
+	wnodeFlagTracedGUID = 0x00020000
+
+	// maxSessionNameLen is the room reserved for the logger and log file
+	// names that ControlTrace appends after EVENT_TRACE_PROPERTIES.
+	maxSessionNameLen = 1024
+
+	// maxLoggers is the number of sessions Windows runs by default.
+	// QueryAllTraces asks for more room when the limit has been raised.
+	maxLoggers = 64
+)
+
+// errSessionNotRunning is returned when no live trace session has the name.
+var errSessionNotRunning = errors.New("session is not running")
+
+type wnodeHeader struct {
+	BufferSize        uint32
+	ProviderId        uint32
+	HistoricalContext uint64
+	TimeStamp         int64
+	Guid              windows.GUID
+	ClientContext     uint32
+	Flags             uint32
+}
+
+// eventTraceProperties mirrors EVENT_TRACE_PROPERTIES.
+type eventTraceProperties struct {
+	Wnode               wnodeHeader
+	BufferSize          uint32
+	MinimumBuffers      uint32
+	MaximumBuffers      uint32
+	MaximumFileSize     uint32
+	LogFileMode         uint32
+	FlushTimer          uint32
+	EnableFlags         uint32
+	AgeLimit            int32
+	NumberOfBuffers     uint32
+	FreeBuffers         uint32
+	EventsLost          uint32
+	BuffersWritten      uint32
+	LogBuffersLost      uint32
+	RealTimeBuffersLost uint32
+	LoggerThreadId      uintptr
+	LogFileNameOffset   uint32
+	LoggerNameOffset    uint32
+}
+
+// SessionStats holds the runtime properties of a live trace session.
+type SessionStats struct {
+	Name                string `json:"name"`
+	LoggerID            uint16 `json:"logger_id"`
+	BufferSize          uint32 `json:"buffer_size"`
+	MinimumBuffers      uint32 `json:"minimum_buffers"`
+	MaximumBuffers      uint32 `json:"maximum_buffers"`
+	LogFileMode         uint32 `json:"log_file_mode"`
+	FlushTimer          uint32 `json:"flush_timer"`
+	NumberOfBuffers     uint32 `json:"number_of_buffers"`
+	FreeBuffers         uint32 `json:"free_buffers"`
+	EventsLost          uint32 `json:"events_lost"`
+	BuffersWritten      uint32 `json:"buffers_written"`
+	LogBuffersLost      uint32 `json:"log_buffers_lost"`
+	RealTimeBuffersLost uint32 `json:"realtime_buffers_lost"`
+}
+
+// newTraceProperties allocates an EVENT_TRACE_PROPERTIES block followed by
+// space for the logger and log file names.
+func newTraceProperties() *eventTraceProperties {
+	size := unsafe.Sizeof(eventTraceProperties{}) + 2*maxSessionNameLen*2
+	buf := make([]byte, size)
+	props := (*eventTraceProperties)(unsafe.Pointer(&buf[0]))
+	props.Wnode.BufferSize = uint32(size)
+	props.Wnode.Flags = wnodeFlagTracedGUID
+	props.LoggerNameOffset = uint32(unsafe.Sizeof(eventTraceProperties{}))
+	props.LogFileNameOffset = props.LoggerNameOffset + maxSessionNameLen*2
+	return props
+}
+
+func controlTrace(handle uint64, name string, props *eventTraceProperties, code uint32) error {
+	var namePtr *uint16
+	if name != "" {
+		var err error
+		namePtr, err = windows.UTF16PtrFromString(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r := sysControlTrace(handle, namePtr, props, code); r != 0 {
+		return windows.Errno(r)
+	}
+	return nil
+}
+
+// startTrace starts a trace session with the given properties and returns
+// its handle.
+func startTrace(name string, props *eventTraceProperties) (uint64, error) {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	var handle uint64
+	r, _, _ := procStartTrace.Call(
+		uintptr(unsafe.Pointer(&handle)),
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(props)))
+	if r != 0 {
+		return 0, windows.Errno(r)
+	}
+	return handle, nil
+}
+
+// stopTrace stops the live session with the given name and returns its
+// final statistics.
+func stopTrace(name string) (*SessionStats, error) {
+	props := newTraceProperties()
+	if err := controlTrace(0, name, props, eventTraceControlStop); err != nil {
+		if err == windows.ERROR_WMI_INSTANCE_NOT_FOUND {
+			return nil, errSessionNotRunning
+		}
+		return nil, err
+	}
+	return newSessionStats(props), nil
+}
+
+// flushTrace writes the buffers of the live session with the given name to
+// its log file and returns its properties.
+func flushTrace(name string) (*eventTraceProperties, error) {
+	props := newTraceProperties()
+	if err := controlTrace(0, name, props, eventTraceControlFlush); err != nil {
+		if err == windows.ERROR_WMI_INSTANCE_NOT_FOUND {
+			return nil, errSessionNotRunning
+		}
+		return nil, err
+	}
+	return props, nil
+}
+
+// updateTrace changes the maximum number of buffers and the flush timer of
+// the live session with the given name, and returns its properties after
+// the update. The other settings, the log file included, are kept.
+func updateTrace(name string, maximumBuffers, flushTimer uint32) (*eventTraceProperties, error) {
+	props, err := querySessionProperties(name)
+	if err != nil {
+		return nil, err
+	}
+	props.MaximumBuffers = maximumBuffers
+	props.FlushTimer = flushTimer
+	// A log file name would switch the session to a new file.
+	props.LogFileNameOffset = 0
+	if err := controlTrace(0, name, props, eventTraceControlUpdate); err != nil {
+		return nil, err
+	}
+	return querySessionProperties(name)
+}
+
+// enableTraceParameters mirrors ENABLE_TRACE_PARAMETERS, without filters.
+type enableTraceParameters struct {
+	Version          uint32
+	EnableProperty   uint32
+	ControlFlags     uint32
+	SourceId         windows.GUID
+	EnableFilterDesc uintptr
+	FilterDescCount  uint32
+}
+
+// enableTrace enables a provider in the session with the given handle. The
+// enable property selects the extended data to collect, or that the GUID
+// is a provider group.
+func enableTrace(handle uint64, guid string, level uint8, matchAnyKeyword, matchAllKeyword uint64, enableProperty uint32) error {
+	g, err := windows.GUIDFromString(guid)
+	if err != nil {
+		return err
+	}
+	params := enableTraceParameters{Version: enableTraceParametersVersion2, EnableProperty: enableProperty}
+	if r := sysEnableTraceEx2(handle, &g, eventControlCodeEnableProvider, level, matchAnyKeyword, matchAllKeyword, &params); r != 0 {
+		return windows.Errno(r)
+	}
+	return nil
+}
+
+// querySession returns the runtime statistics of the live session with the
+// given name, or errSessionNotRunning.
+func querySession(name string) (*SessionStats, error) {
+	props, err := querySessionProperties(name)
+	if err != nil {
+		return nil, err
+	}
+	return newSessionStats(props), nil
+}
+
+// querySessionProperties returns the EVENT_TRACE_PROPERTIES of the live
+// session with the given name, or errSessionNotRunning.
+func querySessionProperties(name string) (*eventTraceProperties, error) {
+	props := newTraceProperties()
+	if err := controlTrace(0, name, props, eventTraceControlQuery); err != nil {
+		if err == windows.ERROR_WMI_INSTANCE_NOT_FOUND {
+			return nil, errSessionNotRunning
+		}
+		return nil, err
+	}
+	return props, nil
+}
+
+// newSessionStats copies the runtime properties of a session.
+func newSessionStats(props *eventTraceProperties) *SessionStats {
+	stats := &SessionStats{
+		Name:                traceString(props, props.LoggerNameOffset),
+		LoggerID:            uint16(props.Wnode.HistoricalContext),
+		BufferSize:          props.BufferSize,
+		MinimumBuffers:      props.MinimumBuffers,
+		MaximumBuffers:      props.MaximumBuffers,
+		LogFileMode:         props.LogFileMode,
+		FlushTimer:          props.FlushTimer,
+		NumberOfBuffers:     props.NumberOfBuffers,
+		FreeBuffers:         props.FreeBuffers,
+		EventsLost:          props.EventsLost,
+		BuffersWritten:      props.BuffersWritten,
+		LogBuffersLost:      props.LogBuffersLost,
+		RealTimeBuffersLost: props.RealTimeBuffersLost,
+	}
+	return stats
+}
+
+// filetimeToTime converts a FILETIME held in an int64 to UTC time.
+func filetimeToTime(ts int64) time.Time {
+	ft := windows.Filetime{LowDateTime: uint32(ts), HighDateTime: uint32(ts >> 32)}
+	return time.Unix(0, ft.Nanoseconds()).UTC()
+}
+
+// queryAllTraces returns the properties of every live trace session.
+func queryAllTraces() ([]*eventTraceProperties, error) {
+	count := uint32(maxLoggers)
+	for {
+		props := make([]*eventTraceProperties, count)
+		for i := range props {
+			props[i] = newTraceProperties()
+		}
+		var found uint32
+		r, _, _ := procQueryAllTraces.Call(
+			uintptr(unsafe.Pointer(&props[0])),
+			uintptr(count),
+			uintptr(unsafe.Pointer(&found)))
+		switch {
+		case r == 0:
+			return props[:min(found, count)], nil
+		case windows.Errno(r) == windows.ERROR_MORE_DATA && found > count:
+			count = found
+		default:
+			return nil, windows.Errno(r)
+		}
+	}
+}
+
+// traceString reads a name ControlTrace or QueryAllTraces wrote after
+// EVENT_TRACE_PROPERTIES.
+func traceString(props *eventTraceProperties, offset uint32) string {
+	if offset == 0 {
+		return ""
+	}
+	return windows.UTF16PtrToString((*uint16)(unsafe.Add(unsafe.Pointer(props), offset)))
+}
+
+// setTraceString writes a name after EVENT_TRACE_PROPERTIES for StartTrace.
+// Names longer than the room newTraceProperties reserves are rejected.
+func setTraceString(props *eventTraceProperties, offset uint32, s string) error {
+	name, err := windows.UTF16FromString(s)
+	if err != nil {
+		return err
+	}
+	if len(name) > maxSessionNameLen {
+		return fmt.Errorf("%q is longer than %d characters", s, maxSessionNameLen-1)
+	}
+	dst := unsafe.Slice((*uint16)(unsafe.Add(unsafe.Pointer(props), offset)), len(name))
+	copy(dst, name)
+	return nil
+}
+
+// registeredProviders caches the GUIDs of the providers registered on the
+// system, which are enumerated once per run.
+var registeredProviders struct {
+	once  sync.Once
+	guids []string
+}
+
+// getRegisteredProviderGUIDs returns the normalized GUIDs of the providers
+// that are currently registered with ETW, whether or not they have a
+// schema.
+func getRegisteredProviderGUIDs() []string {
+	registeredProviders.once.Do(func() {
+		guids, err := enumerateTraceGuids()
+		if err != nil {
+			slog.Warn("cannot enumerate registered providers", "error", err)
+		}
+		registeredProviders.guids = guids
+	})
+	return registeredProviders.guids
+}
+
+func enumerateTraceGuids() ([]string, error) {
+	return queryGUIDList(traceGuidQueryList)
+}
+
+// queryGUIDList returns the GUIDs of a TRACE_QUERY_INFO_CLASS that answers
+// with a plain array of GUIDs.
+func queryGUIDList(class uintptr) ([]string, error) {
+	if err := procEnumerateTraceGuidsEx.Find(); err != nil {
+		return nil, err
+	}
+	var buf []windows.GUID
+	var returned uint32
+	for {
+		var ptr *windows.GUID
+		if len(buf) > 0 {
+			ptr = &buf[0]
+		}
+		r, _, _ := procEnumerateTraceGuidsEx.Call(
+			class,
+			0,
+			0,
+			uintptr(unsafe.Pointer(ptr)),
+			uintptr(len(buf))*unsafe.Sizeof(windows.GUID{}),
+			uintptr(unsafe.Pointer(&returned)))
+		if windows.Errno(r) == windows.ERROR_INSUFFICIENT_BUFFER {
+			buf = make([]windows.GUID, returned/uint32(unsafe.Sizeof(windows.GUID{}))+1)
+			continue
+		}
+		if r != 0 {
+			return nil, windows.Errno(r)
+		}
+		break
+	}
+
+	count := int(returned / uint32(unsafe.Sizeof(windows.GUID{})))
+	guids := make([]string, 0, count)
+	for _, guid := range buf[:min(count, len(buf))] {
+		guids = append(guids, canonicalGUID(guid.String()))
+	}
+	return guids, nil
+}
+
+// providerGroups caches the GUIDs of the provider groups known to ETW.
+var providerGroups struct {
+	once  sync.Once
+	guids []string
+}
+
+// getProviderGroupGUIDs returns the normalized GUIDs of the provider groups
+// that registered providers currently belong to.
+func getProviderGroupGUIDs() []string {
+	providerGroups.once.Do(func() {
+		guids, err := queryGUIDList(traceGroupQueryList)
+		if err != nil {
+			slog.Debug("cannot enumerate provider groups", "error", err)
+		}
+		providerGroups.guids = guids
+	})
+	return providerGroups.guids
+}
+
+// getProviderGroupMembers returns the normalized GUIDs of the registered
+// providers that belong to a provider group.
+func getProviderGroupMembers(group string) ([]string, error) {
+	if err := procEnumerateTraceGuidsEx.Find(); err != nil {
+		return nil, err
+	}
+	g, err := windows.GUIDFromString(group)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1024)
+	var returned uint32
+	for {
+		r, _, _ := procEnumerateTraceGuidsEx.Call(
+			traceGroupQueryInfo,
+			uintptr(unsafe.Pointer(&g)),
+			unsafe.Sizeof(g),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&returned)))
+		if windows.Errno(r) == windows.ERROR_INSUFFICIENT_BUFFER {
+			buf = make([]byte, returned)
+			continue
+		}
+		if windows.Errno(r) == windows.ERROR_WMI_GUID_NOT_FOUND {
+			return nil, nil
+		}
+		if r != 0 {
+			return nil, windows.Errno(r)
+		}
+		break
+	}
+
+	// The member count is followed by the member GUIDs.
+	buf = buf[:returned]
+	if len(buf) < 4 {
+		return nil, nil
+	}
+	count := int(binary.LittleEndian.Uint32(buf))
+	size := int(unsafe.Sizeof(windows.GUID{}))
+	members := make([]string, 0, count)
+	for i := 0; i < count && 4+(i+1)*size <= len(buf); i++ {
+		guid := (*windows.GUID)(unsafe.Pointer(&buf[4+i*size]))
+		members = append(members, canonicalGUID(guid.String()))
+	}
+	return members, nil
+}
+
+// providerInstance is a registration of a provider, or a pre-enabled
+// placeholder, with the sessions that enable it.
+type providerInstance struct {
+	pid     uint32
+	flags   uint32
+	enables []traceEnableInfo
+}
+
+// traceEnableInfo mirrors the fields of TRACE_ENABLE_INFO that matter here.
+type traceEnableInfo struct {
+	loggerID        uint16
+	level           uint8
+	enableProperty  uint32
+	matchAnyKeyword uint64
+	matchAllKeyword uint64
+}
+
+// providerHasInstances reports whether a process currently has the provider
+// registered. Providers that sessions enable, such as the ones of running
+// autologgers, are listed by ETW even when nothing registered them; those
+// only have pre-enabled instances and don't count.
+func providerHasInstances(guid string) (bool, error) {
+	instances, err := queryProviderInstances(guid)
+	if err != nil {
+		return false, err
+	}
+	for _, instance := range instances {
+		if instance.flags&traceProviderFlagPreEnable == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// queryProviderInstances returns the instances of a provider ETW knows, or
+// nil if it knows none.
+func queryProviderInstances(guid string) ([]providerInstance, error) {
+	if err := procEnumerateTraceGuidsEx.Find(); err != nil {
+		return nil, err
+	}
+	g, err := windows.GUIDFromString(guid)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	var returned uint32
+	for {
+		r, _, _ := procEnumerateTraceGuidsEx.Call(
+			traceGuidQueryInfo,
+			uintptr(unsafe.Pointer(&g)),
+			unsafe.Sizeof(g),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&returned)))
+		if windows.Errno(r) == windows.ERROR_INSUFFICIENT_BUFFER {
+			buf = make([]byte, returned)
+			continue
+		}
+		if windows.Errno(r) == windows.ERROR_WMI_GUID_NOT_FOUND {
+			return nil, nil
+		}
+		if r != 0 {
+			return nil, windows.Errno(r)
+		}
+		break
+	}
+
+	// TRACE_GUID_INFO is followed by a TRACE_PROVIDER_INSTANCE_INFO per
+	// instance: NextOffset, EnableCount, Pid and Flags, then EnableCount
+	// TRACE_ENABLE_INFO entries of 32 bytes.
+	buf = buf[:returned]
+	if len(buf) < 8 {
+		return nil, nil
+	}
+	count := binary.LittleEndian.Uint32(buf)
+	var instances []providerInstance
+	offset := 8
+	for i := uint32(0); i < count && offset+16 <= len(buf); i++ {
+		entry := buf[offset:]
+		instance := providerInstance{
+			pid:   binary.LittleEndian.Uint32(entry[8:]),
+			flags: binary.LittleEndian.Uint32(entry[12:]),
+		}
+		enables := int(binary.LittleEndian.Uint32(entry[4:]))
+		for j := 0; j < enables && 16+(j+1)*32 <= len(entry); j++ {
+			info := entry[16+j*32:]
+			if binary.LittleEndian.Uint32(info) == 0 {
+				continue
+			}
+			instance.enables = append(instance.enables, traceEnableInfo{
+				level:           info[4],
+				loggerID:        binary.LittleEndian.Uint16(info[6:]),
+				enableProperty:  binary.LittleEndian.Uint32(info[8:]),
+				matchAnyKeyword: binary.LittleEndian.Uint64(info[16:]),
+				matchAllKeyword: binary.LittleEndian.Uint64(info[24:]),
+			})
+		}
+		instances = append(instances, instance)
+		next := int(binary.LittleEndian.Uint32(entry))
+		if next == 0 {
+			break
+		}
+		offset += next
+	}
+	return instances, nil
+}
+
+// getSessionName returns the name of the live session with a logger ID.
+func getSessionName(loggerID uint16) (string, error) {
+	props := newTraceProperties()
+	if err := controlTrace(uint64(loggerID), "", props, eventTraceControlQuery); err != nil {
+		return "", err
+	}
+	return traceString(props, props.LoggerNameOffset), nil
+}
+
+// getSessionEnables returns the providers a live session enables, keyed by
+// normalized GUID, with the parameters it enabled them with. Every
+// registered provider is queried, as ETW has no per-session list.
+func getSessionEnables(loggerID uint16) map[string]traceEnableInfo {
+	enables := make(map[string]traceEnableInfo)
+	for _, guid := range getRegisteredProviderGUIDs() {
+		instances, err := queryProviderInstances(guid)
+		if err != nil {
+			slog.Debug("cannot query provider instances", "provider", guid, "error", err)
+			continue
+		}
+		for _, instance := range instances {
+			for _, enable := range instance.enables {
+				if enable.loggerID == loggerID {
+					enables[guid] = enable
+				}
+			}
+		}
+	}
+	return enables
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Modes of an event ID filter. A provider whose Filters values disagree on
+// the mode is "mixed", its EventIDLists tell which IDs are which.
+const (
+	eventIDFilterInclude = "include"
+	eventIDFilterExclude = "exclude"
+	eventIDFilterMixed   = "mixed"
+)
+
+// EventIDList is the event IDs of one value of a Filters key.
+type EventIDList struct {
+	Value string `json:"value"`
+	// Mode is "include" or "exclude" for an EVENT_FILTER_EVENT_ID, and
+	// empty for a DWORD holding a single event ID.
+	Mode     string `json:"mode,omitempty"`
+	EventIDs []int  `json:"event_ids"`
+}
+
+// eventIDFilter is a decoded EVENT_FILTER_EVENT_ID:
+//
+//	typedef struct _EVENT_FILTER_EVENT_ID {
+//	    BOOLEAN FilterIn;
+//	    UCHAR   Reserved;
+//	    USHORT  Count;
+//	    USHORT  Events[ANYSIZE_ARRAY];
+//	} EVENT_FILTER_EVENT_ID;
+//
+// With FilterIn set only the listed events are logged, otherwise the listed
+// events are dropped.
+type eventIDFilter struct {
+	filterIn bool
+	ids      []int
+	// warnings describe data that is inconsistent with the structure or
+	// its limits, but doesn't prevent decoding it.
+	warnings []string
+}
+
+// maxFilterEventIDs is MAX_EVENT_FILTER_EVENT_ID_COUNT, EnableTraceEx2
+// rejects event ID filters with more events.
+const maxFilterEventIDs = 64
+
+func (f eventIDFilter) mode() string {
+	if f.filterIn {
+		return eventIDFilterInclude
+	}
+	return eventIDFilterExclude
+}
+
+// parseEventIDFilter decodes an EVENT_FILTER_EVENT_ID blob.
+func parseEventIDFilter(data []byte) (eventIDFilter, error) {
+	if len(data) < 4 {
+		return eventIDFilter{}, fmt.Errorf("EVENT_FILTER_EVENT_ID needs at least 4 bytes, got %d", len(data))
+	}
+	count := int(binary.LittleEndian.Uint16(data[2:4]))
+	need := 4 + 2*count
+	if len(data) < need {
+		return eventIDFilter{}, fmt.Errorf("EVENT_FILTER_EVENT_ID with %d events needs %d bytes, got %d", count, need, len(data))
+	}
+
+	filter := eventIDFilter{filterIn: data[0] != 0, ids: make([]int, count)}
+	seen := make(map[int]bool)
+	var duplicates []int
+	for i := range filter.ids {
+		id := int(binary.LittleEndian.Uint16(data[4+2*i:]))
+		if seen[id] && !slices.Contains(duplicates, id) {
+			duplicates = append(duplicates, id)
+		}
+		seen[id] = true
+		filter.ids[i] = id
+	}
+
+	if data[0] > 1 {
+		filter.warnings = append(filter.warnings, fmt.Sprintf("FilterIn is %d, expected 0 or 1", data[0]))
+	}
+	if data[1] != 0 {
+		filter.warnings = append(filter.warnings, fmt.Sprintf("reserved byte is 0x%02X, expected 0", data[1]))
+	}
+	if len(data) > need {
+		filter.warnings = append(filter.warnings, fmt.Sprintf("%d bytes follow the %d events the header declares", len(data)-need, count))
+	}
+	if count == 0 {
+		filter.warnings = append(filter.warnings, "the filter contains no event IDs")
+	}
+	if count > maxFilterEventIDs {
+		filter.warnings = append(filter.warnings, fmt.Sprintf("%d event IDs exceed MAX_EVENT_FILTER_EVENT_ID_COUNT (%d), EnableTraceEx2 rejects the filter", count, maxFilterEventIDs))
+	}
+	if len(duplicates) > 0 {
+		filter.warnings = append(filter.warnings, fmt.Sprintf("duplicate event IDs: %s", joinInts(duplicates, ", ")))
+	}
+	return filter, nil
+}
+
+// eventIDValueNames are the Filters values event IDs are read from.
+var eventIDValueNames = []string{"EventIds", "EventId", "Events", "Id"}
+
+// providersWithWarnings returns the providers with filter warnings.
+func providersWithWarnings(providers []ETWProvider) []ETWProvider {
+	var with []ETWProvider
+	for _, p := range providers {
+		if len(p.Warnings) > 0 {
+			with = append(with, p)
+		}
+	}
+	return with
+}
+
+// readFilters reads the Filters key of a provider: whether it is enabled and
+// the event IDs it filters on. Windows documents no value names for the other
+// filter descriptors, so they aren't decoded; with raw set, every value is
+// kept as is. A missing Filters key is not an error.
+func readFilters(parentKey registry.Key, providerGUID string, provider *ETWProvider, raw bool) error {
+	filtersKey, err := openKey(parentKey, providerGUID+`\Filters`, registry.READ)
+	if err != nil {
+		if err == registry.ErrNotExist {
+			return nil
+		}
+		slog.Warn("cannot open provider filters", "provider", providerGUID, "error", err)
+		return fmt.Errorf("reading filters: %v", err)
+	}
+	defer filtersKey.Close()
+	provider.HasFilters = true
+
+	if enabledVal, _, err := filtersKey.GetIntegerValue("Enabled"); err == nil {
+		provider.setEnabled(enabledVal)
+	}
+
+	var eventIDs []int
+	var errs []string
+	for _, valueName := range eventIDValueNames {
+		if dwordVal, _, err := filtersKey.GetIntegerValue(valueName); err == nil {
+			if dwordVal <= 65535 {
+				eventIDs = append(eventIDs, int(dwordVal))
+				provider.EventIDLists = append(provider.EventIDLists, EventIDList{Value: valueName, EventIDs: []int{int(dwordVal)}})
+			} else {
+				provider.Warnings = append(provider.Warnings, fmt.Sprintf("%s: %d is not a valid event ID, ignored", valueName, dwordVal))
+			}
+			continue
+		}
+
+		data, _, err := filtersKey.GetBinaryValue(valueName)
+		if err != nil {
+			continue
+		}
+		filter, err := parseEventIDFilter(data)
+		if err != nil {
+			slog.Warn("cannot parse event ID filter", "provider", providerGUID, "value", valueName, "error", err)
+			errs = append(errs, fmt.Sprintf("parsing %s: %v", valueName, err))
+			continue
+		}
+		for _, warning := range filter.warnings {
+			provider.Warnings = append(provider.Warnings, valueName+": "+warning)
+		}
+		switch provider.EventIDFilter {
+		case "":
+			provider.EventIDFilter = filter.mode()
+		case filter.mode(), eventIDFilterMixed:
+		default:
+			provider.Warnings = append(provider.Warnings, fmt.Sprintf("%s: %ss events while an earlier value %ss them", valueName, filter.mode(), provider.EventIDFilter))
+			provider.EventIDFilter = eventIDFilterMixed
+		}
+		provider.EventIDLists = append(provider.EventIDLists, EventIDList{Value: valueName, Mode: filter.mode(), EventIDs: filter.ids})
+		eventIDs = append(eventIDs, filter.ids...)
+	}
+	eventIDs = removeDuplicates(eventIDs)
+	sort.Ints(eventIDs)
+	provider.EventIDs = eventIDs
+
+	if raw {
+		values, err := readRawFilterValues(filtersKey)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		provider.RawFilters = values
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("reading filters: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// getEventIDFilterLabel prefixes a rendered list of event IDs with whether
+// they are included or excluded. When the values of the Filters key disagree,
+// each value is rendered with its own mode, e.g.
+// "Include: 1-5 (EventIds); Exclude: 7 (Events)".
+func getEventIDFilterLabel(provider ETWProvider, ids string) string {
+	switch provider.EventIDFilter {
+	case eventIDFilterInclude:
+		return "Include: " + ids
+	case eventIDFilterExclude:
+		return "Exclude: " + ids
+	case eventIDFilterMixed:
+		var parts []string
+		for _, l := range provider.EventIDLists {
+			part := fmt.Sprintf("%s (%s)", formatEventIDRanges(l.EventIDs), l.Value)
+			switch l.Mode {
+			case eventIDFilterInclude:
+				part = "Include: " + part
+			case eventIDFilterExclude:
+				part = "Exclude: " + part
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, "; ")
+	}
+	return ids
+}
+
+// formatEventIDRanges renders event IDs sorted, with runs of three or more
+// consecutive IDs compressed to ranges, e.g. "1-20, 23, 40-45". Long filters
+// are mostly runs, the compressed form keeps them readable in the tables.
+// Machine-readable output keeps the full list.
+func formatEventIDRanges(ids []int) string {
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		switch {
+		case j-i >= 2:
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		case j > i:
+			parts = append(parts, fmt.Sprintf("%d", sorted[i]), fmt.Sprintf("%d", sorted[j]))
+		default:
+			parts = append(parts, fmt.Sprintf("%d", sorted[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// On 32-bit Windows, a 64-bit argument takes two stack slots, its low half
+// first, and a 64-bit result is returned in EDX:EAX, so TRACEHANDLEs and
+// keyword masks are split and joined here.
+
+// traceLogfileHeaderSize is the size of TRACE_LOGFILE_HEADER, which holds
+// two pointers.
+const traceLogfileHeaderSize = 272
+
+// sysControlTrace calls ControlTraceW and returns its status.
+func sysControlTrace(handle uint64, name *uint16, props *eventTraceProperties, code uint32) uintptr {
+	r, _, _ := procControlTrace.Call(
+		uintptr(handle), uintptr(handle>>32),
+		uintptr(unsafe.Pointer(name)),
+		uintptr(unsafe.Pointer(props)),
+		uintptr(code))
+	return r
+}
+
+// sysEnableTraceEx2 calls EnableTraceEx2 without a timeout and returns its
+// status.
+func sysEnableTraceEx2(handle uint64, guid *windows.GUID, code uint32, level uint8, matchAnyKeyword, matchAllKeyword uint64, params *enableTraceParameters) uintptr {
+	r, _, _ := procEnableTraceEx2.Call(
+		uintptr(handle), uintptr(handle>>32),
+		uintptr(unsafe.Pointer(guid)),
+		uintptr(code),
+		uintptr(level),
+		uintptr(matchAnyKeyword), uintptr(matchAnyKeyword>>32),
+		uintptr(matchAllKeyword), uintptr(matchAllKeyword>>32),
+		0,
+		uintptr(unsafe.Pointer(params)))
+	return r
+}
+
+// sysOpenTrace calls OpenTraceW and returns the handle, which is
+// invalidProcessTraceHandle on failure, and the last error.
+func sysOpenTrace(logfile *eventTraceLogfile) (uint64, error) {
+	low, high, lastErr := procOpenTrace.Call(uintptr(unsafe.Pointer(logfile)))
+	if low == ^uintptr(0) && high == 0 {
+		// Older systems return INVALID_HANDLE_VALUE zero-extended.
+		return invalidProcessTraceHandle, lastErr
+	}
+	return uint64(low) | uint64(high)<<32, lastErr
+}
+
+// closeTrace calls CloseTrace.
+func closeTrace(handle uint64) {
+	procCloseTrace.Call(uintptr(handle), uintptr(handle>>32))
+}
@@ -0,0 +1,54 @@
+//go:build amd64 || arm64
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// The ETW functions that take a TRACEHANDLE or a keyword mask, whose 64-bit
+// values fit in a single argument on 64-bit Windows. etwsys_386.go has their
+// 32-bit counterparts.
+
+// traceLogfileHeaderSize is the size of TRACE_LOGFILE_HEADER, which holds
+// two pointers.
+const traceLogfileHeaderSize = 280
+
+// sysControlTrace calls ControlTraceW and returns its status.
+func sysControlTrace(handle uint64, name *uint16, props *eventTraceProperties, code uint32) uintptr {
+	r, _, _ := procControlTrace.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(name)),
+		uintptr(unsafe.Pointer(props)),
+		uintptr(code))
+	return r
+}
+
+// sysEnableTraceEx2 calls EnableTraceEx2 without a timeout and returns its
+// status.
+func sysEnableTraceEx2(handle uint64, guid *windows.GUID, code uint32, level uint8, matchAnyKeyword, matchAllKeyword uint64, params *enableTraceParameters) uintptr {
+	r, _, _ := procEnableTraceEx2.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(guid)),
+		uintptr(code),
+		uintptr(level),
+		uintptr(matchAnyKeyword),
+		uintptr(matchAllKeyword),
+		0,
+		uintptr(unsafe.Pointer(params)))
+	return r
+}
+
+// sysOpenTrace calls OpenTraceW and returns the handle, which is
+// invalidProcessTraceHandle on failure, and the last error.
+func sysOpenTrace(logfile *eventTraceLogfile) (uint64, error) {
+	r, _, lastErr := procOpenTrace.Call(uintptr(unsafe.Pointer(logfile)))
+	return uint64(r), lastErr
+}
+
+// closeTrace calls CloseTrace.
+func closeTrace(handle uint64) {
+	procCloseTrace.Call(uintptr(handle))
+}
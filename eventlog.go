@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+const (
+	eventLogSource  = "autologgerAnalyzer"
+	eventSourcePath = `SYSTEM\CurrentControlSet\Services\EventLog\Application\` + eventLogSource
+)
+
+// eventLogIDs assigns a stable event ID to every finding so WEF subscriptions
+// and SIEM rules can select on it. The source is registered with
+// EventCreate.exe as message file, which supports IDs 1-1000.
+var eventLogIDs = map[string]uint32{
+	"AUTOLOGGER_DISABLED":           100,
+	"AUTOLOGGER_NO_PROVIDERS":       101,
+	"PROVIDER_DISABLED":             102,
+	"CLOCK_TYPE_UNRELIABLE":         103,
+	"PROVIDER_FILTER_MALFORMED":     104,
+	"AUTOLOGGER_INVALID_SUBKEY":     105,
+	"PROVIDER_DUPLICATE_SUBKEY":     106,
+	"PROVIDER_CHANNEL_DISABLED":     107,
+	"PROVIDER_BINARY_MISSING":       108,
+	"PROVIDER_BINARY_UNSIGNED":      109,
+	"PROVIDER_ORPHANED":             110,
+	"PROVIDER_GROUP_NOT_ENABLED":    111,
+	"AUTOLOGGER_WEAK_ACL":           112,
+	"PROVIDER_RESOURCE_UNAVAILABLE": 113,
+	"SESSION_EVENTS_LOST":           114,
+	"AUTOLOGGER_NOT_RUNNING":        115,
+	"PROVIDER_ENABLE_MISMATCH":      116,
+	"SYSTEM_LOGGER_SLOTS_EXHAUSTED": 117,
+	"AUTOLOGGER_REGISTRY_CHANGED":   118,
+	"AUTOLOGGER_REGISTRY_WRITE":     119,
+	"AUTOLOGGER_CONFIG_CHANGED":     120,
+	"AUTOLOGGER_TAMPERED":           121,
+	"AUTOLOGGER_CONFIG_DRIFT":       122,
+}
+
+// eventLogFallbackID is used for findings without an assigned ID.
+const eventLogFallbackID = 999
+
+// eventLogSink writes findings to the Application event log under the
+// autologgerAnalyzer source.
+type eventLogSink struct {
+	log *eventlog.Log
+}
+
+func newEventLogSink() (*eventLogSink, error) {
+	if err := ensureEventSource(); err != nil {
+		return nil, err
+	}
+
+	l, err := eventlog.Open(eventLogSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %v", err)
+	}
+
+	return &eventLogSink{log: l}, nil
+}
+
+// ensureEventSource registers the event source on first use. This requires
+// administrator privileges.
+func ensureEventSource() error {
+	key, err := openKey(registry.LOCAL_MACHINE, eventSourcePath, registry.QUERY_VALUE)
+	if err == nil {
+		key.Close()
+		return nil
+	}
+
+	if err := eventlog.InstallAsEventCreate(eventLogSource, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		return fmt.Errorf("failed to register event source %s: %v", eventLogSource, err)
+	}
+	return nil
+}
+
+func (e *eventLogSink) WriteReport(report *AutologgerReport) error {
+	for _, f := range report.Findings {
+		if err := e.writeFinding(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFinding logs a finding with its fields as key: value lines so they can
+// be extracted from the rendered message.
+func (e *eventLogSink) writeFinding(f Finding) error {
+	id, ok := eventLogIDs[f.ID]
+	if !ok {
+		id = eventLogFallbackID
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%s\r\n\r\n", f.Message)
+	fmt.Fprintf(&msg, "Finding: %s\r\n", f.ID)
+	fmt.Fprintf(&msg, "Severity: %s\r\n", f.Severity)
+	fmt.Fprintf(&msg, "Autologger: %s\r\n", f.Autologger)
+	if f.ProviderGUID != "" {
+		fmt.Fprintf(&msg, "ProviderGUID: %s\r\n", f.ProviderGUID)
+		fmt.Fprintf(&msg, "ProviderName: %s\r\n", f.ProviderName)
+	}
+
+	var err error
+	switch f.Severity {
+	case SeverityHigh:
+		err = e.log.Error(id, msg.String())
+	case SeverityMedium:
+		err = e.log.Warning(id, msg.String())
+	default:
+		err = e.log.Info(id, msg.String())
+	}
+	if err != nil {
+		return fmt.Errorf("event log: %v", err)
+	}
+	return nil
+}
+
+func (e *eventLogSink) Close() error {
+	return e.log.Close()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// providerFilter restricts which providers appear in a report.
+type providerFilter struct {
+	enabledOnly bool
+	nameMatch   string
+	guids       stringList
+}
+
+func (f *providerFilter) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.enabledOnly, "enabled-only", false, "Only show enabled providers")
+	fs.StringVar(&f.nameMatch, "provider-name-match", "", "Only show providers whose name matches this glob (case-insensitive, e.g. '*Kernel*')")
+	fs.Var(&f.guids, "guid", "Only show the provider with this GUID; comma separated or repeated for several")
+}
+
+func (f *providerFilter) validate() error {
+	if _, err := path.Match(f.nameMatch, ""); err != nil {
+		return fmt.Errorf("invalid -provider-name-match pattern %q: %v", f.nameMatch, err)
+	}
+	return nil
+}
+
+func (f *providerFilter) active() bool {
+	return f.enabledOnly || f.nameMatch != "" || len(f.guids) > 0
+}
+
+func (f *providerFilter) matches(provider ETWProvider) bool {
+	if f.enabledOnly && !provider.Enabled {
+		return false
+	}
+	if f.nameMatch != "" {
+		if ok, _ := path.Match(strings.ToLower(f.nameMatch), strings.ToLower(provider.Name)); !ok {
+			return false
+		}
+	}
+	if len(f.guids) > 0 {
+		found := false
+		for _, guid := range f.guids {
+			if sameGUID(guid, provider.GUID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// apply removes the providers that don't match from the report, along with
+// the findings about them.
+func (f *providerFilter) apply(report *AutologgerReport) {
+	if !f.active() {
+		return
+	}
+
+	kept := make(map[string]bool)
+	var providers []ETWProvider
+	for _, provider := range report.Providers {
+		if f.matches(provider) {
+			providers = append(providers, provider)
+			kept[canonicalGUID(provider.GUID)] = true
+		}
+	}
+	report.Providers = providers
+
+	var findings []Finding
+	for _, finding := range report.Findings {
+		if finding.ProviderGUID == "" || kept[canonicalGUID(finding.ProviderGUID)] {
+			findings = append(findings, finding)
+		}
+	}
+	report.Findings = findings
+}
+
+// sameGUID compares two GUIDs ignoring case and surrounding braces.
+func sameGUID(a, b string) bool {
+	return strings.EqualFold(canonicalGUID(a), canonicalGUID(b))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// ProviderUsage describes how one autologger uses a provider.
+type ProviderUsage struct {
+	Autologger string      `json:"autologger"`
+	Start      uint64      `json:"start"`
+	Provider   ETWProvider `json:"provider"`
+}
+
+// collectProviderUsage scans every autologger and returns all provider
+// references, in autologger order.
+func collectProviderUsage() ([]ProviderUsage, error) {
+	names, err := getAutologgerNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []ProviderUsage
+	progress := newProgress(len(names))
+	defer progress.finish()
+	for _, name := range names {
+		report, err := analyzeAutologger(name, analyzeOptions{})
+		progress.step(name, report, err)
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			continue
+		}
+		for _, provider := range report.Providers {
+			usages = append(usages, ProviderUsage{Autologger: name, Start: report.Config.Start, Provider: provider})
+		}
+	}
+
+	return usages, nil
+}
+
+// findProviderUsage returns the autologgers that have a provider subkey for
+// guid.
+func findProviderUsage(guid string) ([]ProviderUsage, error) {
+	all, err := collectProviderUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []ProviderUsage
+	for _, u := range all {
+		if sameGUID(u.Provider.GUID, guid) {
+			usages = append(usages, u)
+		}
+	}
+	return usages, nil
+}
+
+func runFind(cmd *command, args []string) error {
+	var guids stringList
+	var format string
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.Var(&guids, "guid", "Provider GUID to look for")
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	guids = append(guids, parseArgs(fs, args)...)
+
+	if len(guids) != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one provider GUID is required")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	guid, ok := normalizeGUID(guids[0])
+	if !ok {
+		return fmt.Errorf("%q is not a GUID", guids[0])
+	}
+
+	usages, err := findProviderUsage(guid)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if usages == nil {
+			usages = []ProviderUsage{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(usages)
+	}
+
+	displayProviderUsage(os.Stdout, guid, usages)
+	return nil
+}
+
+func displayProviderUsage(w io.Writer, guid string, usages []ProviderUsage) {
+	fmt.Fprintf(w, "Provider %s (%s)\n", guid, resolveProviderName(guid))
+	if desc := getProviderDescription(guid); desc != "" {
+		fmt.Fprintf(w, "%s\n", desc)
+	}
+	fmt.Fprintf(w, "Referenced by %d autologger(s):\n\n", len(usages))
+	if len(usages) == 0 {
+		return
+	}
+	writeProviderUsageTable(w, usages)
+}
+
+// writeProviderUsageTable prints the level, keywords and event IDs every
+// autologger uses a provider with.
+func writeProviderUsageTable(w io.Writer, usages []ProviderUsage) {
+	fmt.Fprintf(w, "| %-35s | %-8s | %-8s | %-12s | %-18s | %-18s | %-20s |\n",
+		"Autologger", "Started", "Enabled", "Level", "MatchAnyKeyword", "MatchAllKeyword", "Event IDs")
+	fmt.Fprintf(w, "|%s|%s|%s|%s|%s|%s|%s|\n",
+		strings.Repeat("-", 37),
+		strings.Repeat("-", 10),
+		strings.Repeat("-", 10),
+		strings.Repeat("-", 14),
+		strings.Repeat("-", 20),
+		strings.Repeat("-", 20),
+		strings.Repeat("-", 22))
+
+	for _, u := range usages {
+		started := "No"
+		if u.Start != 0 {
+			started = "Yes"
+		}
+		enabled := getEnabledLabel(u.Provider)
+		eventIDs := "No Filters"
+		if u.Provider.HasFilters {
+			eventIDs = "No Event IDs"
+			if len(u.Provider.EventIDs) > 0 {
+				eventIDs = truncateString(getEventIDFilterLabel(u.Provider, formatEventIDRanges(u.Provider.EventIDs)), 20)
+			}
+		}
+
+		fmt.Fprintf(w, "| %-35s | %-8s | %-8s | %-12s | 0x%016X | 0x%016X | %-20s |\n",
+			truncateString(u.Autologger, 35),
+			started,
+			enabled,
+			getProviderLevelName(u.Provider),
+			u.Provider.MatchAnyKeyword,
+			u.Provider.MatchAllKeyword,
+			eventIDs)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Finding severities, in increasing order of importance.
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// Finding is a noteworthy observation about an autologger, such as a disabled
+// session or provider, that scheduled runs can forward to a SIEM.
+type Finding struct {
+	ID           string `json:"id"`
+	Severity     string `json:"severity"`
+	Autologger   string `json:"autologger"`
+	ProviderGUID string `json:"provider_guid,omitempty"`
+	ProviderName string `json:"provider_name,omitempty"`
+	Message      string `json:"message"`
+}
+
+// analyzeFindings inspects a report and returns the findings it raises.
+func analyzeFindings(report *AutologgerReport) []Finding {
+	var findings []Finding
+	config := report.Config
+
+	if config.Start == 0 {
+		findings = append(findings, Finding{
+			ID:         "AUTOLOGGER_DISABLED",
+			Severity:   SeverityHigh,
+			Autologger: config.Name,
+			Message:    fmt.Sprintf("Autologger %s is not started at boot (Start=0)", config.Name),
+		})
+	}
+
+	if isKernelSession(config) {
+		if len(report.Providers) == 0 && len(getKernelGroups(config)) == 0 {
+			findings = append(findings, Finding{
+				ID:         "AUTOLOGGER_NO_PROVIDERS",
+				Severity:   SeverityMedium,
+				Autologger: config.Name,
+				Message:    fmt.Sprintf("Kernel session %s enables no kernel event groups (EnableFlags is 0)", config.Name),
+			})
+		}
+	} else if len(report.Providers) == 0 {
+		findings = append(findings, Finding{
+			ID:         "AUTOLOGGER_NO_PROVIDERS",
+			Severity:   SeverityMedium,
+			Autologger: config.Name,
+			Message:    fmt.Sprintf("Autologger %s has no providers configured", config.Name),
+		})
+	}
+
+	for _, subkey := range report.InvalidSubkeys {
+		findings = append(findings, Finding{
+			ID:         "AUTOLOGGER_INVALID_SUBKEY",
+			Severity:   SeverityMedium,
+			Autologger: config.Name,
+			Message:    fmt.Sprintf("Autologger %s has a subkey %q that is not a provider GUID, a leftover or tampering artifact", config.Name, subkey),
+		})
+	}
+
+	if weak := weakSessionACEs(config.Security); len(weak) > 0 {
+		var grants []string
+		for _, ace := range weak {
+			grants = append(grants, getACEDescription(ace))
+		}
+		findings = append(findings, Finding{
+			ID:         "AUTOLOGGER_WEAK_ACL",
+			Severity:   SeverityHigh,
+			Autologger: config.Name,
+			Message: fmt.Sprintf("Session %s grants control to non-administrative accounts, which could tamper with its telemetry: %s",
+				config.Name, strings.Join(grants, "; ")),
+		})
+	}
+
+	if warning := systemLoggerSlotWarning(config); warning != "" {
+		findings = append(findings, Finding{
+			ID:         "SYSTEM_LOGGER_SLOTS_EXHAUSTED",
+			Severity:   SeverityHigh,
+			Autologger: config.Name,
+			Message:    fmt.Sprintf("Autologger %s requests a system logger slot, but %s", config.Name, warning),
+		})
+	}
+
+	if sessionStateFlagged(config) {
+		findings = append(findings, Finding{
+			ID:         "AUTOLOGGER_NOT_RUNNING",
+			Severity:   SeverityMedium,
+			Autologger: config.Name,
+			Message: fmt.Sprintf("Autologger %s starts at boot, but its session isn't running; it failed to start or was stopped since (Status: %s)",
+				config.Name, getStatusDescription(config.Status)),
+		})
+	}
+
+	if s := config.Session; s != nil && sessionLosing(s) {
+		findings = append(findings, Finding{
+			ID:         "SESSION_EVENTS_LOST",
+			Severity:   SeverityMedium,
+			Autologger: config.Name,
+			Message: fmt.Sprintf("The live session of autologger %s has lost %d events and %d buffers since it started; its BufferSize and MaximumBuffers may be too small for its providers",
+				config.Name, s.EventsLost, s.LogBuffersLost+s.RealTimeBuffersLost),
+		})
+	}
+
+	if warning := clockTypeWarning(config); warning != "" {
+		findings = append(findings, Finding{
+			ID:         "CLOCK_TYPE_UNRELIABLE",
+			Severity:   SeverityLow,
+			Autologger: config.Name,
+			Message:    fmt.Sprintf("Autologger %s uses %s", config.Name, warning),
+		})
+	}
+
+	if baseline, build := getBaselineAutologger(config.Name); baseline != nil {
+		var weakened, drifted []string
+		for _, row := range diffAutologgers(baseline, report, false) {
+			if weakensCollection(row) {
+				weakened = append(weakened, getDeviationDescription(row))
+			} else {
+				drifted = append(drifted, getDeviationDescription(row))
+			}
+		}
+		if len(weakened) > 0 {
+			findings = append(findings, Finding{
+				ID:         "AUTOLOGGER_TAMPERED",
+				Severity:   SeverityHigh,
+				Autologger: config.Name,
+				Message: fmt.Sprintf("Autologger %s collects less than on a clean install of Windows build %s: %s",
+					config.Name, build, strings.Join(weakened, "; ")),
+			})
+		}
+		if len(drifted) > 0 {
+			findings = append(findings, Finding{
+				ID:         "AUTOLOGGER_CONFIG_DRIFT",
+				Severity:   SeverityLow,
+				Autologger: config.Name,
+				Message: fmt.Sprintf("Autologger %s is configured differently from a clean install of Windows build %s: %s",
+					config.Name, build, strings.Join(drifted, "; ")),
+			})
+		}
+	}
+
+	for _, provider := range report.Providers {
+		if provider.EnabledState == enabledOff {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_DISABLED",
+				Severity:     SeverityMedium,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message:      fmt.Sprintf("Provider %s is disabled in autologger %s", provider.Name, config.Name),
+			})
+		}
+		if allChannelsDisabled(provider) {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_CHANNEL_DISABLED",
+				Severity:     SeverityMedium,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("Provider %s is collected by autologger %s, but its event log channels are disabled, so the Event Log service drops its events",
+					provider.Name, config.Name),
+			})
+		}
+		if b := provider.Binary; b != nil && b.Signature == signatureMissing {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_BINARY_MISSING",
+				Severity:     SeverityMedium,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("Provider %s in autologger %s is owned by %s, which doesn't exist; the provider may have been uninstalled or its registration tampered with",
+					provider.Name, config.Name, b.Path),
+			})
+		} else if binaryFlagged(b) {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_BINARY_UNSIGNED",
+				Severity:     SeverityMedium,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message:      fmt.Sprintf("Provider %s in autologger %s is owned by %s", provider.Name, config.Name, getBinaryDescription(b)),
+			})
+		}
+		if unusable := unusableResources(provider); len(unusable) > 0 {
+			descs := make([]string, len(unusable))
+			for i, r := range unusable {
+				descs[i] = getResourceDescription(r)
+			}
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_RESOURCE_UNAVAILABLE",
+				Severity:     SeverityLow,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("Events of provider %s in autologger %s can't be rendered by consumers: %s",
+					provider.Name, config.Name, strings.Join(descs, "; ")),
+			})
+		}
+		if len(provider.LiveMismatches) > 0 {
+			descs := make([]string, len(provider.LiveMismatches))
+			for i, d := range provider.LiveMismatches {
+				descs[i] = getLiveMismatchDescription(d)
+			}
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_ENABLE_MISMATCH",
+				Severity:     SeverityMedium,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("The running session of autologger %s enables provider %s differently from the registry, it was reconfigured after boot: %s",
+					config.Name, provider.Name, strings.Join(descs, "; ")),
+			})
+		}
+		if provider.ProviderType == providerTypeGroup && !groupEnabled(provider) {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_GROUP_NOT_ENABLED",
+				Severity:     SeverityMedium,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("%s in autologger %s is a provider group, but EnableProperty lacks PROVIDER_GROUP (0x20), so none of its %d member providers are enabled",
+					provider.GUID, config.Name, len(provider.GroupMembers)),
+			})
+		}
+		if provider.Orphaned {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_ORPHANED",
+				Severity:     SeverityLow,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("Provider %s in autologger %s is not registered on this host; the entry may be left over from an uninstalled product or copied from another Windows build, or belong to a WPP provider whose binary isn't running",
+					provider.Name, config.Name),
+			})
+		}
+		if len(provider.DuplicateKeys) > 0 {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_DUPLICATE_SUBKEY",
+				Severity:     SeverityLow,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("Provider %s is configured by several subkeys of autologger %s that differ only in formatting: %s",
+					provider.Name, config.Name, strings.Join(provider.DuplicateKeys, ", ")),
+			})
+		}
+		if len(provider.Warnings) > 0 {
+			findings = append(findings, Finding{
+				ID:           "PROVIDER_FILTER_MALFORMED",
+				Severity:     SeverityLow,
+				Autologger:   config.Name,
+				ProviderGUID: provider.GUID,
+				ProviderName: provider.Name,
+				Message: fmt.Sprintf("Provider %s in autologger %s has malformed filter data: %s",
+					provider.Name, config.Name, strings.Join(provider.Warnings, "; ")),
+			})
+		}
+	}
+
+	return findings
+}
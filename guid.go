@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// normalizeGUID returns a GUID in the form used throughout the tool:
+// lowercase with braces, e.g. {11cd958a-c507-4ef3-b3f2-5fd9dfbd2c78}. ok is
+// false if s isn't a GUID with or without braces.
+func normalizeGUID(s string) (guid string, ok bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") != strings.HasSuffix(s, "}") {
+		return "", false
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	if len(s) != 36 {
+		return "", false
+	}
+	for i, c := range s {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return "", false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return "", false
+		}
+	}
+	return "{" + strings.ToLower(s) + "}", true
+}
+
+// canonicalGUID normalizes s if it is a GUID and returns it unchanged
+// otherwise, for values that are only displayed or compared.
+func canonicalGUID(s string) string {
+	if guid, ok := normalizeGUID(s); ok {
+		return guid
+	}
+	return s
+}
+
+// withCanonicalGUIDs returns a copy of a report with its provider GUIDs in
+// canonical form, for the table, Markdown and HTML reports. The
+// machine-readable formats keep the GUIDs as the registry subkeys spell them,
+// so existing queries on them keep matching.
+func withCanonicalGUIDs(report *AutologgerReport) *AutologgerReport {
+	copied := *report
+	copied.Providers = slices.Clone(report.Providers)
+	for i := range copied.Providers {
+		copied.Providers[i].GUID = canonicalGUID(copied.Providers[i].GUID)
+	}
+	copied.Findings = slices.Clone(report.Findings)
+	for i := range copied.Findings {
+		if copied.Findings[i].ProviderGUID != "" {
+			copied.Findings[i].ProviderGUID = canonicalGUID(copied.Findings[i].ProviderGUID)
+		}
+	}
+	return &copied
+}
+
+// providerSubkey is a provider subkey of an autologger.
+type providerSubkey struct {
+	key        string   // name of the registry key
+	guid       string   // normalized provider GUID
+	duplicates []string // other keys with the same GUID in another format
+}
+
+// groupProviderSubkeys normalizes the subkey names of an autologger. Keys that
+// differ only in formatting are merged into the first one, keys that aren't
+// GUIDs at all are returned separately.
+func groupProviderSubkeys(names []string) (subkeys []providerSubkey, invalid []string) {
+	index := make(map[string]int)
+	for _, name := range names {
+		guid, ok := normalizeGUID(name)
+		if !ok {
+			invalid = append(invalid, name)
+			continue
+		}
+		if i, seen := index[guid]; seen {
+			subkeys[i].duplicates = append(subkeys[i].duplicates, name)
+			continue
+		}
+		index[guid] = len(subkeys)
+		subkeys = append(subkeys, providerSubkey{key: name, guid: guid})
+	}
+	return subkeys, invalid
+}
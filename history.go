@@ -0,0 +1,216 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryEntry is a change daemon recorded in the history database.
+type HistoryEntry struct {
+	Time       time.Time `json:"time"`
+	Host       string    `json:"host"`
+	Autologger string    `json:"autologger"`
+	// Change is "added" or "removed" for autologgers, or "changed" for a
+	// setting.
+	Change       string `json:"change"`
+	Setting      string `json:"setting,omitempty"`
+	Provider     string `json:"provider,omitempty"`
+	ProviderName string `json:"provider_name,omitempty"`
+	Old          string `json:"old,omitempty"`
+	New          string `json:"new,omitempty"`
+}
+
+// writeHistory adds a scan of daemon to the history database: the reports
+// as a snapshot, and the changes since the previous scan. All but the newest
+// keep snapshots are then deleted with their changes, unless keep is 0.
+func writeHistory(path string, host *HostMetadata, reports []*AutologgerReport, diff *SnapshotDiff, keep int) error {
+	w, err := newSQLiteReportWriter(path, host)
+	if err != nil {
+		return err
+	}
+	abort := func(err error) error {
+		w.tx.Rollback()
+		w.db.Close()
+		return err
+	}
+	for _, report := range reports {
+		if err := w.WriteReport(report); err != nil {
+			return abort(err)
+		}
+	}
+	if diff != nil {
+		if err := w.WriteChanges(diff); err != nil {
+			return abort(err)
+		}
+	}
+	if keep > 0 {
+		if err := w.prune(keep); err != nil {
+			return abort(err)
+		}
+	}
+	return w.Close()
+}
+
+// historyQuery selects changes from the history database.
+type historyQuery struct {
+	autologger string
+	provider   string
+	since      time.Time
+}
+
+// queryHistory returns the changes that match q, oldest first.
+func queryHistory(path string, q historyQuery) ([]HistoryEntry, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cannot open history database: %v", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	query := `SELECT s.collected_at, s.hostname, c.autologger, c.change, c.setting, c.provider, c.provider_name, c.old_value, c.new_value
+		FROM changes c JOIN snapshots s ON s.id = c.snapshot_id
+		WHERE s.collected_at >= ?`
+	// collected_at is stored as UTC in a fixed format, so it compares as
+	// text.
+	args := []any{q.since.UTC().Format("2006-01-02T15:04:05Z")}
+	if q.autologger != "" {
+		query += ` AND c.autologger = ? COLLATE NOCASE`
+		args = append(args, q.autologger)
+	}
+	if q.provider != "" {
+		query += ` AND c.provider = ? COLLATE NOCASE`
+		args = append(args, canonicalGUID(q.provider))
+	}
+	query += ` ORDER BY s.collected_at, c.id`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var e HistoryEntry
+		var collectedAt string
+		var setting, provider, providerName, old, new sql.NullString
+		if err := rows.Scan(&collectedAt, &e.Host, &e.Autologger, &e.Change, &setting, &provider, &providerName, &old, &new); err != nil {
+			return nil, fmt.Errorf("failed to read history: %v", err)
+		}
+		if e.Time, err = time.Parse("2006-01-02T15:04:05Z", collectedAt); err != nil {
+			return nil, fmt.Errorf("invalid time %q in history: %v", collectedAt, err)
+		}
+		e.Setting, e.Provider, e.ProviderName, e.Old, e.New = setting.String, provider.String, providerName.String, old.String, new.String
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// parseSince parses the age of the oldest change to show: a duration, or a
+// number of days such as "30d".
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid -since %q (expected e.g. 30d or 12h)", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid -since %q (expected e.g. 30d or 12h)", s)
+	}
+	return d, nil
+}
+
+func runHistory(cmd *command, args []string) error {
+	var dbPath, since, provider, format string
+
+	fs := newFlagSet(cmd)
+	fs.StringVar(&dbPath, "db", filepath.Join(defaultStateDir(), "history.db"), "History database written by daemon")
+	fs.StringVar(&since, "since", "30d", "Only show changes from this long ago on, e.g. 30d or 12h; 0 for all")
+	fs.StringVar(&provider, "provider", "", "Only show changes to the provider with this GUID")
+	fs.StringVar(&format, "format", "table", "Output format: table, json or csv")
+	names := parseArgs(fs, args)
+
+	if format != "table" && format != "json" && format != "csv" {
+		return fmt.Errorf("unknown output format %q (expected table, json or csv)", format)
+	}
+	if len(names) > 1 {
+		fs.Usage()
+		return fmt.Errorf("at most one autologger name is allowed")
+	}
+	age, err := parseSince(since)
+	if err != nil {
+		return err
+	}
+
+	q := historyQuery{provider: provider}
+	if len(names) == 1 {
+		q.autologger = names[0]
+	}
+	if age > 0 {
+		q.since = time.Now().Add(-age)
+	}
+	entries, err := queryHistory(dbPath, q)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "csv":
+		return writeHistoryCSV(os.Stdout, entries)
+	}
+	displayHistory(os.Stdout, entries)
+	return nil
+}
+
+// writeHistoryCSV writes the changes as CSV with a header row.
+func writeHistoryCSV(w io.Writer, entries []HistoryEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "host", "autologger", "change", "setting", "provider", "provider_name", "old", "new"})
+	for _, e := range entries {
+		cw.Write([]string{e.Time.Format(time.RFC3339), e.Host, e.Autologger, e.Change, e.Setting, e.Provider, e.ProviderName, e.Old, e.New})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// displayHistory prints a change per line, e.g. "2026-10-17 08:00 WS01
+// DefenderApiLogger: Start changed from Enabled (1) to Disabled (0)".
+func displayHistory(w io.Writer, entries []HistoryEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No changes recorded")
+		return
+	}
+	for _, e := range entries {
+		line := fmt.Sprintf("%s %s %s: ", e.Time.Local().Format("2006-01-02 15:04"), e.Host, e.Autologger)
+		switch {
+		case e.Change != "changed":
+			line += "autologger " + e.Change
+		case e.Provider != "":
+			name := e.ProviderName
+			if name == "" {
+				name = e.Provider
+			}
+			line += fmt.Sprintf("%s of %s changed from %s to %s", e.Setting, name, e.Old, e.New)
+		default:
+			line += fmt.Sprintf("%s changed from %s to %s", e.Setting, e.Old, e.New)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+const currentVersionPath = `SOFTWARE\Microsoft\Windows NT\CurrentVersion`
+
+// HostMetadata describes the machine and run a report was collected on. It is
+// embedded in every machine-readable output so results can be aggregated
+// across a fleet without guessing their provenance.
+type HostMetadata struct {
+	Hostname    string    `json:"hostname"`
+	Domain      string    `json:"domain"`
+	OSName      string    `json:"os_name"`
+	OSBuild     string    `json:"os_build"`
+	CollectedAt time.Time `json:"collected_at"`
+	ToolVersion string    `json:"tool_version"`
+	Elevated    bool      `json:"elevated"`
+	// RegistryView is the WOW64 registry view that was read, 64-bit
+	// unless -wow64-32 was given.
+	RegistryView string `json:"registry_view"`
+}
+
+func collectHostMetadata() *HostMetadata {
+	host := &HostMetadata{
+		CollectedAt:  time.Now().UTC(),
+		ToolVersion:  version,
+		Elevated:     windows.GetCurrentProcessToken().IsElevated(),
+		RegistryView: registryViewName(),
+	}
+
+	if name, err := os.Hostname(); err == nil {
+		host.Hostname = name
+	}
+	host.Domain = computerDNSDomain()
+
+	key, err := openKey(registry.LOCAL_MACHINE, currentVersionPath, registry.READ)
+	if err != nil {
+		return host
+	}
+	defer key.Close()
+
+	if val, _, err := key.GetStringValue("ProductName"); err == nil {
+		host.OSName = val
+	}
+	if val, _, err := key.GetStringValue("DisplayVersion"); err == nil && val != "" {
+		host.OSName += " " + val
+	}
+	if build, _, err := key.GetStringValue("CurrentBuild"); err == nil {
+		host.OSBuild = build
+		if ubr, _, err := key.GetIntegerValue("UBR"); err == nil {
+			host.OSBuild = fmt.Sprintf("%s.%d", build, ubr)
+		}
+	}
+
+	return host
+}
+
+// computerDNSDomain returns the DNS domain the computer is joined to, or an
+// empty string for workgroup machines.
+func computerDNSDomain() string {
+	n := uint32(256)
+	buf := make([]uint16, n)
+	if err := windows.GetComputerNameEx(windows.ComputerNameDnsDomain, &buf[0], &n); err != nil {
+		return ""
+	}
+	return windows.UTF16ToString(buf[:n])
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"startStatus":                    getStartStatus,
+	"statusDesc":                     getStatusDescription,
+	"logFileMode":                    getLogFileModeDescription,
+	"kernelFlags":                    getKernelFlagsDescription,
+	"logFile":                        getLogFileDescription,
+	"clockType":                      getClockTypeDescription,
+	"clockWarning":                   clockTypeWarning,
+	"memory":                         getMemoryDescription,
+	"sessionStats":                   getSessionStatsDescription,
+	"sessionLosing":                  sessionLosing,
+	"sessionState":                   getSessionStateDescription,
+	"sessionStateFlagged":            sessionStateFlagged,
+	"providersWithSessions":          providersWithSessions,
+	"otherSessions":                  otherSessions,
+	"sessionEnableDesc":              getSessionEnableDescription,
+	"providersWithLiveMismatches":    providersWithLiveMismatches,
+	"liveMismatchDesc":               getLiveMismatchDescription,
+	"totalMemory":                    totalMemory,
+	"sessionKind":                    getSessionKindDescription,
+	"kernelGUIDWarning":              kernelGUIDWarning,
+	"systemLoggerSlots":              getSystemLoggerSlotsDescription,
+	"systemLoggerSlotWarning":        systemLoggerSlotWarning,
+	"sessionLimitWarning":            sessionLimitWarning,
+	"isKernelSession":                isKernelSession,
+	"kernelGroups":                   getKernelGroups,
+	"groupMaskGroups":                getGroupMaskGroups,
+	"join":                           strings.Join,
+	"eventIDFilterLabel":             getEventIDFilterLabel,
+	"providersWithWarnings":          providersWithWarnings,
+	"enabledLabel":                   getEnabledLabel,
+	"providersWithChannels":          providersWithChannels,
+	"providersWithEventChannels":     providersWithEventChannels,
+	"eventChannelRouteDesc":          getEventChannelRouteDescription,
+	"channelDesc":                    getChannelDescription,
+	"configRows":                     configRows,
+	"eventIDRanges":                  formatEventIDRanges,
+	"providersWithEventNames":        providersWithEventNames,
+	"providersWithKeywordNames":      providersWithKeywordNames,
+	"keywordNamesDesc":               getKeywordNamesDescription,
+	"eventNameDesc":                  getEventNameDescription,
+	"providersWithCoverage":          providersWithCoverage,
+	"coverageDesc":                   getCoverageDescription,
+	"providersWithBinaries":          providersWithBinaries,
+	"binaryDesc":                     getBinaryDescription,
+	"providersWithUnusableResources": providersWithUnusableResources,
+	"unusableResources":              unusableResources,
+	"resourceDesc":                   getResourceDescription,
+	"binaryFlagged":                  binaryFlagged,
+	"vendorLabel":                    getVendorLabel,
+	"providerTypeLabel":              getProviderTypeLabel,
+	"providerTypeNote":               getProviderTypeNote,
+	"providersWithTypeNotes":         providersWithTypeNotes,
+	"providersOrphaned":              providersOrphaned,
+	"providersGroups":                providersGroups,
+	"providersWithSecurity":          providersWithSecurity,
+	"securitySource":                 getSecuritySourceLabel,
+	"aceDesc":                        getACEDescription,
+	"weakACE":                        isWeakACE,
+	"effectiveACEs":                  getEffectiveACEs,
+	"providerGroupNote":              getProviderGroupNote,
+	"groupEnabled":                   groupEnabled,
+	"providerLevelName":              getProviderLevelName,
+	"providersWithManifestFields":    providersWithManifestFields,
+	"manifestFieldsDesc":             getManifestFieldsDescription,
+	"keyword":                        formatKeyword,
+	"enableProperty":                 getEnablePropertyDescription,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>ETW Autologger Report - {{.Host.Hostname}}</title>
+<style>
+body { font-family: Segoe UI, Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; font-size: 0.9em; }
+th { background: #f0f0f0; }
+table.sortable th { cursor: pointer; user-select: none; }
+table.sortable th::after { content: " \2195"; color: #999; }
+td.mono, .mono { font-family: Consolas, monospace; }
+details { margin: 0.5em 0; }
+details > summary { cursor: pointer; font-weight: 600; font-size: 1.1em; }
+details details { margin-left: 1.5em; }
+.yes { color: #1a7f37; }
+.no { color: #b35900; }
+</style>
+</head>
+<body>
+<h1>ETW Autologger Report</h1>
+<table>
+<tr><th>Hostname</th><td>{{.Host.Hostname}}</td></tr>
+<tr><th>Domain</th><td>{{.Host.Domain}}</td></tr>
+<tr><th>Operating System</th><td>{{.Host.OSName}}</td></tr>
+<tr><th>OS Build</th><td>{{.Host.OSBuild}}</td></tr>
+<tr><th>Collected (UTC)</th><td>{{.Host.CollectedAt.Format "2006-01-02 15:04:05"}}</td></tr>
+<tr><th>Tool Version</th><td>{{.Host.ToolVersion}}</td></tr>
+<tr><th>Elevated</th><td>{{if .Host.Elevated}}Yes{{else}}No{{end}}</td></tr>
+<tr><th>Registry View</th><td>{{.Host.RegistryView}}</td></tr>
+{{if gt (len .Reports) 1}}<tr><th>Buffer Memory</th><td>{{totalMemory .Reports}}</td></tr>{{end}}
+{{with sessionLimitWarning}}<tr><th>Trace Sessions</th><td><span class="no">Warning: {{.}}</span></td></tr>{{end}}
+</table>
+{{range .Reports}}
+<details open>
+<summary>{{.Config.Name}}</summary>
+<details open>
+<summary>Configuration</summary>
+<table>
+<tr><th>Property</th><th>Type</th><th>Value</th></tr>
+{{range configRows .Config}}<tr><td>{{.Property}}</td><td>{{.Type}}</td><td class="mono">{{.Value}}</td></tr>
+{{end}}</table>
+<ul>
+<li>Start: {{startStatus .Config.Start}}</li>
+<li>Status: {{statusDesc .Config.Status}}</li>
+<li>Running: <span{{if sessionStateFlagged .Config}} class="no"{{end}}>{{sessionState .Config}}</span></li>
+<li>LogFileMode: <span class="mono">{{logFileMode .Config.LogFileMode}}</span></li>
+<li>ClockType: {{clockType .Config.ClockType}}{{with clockWarning .Config}} <span class="no">Warning: {{.}}</span>{{end}}</li>
+<li>Log File: <span class="mono">{{logFile .Config}}</span></li>
+<li>Buffer Memory: {{memory .Config}}</li>
+{{with .Config.Session}}<li>Live Session: <span{{if sessionLosing .}} class="no"{{end}}>{{sessionStats .}}</span></li>{{end}}
+{{if .Config.SessionKind}}<li>Session Type: {{sessionKind .Config}}{{with kernelGUIDWarning .Config}} <span class="no">Warning: {{.}}</span>{{end}}</li>
+<li>System Logger Slots: {{systemLoggerSlots}}{{with systemLoggerSlotWarning .Config}} <span class="no">Warning: {{.}}</span>{{end}}</li>{{end}}
+{{if .Config.EnableFlags}}<li>EnableFlags: <span class="mono">{{kernelFlags .Config.EnableFlags}}</span></li>{{end}}
+{{with groupMaskGroups .Config.GroupMask}}<li>Extended Groups: <span class="mono">{{join . " | "}}</span></li>{{end}}
+</ul>
+</details>
+{{with .Config.Security}}
+<details open>
+<summary>Session Security</summary>
+<p>{{securitySource .}}: <span class="mono">{{.SDDL}}</span></p>
+<ul>
+{{range effectiveACEs .}}<li{{if weakACE .}} class="no"{{end}}>{{aceDesc .}}</li>
+{{end}}</ul>
+</details>
+{{end}}
+{{if and (isKernelSession .Config) (not .Providers)}}
+<details open>
+<summary>Kernel Event Groups</summary>
+<ul>
+{{range kernelGroups .Config}}<li class="mono">{{.}}</li>
+{{end}}</ul>
+</details>
+{{else}}
+<details open>
+<summary>ETW Providers ({{len .Providers}} found)</summary>
+<table class="sortable">
+<thead><tr><th>GUID</th><th>Provider Name</th><th>Vendor</th><th>Type</th><th>Enabled</th><th>Level</th><th>MatchAnyKeyword</th><th>MatchAllKeyword</th><th>EnableProperty</th><th>Event IDs</th></tr></thead>
+<tbody>
+{{range .Providers}}<tr>
+<td class="mono">{{.GUID}}</td>
+<td>{{.Name}}</td>
+<td>{{vendorLabel .Vendor}}</td>
+<td>{{providerTypeLabel .ProviderType}}</td>
+<td>{{if .Enabled}}<span class="yes">Yes</span>{{else}}<span class="no">{{enabledLabel .}}</span>{{end}}</td>
+<td>{{providerLevelName .}}</td>
+<td class="mono">{{keyword .MatchAnyKeyword}}</td>
+<td class="mono">{{keyword .MatchAllKeyword}}</td>
+<td>{{enableProperty .EnableProperty}}</td>
+<td>{{if .Error}}<span class="no">Error: {{.Error}}</span>{{else if .HasFilters}}{{if .EventIDs}}{{eventIDFilterLabel . (eventIDRanges .EventIDs)}}{{else}}No Event IDs{{end}}{{else}}No Filters{{end}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+</details>
+{{with .InvalidSubkeys}}
+<details open>
+<summary>Invalid Subkeys</summary>
+<ul>
+{{range .}}<li class="mono no">{{.}}</li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithEventNames .Providers}}
+<details open>
+<summary>Event Names</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)
+<ul>{{range .EventNames}}<li>{{eventNameDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithCoverage .Providers}}
+<details open>
+<summary>Event Coverage</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>): <span{{if .Coverage.Low}} class="no"{{end}}>{{coverageDesc .Coverage}}</span></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithEventChannels .Providers}}
+<details open>
+<summary>Event Channel Routing</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)
+<ul>{{range .EventChannels}}<li{{if not .Enabled}} class="no"{{end}}>{{eventChannelRouteDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithBinaries .Providers}}
+<details open>
+<summary>Provider Binaries</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>): <span class="mono{{if binaryFlagged .Binary}} no{{end}}">{{binaryDesc .Binary}}</span></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithUnusableResources .Providers}}
+<details open>
+<summary>Provider Resources</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)
+<ul>{{range unusableResources .}}<li class="no">{{resourceDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithTypeNotes .Providers}}
+<details open>
+<summary>Provider Types</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>): {{providerTypeNote .}}</li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersGroups .Providers}}
+<details open>
+<summary>Provider Groups</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>): <span{{if not (groupEnabled .)}} class="no"{{end}}>{{providerGroupNote .}}</span>
+{{with .GroupMembers}}<ul>{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)</li>{{end}}</ul>{{end}}</li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersOrphaned .Providers}}
+<details open>
+<summary>Orphaned Providers</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>): <span class="no">not registered on this host</span></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithSessions .Providers}}
+<details open>
+<summary>Live Sessions</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>): {{len (otherSessions .)}} other session(s)
+<ul>{{range .Sessions}}<li>{{sessionEnableDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithLiveMismatches .Providers}}
+<details open>
+<summary>Live Enable Parameters</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)
+<ul>{{range .LiveMismatches}}<li class="no">{{liveMismatchDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithSecurity .Providers}}
+<details open>
+<summary>Provider Security</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>): {{securitySource .Security}} <span class="mono">{{.Security.SDDL}}</span>
+<ul>{{range effectiveACEs .Security}}<li>{{aceDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithKeywordNames .Providers}}
+<details open>
+<summary>Keywords</summary>
+<ul>
+{{range $p := .}}<li>{{$p.Name}} (<span class="mono">{{$p.GUID}}</span>)
+<ul>{{with $p.MatchAnyKeywordNames}}<li>MatchAnyKeyword {{keywordNamesDesc $p.MatchAnyKeyword .}}</li>{{end}}{{with $p.MatchAllKeywordNames}}<li>MatchAllKeyword {{keywordNamesDesc $p.MatchAllKeyword .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithManifestFields .Providers}}
+<details open>
+<summary>Manifest Levels and Channels</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)
+<ul>{{with .ManifestLevels}}<li>Levels: {{manifestFieldsDesc .}}</li>{{end}}{{with .ManifestChannels}}<li>Channels: {{manifestFieldsDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithWarnings .Providers}}
+<details open>
+<summary>Filter Warnings</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)
+<ul>{{range .Warnings}}<li class="no">{{.}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{with providersWithChannels .Providers}}
+<details open>
+<summary>Event Log Channels</summary>
+<ul>
+{{range .}}<li>{{.Name}} (<span class="mono">{{.GUID}}</span>)
+<ul>{{range .Channels}}<li{{if not .Enabled}} class="no"{{end}}>{{channelDesc .}}</li>{{end}}</ul></li>
+{{end}}</ul>
+</details>
+{{end}}
+{{end}}
+</details>
+{{end}}
+<script>
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  table.querySelectorAll("th").forEach(function (th, col) {
+    var asc = true;
+    th.addEventListener("click", function () {
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[col].textContent.trim(), y = b.cells[col].textContent.trim();
+        var nx = parseFloat(x), ny = parseFloat(y);
+        var cmp = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+        return asc ? cmp : -cmp;
+      });
+      asc = !asc;
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// writeHTMLReport renders a standalone HTML document with one collapsible
+// section per autologger and client-side sortable provider tables.
+func writeHTMLReport(w io.Writer, host *HostMetadata, reports []*AutologgerReport) error {
+	return htmlReportTemplate.Execute(w, struct {
+		Host    *HostMetadata
+		Reports []*AutologgerReport
+	}{host, reports})
+}
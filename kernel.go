@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Session kinds that receive kernel events. They are identified by the fixed
+// name and control GUID of the NT Kernel Logger and the Circular Kernel
+// Context Logger, or by EVENT_TRACE_SYSTEM_LOGGER_MODE for other sessions.
+const (
+	sessionKernel         = "kernel"
+	sessionCircularKernel = "circular_kernel"
+	sessionSystemLogger   = "system_logger"
+)
+
+const (
+	kernelLoggerName       = "NT Kernel Logger"
+	systemTraceControlGUID = "{9E814AAD-3204-11D2-9A82-006008A86939}"
+	ckclName               = "Circular Kernel Context Logger"
+	ckclGUID               = "{54DEA73A-ED1F-42A4-AF71-3E63D056F174}"
+	systemLoggerMode       = 0x02000000 // EVENT_TRACE_SYSTEM_LOGGER_MODE
+
+	// maxSystemLoggers is the number of sessions that can receive kernel
+	// events at the same time, the two kernel loggers included.
+	maxSystemLoggers = 8
+)
+
+// getSessionKind returns the kind of a session that receives kernel events,
+// or "" for a regular session.
+func getSessionKind(config *AutologgerConfig) string {
+	switch {
+	case strings.EqualFold(config.Name, kernelLoggerName) || sameGUID(config.GUID, systemTraceControlGUID):
+		return sessionKernel
+	case strings.EqualFold(config.Name, ckclName) || sameGUID(config.GUID, ckclGUID):
+		return sessionCircularKernel
+	case config.LogFileMode&systemLoggerMode != 0:
+		return sessionSystemLogger
+	}
+	return ""
+}
+
+// isKernelSession reports whether a session selects kernel event groups with
+// EnableFlags instead of enabling providers through subkeys.
+func isKernelSession(config *AutologgerConfig) bool {
+	return config.SessionKind == sessionKernel || config.SessionKind == sessionCircularKernel
+}
+
+// getSessionKindDescription explains how a session receives kernel events.
+func getSessionKindDescription(config *AutologgerConfig) string {
+	switch config.SessionKind {
+	case sessionKernel:
+		return fmt.Sprintf("NT Kernel Logger, kernel groups from EnableFlags (fixed GUID %s)", systemTraceControlGUID)
+	case sessionCircularKernel:
+		return fmt.Sprintf("Circular Kernel Context Logger, kernel groups from EnableFlags kept in memory (fixed GUID %s)", ckclGUID)
+	case sessionSystemLogger:
+		return "System logger (EVENT_TRACE_SYSTEM_LOGGER_MODE), receives kernel events in addition to its providers"
+	}
+	return "Regular session"
+}
+
+// kernelGUIDWarning explains why a kernel logger won't receive kernel events,
+// or returns "" if it will. The kernel loggers are recognized by their
+// control GUID, a session with the name but another GUID is a regular one.
+func kernelGUIDWarning(config *AutologgerConfig) string {
+	var fixed string
+	switch config.SessionKind {
+	case sessionKernel:
+		fixed = systemTraceControlGUID
+	case sessionCircularKernel:
+		fixed = ckclGUID
+	default:
+		return ""
+	}
+	if config.GUID == "" || sameGUID(config.GUID, fixed) {
+		return ""
+	}
+	return fmt.Sprintf("GUID %s is not the fixed GUID %s, the session won't receive kernel events", config.GUID, fixed)
+}
+
+// systemLoggers caches the autologgers started at boot that take a system
+// logger slot, so every report doesn't rescan the registry.
+var systemLoggers struct {
+	once  sync.Once
+	names []string
+}
+
+// startedSystemLoggers returns the autologgers started at boot that receive
+// kernel events.
+func startedSystemLoggers() []string {
+	systemLoggers.once.Do(func() {
+		names, err := getAutologgerNames()
+		if err != nil {
+			slog.Warn("cannot count system loggers", "error", err)
+			return
+		}
+		for _, name := range names {
+			config, err := getAutologgerConfig(name)
+			if err != nil {
+				slog.Debug("skipping autologger for system logger count", "autologger", name, "error", err)
+				continue
+			}
+			if config.SessionKind != "" && config.Start != 0 {
+				systemLoggers.names = append(systemLoggers.names, name)
+			}
+		}
+	})
+	return systemLoggers.names
+}
+
+// SystemLoggerSlot is a running session that takes one of the system logger
+// slots.
+type SystemLoggerSlot struct {
+	Session  string `json:"session"`
+	LoggerID uint16 `json:"logger_id"`
+	Kind     string `json:"kind"`
+	// Autologger is the autologger the session was started from, or "" if
+	// it was started at runtime.
+	Autologger string `json:"autologger,omitempty"`
+}
+
+// liveSystemLoggers caches the running sessions that take a system logger
+// slot. ok is false if the sessions could not be queried.
+var liveSystemLoggers struct {
+	once  sync.Once
+	slots []SystemLoggerSlot
+	ok    bool
+}
+
+// systemLoggerSlotsInUse returns the running sessions that receive kernel
+// events, and false if the sessions could not be queried.
+func systemLoggerSlotsInUse() ([]SystemLoggerSlot, bool) {
+	liveSystemLoggers.once.Do(func() {
+		traces, err := queryAllTraces()
+		if err != nil {
+			slog.Warn("cannot count system logger slots in use", "error", err)
+			return
+		}
+		autologgers := make(map[string]string)
+		if names, err := getAutologgerNames(); err == nil {
+			for _, name := range names {
+				autologgers[strings.ToLower(name)] = name
+			}
+		}
+		for _, props := range traces {
+			stats := newSessionStats(props)
+			kind := getSessionKind(&AutologgerConfig{
+				Name:        stats.Name,
+				GUID:        props.Wnode.Guid.String(),
+				LogFileMode: uint64(stats.LogFileMode),
+			})
+			if kind == "" {
+				continue
+			}
+			liveSystemLoggers.slots = append(liveSystemLoggers.slots, SystemLoggerSlot{
+				Session:    stats.Name,
+				LoggerID:   stats.LoggerID,
+				Kind:       kind,
+				Autologger: autologgers[strings.ToLower(stats.Name)],
+			})
+		}
+		liveSystemLoggers.ok = true
+	})
+	return liveSystemLoggers.slots, liveSystemLoggers.ok
+}
+
+// getSystemLoggerSlotsDescription renders how many of the system logger slots
+// are in use and by which sessions, and how many the autologgers started at
+// boot take.
+func getSystemLoggerSlotsDescription() string {
+	var desc string
+	if slots, ok := systemLoggerSlotsInUse(); ok {
+		names := make([]string, len(slots))
+		for i, slot := range slots {
+			names[i] = slot.Session
+		}
+		desc = fmt.Sprintf("%d of %d in use", len(slots), maxSystemLoggers)
+		if len(names) > 0 {
+			desc += " (" + strings.Join(names, ", ") + ")"
+		}
+		desc += ", "
+	}
+	used := len(startedSystemLoggers())
+	desc += fmt.Sprintf("%d of %d used by autologgers started at boot", used, maxSystemLoggers)
+	if used > maxSystemLoggers {
+		desc += ", some of them will fail to start"
+	}
+	return desc
+}
+
+// systemLoggerSlotWarning explains why an autologger that needs a system
+// logger slot won't get one, or returns "" if it will or doesn't need one.
+func systemLoggerSlotWarning(config *AutologgerConfig) string {
+	if config.SessionKind == "" || config.Start == 0 || config.SessionState == sessionRunning {
+		return ""
+	}
+	if slots, ok := systemLoggerSlotsInUse(); ok && len(slots) >= maxSystemLoggers {
+		return fmt.Sprintf("all %d system logger slots are in use, the session can't start until one is freed", maxSystemLoggers)
+	}
+	if started := startedSystemLoggers(); len(started) > maxSystemLoggers {
+		return fmt.Sprintf("%d autologgers started at boot need one of the %d system logger slots, the session may fail to start", len(started), maxSystemLoggers)
+	}
+	return ""
+}
+
+// getKernelGroups lists the kernel event groups a kernel session enables,
+// the EVENT_TRACE_FLAG_* groups followed by the extended groups.
+func getKernelGroups(config *AutologgerConfig) []string {
+	return append(getKernelFlagNames(config.EnableFlags), getGroupMaskGroups(config.GroupMask)...)
+}
+
+// displayKernelGroups lists the kernel event groups of a kernel session,
+// which has them instead of providers.
+func displayKernelGroups(w io.Writer, config *AutologgerConfig) {
+	groups := getKernelGroups(config)
+	fmt.Fprintf(w, "Kernel Event Groups under %s (%d enabled):\n\n", config.Name, len(groups))
+	for _, group := range groups {
+		fmt.Fprintf(w, "- %s\n", group)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// kernelFlags are the EVENT_TRACE_FLAG_* bits of a kernel session's
+// EnableFlags, named after the xperf kernel groups where those are
+// well known.
+var kernelFlags = []struct {
+	mask uint64
+	name string
+}{
+	{0x00000001, "PROCESS"},
+	{0x00000002, "THREAD"},
+	{0x00000004, "IMAGE_LOAD"},
+	{0x00000008, "PROCESS_COUNTERS"},
+	{0x00000010, "CSWITCH"},
+	{0x00000020, "DPC"},
+	{0x00000040, "INTERRUPT"},
+	{0x00000080, "SYSCALL"},
+	{0x00000100, "DISK_IO"},
+	{0x00000200, "DISK_FILE_IO"},
+	{0x00000400, "DISK_IO_INIT"},
+	{0x00000800, "DISPATCHER"},
+	{0x00001000, "MEMORY_PAGE_FAULTS"},
+	{0x00002000, "MEMORY_HARD_FAULTS"},
+	{0x00004000, "VIRTUAL_ALLOC"},
+	{0x00008000, "VAMAP"},
+	{0x00010000, "NETWORK"},
+	{0x00020000, "REGISTRY"},
+	{0x00040000, "DBGPRINT"},
+	{0x00080000, "JOB"},
+	{0x00100000, "ALPC"},
+	{0x00200000, "SPLIT_IO"},
+	{0x00800000, "DRIVER"},
+	{0x01000000, "PROFILE"},
+	{0x02000000, "FILE_IO"},
+	{0x04000000, "FILE_IO_INIT"},
+	{0x10000000, "NO_SYSCONFIG"},
+	{0x20000000, "ENABLE_RESERVE"},
+	{0x40000000, "FORWARD_WMI"},
+	{0x80000000, "EXTENSION"},
+}
+
+// perfInfoGroups are the extended kernel groups of a PERFINFO_GROUPMASK. The
+// top three bits of a group select the DWORD of the mask (1-7), the rest are
+// the bits within it; DWORD 0 holds the EVENT_TRACE_FLAG_* bits.
+var perfInfoGroups = []struct {
+	group uint32
+	name  string
+}{
+	{0x20000001, "MEMORY"},
+	{0x20000002, "PROFILE"},
+	{0x20000004, "CONTEXT_SWITCH"},
+	{0x20000008, "FOOTPRINT"},
+	{0x20000010, "DRIVERS"},
+	{0x20000020, "REFSET"},
+	{0x20000040, "POOL"},
+	{0x20000080, "DPC"},
+	{0x20000100, "COMPACT_CSWITCH"},
+	{0x20000200, "DISPATCHER"},
+	{0x20000400, "PMC_PROFILE"},
+	{0x20000800, "PROCESS_INSWAP"},
+	{0x20001000, "AFFINITY"},
+	{0x20002000, "PRIORITY"},
+	{0x20004000, "INTERRUPT"},
+	{0x20008000, "VIRTUAL_ALLOC"},
+	{0x20010000, "SPINLOCK"},
+	{0x20020000, "SYNC_OBJECTS"},
+	{0x20040000, "DPC_QUEUE"},
+	{0x20080000, "MEMINFO"},
+	{0x20100000, "CONTMEM_GEN"},
+	{0x20200000, "SPINLOCK_CNTRS"},
+	{0x20400000, "SESSION"},
+	{0x20800000, "MEMINFO_WS"},
+	{0x21000000, "KERNEL_QUEUE"},
+	{0x22000000, "INTERRUPT_STEER"},
+	{0x24000000, "SHOULD_YIELD"},
+	{0x28000000, "WS"},
+	{0x40000001, "ANTI_STARVATION"},
+	{0x40000002, "PROCESS_FREEZE"},
+	{0x40000004, "PFN_LIST"},
+	{0x40000008, "WS_DETAIL"},
+	{0x40000010, "WS_ENTRY"},
+	{0x40000020, "HEAP"},
+	{0x40000040, "SYSCALL"},
+	{0x40000080, "UMS"},
+	{0x40000100, "BACKTRACE"},
+	{0x40000200, "VULCAN"},
+	{0x40000400, "OBJECTS"},
+	{0x40000800, "EVENTS"},
+	{0x40001000, "FULLTRACE"},
+	{0x40002000, "DFSS"},
+	{0x40004000, "PREFETCH"},
+	{0x40008000, "PROCESSOR_IDLE"},
+	{0x40010000, "CPU_CONFIG"},
+	{0x40020000, "TIMER"},
+	{0x40040000, "CLOCK_INTERRUPT"},
+	{0x40080000, "LOAD_BALANCER"},
+	{0x40100000, "CLOCK_TIMER"},
+	{0x40200000, "IDLE_SELECTION"},
+	{0x40400000, "IPI"},
+	{0x40800000, "IO_TIMER"},
+	{0x41000000, "REG_HIVE"},
+	{0x42000000, "REG_NOTIF"},
+	{0x44000000, "PPM_EXIT_LATENCY"},
+	{0x48000000, "WORKER_THREAD"},
+	{0x80000001, "OPTICAL_IO"},
+	{0x80000002, "OPTICAL_IO_INIT"},
+	{0x80000008, "DLL_INFO"},
+	{0x80000010, "DLL_FLUSH_WS"},
+	{0x80000040, "OB_HANDLE"},
+	{0x80000080, "OB_OBJECT"},
+	{0x80000200, "WAKE_DROP"},
+	{0x80000400, "WAKE_EVENT"},
+	{0x80000800, "DEBUGGER"},
+	{0x80001000, "PROC_ATTACH"},
+	{0x80002000, "WAKE_COUNTER"},
+	{0x80008000, "POWER"},
+	{0x80010000, "SOFT_TRIM"},
+	{0x80020000, "CC"},
+	{0x80080000, "FLT_IO_INIT"},
+	{0x80100000, "FLT_IO"},
+	{0x80200000, "FLT_FASTIO"},
+	{0x80400000, "FLT_IO_FAILURE"},
+	{0x80800000, "HV_PROFILE"},
+	{0x81000000, "WDF_DPC"},
+	{0x82000000, "WDF_INTERRUPT"},
+	{0x84000000, "CACHE_FLUSH"},
+	{0xA0000001, "HIBER_RUNDOWN"},
+	{0xC0000001, "SYSCFG_SYSTEM"},
+	{0xC0000002, "SYSCFG_GRAPHICS"},
+	{0xC0000004, "SYSCFG_STORAGE"},
+	{0xC0000008, "SYSCFG_NETWORK"},
+	{0xC0000010, "SYSCFG_SERVICES"},
+	{0xC0000020, "SYSCFG_PNP"},
+	{0xC0000040, "SYSCFG_OPTICAL"},
+	{0xE0000001, "CLUSTER_OFF"},
+	{0xE0000002, "MEMORY_CONTROL"},
+}
+
+// readEnableFlags reads the EnableFlags value of a kernel session. It is a
+// REG_DWORD, or a REG_BINARY PERFINFO_GROUPMASK for the extended kernel
+// groups whose first DWORD holds the same EVENT_TRACE_FLAG_* bits. The full
+// group mask is returned as well when it is longer than one DWORD.
+func readEnableFlags(key registry.Key) (uint64, []uint32) {
+	if val, _, err := key.GetIntegerValue("EnableFlags"); err == nil {
+		return val, nil
+	}
+	data, valType, err := readRawValue(key, "EnableFlags")
+	if err != nil || valType != registry.BINARY || len(data) < 4 {
+		return 0, nil
+	}
+	if len(data) == 4 {
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	}
+
+	mask := make([]uint32, len(data)/4)
+	for i := range mask {
+		mask[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	slog.Debug("EnableFlags is an extended group mask", "dwords", len(mask))
+	return uint64(mask[0]), mask
+}
+
+// getGroupMaskGroups names the extended kernel groups set in a group mask.
+// Bits without a known name are shown as the raw group value.
+func getGroupMaskGroups(mask []uint32) []string {
+	var names []string
+	for i := 1; i < len(mask) && i < 8; i++ {
+		rest := mask[i]
+		for _, g := range perfInfoGroups {
+			if int(g.group>>29) == i && rest&(g.group&0x1FFFFFFF) != 0 {
+				names = append(names, g.name)
+				rest &^= g.group & 0x1FFFFFFF
+			}
+		}
+		for bit := uint32(1); rest != 0; bit <<= 1 {
+			if rest&bit != 0 {
+				names = append(names, fmt.Sprintf("0x%08X", uint32(i)<<29|bit))
+				rest &^= bit
+			}
+		}
+	}
+	return names
+}
+
+// getKernelFlagsDescription translates EnableFlags into kernel group names.
+func getKernelFlagsDescription(flags uint64) string {
+	names := getKernelFlagNames(flags)
+	if len(names) == 0 {
+		return fmt.Sprintf("0x%08X (No flags set)", flags)
+	}
+	return fmt.Sprintf("0x%08X (%s)", flags, strings.Join(names, " | "))
+}
+
+// getKernelFlagNames names the kernel groups set in EnableFlags. PROCESS and
+// THREAD together are shown as PROC_THREAD like xperf does.
+func getKernelFlagNames(flags uint64) []string {
+	var names []string
+	rest := flags
+	if rest&0x3 == 0x3 {
+		names = append(names, "PROC_THREAD")
+		rest &^= 0x3
+	}
+	for _, flag := range kernelFlags {
+		if rest&flag.mask != 0 {
+			names = append(names, flag.name)
+			rest &^= flag.mask
+		}
+	}
+	if rest != 0 {
+		names = append(names, fmt.Sprintf("0x%X", rest))
+	}
+	return names
+}
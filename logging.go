@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Diagnostics go to stderr through log/slog. Warnings and errors are shown by
+// default, -v adds informational messages such as name resolution failures
+// and -vv adds debug messages for every registry fallback.
+var (
+	logLevel  = new(slog.LevelVar)
+	logFormat = "text"
+	// logOutput is replaced by a log file when running as a service,
+	// which has no stderr.
+	logOutput io.Writer = os.Stderr
+)
+
+func init() {
+	logLevel.Set(slog.LevelWarn)
+	configureLogger()
+}
+
+func configureLogger() {
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	if logFormat == "json" {
+		handler = slog.NewJSONHandler(logOutput, opts)
+	} else {
+		// Timestamps are noise for an interactive tool, but not in a log
+		// file.
+		if logOutput == io.Writer(os.Stderr) {
+			opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.Attr{}
+				}
+				return a
+			}
+		}
+		handler = slog.NewTextHandler(logOutput, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// registerLogFlags adds the verbosity flags every command accepts.
+func registerLogFlags(fs *flag.FlagSet) {
+	fs.BoolFunc("v", "Verbose logging, e.g. providers whose names can't be resolved", func(string) error {
+		if logLevel.Level() > slog.LevelInfo {
+			logLevel.Set(slog.LevelInfo)
+		}
+		return nil
+	})
+	fs.BoolFunc("vv", "Debug logging, including every registry fallback", func(string) error {
+		logLevel.Set(slog.LevelDebug)
+		return nil
+	})
+	fs.Func("log-format", "Log format on stderr: text or json (default text)", func(s string) error {
+		if s != "text" && s != "json" {
+			return fmt.Errorf("expected text or json")
+		}
+		logFormat = s
+		configureLogger()
+		return nil
+	})
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
-	"encoding/binary"
-	"flag"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
 	"sort"
 	"strings"
 
@@ -13,99 +14,430 @@ import (
 
 const (
 	baseAutologgerPath = `SYSTEM\CurrentControlSet\Control\WMI\Autologger`
+	wmiPath            = `SYSTEM\CurrentControlSet\Control\WMI`
 )
 
+// version is reported in SIEM output and can be set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
 type ETWProvider struct {
-	GUID       string
-	Name       string
-	HasFilters bool
-	EventIDs   []int
-	Enabled    bool
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+	// Description is the short description of the provider from the
+	// provider database, not set with -no-resolve.
+	Description string `json:"description,omitempty"`
+	// Vendor tells whether the provider is part of Windows ("microsoft")
+	// or not ("third_party"), from the signer of its binary and its name.
+	// It is not set with -no-resolve.
+	Vendor string `json:"vendor,omitempty"`
+	// ProviderType tells whether the provider is manifest, MOF, WPP or
+	// TraceLogging based, which decides how the keywords and filters
+	// apply. It is not set with -no-resolve.
+	ProviderType string `json:"provider_type,omitempty"`
+	// GroupMembers are the registered providers a provider group entry
+	// expands to, not set with -no-resolve.
+	GroupMembers []GroupMember `json:"group_members,omitempty"`
+	// Security is the descriptor from WMI\Security that controls who may
+	// enable the provider, only read with -security.
+	Security *SecurityDescriptor `json:"security,omitempty"`
+	// Orphaned tells that the provider isn't registered on this host in
+	// any way, not set with -no-resolve.
+	Orphaned bool `json:"orphaned,omitempty"`
+	// Binary is the binary named by the publisher entry or WMI key, not
+	// set with -no-resolve.
+	Binary *ProviderBinary `json:"binary,omitempty"`
+	// Resources are the resource and message files of the publisher entry
+	// with whether events can be rendered from them, not set with
+	// -no-resolve.
+	Resources  []ProviderResource `json:"resources,omitempty"`
+	HasFilters bool               `json:"has_filters"`
+	EventIDs   []int              `json:"event_ids"`
+	// EventIDFilter tells whether EventIDs are the only events logged
+	// ("include") or the events dropped ("exclude"), or "mixed" when the
+	// values of the Filters key disagree. It is empty when the IDs don't
+	// come from an EVENT_FILTER_EVENT_ID structure.
+	EventIDFilter string `json:"event_id_filter,omitempty"`
+	// EventIDLists are the event IDs of every value of the Filters key
+	// with its own mode, as EventIDs merges them.
+	EventIDLists []EventIDList `json:"event_id_lists,omitempty"`
+	// EventNames are the names of the filtered event IDs from the
+	// provider's manifest, not set with -no-resolve.
+	EventNames []EventName `json:"event_names,omitempty"`
+	// RawFilters are the values of the Filters key as stored, only read
+	// with -raw-filters.
+	RawFilters []RawFilterValue `json:"raw_filters,omitempty"`
+	// Warnings describe filter data that was decoded, but is inconsistent
+	// or exceeds the limits of ETW.
+	Warnings []string `json:"warnings,omitempty"`
+	// DuplicateKeys are other subkeys for the same GUID that differ only
+	// in case or braces. Only the first subkey is analyzed.
+	DuplicateKeys []string `json:"duplicate_keys,omitempty"`
+	// Channels are the event log channels the provider's events land in,
+	// only set for the EventLog-* autologgers.
+	Channels []ProviderChannel `json:"channels,omitempty"`
+	// EventChannels are the channels the events passing the filters are
+	// written to, from the provider's manifest. They are not set with
+	// -no-resolve.
+	EventChannels []EventChannelRoute `json:"event_channels,omitempty"`
+	// Sessions are the live sessions that currently enable the provider,
+	// this autologger's included, not set with -no-resolve.
+	Sessions []SessionEnable `json:"sessions,omitempty"`
+	// LiveMismatches are the differences between the registry and the
+	// parameters the running autologger session enabled the provider
+	// with, not set with -no-resolve.
+	LiveMismatches []Drift `json:"live_mismatches,omitempty"`
+
+	Enabled bool `json:"enabled"`
+	// EnabledState tells whether Enabled was set to 1 ("on"), to 0 ("off")
+	// or not set at all ("missing").
+	EnabledState    string `json:"enabled_state"`
+	EnableLevel     uint64 `json:"enable_level"`
+	MatchAnyKeyword uint64 `json:"match_any_keyword"`
+	MatchAllKeyword uint64 `json:"match_all_keyword"`
+	// MatchAnyKeywordNames and MatchAllKeywordNames are the manifest
+	// keywords the masks select, not set with -no-resolve.
+	MatchAnyKeywordNames []string `json:"match_any_keyword_names,omitempty"`
+	MatchAllKeywordNames []string `json:"match_all_keyword_names,omitempty"`
+	// ManifestLevels and ManifestChannels are the levels and channels the
+	// provider's manifest defines, not set with -no-resolve.
+	ManifestLevels   []ManifestField `json:"manifest_levels,omitempty"`
+	ManifestChannels []ManifestField `json:"manifest_channels,omitempty"`
+	// Coverage compares the events of the provider's manifest with the
+	// filters, not set with -no-resolve or without a manifest.
+	Coverage       *EventCoverage `json:"coverage,omitempty"`
+	EnableProperty uint64         `json:"enable_property"`
+	// Error describes the parts of the provider that could not be read,
+	// typically because of access denied in non-elevated runs.
+	Error string `json:"error,omitempty"`
+	// OtherValues are the values of the provider key not listed above.
+	OtherValues []RegistryValue `json:"other_values,omitempty"`
+}
+
+// Enabled states of a provider.
+const (
+	enabledOn      = "on"
+	enabledOff     = "off"
+	enabledMissing = "missing"
+)
+
+// setEnabled records the Enabled value of a provider.
+func (p *ETWProvider) setEnabled(value uint64) {
+	p.Enabled = value != 0
+	p.EnabledState = enabledOff
+	if p.Enabled {
+		p.EnabledState = enabledOn
+	}
+}
+
+// getEnabledLabel renders whether a provider is enabled for display.
+func getEnabledLabel(p ETWProvider) string {
+	switch p.EnabledState {
+	case enabledOn:
+		return "Yes"
+	case enabledOff:
+		return "No"
+	}
+	return "Not set"
 }
 
 type AutologgerConfig struct {
-	Name           string
-	Age            uint64
-	BufferSize     uint64
-	ClockType      uint64
-	FlushTimer     uint64
-	GUID           string
-	LogFileMode    uint64
-	MaximumBuffers uint64
-	MinimumBuffers uint64
-	Start          uint64
-	Status         uint64
+	Name           string `json:"name"`
+	Age            uint64 `json:"age"`
+	BufferSize     uint64 `json:"buffer_size"`
+	ClockType      uint64 `json:"clock_type"`
+	FlushTimer     uint64 `json:"flush_timer"`
+	GUID           string `json:"guid"`
+	LogFileMode    uint64 `json:"log_file_mode"`
+	MaximumBuffers uint64 `json:"maximum_buffers"`
+	MinimumBuffers uint64 `json:"minimum_buffers"`
+	Start          uint64 `json:"start"`
+	Status         uint64 `json:"status"`
+	// EnableFlags is only set for kernel sessions, which enable kernel
+	// event groups instead of provider subkeys.
+	EnableFlags uint64 `json:"enable_flags,omitempty"`
+	// GroupMask is the full PERFINFO_GROUPMASK when EnableFlags is a
+	// REG_BINARY value with extended kernel groups.
+	GroupMask []uint32 `json:"group_mask,omitempty"`
+	// SessionKind is set for sessions that receive kernel events, see
+	// getSessionKind.
+	SessionKind string `json:"session_kind,omitempty"`
+	// File settings, only present for sessions that log to a file.
+	FileName    string `json:"file_name,omitempty"`
+	MaxFileSize uint64 `json:"max_file_size,omitempty"` // in MB
+	FileMax     uint64 `json:"file_max,omitempty"`
+	FileCounter uint64 `json:"file_counter,omitempty"`
+	// FilePath is FileName with environment variables expanded, FileExists
+	// and FileSize describe the file at that path when it was analyzed.
+	FilePath   string `json:"file_path,omitempty"`
+	FileExists bool   `json:"file_exists,omitempty"`
+	FileSize   int64  `json:"file_size,omitempty"`
+	// OtherValues are the values of the autologger key not listed above.
+	OtherValues []RegistryValue `json:"other_values,omitempty"`
+	// Security is the descriptor from WMI\Security that controls the
+	// session, only read with -security.
+	Security *SecurityDescriptor `json:"security,omitempty"`
+	// SessionState tells whether the autologger's session is "running" or
+	// "stopped" on this host, or is empty if it couldn't be queried. Status
+	// only records the last start attempt and is often stale.
+	SessionState string `json:"session_state,omitempty"`
+	// Session holds the runtime statistics of the live session, if it is
+	// running.
+	Session *SessionStats `json:"session,omitempty"`
+}
+
+// Exit codes. These are part of the command line contract, so scripts and
+// image pipelines can gate on them.
+const (
+	exitClean    = 0 // nothing to report
+	exitFindings = 1 // at least one finding was raised
+	exitError    = 2 // an autologger could not be read or the arguments were invalid
+)
+
+// exitStatus is returned by a command that completed but wants the process
+// to exit with a non-zero code.
+type exitStatus int
+
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
 }
 
 func main() {
-	var autologgerName string
-	var listMode bool
+	if err := runCommand(os.Args[1:]); err != nil {
+		var status exitStatus
+		if errors.As(err, &status) {
+			os.Exit(int(status))
+		}
+		if logFormat == "json" {
+			slog.Error("command failed", "error", err)
+		} else {
+			fmt.Fprintln(os.Stderr, newPalette(os.Stderr, "auto").red(fmt.Sprintf("Error: %v", err)))
+		}
+		os.Exit(exitError)
+	}
+}
 
-	flag.StringVar(&autologgerName, "autologger", "", "Name of the autologger to analyze (required)")
-	flag.BoolVar(&listMode, "list", false, "List all available autologgers")
-	flag.Parse()
+// reportOptions control how the reports handed to a writer are produced.
+type reportOptions struct {
+	// skipErrors skips autologgers that cannot be read instead of failing,
+	// as done when several autologgers are analyzed.
+	skipErrors bool
+	host       *HostMetadata
+	analyze    analyzeOptions
+	progress   *progress
+	filter     providerFilter
+	sort       providerSort
+}
 
-	if listMode {
-		listAutologgers()
-		return
+// writeReports analyzes each autologger in turn and hands the result to the
+// writer.
+func writeReports(writer reportWriter, names []string, opts reportOptions) (reportSummary, error) {
+	var summary reportSummary
+	for _, name := range names {
+		report, err := analyzeAutologger(name, opts.analyze)
+		opts.progress.step(name, report, err)
+		if err != nil {
+			if !opts.skipErrors {
+				opts.progress.finish()
+				return summary, err
+			}
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			summary.skipped++
+			continue
+		}
+		report.Host = opts.host
+		opts.filter.apply(report)
+		opts.sort.apply(report)
+		summary.findings += len(report.Findings)
+		for _, provider := range report.Providers {
+			if provider.Error != "" {
+				summary.partial++
+			}
+		}
+		if err := writer.WriteReport(report); err != nil {
+			return summary, err
+		}
+	}
+	opts.progress.finish()
+	if summary.skipped > 0 || summary.partial > 0 {
+		slog.Warn("results are incomplete, run elevated to read every key",
+			"skipped_autologgers", summary.skipped,
+			"providers_with_errors", summary.partial)
+	}
+	return summary, writer.Close()
+}
+
+// reportSummary counts what writeReports came across.
+type reportSummary struct {
+	findings int
+	skipped  int // autologgers that could not be read
+	partial  int // providers that could only partly be read
+}
+
+// exitStatus maps the summary onto the exit code contract. Unreadable
+// autologgers and providers take precedence over findings.
+func (s reportSummary) exitStatus() error {
+	switch {
+	case s.skipped > 0 || s.partial > 0:
+		return exitStatus(exitError)
+	case s.findings > 0:
+		return exitStatus(exitFindings)
+	default:
+		return nil
 	}
+}
 
-	if autologgerName == "" {
-		fmt.Println("Error: autologger name is required")
-		fmt.Println("Usage:")
-		fmt.Println("  -list                    List all available autologgers")
-		fmt.Println("  -autologger <name>       Analyze specific autologger")
-		fmt.Println("\nExample:")
-		fmt.Println("  go run . -list")
-		fmt.Println("  go run . -autologger DefenderApiLogger")
-		return
+// analyzeOptions control how the registry is read.
+type analyzeOptions struct {
+	// noResolve skips the Publishers and WMI lookups of provider names,
+	// which are the slowest part of the analysis.
+	noResolve bool
+	// rawFilters keeps the raw values of every Filters key for hex dumps.
+	rawFilters bool
+	// security reads the security descriptor of every provider.
+	security bool
+}
+
+// unresolvedName is used as provider name with -no-resolve.
+const unresolvedName = "(not resolved)"
+
+// analyzeAutologger collects the configuration and providers of a single
+// autologger.
+func analyzeAutologger(name string, opts analyzeOptions) (*AutologgerReport, error) {
+	config, err := getAutologgerConfig(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading autologger config: %v", err)
 	}
 
-	// Show autologger configuration
-	config, err := getAutologgerConfig(autologgerName)
+	providers, invalid, err := getETWProviders(name, opts)
 	if err != nil {
-		log.Fatalf("Error reading autologger config: %v", err)
+		return nil, fmt.Errorf("reading ETW providers: %v", err)
 	}
 
-	displayAutologgerConfig(config)
+	if opts.security && config.GUID != "" {
+		if config.Security, err = readGUIDSecurity(config.GUID); err != nil {
+			slog.Warn("cannot read session security", "autologger", name, "error", err)
+		}
+	}
 
-	// Show ETW providers
-	providers, err := getETWProviders(autologgerName)
-	if err != nil {
-		log.Fatalf("Error reading ETW providers: %v", err)
+	switch stats, err := findAutologgerSession(config); err {
+	case nil:
+		config.SessionState = sessionRunning
+		config.Session = stats
+	case errSessionNotRunning:
+		config.SessionState = sessionStopped
+	default:
+		slog.Debug("cannot query session", "autologger", name, "error", err)
 	}
 
-	displayETWProviders(providers, autologgerName)
+	if config.Session != nil && !isKernelSession(config) {
+		for i := range providers {
+			compareLiveEnable(&providers[i], config.Session.LoggerID)
+		}
+	}
+
+	if isEventLogSession(name) {
+		for i := range providers {
+			channels, err := getProviderChannels(name, providers[i].GUID)
+			if err != nil {
+				slog.Warn("cannot read event log channels", "provider", providers[i].GUID, "error", err)
+			}
+			providers[i].Channels = channels
+		}
+	}
+
+	report := &AutologgerReport{Config: config, Providers: providers, InvalidSubkeys: invalid}
+	report.Findings = analyzeFindings(report)
+
+	return report, nil
 }
 
-func listAutologgers() {
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, baseAutologgerPath, registry.READ)
+func getAutologgerNames() ([]string, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, baseAutologgerPath, registry.READ)
 	if err != nil {
-		log.Fatalf("Failed to open autologger registry key: %v", err)
+		return nil, fmt.Errorf("failed to open autologger registry key: %v", err)
 	}
 	defer key.Close()
 
-	autologgers, err := key.ReadSubKeyNames(-1)
+	names, err := key.ReadSubKeyNames(-1)
 	if err != nil {
-		log.Fatalf("Failed to read autologger names: %v", err)
+		return nil, fmt.Errorf("failed to read autologger names: %v", err)
 	}
+	sort.Strings(names)
 
-	fmt.Printf("Available Autologgers (%d found):\n", len(autologgers))
-	fmt.Println(strings.Repeat("=", 50))
+	return names, nil
+}
 
-	sort.Strings(autologgers)
+func listAutologgers(startedOnly bool) error {
+	autologgers, err := getAutologgerNames()
+	if err != nil {
+		return err
+	}
+
+	var configs []*AutologgerConfig
+	var providerCounts []int
 	for _, name := range autologgers {
-		fmt.Printf("- %s\n", name)
+		config, err := getAutologgerConfig(name)
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			continue
+		}
+		if startedOnly && config.Start == 0 {
+			continue
+		}
+		configs = append(configs, config)
+		providerCounts = append(providerCounts, countProviders(name))
 	}
+
+	fmt.Printf("Available Autologgers (%d found):\n\n", len(configs))
+
+	fmt.Printf("| %-35s | %-5s | %-10s | %-9s | %-38s | %-30s |\n",
+		"Name", "Start", "Status", "Providers", "GUID", "LogFileMode")
+	fmt.Printf("|%s|%s|%s|%s|%s|%s|\n",
+		strings.Repeat("-", 37),
+		strings.Repeat("-", 7),
+		strings.Repeat("-", 12),
+		strings.Repeat("-", 11),
+		strings.Repeat("-", 40),
+		strings.Repeat("-", 32))
+
+	for i, config := range configs {
+		status := "0"
+		if config.Status != 0 {
+			status = fmt.Sprintf("0x%08X", config.Status)
+		}
+		fmt.Printf("| %-35s | %-5d | %-10s | %-9d | %-38s | %-30s |\n",
+			truncateString(config.Name, 35),
+			config.Start,
+			status,
+			providerCounts[i],
+			config.GUID,
+			truncateString(logFileModeSummary(config.LogFileMode), 30))
+	}
+
+	return nil
+}
+
+// countProviders returns the number of providers of an autologger without
+// resolving their names.
+func countProviders(autologgerName string) int {
+	guids, _ := getProviderGUIDs(autologgerName)
+	return len(guids)
 }
 
 func getAutologgerConfig(autologgerName string) (*AutologgerConfig, error) {
 	autologgerPath := baseAutologgerPath + `\` + autologgerName
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, autologgerPath, registry.READ)
+	key, err := openKey(registry.LOCAL_MACHINE, autologgerPath, registry.READ)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open autologger key: %v", err)
 	}
 	defer key.Close()
+	return readAutologgerConfig(key, autologgerName), nil
+}
+
+// readAutologgerConfig reads the configuration of an autologger from its
+// key, which may also be in an offline hive.
+func readAutologgerConfig(key registry.Key, autologgerName string) *AutologgerConfig {
 	config := &AutologgerConfig{Name: autologgerName}
 
 	if val, _, err := key.GetIntegerValue("Age"); err == nil {
@@ -138,36 +470,166 @@ func getAutologgerConfig(autologgerName string) (*AutologgerConfig, error) {
 	if val, _, err := key.GetIntegerValue("Status"); err == nil {
 		config.Status = val
 	}
+	config.EnableFlags, config.GroupMask = readEnableFlags(key)
+	config.SessionKind = getSessionKind(config)
+	readFileSettings(key, config)
+	config.OtherValues = readOtherValues(key, knownAutologgerValues)
 
-	return config, nil
+	return config
 }
 
-func displayAutologgerConfig(config *AutologgerConfig) {
-	fmt.Printf("Autologger Configuration: %s\n", config.Name)
-	fmt.Println(strings.Repeat("=", 60))
+// readFileSettings reads the log file values of an autologger and looks up
+// the file they point to.
+func readFileSettings(key registry.Key, config *AutologgerConfig) {
+	if val, _, err := key.GetStringValue("FileName"); err == nil {
+		config.FileName = val
+	}
+	if val, _, err := key.GetIntegerValue("MaxFileSize"); err == nil {
+		config.MaxFileSize = val
+	}
+	if val, _, err := key.GetIntegerValue("FileMax"); err == nil {
+		config.FileMax = val
+	}
+	if val, _, err := key.GetIntegerValue("FileCounter"); err == nil {
+		config.FileCounter = val
+	}
 
-	fmt.Printf("| %-20s | %-15s | %-20s |\n", "Property", "Type", "Value")
-	fmt.Printf("|%s|%s|%s|\n",
+	if config.FileName == "" {
+		return
+	}
+	path, err := registry.ExpandString(config.FileName)
+	if err != nil {
+		slog.Debug("cannot expand FileName", "file_name", config.FileName, "error", err)
+		path = config.FileName
+	}
+	config.FilePath = path
+	if info, err := os.Stat(path); err == nil {
+		config.FileExists = true
+		config.FileSize = info.Size()
+	} else if !os.IsNotExist(err) {
+		slog.Info("cannot stat log file", "path", path, "error", err)
+	}
+}
+
+// getLogFileDescription describes the log file of a session, including
+// whether it exists.
+func getLogFileDescription(config *AutologgerConfig) string {
+	if config.FileName == "" {
+		return "None (real-time or in-memory session)"
+	}
+	state := "does not exist"
+	if config.FileExists {
+		state = "exists, " + formatKB(uint64(config.FileSize+1023)/1024)
+	}
+	return fmt.Sprintf("%s (%s)", config.FilePath, state)
+}
+
+func displayAutologgerConfig(w io.Writer, config *AutologgerConfig, pal palette) {
+	fmt.Fprintf(w, "Autologger Configuration: %s\n", config.Name)
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+
+	fmt.Fprintf(w, "| %-20s | %-15s | %-20s |\n", "Property", "Type", "Value")
+	fmt.Fprintf(w, "|%s|%s|%s|\n",
 		strings.Repeat("-", 22),
 		strings.Repeat("-", 17),
 		strings.Repeat("-", 22))
 
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "Age", "REG_DWORD", config.Age)
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "BufferSize", "REG_DWORD", config.BufferSize)
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "ClockType", "REG_DWORD", config.ClockType)
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "FlushTimer", "REG_DWORD", config.FlushTimer)
-	fmt.Printf("| %-20s | %-15s | %-20s |\n", "GUID", "REG_SZ", config.GUID)
-	fmt.Printf("| %-20s | %-15s | 0x%-18X |\n", "LogFileMode", "REG_DWORD", config.LogFileMode)
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "MaximumBuffers", "REG_DWORD", config.MaximumBuffers)
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "MinimumBuffers", "REG_DWORD", config.MinimumBuffers)
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "Start", "REG_DWORD", config.Start)
-	fmt.Printf("| %-20s | %-15s | %-20d |\n", "Status", "REG_DWORD", config.Status)
+	for _, row := range configRows(config) {
+		fmt.Fprintf(w, "| %-20s | %-15s | %-20s |\n", row.Property, row.Type, row.Value)
+	}
 
-	fmt.Printf("\nConfiguration Details:\n")
-	fmt.Printf("- Start: %s\n", getStartStatus(config.Start))
-	fmt.Printf("- Status: %s\n", getStatusDescription(config.Status))
-	fmt.Printf("- LogFileMode: %s\n", getLogFileModeDescription(config.LogFileMode))
-	fmt.Println()
+	fmt.Fprintf(w, "\nConfiguration Details:\n")
+	start := getStartStatus(config.Start)
+	if config.Start == 0 {
+		start = pal.red(start)
+	}
+	fmt.Fprintf(w, "- Start: %s\n", start)
+	status := getStatusDescription(config.Status)
+	if config.Status != 0 {
+		status = pal.red(status)
+	}
+	fmt.Fprintf(w, "- Status: %s\n", status)
+	running := getSessionStateDescription(config)
+	if sessionStateFlagged(config) {
+		running = pal.red(running)
+	}
+	fmt.Fprintf(w, "- Running: %s\n", running)
+	fmt.Fprintf(w, "- LogFileMode: %s\n", getLogFileModeDescription(config.LogFileMode))
+	fmt.Fprintf(w, "- ClockType: %s\n", getClockTypeDescription(config.ClockType))
+	if warning := clockTypeWarning(config); warning != "" {
+		fmt.Fprintf(w, "  %s\n", pal.yellow("Warning: "+warning))
+	}
+	if config.SessionKind != "" {
+		fmt.Fprintf(w, "- Session Type: %s\n", getSessionKindDescription(config))
+		if warning := kernelGUIDWarning(config); warning != "" {
+			fmt.Fprintf(w, "  %s\n", pal.yellow("Warning: "+warning))
+		}
+		fmt.Fprintf(w, "- System Logger Slots: %s\n", getSystemLoggerSlotsDescription())
+		if warning := systemLoggerSlotWarning(config); warning != "" {
+			fmt.Fprintf(w, "  %s\n", pal.red("Warning: "+warning))
+		}
+	}
+	if config.EnableFlags != 0 {
+		fmt.Fprintf(w, "- EnableFlags: %s\n", getKernelFlagsDescription(config.EnableFlags))
+	}
+	if groups := getGroupMaskGroups(config.GroupMask); len(groups) > 0 {
+		fmt.Fprintf(w, "- Extended Groups: %s\n", strings.Join(groups, " | "))
+	}
+	fmt.Fprintf(w, "- Log File: %s\n", getLogFileDescription(config))
+	if config.MaxFileSize != 0 {
+		fmt.Fprintf(w, "- Maximum File Size: %d MB\n", config.MaxFileSize)
+	}
+	fmt.Fprintf(w, "- Buffer Memory: %s\n", getMemoryDescription(config))
+	if s := config.Session; s != nil {
+		desc := getSessionStatsDescription(s)
+		if sessionLosing(s) {
+			desc = pal.yellow(desc)
+		}
+		fmt.Fprintf(w, "- Live Session: %s\n", desc)
+	}
+	fmt.Fprintln(w)
+}
+
+type configRow struct {
+	Property string
+	Type     string
+	Value    string
+}
+
+// configRows returns the registry values of an autologger in display order,
+// shared by the table and report renderers.
+func configRows(config *AutologgerConfig) []configRow {
+	rows := []configRow{
+		{"Age", "REG_DWORD", fmt.Sprintf("%d", config.Age)},
+		{"BufferSize", "REG_DWORD", fmt.Sprintf("%d", config.BufferSize)},
+		{"ClockType", "REG_DWORD", fmt.Sprintf("%d", config.ClockType)},
+		{"FlushTimer", "REG_DWORD", fmt.Sprintf("%d", config.FlushTimer)},
+		{"GUID", "REG_SZ", config.GUID},
+		{"LogFileMode", "REG_DWORD", fmt.Sprintf("0x%X", config.LogFileMode)},
+		{"MaximumBuffers", "REG_DWORD", fmt.Sprintf("%d", config.MaximumBuffers)},
+		{"MinimumBuffers", "REG_DWORD", fmt.Sprintf("%d", config.MinimumBuffers)},
+		{"Start", "REG_DWORD", fmt.Sprintf("%d", config.Start)},
+		{"Status", "REG_DWORD", fmt.Sprintf("%d", config.Status)},
+	}
+	if config.EnableFlags != 0 {
+		rows = append(rows, configRow{"EnableFlags", "REG_DWORD", fmt.Sprintf("0x%X", config.EnableFlags)})
+	}
+	if config.FileName != "" {
+		rows = append(rows, configRow{"FileName", "REG_SZ", config.FileName})
+	}
+	if config.MaxFileSize != 0 {
+		rows = append(rows, configRow{"MaxFileSize", "REG_DWORD", fmt.Sprintf("%d", config.MaxFileSize)})
+	}
+	if config.FileMax != 0 {
+		rows = append(rows, configRow{"FileMax", "REG_DWORD", fmt.Sprintf("%d", config.FileMax)})
+	}
+	if config.FileCounter != 0 {
+		rows = append(rows, configRow{"FileCounter", "REG_DWORD", fmt.Sprintf("%d", config.FileCounter)})
+	}
+	for _, v := range config.OtherValues {
+		rows = append(rows, configRow{v.Name, v.Type, v.Data})
+	}
+	return rows
 }
 
 func getStartStatus(start uint64) string {
@@ -181,18 +643,134 @@ func getStartStatus(start uint64) string {
 	}
 }
 
-func getStatusDescription(status uint64) string {
-	switch status {
+// getClockTypeDescription names the timer used for event timestamps.
+func getClockTypeDescription(clockType uint64) string {
+	switch clockType {
 	case 0:
-		return "Stopped (0)"
+		return "Default, QueryPerformanceCounter (0)"
 	case 1:
-		return "Running (1)"
+		return "QueryPerformanceCounter (1)"
+	case 2:
+		return "SystemTime (2)"
+	case 3:
+		return "CPU cycle counter (3)"
 	default:
-		return fmt.Sprintf("Unknown (%d)", status)
+		return fmt.Sprintf("Unknown (%d)", clockType)
 	}
 }
 
+// clockTypeWarning explains why the timestamps of a session are unreliable,
+// or returns "" if they are fine. The CPU cycle counter is not synchronized
+// across processors and can't be converted to wall clock time reliably, which
+// matters when buffers mix processors or events are consumed in real time.
+func clockTypeWarning(config *AutologgerConfig) string {
+	if config.ClockType != 3 {
+		return ""
+	}
+	const (
+		realTimeMode            = 0x00000100 // EVENT_TRACE_REAL_TIME_MODE
+		noPerProcessorBuffering = 0x10000000 // EVENT_TRACE_NO_PER_PROCESSOR_BUFFERING
+	)
+	switch {
+	case config.LogFileMode&noPerProcessorBuffering != 0:
+		return "CPU cycle timestamps combined with EVENT_TRACE_NO_PER_PROCESSOR_BUFFERING: events from different processors share buffers and can't be ordered reliably"
+	case config.LogFileMode&realTimeMode != 0:
+		return "CPU cycle timestamps combined with EVENT_TRACE_REAL_TIME_MODE: real-time consumers can't reliably convert them to wall clock time"
+	}
+	return ""
+}
+
+// getLevelName renders a provider EnableLevel as the standard ETW level
+// name. Levels include everything more severe, so Warning also collects
+// Error and Critical events.
+func getLevelName(level uint64) string {
+	switch level {
+	case 0:
+		return "All (0)"
+	case 1:
+		return "Critical (1)"
+	case 2:
+		return "Error (2)"
+	case 3:
+		return "Warning (3)"
+	case 4:
+		return "Info (4)"
+	case 5:
+		return "Verbose (5)"
+	default:
+		return fmt.Sprintf("Custom (%d)", level)
+	}
+}
+
+// formatKeyword renders a 64-bit keyword mask in hex. A MatchAnyKeyword of
+// 0 enables all keywords, a MatchAllKeyword of 0 adds no restriction.
+func formatKeyword(mask uint64) string {
+	return fmt.Sprintf("0x%X", mask)
+}
+
+// enablePropertyFlags are the EVENT_ENABLE_PROPERTY_* flags, without the
+// prefix.
+var enablePropertyFlags = []struct {
+	mask uint64
+	name string
+}{
+	{0x001, "SID"},
+	{0x002, "TS_ID"},
+	{0x004, "STACK_TRACE"},
+	{0x008, "PSM_KEY"},
+	{0x010, "IGNORE_KEYWORD_0"},
+	{0x020, "PROVIDER_GROUP"},
+	{0x040, "ENABLE_KEYWORD_0"},
+	{0x080, "PROCESS_START_KEY"},
+	{0x100, "EVENT_KEY"},
+	{0x200, "EXCLUDE_INPRIVATE"},
+	{0x400, "ENABLE_SILOS"},
+	{0x800, "SOURCE_CONTAINER_TRACKING"},
+}
+
+// getEnablePropertyDescription lists the EVENT_ENABLE_PROPERTY_* flags set in
+// an EnableProperty value, e.g. "SID,STACK_TRACE". Unknown bits are
+// appended in hex.
+func getEnablePropertyDescription(property uint64) string {
+	if property == 0 {
+		return "-"
+	}
+	var names []string
+	for _, flag := range enablePropertyFlags {
+		if property&flag.mask != 0 {
+			names = append(names, flag.name)
+			property &^= flag.mask
+		}
+	}
+	if property != 0 {
+		names = append(names, fmt.Sprintf("0x%X", property))
+	}
+	return strings.Join(names, ",")
+}
+
 func getLogFileModeDescription(mode uint64) string {
+	modes := logFileModeFlags(mode)
+	if len(modes) == 0 {
+		return fmt.Sprintf("0x%08X (No flags set)", mode)
+	}
+
+	return fmt.Sprintf("0x%08X (%s)", mode, strings.Join(modes, " | "))
+}
+
+// logFileModeSummary is a compact form of getLogFileModeDescription for
+// table columns, without the FILE_MODE_ prefixes.
+func logFileModeSummary(mode uint64) string {
+	modes := logFileModeFlags(mode)
+	for i, m := range modes {
+		modes[i] = strings.TrimPrefix(m, "FILE_MODE_")
+	}
+	if len(modes) == 0 {
+		return fmt.Sprintf("0x%08X", mode)
+	}
+	return fmt.Sprintf("0x%08X %s", mode, strings.Join(modes, ","))
+}
+
+func logFileModeFlags(mode uint64) []string {
 	modes := []string{}
 
 	if mode&0x00000001 != 0 {
@@ -280,165 +858,356 @@ func getLogFileModeDescription(mode uint64) string {
 		modes = append(modes, "FILE_MODE_HARD_DISABLE")
 	}
 
-	if len(modes) == 0 {
-		return fmt.Sprintf("0x%08X (No flags set)", mode)
-	}
-
-	return fmt.Sprintf("0x%08X (%s)", mode, strings.Join(modes, " | "))
+	return modes
 }
 
-func displayETWProviders(providers []ETWProvider, autologgerName string) {
-	fmt.Printf("ETW Providers under %s (%d found):\n\n", autologgerName, len(providers))
-
-	fmt.Printf("| %-40s | %-35s | %-8s | %-20s |\n", "GUID", "Provider Name", "Enabled", "Event IDs")
-	fmt.Printf("|%s|%s|%s|%s|\n",
-		strings.Repeat("-", 42),
-		strings.Repeat("-", 37),
-		strings.Repeat("-", 10),
-		strings.Repeat("-", 22))
-
-	for _, provider := range providers {
-		enabledStr := "No"
-		if provider.Enabled {
-			enabledStr = "Yes"
-		}
+// displayETWProviders prints the provider table, sizing the columns to their
+// content. When maxWidth is non-zero, the name and event ID columns are
+// truncated so that the table fits in maxWidth characters. Disabled
+// providers are highlighted in red.
+func displayETWProviders(w io.Writer, providers []ETWProvider, autologgerName string, maxWidth int, pal palette) {
+	fmt.Fprintf(w, "ETW Providers under %s (%d found):\n\n", autologgerName, len(providers))
+
+	type row struct{ guid, name, vendor, providerType, enabled, level, matchAny, matchAll, properties, eventIDs string }
+	rows := make([]row, len(providers))
+	guidWidth, nameWidth, enabledWidth, levelWidth, eventIDsWidth := len("GUID"), len("Provider Name"), len("Enabled"), len("Level"), len("Event IDs")
+	matchAnyWidth, matchAllWidth, propertiesWidth := len("MatchAnyKeyword"), len("MatchAllKeyword"), len("EnableProperty")
+	vendorWidth, typeWidth := len("Vendor"), len("Type")
+	for i, provider := range providers {
+		enabledStr := getEnabledLabel(provider)
 
 		eventIDsStr := "No Filters"
 		if provider.HasFilters {
 			if len(provider.EventIDs) > 0 {
-				eventIDsStr = fmt.Sprintf("%v", provider.EventIDs)
-				if len(eventIDsStr) > 20 {
-					eventIDsStr = eventIDsStr[:17] + "..."
-				}
+				eventIDsStr = getEventIDFilterLabel(provider, formatEventIDRanges(provider.EventIDs))
 			} else {
 				eventIDsStr = "No Event IDs"
 			}
 		}
 
-		fmt.Printf("| %-40s | %-35s | %-8s | %-20s |\n",
-			provider.GUID,
-			truncateString(provider.Name, 35),
-			enabledStr,
-			eventIDsStr)
-	}
-	fmt.Printf("\n\nDetailed Event IDs:\n")
-	fmt.Println(strings.Repeat("=", 80))
+		if provider.Error != "" {
+			eventIDsStr = "Error: " + provider.Error
+		}
+
+		rows[i] = row{provider.GUID, provider.Name, getVendorLabel(provider.Vendor), getProviderTypeLabel(provider.ProviderType), enabledStr, getProviderLevelName(provider),
+			formatKeyword(provider.MatchAnyKeyword), formatKeyword(provider.MatchAllKeyword),
+			getEnablePropertyDescription(provider.EnableProperty), eventIDsStr}
+		guidWidth = max(guidWidth, len(provider.GUID))
+		vendorWidth = max(vendorWidth, len(rows[i].vendor))
+		typeWidth = max(typeWidth, len(rows[i].providerType))
+		levelWidth = max(levelWidth, len(rows[i].level))
+		matchAnyWidth = max(matchAnyWidth, len(rows[i].matchAny))
+		matchAllWidth = max(matchAllWidth, len(rows[i].matchAll))
+		propertiesWidth = max(propertiesWidth, len(rows[i].properties))
+		nameWidth = max(nameWidth, len(provider.Name))
+		eventIDsWidth = max(eventIDsWidth, len(eventIDsStr))
+	}
+
+	// Each column adds "| " and " " around its content, plus the final "|".
+	if total := guidWidth + nameWidth + vendorWidth + typeWidth + enabledWidth + levelWidth + matchAnyWidth + matchAllWidth + propertiesWidth + eventIDsWidth + 10*3 + 1; maxWidth > 0 && total > maxWidth {
+		excess := total - maxWidth
+		shrink := func(width *int, floor int) {
+			cut := max(0, min(excess, *width-floor))
+			*width -= cut
+			excess -= cut
+		}
+		shrink(&eventIDsWidth, 20)
+		shrink(&nameWidth, 35)
+		shrink(&eventIDsWidth, 12)
+		shrink(&nameWidth, 20)
+	}
+
+	format := fmt.Sprintf("| %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds | %%-%ds |\n",
+		guidWidth, nameWidth, vendorWidth, typeWidth, enabledWidth, levelWidth, matchAnyWidth, matchAllWidth, propertiesWidth, eventIDsWidth)
+	fmt.Fprintf(w, format, "GUID", "Provider Name", "Vendor", "Type", "Enabled", "Level", "MatchAnyKeyword", "MatchAllKeyword", "EnableProperty", "Event IDs")
+	fmt.Fprintf(w, "|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|\n",
+		strings.Repeat("-", guidWidth+2),
+		strings.Repeat("-", nameWidth+2),
+		strings.Repeat("-", vendorWidth+2),
+		strings.Repeat("-", typeWidth+2),
+		strings.Repeat("-", enabledWidth+2),
+		strings.Repeat("-", levelWidth+2),
+		strings.Repeat("-", matchAnyWidth+2),
+		strings.Repeat("-", matchAllWidth+2),
+		strings.Repeat("-", propertiesWidth+2),
+		strings.Repeat("-", eventIDsWidth+2))
+
+	for _, r := range rows {
+		// Pad before coloring, escape sequences would throw off the widths.
+		enabled := fmt.Sprintf("%-*s", enabledWidth, r.enabled)
+		if r.enabled == "No" {
+			enabled = pal.red(enabled)
+		}
+		eventIDs := fmt.Sprintf("%-*s", eventIDsWidth, truncateString(r.eventIDs, eventIDsWidth))
+		if strings.HasPrefix(r.eventIDs, "Error: ") {
+			eventIDs = pal.yellow(eventIDs)
+		}
+		fmt.Fprintf(w, "| %-*s | %-*s | %-*s | %-*s | %s | %-*s | %-*s | %-*s | %-*s | %s |\n",
+			guidWidth, r.guid,
+			nameWidth, truncateString(r.name, nameWidth),
+			vendorWidth, r.vendor,
+			typeWidth, r.providerType,
+			enabled,
+			levelWidth, r.level,
+			matchAnyWidth, r.matchAny,
+			matchAllWidth, r.matchAll,
+			propertiesWidth, r.properties,
+			eventIDs)
+	}
+	if summary := getVendorSummary(providers); summary != "" {
+		fmt.Fprintf(w, "\nVendors: %s\n", summary)
+	}
+	fmt.Fprintf(w, "\n\nDetailed Event IDs:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
 
 	for _, provider := range providers {
 		if provider.HasFilters && len(provider.EventIDs) > 0 {
-			fmt.Printf("\n%s (%s):\n", provider.Name, provider.GUID)
-			fmt.Printf("Event IDs: %v\n", provider.EventIDs)
+			fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+			fmt.Fprintf(w, "Event IDs: %s\n", getEventIDFilterLabel(provider, formatEventIDRanges(provider.EventIDs)))
+			writeEventNames(w, provider.EventNames)
+		}
+	}
+
+	displayCoverage(w, providers, pal)
+	displayEventChannels(w, providers, pal)
+	displayBinaries(w, providers, pal)
+	displayResources(w, providers, pal)
+	displayProviderTypes(w, providers)
+	displayProviderGroups(w, providers, pal)
+	displayOrphanedProviders(w, providers, pal)
+	displayProviderSessions(w, providers)
+	displayLiveMismatches(w, providers, pal)
+	displaySecurity(w, providers)
+
+	header := false
+	for _, provider := range providersWithKeywordNames(providers) {
+		if !header {
+			fmt.Fprintf(w, "\n\nKeywords:\n")
+			fmt.Fprintln(w, strings.Repeat("=", 80))
+			header = true
+		}
+		fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+		if len(provider.MatchAnyKeywordNames) > 0 {
+			fmt.Fprintf(w, "MatchAnyKeyword %s\n", getKeywordNamesDescription(provider.MatchAnyKeyword, provider.MatchAnyKeywordNames))
+		}
+		if len(provider.MatchAllKeywordNames) > 0 {
+			fmt.Fprintf(w, "MatchAllKeyword %s\n", getKeywordNamesDescription(provider.MatchAllKeyword, provider.MatchAllKeywordNames))
+		}
+	}
+
+	header = false
+	for _, provider := range providersWithManifestFields(providers) {
+		if !header {
+			fmt.Fprintf(w, "\n\nManifest Levels and Channels:\n")
+			fmt.Fprintln(w, strings.Repeat("=", 80))
+			header = true
+		}
+		fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+		if len(provider.ManifestLevels) > 0 {
+			fmt.Fprintf(w, "Levels: %s\n", getManifestFieldsDescription(provider.ManifestLevels))
+		}
+		if len(provider.ManifestChannels) > 0 {
+			fmt.Fprintf(w, "Channels: %s\n", getManifestFieldsDescription(provider.ManifestChannels))
+		}
+	}
+
+	header = false
+	for _, provider := range providers {
+		if len(provider.Warnings) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\n\nFilter Warnings:\n")
+			fmt.Fprintln(w, strings.Repeat("=", 80))
+			header = true
+		}
+		fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+		for _, warning := range provider.Warnings {
+			fmt.Fprintf(w, "- %s\n", pal.yellow(warning))
+		}
+	}
+
+	header = false
+	for _, provider := range providers {
+		if len(provider.OtherValues) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\n\nOther Provider Values:\n")
+			fmt.Fprintln(w, strings.Repeat("=", 80))
+			header = true
+		}
+		fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+		for _, v := range provider.OtherValues {
+			fmt.Fprintf(w, "- %s (%s): %s\n", v.Name, v.Type, v.Data)
 		}
 	}
 }
 
+// displayInvalidSubkeys lists the subkeys of an autologger that aren't
+// provider GUIDs.
+func displayInvalidSubkeys(w io.Writer, subkeys []string, pal palette) {
+	if len(subkeys) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n\nInvalid Subkeys (%d):\n", len(subkeys))
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, subkey := range subkeys {
+		fmt.Fprintf(w, "- %s\n", pal.yellow(subkey))
+	}
+}
+
+// displayFindings lists the findings of an autologger, colored by severity.
+func displayFindings(w io.Writer, findings []Finding, pal palette) {
+	if len(findings) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n\nFindings (%d):\n", len(findings))
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s %s: %s\n", pal.severity(f.Severity, fmt.Sprintf("[%-6s]", strings.ToUpper(f.Severity))), f.ID, f.Message)
+	}
+}
+
+// truncateString shortens s to maxLen characters, cutting on rune
+// boundaries so names with non-ASCII characters stay valid UTF-8.
 func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	return string(runes[:maxLen-3]) + "..."
 }
 
-func getETWProviders(autologgerName string) ([]ETWProvider, error) {
+// getETWProviders reads the providers of an autologger. Subkeys that aren't
+// GUIDs are not providers and are returned separately.
+func getETWProviders(autologgerName string, opts analyzeOptions) ([]ETWProvider, []string, error) {
 	autologgerPath := baseAutologgerPath + `\` + autologgerName
 
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, autologgerPath, registry.READ)
+	key, err := openKey(registry.LOCAL_MACHINE, autologgerPath, registry.READ)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open registry key: %v", err)
+		return nil, nil, fmt.Errorf("failed to open registry key: %v", err)
 	}
 	defer key.Close()
+	return readETWProviders(key, autologgerName, opts)
+}
+
+// readETWProviders reads the providers under the key of an autologger,
+// which may also be in an offline hive.
+func readETWProviders(key registry.Key, autologgerName string, opts analyzeOptions) ([]ETWProvider, []string, error) {
 	subkeyNames, err := key.ReadSubKeyNames(-1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read subkey names: %v", err)
+		return nil, nil, fmt.Errorf("failed to read subkey names: %v", err)
 	}
 
-	var providers []ETWProvider
+	subkeys, invalid := groupProviderSubkeys(subkeyNames)
+	for _, name := range invalid {
+		slog.Warn("subkey is not a provider GUID", "autologger", autologgerName, "subkey", name)
+	}
 
-	for _, guid := range subkeyNames {
-		provider := ETWProvider{
-			GUID: guid,
-			Name: resolveProviderName(guid),
+	if !opts.noResolve {
+		guids := make([]string, len(subkeys))
+		for i, subkey := range subkeys {
+			guids[i] = subkey.guid
 		}
-
-		eventIDs, hasFilters, enabled := getEventIDsFromFilters(key, guid)
-		provider.HasFilters = hasFilters
-		provider.EventIDs = eventIDs
-		provider.Enabled = enabled
-
-		providers = append(providers, provider)
+		resolveProviderNames(guids)
 	}
-	sort.Slice(providers, func(i, j int) bool {
-		return providers[i].GUID < providers[j].GUID
-	})
-
-	return providers, nil
-}
 
-func getEventIDsFromFilters(parentKey registry.Key, providerGUID string) ([]int, bool, bool) {
-	filtersKey, err := registry.OpenKey(parentKey, providerGUID+`\Filters`, registry.READ)
-	if err != nil {
-		return nil, false, false
-	}
-	defer filtersKey.Close()
+	var providers []ETWProvider
 
-	var eventIDs []int
-	enabled := false
-	if enabledVal, _, err := filtersKey.GetIntegerValue("Enabled"); err == nil {
-		enabled = enabledVal != 0
-	}
+	for _, subkey := range subkeys {
+		// Keys that can't be read don't abort the analysis, the provider is
+		// reported with what could be read and annotated with the errors.
+		var errs []string
 
-	if binaryVal, _, err := filtersKey.GetBinaryValue("EventIds"); err == nil {
-		eventIDs = parseEventIDsBinary(binaryVal)
-	}
-	valueNames := []string{"EventId", "Events", "Id"}
-	for _, valueName := range valueNames {
-		if ids := readEventIDsFromValue(filtersKey, valueName); len(ids) > 0 {
-			eventIDs = append(eventIDs, ids...)
+		name := unresolvedName
+		if !opts.noResolve {
+			var err error
+			if name, err = lookupProviderName(subkey.guid); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		guid := subkey.guid
+		provider := ETWProvider{
+			GUID:          subkey.key,
+			Name:          name,
+			EnabledState:  enabledMissing,
+			DuplicateKeys: subkey.duplicates,
 		}
-	}
-	eventIDs = removeDuplicates(eventIDs)
-	sort.Ints(eventIDs)
 
-	return eventIDs, true, enabled
-}
+		if err := readFilters(key, subkey.key, &provider, opts.rawFilters); err != nil {
+			errs = append(errs, err.Error())
+		}
 
-func parseEventIDsBinary(data []byte) []int {
-	var eventIDs []int
+		if err := readEnableParameters(key, subkey.key, &provider); err != nil {
+			errs = append(errs, err.Error())
+		}
 
-	for i := 0; i+1 < len(data); i += 2 {
-		eventID := binary.LittleEndian.Uint16(data[i : i+2])
-		if eventID > 0 && eventID < 65535 {
-			eventIDs = append(eventIDs, int(eventID))
+		if opts.security {
+			sd, err := readGUIDSecurity(guid)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			provider.Security = sd
 		}
-	}
 
-	if len(eventIDs) == 0 {
-		for i := 0; i+3 < len(data); i += 4 {
-			eventID := binary.LittleEndian.Uint32(data[i : i+4])
-			if eventID > 0 && eventID < 65535 {
-				eventIDs = append(eventIDs, int(eventID))
+		if !opts.noResolve {
+			provider.Description = getProviderDescription(guid)
+			provider.Binary = resolveProviderBinary(guid)
+			provider.Resources = resolveProviderResources(guid)
+			provider.Vendor = classifyVendor(provider)
+			provider.ProviderType = classifyProviderType(provider)
+			if provider.ProviderType == providerTypeGroup {
+				provider.GroupMembers = resolveGroupMembers(guid)
+				if strings.HasPrefix(provider.Name, "(") {
+					provider.Name = providerGroupName
+				}
 			}
+			provider.Orphaned = isOrphanedProvider(provider)
+			provider.EventNames = resolveEventNames(guid, provider.EventIDs)
+			provider.MatchAnyKeywordNames = resolveKeywordNames(guid, provider.MatchAnyKeyword)
+			provider.MatchAllKeywordNames = resolveKeywordNames(guid, provider.MatchAllKeyword)
+			provider.ManifestLevels = resolveManifestFields(guid, eventLevelInformation)
+			provider.ManifestChannels = resolveManifestFields(guid, eventChannelInformation)
+			provider.Coverage = computeCoverage(provider)
+			provider.EventChannels = resolveEventChannels(provider)
+			provider.Sessions = getProviderSessions(guid)
 		}
+
+		provider.Error = strings.Join(errs, "; ")
+		providers = append(providers, provider)
 	}
+	sort.Slice(providers, func(i, j int) bool {
+		return canonicalGUID(providers[i].GUID) < canonicalGUID(providers[j].GUID)
+	})
 
-	return eventIDs
+	return providers, invalid, nil
 }
 
-func readEventIDsFromValue(key registry.Key, valueName string) []int {
-	var eventIDs []int
-
-	if dwordVal, _, err := key.GetIntegerValue(valueName); err == nil {
-		if dwordVal <= 65535 {
-			eventIDs = append(eventIDs, int(dwordVal))
-		}
-		return eventIDs
+// readEnableParameters reads the level, keyword masks and enable properties
+// the provider is enabled with. Missing values are left at 0. Enabled is
+// read from the provider key, where ETW looks for it, and takes precedence
+// over one under Filters.
+func readEnableParameters(parentKey registry.Key, providerGUID string, provider *ETWProvider) error {
+	providerKey, err := openKey(parentKey, providerGUID, registry.READ)
+	if err != nil {
+		slog.Warn("cannot open provider key", "provider", providerGUID, "error", err)
+		return fmt.Errorf("reading enable parameters: %v", err)
 	}
+	defer providerKey.Close()
 
-	if binaryVal, _, err := key.GetBinaryValue(valueName); err == nil {
-		return parseEventIDsBinary(binaryVal)
+	if enabled, _, err := providerKey.GetIntegerValue("Enabled"); err == nil {
+		provider.setEnabled(enabled)
 	}
+	provider.EnableLevel, _, _ = providerKey.GetIntegerValue("EnableLevel")
+	provider.MatchAnyKeyword, _, _ = providerKey.GetIntegerValue("MatchAnyKeyword")
+	provider.MatchAllKeyword, _, _ = providerKey.GetIntegerValue("MatchAllKeyword")
+	provider.EnableProperty, _, _ = providerKey.GetIntegerValue("EnableProperty")
+	provider.OtherValues = readOtherValues(providerKey, knownProviderValues)
 
-	return eventIDs
+	return nil
 }
 
 func removeDuplicates(slice []int) []int {
@@ -456,33 +1225,44 @@ func removeDuplicates(slice []int) []int {
 }
 
 func resolveProviderName(guid string) string {
-	publishersPath := `SOFTWARE\Microsoft\Windows\CurrentVersion\WINEVT\Publishers\` + guid
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, publishersPath, registry.READ)
+	name, _ := lookupProviderName(guid)
+	return name
+}
+
+// readProviderName resolves the name of a provider from the registry and the
+// fallbacks, without the cache of lookupProviderName.
+func readProviderName(guid string) (string, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, publishersPath+`\`+guid, registry.READ)
 	if err != nil {
-		return resolveFromWMI(guid)
+		slog.Debug("provider is not a registered publisher, trying WMI", "provider", guid, "error", err)
+		if err != registry.ErrNotExist {
+			return resolveFromWMI(guid), fmt.Errorf("reading publisher: %v", err)
+		}
+		return resolveFromWMI(guid), nil
 	}
 	defer key.Close()
 
 	if name, _, err := key.GetStringValue(""); err == nil && name != "" {
-		return name
+		return name, nil
 	}
 
 	if name, _, err := key.GetStringValue("Name"); err == nil && name != "" {
-		return name
+		return name, nil
 	}
 
 	if name, _, err := key.GetStringValue("DisplayName"); err == nil && name != "" {
-		return name
+		return name, nil
 	}
 
-	return resolveFromWMI(guid)
+	slog.Debug("publisher key has no name, trying WMI", "provider", guid)
+	return resolveFromWMI(guid), nil
 }
 
 func resolveFromWMI(guid string) string {
-	wmiPath := `SYSTEM\CurrentControlSet\Control\WMI\{` + strings.Trim(guid, "{}") + `}`
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, wmiPath, registry.READ)
+	key, err := openKey(registry.LOCAL_MACHINE, wmiPath+`\`+canonicalGUID(guid), registry.READ)
 	if err != nil {
-		return "(Unknown Provider)"
+		slog.Debug("provider is not in the WMI key, trying the provider database", "provider", guid, "error", err)
+		return resolveFromProviderDB(guid)
 	}
 	defer key.Close()
 
@@ -494,5 +1274,6 @@ func resolveFromWMI(guid string) string {
 		return name
 	}
 
-	return "(Unknown Provider)"
+	slog.Debug("WMI key has no name, trying the provider database", "provider", guid)
+	return resolveFromProviderDB(guid)
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeManifest records the SHA-256 hash of every artifact in sha256sum
+// format, so a collection can be checked with sha256sum -c or
+// Get-FileHash.
+func writeManifest(manifestPath string, artifacts []string) error {
+	var buf bytes.Buffer
+	for _, path := range artifacts {
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s  %s\n", sum, filepath.Base(path))
+	}
+
+	return writeAtomically(manifestPath, buf.Bytes())
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// signManifest signs the manifest with an Ed25519 key stored as a PKCS#8 PEM
+// file (openssl genpkey -algorithm ed25519) and writes a minisign compatible
+// signature next to it. It returns the public key in minisign format so the
+// signature can be checked with minisign -Vm <manifest> -P <key>.
+func signManifest(manifestPath, keyPath string) (string, error) {
+	priv, err := loadEd25519Key(keyPath)
+	if err != nil {
+		return "", err
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+
+	message, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return "", err
+	}
+
+	// minisign identifies keys by an 8 byte ID; derive it from the public key
+	// so the same key always yields the same ID.
+	keyHash := sha256.Sum256(pub)
+	keyID := keyHash[:8]
+
+	sigBlob := append([]byte("Ed"), keyID...)
+	sigBlob = append(sigBlob, ed25519.Sign(priv, message)...)
+	signature := sigBlob[10:]
+
+	trustedComment := fmt.Sprintf("timestamp:%d\tfile:%s", time.Now().Unix(), filepath.Base(manifestPath))
+	globalSig := ed25519.Sign(priv, append(append([]byte{}, signature...), trustedComment...))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "untrusted comment: signature from autologgerAnalyzer\n")
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(sigBlob))
+	fmt.Fprintf(&out, "trusted comment: %s\n", trustedComment)
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(globalSig))
+
+	if err := writeAtomically(manifestPath+".minisig", out.Bytes()); err != nil {
+		return "", err
+	}
+
+	pubBlob := append(append([]byte("Ed"), keyID...), pub...)
+	return base64.StdEncoding.EncodeToString(pubBlob), nil
+}
+
+func loadEd25519Key(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %v", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", path)
+	}
+
+	return priv, nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeMarkdownReport renders a report as GitHub-flavored markdown so it can be
+// pasted into tickets and wikis as-is.
+func writeMarkdownReport(w io.Writer, report *AutologgerReport) error {
+	bw := bufio.NewWriter(w)
+	config := report.Config
+
+	fmt.Fprintf(bw, "# Autologger: %s\n\n", markdownEscape(config.Name))
+
+	fmt.Fprintf(bw, "## Configuration\n\n")
+	fmt.Fprintf(bw, "| Property | Type | Value |\n")
+	fmt.Fprintf(bw, "|----------|------|-------|\n")
+	for _, row := range configRows(config) {
+		fmt.Fprintf(bw, "| %s | %s | `%s` |\n", row.Property, row.Type, row.Value)
+	}
+
+	fmt.Fprintf(bw, "\n### Configuration Details\n\n")
+	fmt.Fprintf(bw, "```\n")
+	fmt.Fprintf(bw, "Start:       %s\n", getStartStatus(config.Start))
+	fmt.Fprintf(bw, "Status:      %s\n", getStatusDescription(config.Status))
+	fmt.Fprintf(bw, "Running:     %s\n", getSessionStateDescription(config))
+	fmt.Fprintf(bw, "LogFileMode: %s\n", getLogFileModeDescription(config.LogFileMode))
+	fmt.Fprintf(bw, "ClockType:   %s\n", getClockTypeDescription(config.ClockType))
+	if warning := clockTypeWarning(config); warning != "" {
+		fmt.Fprintf(bw, "             Warning: %s\n", warning)
+	}
+	if config.SessionKind != "" {
+		fmt.Fprintf(bw, "Session:     %s\n", getSessionKindDescription(config))
+		if warning := kernelGUIDWarning(config); warning != "" {
+			fmt.Fprintf(bw, "             Warning: %s\n", warning)
+		}
+		fmt.Fprintf(bw, "Slots:       %s\n", getSystemLoggerSlotsDescription())
+		if warning := systemLoggerSlotWarning(config); warning != "" {
+			fmt.Fprintf(bw, "             Warning: %s\n", warning)
+		}
+	}
+	if config.EnableFlags != 0 {
+		fmt.Fprintf(bw, "EnableFlags: %s\n", getKernelFlagsDescription(config.EnableFlags))
+	}
+	if groups := getGroupMaskGroups(config.GroupMask); len(groups) > 0 {
+		fmt.Fprintf(bw, "Extended:    %s\n", strings.Join(groups, " | "))
+	}
+	fmt.Fprintf(bw, "Log File:    %s\n", getLogFileDescription(config))
+	fmt.Fprintf(bw, "Memory:      %s\n", getMemoryDescription(config))
+	if config.Session != nil {
+		fmt.Fprintf(bw, "Live:        %s\n", getSessionStatsDescription(config.Session))
+	}
+	fmt.Fprintf(bw, "```\n\n")
+
+	if sd := config.Security; sd != nil {
+		fmt.Fprintf(bw, "### Session Security\n\n%s: `%s`\n\n", getSecuritySourceLabel(sd), sd.SDDL)
+		for _, ace := range getEffectiveACEs(sd) {
+			desc := markdownEscape(getACEDescription(ace))
+			if isWeakACE(ace) {
+				desc = "**" + desc + "**"
+			}
+			fmt.Fprintf(bw, "- %s\n", desc)
+		}
+		fmt.Fprintln(bw)
+	}
+
+	if isKernelSession(config) && len(report.Providers) == 0 {
+		groups := getKernelGroups(config)
+		fmt.Fprintf(bw, "## Kernel Event Groups (%d enabled)\n\n", len(groups))
+		for _, group := range groups {
+			fmt.Fprintf(bw, "- `%s`\n", group)
+		}
+		return bw.Flush()
+	}
+
+	fmt.Fprintf(bw, "## ETW Providers (%d found)\n\n", len(report.Providers))
+	fmt.Fprintf(bw, "| GUID | Provider Name | Vendor | Type | Enabled | Level | MatchAnyKeyword | MatchAllKeyword | EnableProperty | Event IDs |\n")
+	fmt.Fprintf(bw, "|------|---------------|--------|------|---------|-------|-----------------|-----------------|----------------|-----------|\n")
+	for _, provider := range report.Providers {
+		enabledStr := getEnabledLabel(provider)
+
+		eventIDsStr := "No Filters"
+		if provider.HasFilters {
+			if len(provider.EventIDs) > 0 {
+				eventIDsStr = getEventIDFilterLabel(provider, formatEventIDRanges(provider.EventIDs))
+			} else {
+				eventIDsStr = "No Event IDs"
+			}
+		}
+		if provider.Error != "" {
+			eventIDsStr = "**Error:** " + markdownEscape(provider.Error)
+		}
+
+		fmt.Fprintf(bw, "| `%s` | %s | %s | %s | %s | %s | `%s` | `%s` | %s | %s |\n",
+			provider.GUID,
+			markdownEscape(provider.Name),
+			getVendorLabel(provider.Vendor),
+			getProviderTypeLabel(provider.ProviderType),
+			enabledStr,
+			getProviderLevelName(provider),
+			formatKeyword(provider.MatchAnyKeyword),
+			formatKeyword(provider.MatchAllKeyword),
+			getEnablePropertyDescription(provider.EnableProperty),
+			eventIDsStr)
+	}
+
+	if summary := getVendorSummary(report.Providers); summary != "" {
+		fmt.Fprintf(bw, "\nVendors: %s\n", summary)
+	}
+
+	fmt.Fprintf(bw, "\n## Detailed Event IDs\n")
+	for _, provider := range report.Providers {
+		if provider.HasFilters && len(provider.EventIDs) > 0 {
+			fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+			fmt.Fprintf(bw, "```\n%s\n```\n", getEventIDFilterLabel(provider, formatEventIDRanges(provider.EventIDs)))
+			if len(provider.EventNames) > 0 {
+				fmt.Fprintln(bw)
+				for _, event := range provider.EventNames {
+					fmt.Fprintf(bw, "- %s\n", markdownEscape(getEventNameDescription(event)))
+				}
+			}
+		}
+	}
+
+	if covered := providersWithCoverage(report.Providers); len(covered) > 0 {
+		fmt.Fprintf(bw, "\n## Event Coverage\n\n")
+		for _, provider := range covered {
+			desc := getCoverageDescription(provider.Coverage)
+			if provider.Coverage.Low() {
+				desc = "**" + desc + "**"
+			}
+			fmt.Fprintf(bw, "- %s (`%s`): %s\n", markdownEscape(provider.Name), provider.GUID, desc)
+		}
+	}
+
+	if routed := providersWithEventChannels(report.Providers); len(routed) > 0 {
+		fmt.Fprintf(bw, "\n## Event Channel Routing\n")
+		for _, provider := range routed {
+			fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+			for _, r := range provider.EventChannels {
+				desc := markdownEscape(getEventChannelRouteDescription(r))
+				if !r.Enabled {
+					desc = "**" + desc + "**"
+				}
+				fmt.Fprintf(bw, "- %s\n", desc)
+			}
+		}
+	}
+
+	if owned := providersWithBinaries(report.Providers); len(owned) > 0 {
+		fmt.Fprintf(bw, "\n## Provider Binaries\n\n")
+		for _, provider := range owned {
+			desc := markdownEscape(getBinaryDescription(provider.Binary))
+			if binaryFlagged(provider.Binary) {
+				desc = "**" + desc + "**"
+			}
+			fmt.Fprintf(bw, "- %s (`%s`): %s\n", markdownEscape(provider.Name), provider.GUID, desc)
+		}
+	}
+
+	if unusable := providersWithUnusableResources(report.Providers); len(unusable) > 0 {
+		fmt.Fprintf(bw, "\n## Provider Resources\n")
+		for _, provider := range unusable {
+			fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+			for _, r := range unusableResources(provider) {
+				fmt.Fprintf(bw, "- **%s**\n", markdownEscape(getResourceDescription(r)))
+			}
+		}
+	}
+
+	if typed := providersWithTypeNotes(report.Providers); len(typed) > 0 {
+		fmt.Fprintf(bw, "\n## Provider Types\n\n")
+		for _, provider := range typed {
+			fmt.Fprintf(bw, "- %s (`%s`): %s\n", markdownEscape(provider.Name), provider.GUID, getProviderTypeNote(provider))
+		}
+	}
+
+	if groups := providersGroups(report.Providers); len(groups) > 0 {
+		fmt.Fprintf(bw, "\n## Provider Groups\n")
+		for _, provider := range groups {
+			note := getProviderGroupNote(provider)
+			if !groupEnabled(provider) {
+				note = "**" + note + "**"
+			}
+			fmt.Fprintf(bw, "\n### %s (`%s`)\n\n%s\n", markdownEscape(provider.Name), provider.GUID, note)
+			if len(provider.GroupMembers) > 0 {
+				fmt.Fprintln(bw)
+				for _, m := range provider.GroupMembers {
+					fmt.Fprintf(bw, "- %s (`%s`)\n", markdownEscape(m.Name), m.GUID)
+				}
+			}
+		}
+	}
+
+	if orphaned := providersOrphaned(report.Providers); len(orphaned) > 0 {
+		fmt.Fprintf(bw, "\n## Orphaned Providers\n\n")
+		for _, provider := range orphaned {
+			fmt.Fprintf(bw, "- %s (`%s`): **not registered on this host**\n", markdownEscape(provider.Name), provider.GUID)
+		}
+	}
+
+	if enabled := providersWithSessions(report.Providers); len(enabled) > 0 {
+		fmt.Fprintf(bw, "\n## Live Sessions\n")
+		for _, provider := range enabled {
+			fmt.Fprintf(bw, "\n### %s (`%s`)\n\n%d other session(s)\n\n", markdownEscape(provider.Name), provider.GUID, len(otherSessions(provider)))
+			for _, s := range provider.Sessions {
+				fmt.Fprintf(bw, "- %s\n", markdownEscape(getSessionEnableDescription(s)))
+			}
+		}
+	}
+
+	if mismatched := providersWithLiveMismatches(report.Providers); len(mismatched) > 0 {
+		fmt.Fprintf(bw, "\n## Live Enable Parameters\n")
+		for _, provider := range mismatched {
+			fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+			for _, d := range provider.LiveMismatches {
+				fmt.Fprintf(bw, "- **%s**\n", markdownEscape(getLiveMismatchDescription(d)))
+			}
+		}
+	}
+
+	if secured := providersWithSecurity(report.Providers); len(secured) > 0 {
+		fmt.Fprintf(bw, "\n## Provider Security\n")
+		for _, provider := range secured {
+			fmt.Fprintf(bw, "\n### %s (`%s`)\n\n%s: `%s`\n\n", markdownEscape(provider.Name), provider.GUID,
+				getSecuritySourceLabel(provider.Security), provider.Security.SDDL)
+			for _, ace := range getEffectiveACEs(provider.Security) {
+				fmt.Fprintf(bw, "- %s\n", markdownEscape(getACEDescription(ace)))
+			}
+		}
+	}
+
+	header := false
+	for _, provider := range providersWithKeywordNames(report.Providers) {
+		if !header {
+			fmt.Fprintf(bw, "\n## Keywords\n")
+			header = true
+		}
+		fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+		if len(provider.MatchAnyKeywordNames) > 0 {
+			fmt.Fprintf(bw, "- MatchAnyKeyword %s\n", getKeywordNamesDescription(provider.MatchAnyKeyword, provider.MatchAnyKeywordNames))
+		}
+		if len(provider.MatchAllKeywordNames) > 0 {
+			fmt.Fprintf(bw, "- MatchAllKeyword %s\n", getKeywordNamesDescription(provider.MatchAllKeyword, provider.MatchAllKeywordNames))
+		}
+	}
+
+	header = false
+	for _, provider := range providersWithManifestFields(report.Providers) {
+		if !header {
+			fmt.Fprintf(bw, "\n## Manifest Levels and Channels\n")
+			header = true
+		}
+		fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+		if len(provider.ManifestLevels) > 0 {
+			fmt.Fprintf(bw, "- Levels: %s\n", markdownEscape(getManifestFieldsDescription(provider.ManifestLevels)))
+		}
+		if len(provider.ManifestChannels) > 0 {
+			fmt.Fprintf(bw, "- Channels: %s\n", markdownEscape(getManifestFieldsDescription(provider.ManifestChannels)))
+		}
+	}
+
+	header = false
+	for _, provider := range report.Providers {
+		if len(provider.Warnings) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(bw, "\n## Filter Warnings\n")
+			header = true
+		}
+		fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+		for _, warning := range provider.Warnings {
+			fmt.Fprintf(bw, "- %s\n", markdownEscape(warning))
+		}
+	}
+
+	header = false
+	for _, provider := range report.Providers {
+		if len(provider.Channels) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(bw, "\n## Event Log Channels\n")
+			header = true
+		}
+		fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+		for _, c := range provider.Channels {
+			fmt.Fprintf(bw, "- %s\n", markdownEscape(getChannelDescription(c)))
+		}
+	}
+
+	header = false
+	for _, provider := range report.Providers {
+		if len(provider.RawFilters) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(bw, "\n## Raw Filters\n")
+			header = true
+		}
+		fmt.Fprintf(bw, "\n### %s (`%s`)\n\n```\n", markdownEscape(provider.Name), provider.GUID)
+		for _, v := range provider.RawFilters {
+			writeHexDump(bw, v, "")
+		}
+		fmt.Fprintf(bw, "```\n")
+	}
+
+	header = false
+	for _, provider := range report.Providers {
+		if len(provider.OtherValues) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(bw, "\n## Other Provider Values\n")
+			header = true
+		}
+		fmt.Fprintf(bw, "\n### %s (`%s`)\n\n", markdownEscape(provider.Name), provider.GUID)
+		fmt.Fprintf(bw, "| Name | Type | Data |\n|------|------|------|\n")
+		for _, v := range provider.OtherValues {
+			fmt.Fprintf(bw, "| %s | %s | `%s` |\n", markdownEscape(v.Name), v.Type, v.Data)
+		}
+	}
+
+	if len(report.InvalidSubkeys) > 0 {
+		fmt.Fprintf(bw, "\n## Invalid Subkeys\n\n")
+		for _, subkey := range report.InvalidSubkeys {
+			fmt.Fprintf(bw, "- `%s`\n", subkey)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// markdownEscape escapes characters that would break a markdown table cell.
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func joinInts(values []int, sep string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%d", v)
+	}
+	return strings.Join(parts, sep)
+}
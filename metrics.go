@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsState holds the exposition text of the most recent scan.
+type metricsState struct {
+	mu   sync.RWMutex
+	text string
+}
+
+func (m *metricsState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, m.text)
+}
+
+// runMetricsDaemon rescans all autologgers every interval and serves the
+// results in the Prometheus text format on addr under /metrics.
+func runMetricsDaemon(addr string, interval time.Duration) error {
+	state := &metricsState{}
+	state.text = scanMetrics()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			text := scanMetrics()
+			state.mu.Lock()
+			state.text = text
+			state.mu.Unlock()
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", state)
+	slog.Info("serving metrics", "addr", addr, "path", "/metrics", "interval", interval)
+	return http.ListenAndServe(addr, mux)
+}
+
+// scanMetrics analyzes every autologger and renders the gauges.
+func scanMetrics() string {
+	names, err := getAutologgerNames()
+	if err != nil {
+		slog.Error("reading autologger names", "error", err)
+	}
+
+	var enabled, providers, running, eventsLost, findings []string
+	scanErrors := 0
+	for _, name := range names {
+		report, err := analyzeAutologger(name, analyzeOptions{})
+		if err != nil {
+			scanErrors++
+			continue
+		}
+
+		label := fmt.Sprintf(`{autologger="%s"}`, promLabelEscape(name))
+		enabled = append(enabled, fmt.Sprintf("autologger_enabled%s %d", label, boolToInt(report.Config.Start != 0)))
+		providers = append(providers, fmt.Sprintf("autologger_provider_count%s %d", label, len(report.Providers)))
+		findings = append(findings, fmt.Sprintf("autologger_findings%s %d", label, len(report.Findings)))
+
+		// analyzeAutologger found the session like show does, also when it
+		// runs under another name than the autologger's.
+		session := report.Config.Session
+		running = append(running, fmt.Sprintf("autologger_session_running%s %d", label, boolToInt(session != nil)))
+		if session != nil {
+			eventsLost = append(eventsLost, fmt.Sprintf("session_events_lost%s %d", label, session.EventsLost))
+		}
+	}
+
+	var sb strings.Builder
+	writeGauge(&sb, "autologger_enabled", "Whether the autologger is configured to start at boot (Start != 0).", enabled)
+	writeGauge(&sb, "autologger_provider_count", "Number of providers configured under the autologger.", providers)
+	writeGauge(&sb, "autologger_findings", "Number of findings raised for the autologger.", findings)
+	writeGauge(&sb, "autologger_session_running", "Whether the live trace session of the autologger is running.", running)
+	writeGauge(&sb, "session_events_lost", "Events lost by the live trace session.", eventsLost)
+	writeGauge(&sb, "autologger_scan_errors", "Autologgers that could not be read during the last scan.", []string{fmt.Sprintf("autologger_scan_errors %d", scanErrors)})
+	writeGauge(&sb, "last_scan_timestamp", "Unix time of the last completed scan.", []string{fmt.Sprintf("last_scan_timestamp %d", time.Now().Unix())})
+
+	return sb.String()
+}
+
+func writeGauge(sb *strings.Builder, name, help string, samples []string) {
+	sort.Strings(samples)
+	fmt.Fprintf(sb, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(sb, "# TYPE %s gauge\n", name)
+	for _, s := range samples {
+		sb.WriteString(s + "\n")
+	}
+}
+
+func promLabelEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
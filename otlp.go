@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+var otlpSeverity = map[string]logspb.SeverityNumber{
+	SeverityLow:    logspb.SeverityNumber_SEVERITY_NUMBER_INFO2,
+	SeverityMedium: logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+	SeverityHigh:   logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+}
+
+// otlpSink exports inventory records and findings as OTLP log records over
+// gRPC, with the host metadata attached as resource attributes.
+type otlpSink struct {
+	conn     *grpc.ClientConn
+	client   collogspb.LogsServiceClient
+	headers  metadata.MD
+	resource *resourcepb.Resource
+	host     *HostMetadata
+}
+
+// newOTLPSink connects to an OTLP/gRPC endpoint (host:port). headers is a
+// comma separated list of key=value pairs sent with every export, e.g. for
+// authentication.
+func newOTLPSink(endpoint, headers string, plaintext bool, host *HostMetadata) (*otlpSink, error) {
+	creds := credentials.NewTLS(&tls.Config{})
+	if plaintext {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP client: %v", err)
+	}
+
+	md := metadata.MD{}
+	for _, pair := range strings.Split(headers, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(pair), "="); ok {
+			md.Append(strings.ToLower(k), v)
+		}
+	}
+
+	resource := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+		otlpString("service.name", siemProduct),
+		otlpString("service.version", host.ToolVersion),
+		otlpString("host.name", host.Hostname),
+		otlpString("host.domain", host.Domain),
+		otlpString("os.type", "windows"),
+		otlpString("os.description", host.OSName),
+		otlpString("os.build_id", host.OSBuild),
+		{Key: "process.elevated", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: host.Elevated}}},
+	}}
+
+	return &otlpSink{
+		conn:     conn,
+		client:   collogspb.NewLogsServiceClient(conn),
+		headers:  md,
+		resource: resource,
+		host:     host,
+	}, nil
+}
+
+func (o *otlpSink) WriteReport(report *AutologgerReport) error {
+	ts := uint64(o.host.CollectedAt.UnixNano())
+	observed := uint64(time.Now().UnixNano())
+
+	var records []*logspb.LogRecord
+	for _, record := range reportRecords(o.host, report) {
+		// The host is already described by the resource attributes.
+		record.Host = nil
+
+		body, err := otlpValueOf(record)
+		if err != nil {
+			return err
+		}
+
+		lr := &logspb.LogRecord{
+			TimeUnixNano:         ts,
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+			SeverityText:         "INFO",
+			Body:                 body,
+			Attributes: []*commonpb.KeyValue{
+				otlpString("autologger.record_type", record.Type),
+				otlpString("autologger.name", record.Autologger),
+			},
+		}
+		if record.Finding != nil {
+			lr.SeverityNumber = otlpSeverity[record.Finding.Severity]
+			lr.SeverityText = strings.ToUpper(record.Finding.Severity)
+			lr.EventName = record.Finding.ID
+		}
+		records = append(records, lr)
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: o.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: siemProduct, Version: o.host.ToolVersion},
+				LogRecords: records,
+			}},
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if len(o.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, o.headers)
+	}
+
+	if _, err := o.client.Export(ctx, req); err != nil {
+		return fmt.Errorf("otlp: %v", err)
+	}
+	return nil
+}
+
+func (o *otlpSink) Close() error {
+	return o.conn.Close()
+}
+
+func otlpString(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// otlpValueOf converts v into an OTLP AnyValue by way of its JSON form, so
+// records keep the same field names as in the JSON outputs.
+func otlpValueOf(v any) (*commonpb.AnyValue, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return otlpAnyValue(generic), nil
+}
+
+func otlpAnyValue(v any) *commonpb.AnyValue {
+	switch val := v.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: val}}
+	case float64:
+		if val == float64(int64(val)) {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(val)}}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: val}}
+	case []any:
+		values := make([]*commonpb.AnyValue, len(val))
+		for i, item := range val {
+			values[i] = otlpAnyValue(item)
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		kvs := make([]*commonpb.KeyValue, 0, len(val))
+		for _, k := range keys {
+			kvs = append(kvs, &commonpb.KeyValue{Key: k, Value: otlpAnyValue(val[k])})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+	default:
+		return &commonpb.AnyValue{}
+	}
+}
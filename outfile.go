@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile collects output in a temporary file next to the destination and
+// only replaces the destination on Commit, so readers never observe a partially
+// written report. In append mode the existing contents are copied into the
+// temporary file first, which keeps appends atomic as well.
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+func createAtomicFile(path string, appendMode bool) (*atomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	a := &atomicFile{File: tmp, path: path}
+
+	// The temporary file is created with mode 0600, an existing destination
+	// keeps its own.
+	if info, err := os.Stat(path); err == nil {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			a.Abort()
+			return nil, fmt.Errorf("failed to set the mode of the output: %v", err)
+		}
+	}
+
+	if appendMode {
+		existing, err := os.Open(path)
+		if err == nil {
+			_, err = io.Copy(tmp, existing)
+			existing.Close()
+			if err != nil {
+				a.Abort()
+				return nil, fmt.Errorf("failed to copy existing output: %v", err)
+			}
+		} else if !os.IsNotExist(err) {
+			a.Abort()
+			return nil, fmt.Errorf("failed to open existing output: %v", err)
+		}
+	}
+
+	return a, nil
+}
+
+// Commit flushes the temporary file and moves it over the destination.
+func (a *atomicFile) Commit() error {
+	if err := a.Sync(); err != nil {
+		a.Abort()
+		return err
+	}
+	if err := a.Close(); err != nil {
+		os.Remove(a.Name())
+		return err
+	}
+	if err := os.Rename(a.Name(), a.path); err != nil {
+		os.Remove(a.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temporary file and leaves the destination untouched.
+func (a *atomicFile) Abort() {
+	a.Close()
+	os.Remove(a.Name())
+}
+
+// writeAtomically writes data to a temporary file next to path and renames
+// it over path, like -out does.
+func writeAtomically(path string, data []byte) error {
+	out, err := createAtomicFile(path, false)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Abort()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := out.Commit(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
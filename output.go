@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AutologgerReport bundles everything collected for a single autologger so it
+// can be rendered by the machine-readable output formats.
+type AutologgerReport struct {
+	Host      *HostMetadata     `json:"host,omitempty"`
+	Config    *AutologgerConfig `json:"config"`
+	Providers []ETWProvider     `json:"providers"`
+	Findings  []Finding         `json:"findings,omitempty"`
+	// InvalidSubkeys are subkeys of the autologger that aren't provider
+	// GUIDs, such as leftovers of removed providers or tampering.
+	InvalidSubkeys []string `json:"invalid_subkeys,omitempty"`
+}
+
+// reportWriter receives reports as they are produced. Streaming formats write
+// each report immediately; document formats buffer until Close.
+type reportWriter interface {
+	WriteReport(report *AutologgerReport) error
+	Close() error
+}
+
+// newReportWriter returns the writer for format. tableWidth limits the width
+// of table output; 0 means unlimited.
+func newReportWriter(format string, w io.Writer, multi bool, host *HostMetadata, tableWidth int, pal palette) reportWriter {
+	switch format {
+	case "json":
+		return &jsonReportWriter{w: w, multi: multi}
+	case "jsonl":
+		return &jsonlReportWriter{enc: json.NewEncoder(w)}
+	case "markdown":
+		return &markdownReportWriter{w: w}
+	case "html":
+		return &htmlReportWriter{w: w, host: host}
+	case "cef":
+		return &cefReportWriter{w: w, host: host}
+	case "leef":
+		return &leefReportWriter{w: w, host: host}
+	case "parquet":
+		return newParquetReportWriter(w, host)
+	case "tsv":
+		return &tsvReportWriter{w: w}
+	default:
+		return &tableReportWriter{w: w, width: tableWidth, pal: pal}
+	}
+}
+
+type tableReportWriter struct {
+	w      io.Writer
+	width  int
+	pal    palette
+	count  int
+	memory memoryTotals
+}
+
+func (t *tableReportWriter) WriteReport(report *AutologgerReport) error {
+	report = withCanonicalGUIDs(report)
+	if t.count > 0 {
+		fmt.Fprintf(t.w, "\n\n")
+	}
+	t.count++
+	t.memory.add(report.Config)
+	displayAutologgerConfig(t.w, report.Config, t.pal)
+	displaySessionSecurity(t.w, report.Config, t.pal)
+	if isKernelSession(report.Config) && len(report.Providers) == 0 {
+		displayKernelGroups(t.w, report.Config)
+	} else {
+		displayETWProviders(t.w, report.Providers, report.Config.Name, t.width, t.pal)
+	}
+	displayChannels(t.w, report.Providers, t.pal)
+	displayRawFilters(t.w, report.Providers)
+	displayInvalidSubkeys(t.w, report.InvalidSubkeys, t.pal)
+	displayFindings(t.w, report.Findings, t.pal)
+	return nil
+}
+
+// Close prints the buffer memory all autologgers can consume together, when
+// more than one was written.
+func (t *tableReportWriter) Close() error {
+	if t.count > 1 {
+		fmt.Fprintf(t.w, "\n\nTotal Buffer Memory: %s\n", t.memory)
+	}
+	if warning := sessionLimitWarning(); warning != "" && t.count > 0 {
+		fmt.Fprintf(t.w, "\n%s\n", t.pal.yellow("Warning: "+warning))
+	}
+	return nil
+}
+
+// tsvReportWriter emits one tab separated line per provider with a header
+// line, for pasting into spreadsheets and for cut/awk.
+type tsvReportWriter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+func (t *tsvReportWriter) WriteReport(report *AutologgerReport) error {
+	if !t.headerWritten {
+		t.headerWritten = true
+		if _, err := fmt.Fprintln(t.w, "autologger\tguid\tname\tenabled\tenable_level\tmatch_any_keyword\tmatch_all_keyword\tenable_property\thas_filters\tevent_ids\tevent_id_filter\tenabled_state\tvendor\tprovider_type"); err != nil {
+			return err
+		}
+	}
+	for _, p := range report.Providers {
+		_, err := fmt.Fprintf(t.w, "%s\t%s\t%s\t%t\t%d\t%s\t%s\t%d\t%t\t%s\t%s\t%s\t%s\t%s\n",
+			tsvField(report.Config.Name),
+			tsvField(p.GUID),
+			tsvField(p.Name),
+			p.Enabled,
+			p.EnableLevel,
+			formatKeyword(p.MatchAnyKeyword),
+			formatKeyword(p.MatchAllKeyword),
+			p.EnableProperty,
+			p.HasFilters,
+			joinInts(p.EventIDs, ","),
+			p.EventIDFilter,
+			p.EnabledState,
+			p.Vendor,
+			p.ProviderType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tsvReportWriter) Close() error { return nil }
+
+// tsvField replaces the characters that would break the line structure.
+func tsvField(s string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// jsonReportWriter emits a single object for one autologger, or an array when
+// several autologgers were analyzed.
+type jsonReportWriter struct {
+	w       io.Writer
+	multi   bool
+	reports []*AutologgerReport
+}
+
+func (j *jsonReportWriter) WriteReport(report *AutologgerReport) error {
+	j.reports = append(j.reports, report)
+	return nil
+}
+
+func (j *jsonReportWriter) Close() error {
+	for _, report := range j.reports {
+		if report.Providers == nil {
+			report.Providers = []ETWProvider{}
+		}
+	}
+
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	if !j.multi && len(j.reports) == 1 {
+		return enc.Encode(j.reports[0])
+	}
+	if j.reports == nil {
+		j.reports = []*AutologgerReport{}
+	}
+	return enc.Encode(j.reports)
+}
+
+// jsonlReportWriter streams one compact JSON object per line.
+type jsonlReportWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlReportWriter) WriteReport(report *AutologgerReport) error {
+	if report.Providers == nil {
+		report.Providers = []ETWProvider{}
+	}
+	return j.enc.Encode(report)
+}
+
+func (j *jsonlReportWriter) Close() error { return nil }
+
+type markdownReportWriter struct {
+	w      io.Writer
+	count  int
+	memory memoryTotals
+}
+
+func (m *markdownReportWriter) WriteReport(report *AutologgerReport) error {
+	if m.count > 0 {
+		if _, err := io.WriteString(m.w, "\n---\n\n"); err != nil {
+			return err
+		}
+	}
+	m.count++
+	m.memory.add(report.Config)
+	return writeMarkdownReport(m.w, withCanonicalGUIDs(report))
+}
+
+func (m *markdownReportWriter) Close() error {
+	if m.count > 1 {
+		if _, err := fmt.Fprintf(m.w, "\n---\n\n**Total buffer memory:** %s\n", m.memory); err != nil {
+			return err
+		}
+	}
+	if warning := sessionLimitWarning(); warning != "" && m.count > 0 {
+		_, err := fmt.Fprintf(m.w, "\n> **Warning:** %s\n", warning)
+		return err
+	}
+	return nil
+}
+
+type htmlReportWriter struct {
+	w       io.Writer
+	host    *HostMetadata
+	reports []*AutologgerReport
+}
+
+func (h *htmlReportWriter) WriteReport(report *AutologgerReport) error {
+	h.reports = append(h.reports, withCanonicalGUIDs(report))
+	return nil
+}
+
+func (h *htmlReportWriter) Close() error {
+	return writeHTMLReport(h.w, h.host, h.reports)
+}
+
+// multiReportWriter fans reports out to the primary output and any sinks.
+type multiReportWriter struct {
+	writers []reportWriter
+}
+
+func withSinks(writer reportWriter, sinks []reportWriter) reportWriter {
+	if len(sinks) == 0 {
+		return writer
+	}
+	return &multiReportWriter{writers: append([]reportWriter{writer}, sinks...)}
+}
+
+func (m *multiReportWriter) WriteReport(report *AutologgerReport) error {
+	for _, w := range m.writers {
+		if err := w.WriteReport(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiReportWriter) Close() error {
+	var firstErr error
+	for _, w := range m.writers {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRow is the flattened inventory record written by -format parquet:
+// one row per provider, with the owning autologger and host denormalized onto
+// it so the file can be queried directly from Spark or DuckDB.
+type parquetRow struct {
+	Hostname       string    `parquet:"hostname"`
+	Domain         string    `parquet:"domain"`
+	OSName         string    `parquet:"os_name"`
+	OSBuild        string    `parquet:"os_build"`
+	CollectedAt    time.Time `parquet:"collected_at,timestamp(millisecond)"`
+	ToolVersion    string    `parquet:"tool_version"`
+	Elevated       bool      `parquet:"elevated"`
+	RegistryView   string    `parquet:"registry_view"`
+	Autologger     string    `parquet:"autologger"`
+	AutologgerGUID string    `parquet:"autologger_guid"`
+	Start          int64     `parquet:"start"`
+	Status         int64     `parquet:"status"`
+	LogFileMode    int64     `parquet:"log_file_mode"`
+	BufferSize     int64     `parquet:"buffer_size"`
+	MinimumBuffers int64     `parquet:"minimum_buffers"`
+	MaximumBuffers int64     `parquet:"maximum_buffers"`
+	EnableFlags    int64     `parquet:"enable_flags"`
+	FileName       string    `parquet:"file_name,optional"`
+	MaxFileSize    int64     `parquet:"max_file_size"`
+	FileSize       int64     `parquet:"file_size"`
+	SessionState   string    `parquet:"session_state,optional"`
+	ProviderGUID   string    `parquet:"provider_guid,optional"`
+	ProviderName   string    `parquet:"provider_name,optional"`
+	Enabled        bool      `parquet:"provider_enabled"`
+	EnabledState   string    `parquet:"provider_enabled_state,optional"`
+	Vendor         string    `parquet:"provider_vendor,optional"`
+	ProviderType   string    `parquet:"provider_type,optional"`
+	HasFilters     bool      `parquet:"provider_has_filters"`
+	EnableLevel    int64     `parquet:"provider_enable_level"`
+	MatchAny       uint64    `parquet:"provider_match_any_keyword"`
+	MatchAll       uint64    `parquet:"provider_match_all_keyword"`
+	EnableProperty int64     `parquet:"provider_enable_property"`
+	EventIDs       []int32   `parquet:"event_ids,list"`
+	EventIDFilter  string    `parquet:"event_id_filter,optional"`
+}
+
+type parquetReportWriter struct {
+	host   *HostMetadata
+	writer *parquet.GenericWriter[parquetRow]
+}
+
+func newParquetReportWriter(w io.Writer, host *HostMetadata) *parquetReportWriter {
+	return &parquetReportWriter{
+		host:   host,
+		writer: parquet.NewGenericWriter[parquetRow](w),
+	}
+}
+
+func (p *parquetReportWriter) WriteReport(report *AutologgerReport) error {
+	c := report.Config
+	base := parquetRow{
+		Hostname:       p.host.Hostname,
+		Domain:         p.host.Domain,
+		OSName:         p.host.OSName,
+		OSBuild:        p.host.OSBuild,
+		CollectedAt:    p.host.CollectedAt,
+		ToolVersion:    p.host.ToolVersion,
+		Elevated:       p.host.Elevated,
+		RegistryView:   p.host.RegistryView,
+		Autologger:     c.Name,
+		AutologgerGUID: c.GUID,
+		Start:          int64(c.Start),
+		Status:         int64(c.Status),
+		LogFileMode:    int64(c.LogFileMode),
+		BufferSize:     int64(c.BufferSize),
+		MinimumBuffers: int64(c.MinimumBuffers),
+		MaximumBuffers: int64(c.MaximumBuffers),
+		EnableFlags:    int64(c.EnableFlags),
+		FileName:       c.FileName,
+		MaxFileSize:    int64(c.MaxFileSize),
+		FileSize:       c.FileSize,
+		SessionState:   c.SessionState,
+	}
+
+	// Autologgers without providers still get a row so they show up in the
+	// inventory.
+	if len(report.Providers) == 0 {
+		_, err := p.writer.Write([]parquetRow{base})
+		return err
+	}
+
+	rows := make([]parquetRow, 0, len(report.Providers))
+	for _, provider := range report.Providers {
+		row := base
+		row.ProviderGUID = provider.GUID
+		row.ProviderName = provider.Name
+		row.Enabled = provider.Enabled
+		row.EnabledState = provider.EnabledState
+		row.Vendor = provider.Vendor
+		row.ProviderType = provider.ProviderType
+		row.HasFilters = provider.HasFilters
+		row.EnableLevel = int64(provider.EnableLevel)
+		row.MatchAny = provider.MatchAnyKeyword
+		row.MatchAll = provider.MatchAllKeyword
+		row.EnableProperty = int64(provider.EnableProperty)
+		row.EventIDFilter = provider.EventIDFilter
+		for _, id := range provider.EventIDs {
+			row.EventIDs = append(row.EventIDs, int32(id))
+		}
+		rows = append(rows, row)
+	}
+
+	_, err := p.writer.Write(rows)
+	return err
+}
+
+func (p *parquetReportWriter) Close() error {
+	return p.writer.Close()
+}
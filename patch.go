@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around a change in a
+// unified diff.
+const diffContext = 3
+
+// writeDocumentDiff writes the differences between two canonical documents
+// as a unified diff of their YAML renderings, or as a JSON Patch that turns
+// a into b.
+func writeDocumentDiff(w io.Writer, format, labelA, labelB string, a, b any) error {
+	if format == "json-patch" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(jsonPatch(a, b))
+	}
+	_, err := io.WriteString(w, unifiedDiff(labelA, labelB, splitLines(renderYAML(a)), splitLines(renderYAML(b))))
+	return err
+}
+
+func splitLines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineEdit is a line of a diff: ' ' for a line both sides have, '-' for a
+// line only a has and '+' for one only b has.
+type lineEdit struct {
+	op   byte
+	line string
+}
+
+// diffLines computes a shortest edit script from a to b with Myers'
+// algorithm.
+func diffLines(a, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	// v[k+offset] is the furthest x reached on diagonal k. trace holds v
+	// before every step d, limited to the diagonals -d..d.
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	var edits []lineEdit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, lineEdit{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, lineEdit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, lineEdit{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, lineEdit{' ', a[x]})
+	}
+	slices.Reverse(edits)
+	return edits
+}
+
+// unifiedDiff formats the differences between a and b as a unified diff
+// with diffContext lines of context, or returns "" if they are the same.
+func unifiedDiff(labelA, labelB string, a, b []string) string {
+	edits := diffLines(a, b)
+	var out strings.Builder
+	// Hunks start and end diffContext lines around the changes, and changes
+	// that are at most twice that apart share a hunk.
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].op != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(edits))
+
+		// The line numbers of the hunk follow from the edits before it.
+		lineA, lineB := 1, 1
+		for _, e := range edits[:start] {
+			if e.op != '+' {
+				lineA++
+			}
+			if e.op != '-' {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				countA++
+			}
+			if e.op != '-' {
+				countB++
+			}
+		}
+		// An empty range is numbered by the line before it.
+		if countA == 0 {
+			lineA--
+		}
+		if countB == 0 {
+			lineB--
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", labelA, labelB)
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// jsonPatch returns the RFC 6902 operations that turn document a into b.
+// Objects are compared key by key and lists element by element; a list
+// that got longer or shorter is changed at its end.
+func jsonPatch(a, b any) []map[string]any {
+	ops := []map[string]any{}
+	diffJSON(&ops, "", a, b)
+	return ops
+}
+
+func diffJSON(ops *[]map[string]any, path string, a, b any) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				va, inA := a[k]
+				vb, inB := b[k]
+				p := path + "/" + escapeJSONPointer(k)
+				switch {
+				case !inB:
+					*ops = append(*ops, map[string]any{"op": "remove", "path": p})
+				case !inA:
+					*ops = append(*ops, map[string]any{"op": "add", "path": p, "value": vb})
+				default:
+					diffJSON(ops, p, va, vb)
+				}
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			common := min(len(a), len(b))
+			for i := range common {
+				diffJSON(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+			}
+			for i := common; i < len(b); i++ {
+				*ops = append(*ops, map[string]any{"op": "add", "path": path + "/" + strconv.Itoa(i), "value": b[i]})
+			}
+			// Removed from the end, so the indexes stay valid.
+			for i := len(a) - 1; i >= common; i-- {
+				*ops = append(*ops, map[string]any{"op": "remove", "path": path + "/" + strconv.Itoa(i)})
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*ops = append(*ops, map[string]any{"op": "replace", "path": path, "value": b})
+	}
+}
+
+// escapeJSONPointer escapes a key for a JSON Pointer (RFC 6901).
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
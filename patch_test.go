@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// applyEdits rebuilds both sides of a diff from its edits.
+func applyEdits(edits []lineEdit) (a, b []string) {
+	for _, e := range edits {
+		if e.op != '+' {
+			a = append(a, e.line)
+		}
+		if e.op != '-' {
+			b = append(b, e.line)
+		}
+	}
+	return a, b
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []string
+		changes int
+	}{
+		{"equal", []string{"a", "b", "c"}, []string{"a", "b", "c"}, 0},
+		{"both empty", nil, nil, 0},
+		{"from empty", nil, []string{"a", "b"}, 2},
+		{"to empty", []string{"a", "b"}, nil, 2},
+		{"insert", []string{"a", "c"}, []string{"a", "b", "c"}, 1},
+		{"delete", []string{"a", "b", "c"}, []string{"a", "c"}, 1},
+		{"replace", []string{"a", "b", "c"}, []string{"a", "x", "c"}, 2},
+		{"reorder", []string{"a", "b", "c", "d"}, []string{"b", "a", "d", "c"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := diffLines(tt.a, tt.b)
+			a, b := applyEdits(edits)
+			if !slices.Equal(a, tt.a) {
+				t.Errorf("edits rebuild a as %q, want %q", a, tt.a)
+			}
+			if !slices.Equal(b, tt.b) {
+				t.Errorf("edits rebuild b as %q, want %q", b, tt.b)
+			}
+			changes := 0
+			for _, e := range edits {
+				if e.op != ' ' {
+					changes++
+				}
+			}
+			if changes != tt.changes {
+				t.Errorf("got %d changed lines, want %d (shortest edit script)", changes, tt.changes)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int) []string {
+		var l []string
+		for i := 1; i <= n; i++ {
+			l = append(l, strings.Repeat("x", i))
+		}
+		return l
+	}
+
+	if got := unifiedDiff("a", "b", lines(5), lines(5)); got != "" {
+		t.Errorf("equal inputs: got %q, want no diff", got)
+	}
+
+	// A change in the middle of 20 lines has 3 lines of context on both
+	// sides.
+	a := lines(20)
+	b := lines(20)
+	b[9] = "changed"
+	want := "--- a/a\n+++ b/b\n@@ -7,7 +7,7 @@\n" +
+		" xxxxxxx\n xxxxxxxx\n xxxxxxxxx\n-xxxxxxxxxx\n+changed\n xxxxxxxxxxx\n xxxxxxxxxxxx\n xxxxxxxxxxxxx\n"
+	if got := unifiedDiff("a", "b", a, b); got != want {
+		t.Errorf("single change:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	// Changes far apart get a hunk each.
+	b = lines(20)
+	b[1], b[18] = "first", "last"
+	got := unifiedDiff("a", "b", a, b)
+	if n := strings.Count(got, "\n@@ "); n != 2 {
+		t.Errorf("distant changes: got %d hunks, want 2:\n%s", n, got)
+	}
+	if !strings.Contains(got, "@@ -1,5 +1,5 @@\n") || !strings.Contains(got, "@@ -16,5 +16,5 @@\n") {
+		t.Errorf("distant changes: unexpected hunk headers:\n%s", got)
+	}
+
+	// Lines added to an empty file are numbered from 0 on the empty side.
+	got = unifiedDiff("a", "b", nil, []string{"new"})
+	if !strings.Contains(got, "@@ -0,0 +1,1 @@\n+new\n") {
+		t.Errorf("insert into empty: unexpected diff:\n%s", got)
+	}
+
+	// Lines appended at the end.
+	got = unifiedDiff("a", "b", lines(2), lines(3))
+	if !strings.Contains(got, "@@ -1,2 +1,3 @@\n") {
+		t.Errorf("append: unexpected hunk header:\n%s", got)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	parse := func(s string) any {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", `{"a":1,"b":[1,2]}`, `{"a":1,"b":[1,2]}`, `[]`},
+		{"replace", `{"a":1}`, `{"a":2}`, `[{"op":"replace","path":"/a","value":2}]`},
+		{"add and remove keys", `{"a":1,"b":2}`, `{"b":2,"c":3}`,
+			`[{"op":"remove","path":"/a"},{"op":"add","path":"/c","value":3}]`},
+		{"nested", `{"p":{"q":[1,2]}}`, `{"p":{"q":[1,3]}}`, `[{"op":"replace","path":"/p/q/1","value":3}]`},
+		{"list grows", `[1]`, `[1,2,3]`,
+			`[{"op":"add","path":"/1","value":2},{"op":"add","path":"/2","value":3}]`},
+		{"list shrinks from the end", `[1,2,3]`, `[1]`,
+			`[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`},
+		{"type change", `{"a":[1]}`, `{"a":"x"}`, `[{"op":"replace","path":"/a","value":"x"}]`},
+		{"escaped keys", `{"a/b":1,"c~d":1}`, `{"a/b":2,"c~d":2}`,
+			`[{"op":"replace","path":"/a~1b","value":2},{"op":"replace","path":"/c~0d","value":2}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(jsonPatch(parse(tt.a), parse(tt.b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parse(string(got)), parse(tt.want)) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeJSONPointer(t *testing.T) {
+	tests := map[string]string{
+		"plain":  "plain",
+		"a/b":    "a~1b",
+		"a~b":    "a~0b",
+		"~/":     "~0~1",
+		"~1":     "~01",
+		"":       "",
+		"{guid}": "{guid}",
+	}
+	for key, want := range tests {
+		if got := escapeJSONPointer(key); got != want {
+			t.Errorf("escapeJSONPointer(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressInterval limits how often the status line is redrawn.
+const progressInterval = 200 * time.Millisecond
+
+// progress draws a single status line on a console while many autologgers
+// are analyzed, so long runs don't look hung. A nil *progress does nothing.
+type progress struct {
+	w         io.Writer
+	total     int
+	done      int
+	providers int
+	errors    int
+	lastLen   int
+	lastDraw  time.Time
+}
+
+// newProgress returns a progress line for total autologgers on stderr, or nil
+// if stderr is not a console or there is only one autologger.
+func newProgress(total int) *progress {
+	if total < 2 || !isConsole(os.Stderr) {
+		return nil
+	}
+	return &progress{w: os.Stderr, total: total}
+}
+
+// step records one analyzed autologger.
+func (p *progress) step(name string, report *AutologgerReport, err error) {
+	if p == nil {
+		return
+	}
+	p.done++
+	if err != nil {
+		// Clear the line for the warning that is logged next; it is redrawn
+		// on the following step.
+		p.errors++
+		p.finish()
+		p.lastDraw = time.Time{}
+		return
+	}
+	if report != nil {
+		p.providers += len(report.Providers)
+	}
+	if p.done < p.total && time.Since(p.lastDraw) < progressInterval {
+		return
+	}
+	p.lastDraw = time.Now()
+	p.draw(fmt.Sprintf("[%d/%d] %d providers, %d errors  %s", p.done, p.total, p.providers, p.errors, name))
+}
+
+// finish clears the status line.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.draw("")
+	fmt.Fprint(p.w, "\r")
+	p.lastLen = 0
+}
+
+func (p *progress) draw(line string) {
+	if width := terminalWidth(p.w); width > 1 && len(line) >= width {
+		line = line[:width-1]
+	}
+	// Overwrite what is left of a longer previous line.
+	pad := max(0, p.lastLen-len(line))
+	fmt.Fprint(p.w, "\r"+line+strings.Repeat(" ", pad))
+	p.lastLen = len(line)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// embeddedProviderDB maps provider GUIDs to their names, so providers are
+// named on systems where the Publishers entries are missing. It is written by
+// providers dump-db on clean reference installs, whose builds its header
+// lists.
+//
+//go:embed providerdb.tsv
+var embeddedProviderDB string
+
+// providerDBEntry is the name and short description of a provider.
+type providerDBEntry struct {
+	name        string
+	description string
+}
+
+// providerDB is the database in use, loaded on first use.
+var providerDB struct {
+	once    sync.Once
+	entries map[string]providerDBEntry
+	// sources are the builds the entries were dumped on.
+	sources []string
+	// warnEmpty warns once that an empty database can't name a provider.
+	warnEmpty sync.Once
+}
+
+// providerDBSourcePrefix starts the header lines naming a build a database
+// was dumped on.
+const providerDBSourcePrefix = "# Source build: "
+
+// parseProviderDB reads a provider database: one provider per line with the
+// GUID, name and an optional description separated by tabs. Empty lines and
+// lines starting with # are ignored, except for the source build lines, which
+// are returned.
+func parseProviderDB(r io.Reader) (map[string]providerDBEntry, []string, error) {
+	entries := make(map[string]providerDBEntry)
+	var sources []string
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if source, ok := strings.CutPrefix(text, providerDBSourcePrefix); ok {
+			sources = append(sources, strings.TrimSpace(source))
+			continue
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 2 {
+			return nil, nil, fmt.Errorf("line %d: expected GUID and name separated by a tab", line)
+		}
+		guid, ok := normalizeGUID(fields[0])
+		if !ok {
+			return nil, nil, fmt.Errorf("line %d: %q is not a GUID", line, fields[0])
+		}
+		entry := providerDBEntry{name: strings.TrimSpace(fields[1])}
+		if len(fields) > 2 {
+			entry.description = strings.TrimSpace(fields[2])
+		}
+		entries[guid] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, sources, nil
+}
+
+// getProviderDB returns the provider database, keyed by normalized GUID.
+func getProviderDB() map[string]providerDBEntry {
+	providerDB.once.Do(func() {
+		entries, sources, err := parseProviderDB(strings.NewReader(embeddedProviderDB))
+		if err != nil {
+			slog.Error("embedded provider database is invalid", "error", err)
+		}
+		providerDB.entries = entries
+		providerDB.sources = sources
+	})
+	return providerDB.entries
+}
+
+// registerProviderDBFlags adds the flag that loads a provider database file
+// to a command.
+func registerProviderDBFlags(fs *flag.FlagSet) {
+	fs.Func("provider-db", "Load a provider database written by providers dump-db, on top of the embedded one", loadProviderDBFile)
+}
+
+// loadProviderDBFile adds the entries of a provider database file to the
+// database in use. Entries of the file replace embedded ones for the same
+// GUID.
+func loadProviderDBFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open provider database: %v", err)
+	}
+	defer f.Close()
+
+	entries, sources, err := parseProviderDB(f)
+	if err != nil {
+		return fmt.Errorf("reading provider database %s: %v", path, err)
+	}
+	db := getProviderDB()
+	if db == nil {
+		db = make(map[string]providerDBEntry)
+		providerDB.entries = db
+	}
+	for guid, entry := range entries {
+		if entry.description == "" {
+			entry.description = db[guid].description
+		}
+		db[guid] = entry
+	}
+	for _, source := range sources {
+		if !slices.Contains(providerDB.sources, source) {
+			providerDB.sources = append(providerDB.sources, source)
+		}
+	}
+	slog.Debug("loaded provider database", "path", path, "providers", len(entries))
+	return nil
+}
+
+// writeProviderDB writes a provider database sorted by GUID, preceded by
+// comment lines.
+func writeProviderDB(w io.Writer, entries map[string]providerDBEntry, comments []string) error {
+	guids := make([]string, 0, len(entries))
+	for guid := range entries {
+		guids = append(guids, guid)
+	}
+	sort.Strings(guids)
+
+	bw := bufio.NewWriter(w)
+	for _, comment := range comments {
+		fmt.Fprintf(bw, "# %s\n", comment)
+	}
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+	for _, guid := range guids {
+		entry := entries[guid]
+		fmt.Fprintf(bw, "%s\t%s", guid, clean.Replace(entry.name))
+		if entry.description != "" {
+			fmt.Fprintf(bw, "\t%s", clean.Replace(entry.description))
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// lookupProviderDB returns the database entry of a provider.
+func lookupProviderDB(guid string) (providerDBEntry, bool) {
+	entry, ok := getProviderDB()[canonicalGUID(guid)]
+	return entry, ok
+}
+
+// getProviderDescription returns the short description of a provider from
+// the database, or "" if it has none.
+func getProviderDescription(guid string) string {
+	entry, _ := lookupProviderDB(guid)
+	return entry.description
+}
+
+// resolveFromProviderDB names a provider the registry doesn't know from the
+// provider database, before enumerating the live providers.
+func resolveFromProviderDB(guid string) string {
+	if entry, ok := lookupProviderDB(guid); ok && entry.name != "" {
+		return entry.name
+	}
+	if len(getProviderDB()) == 0 {
+		// The embedded database ships empty until a reference build is
+		// dumped into it, so providers the registry doesn't know are only
+		// named by a -provider-db file.
+		providerDB.warnEmpty.Do(func() {
+			slog.Warn("the provider database is empty, so providers the registry doesn't know can't be named; load one written by providers dump-db on a reference install with -provider-db")
+		})
+	}
+	slog.Debug("provider is not in the provider database, trying the live providers", "provider", guid)
+	return resolveFromLiveProviders(guid)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ProviderDetails is everything known about a provider on this host, for
+// show provider <guid>.
+type ProviderDetails struct {
+	GUID         string             `json:"guid"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description,omitempty"`
+	ProviderType string             `json:"provider_type"`
+	Vendor       string             `json:"vendor"`
+	Binary       *ProviderBinary    `json:"binary,omitempty"`
+	Resources    []ProviderResource `json:"resources,omitempty"`
+	// Events is the number of events the provider's manifest defines.
+	Events   int             `json:"events"`
+	Keywords []ManifestField `json:"keywords,omitempty"`
+	Levels   []ManifestField `json:"levels,omitempty"`
+	Channels []ManifestField `json:"channels,omitempty"`
+	// Autologgers are the autologgers with a subkey for the provider.
+	Autologgers []ProviderUsage `json:"autologgers"`
+	// Sessions are the live sessions that currently enable the provider.
+	Sessions []SessionEnable `json:"sessions"`
+}
+
+// SessionEnable is a live session enabling a provider, with the parameters
+// it enabled it with.
+type SessionEnable struct {
+	Session         string `json:"session"`
+	LoggerID        uint16 `json:"logger_id"`
+	Level           uint8  `json:"level"`
+	MatchAnyKeyword uint64 `json:"match_any_keyword"`
+	MatchAllKeyword uint64 `json:"match_all_keyword"`
+	EnableProperty  uint32 `json:"enable_property"`
+	// ThisAutologger tells that the session is the one of the autologger
+	// the provider was analyzed in.
+	ThisAutologger bool `json:"this_autologger,omitempty"`
+}
+
+// collectProviderDetails gathers the registration, manifest, autologgers and
+// live sessions of a provider.
+func collectProviderDetails(guid string) (*ProviderDetails, error) {
+	provider := ETWProvider{GUID: guid, Name: resolveProviderName(guid)}
+	provider.Binary = resolveProviderBinary(guid)
+	provider.ProviderType = classifyProviderType(provider)
+
+	usages, err := findProviderUsage(guid)
+	if err != nil {
+		return nil, err
+	}
+	details := &ProviderDetails{
+		GUID:         guid,
+		Name:         provider.Name,
+		Description:  getProviderDescription(guid),
+		ProviderType: provider.ProviderType,
+		Vendor:       classifyVendor(provider),
+		Binary:       provider.Binary,
+		Resources:    resolveProviderResources(guid),
+		Events:       len(getProviderManifest(guid).events),
+		Keywords:     resolveManifestFields(guid, eventKeywordInformation),
+		Levels:       resolveManifestFields(guid, eventLevelInformation),
+		Channels:     resolveManifestFields(guid, eventChannelInformation),
+		Autologgers:  usages,
+		Sessions:     getProviderSessions(guid),
+	}
+	if details.Autologgers == nil {
+		details.Autologgers = []ProviderUsage{}
+	}
+	return details, nil
+}
+
+// getProviderSessions returns the live sessions that enable a provider, one
+// entry per session.
+func getProviderSessions(guid string) []SessionEnable {
+	sessions := []SessionEnable{}
+	instances, err := queryProviderInstances(guid)
+	if err != nil {
+		slog.Debug("cannot query provider instances", "provider", guid, "error", err)
+		return sessions
+	}
+	seen := make(map[uint16]bool)
+	for _, instance := range instances {
+		for _, enable := range instance.enables {
+			if seen[enable.loggerID] {
+				continue
+			}
+			seen[enable.loggerID] = true
+			name, err := getSessionName(enable.loggerID)
+			if err != nil {
+				slog.Debug("cannot query session", "logger_id", enable.loggerID, "error", err)
+				name = fmt.Sprintf("(logger %d)", enable.loggerID)
+			}
+			sessions = append(sessions, SessionEnable{
+				Session:         name,
+				LoggerID:        enable.loggerID,
+				Level:           enable.level,
+				MatchAnyKeyword: enable.matchAnyKeyword,
+				MatchAllKeyword: enable.matchAllKeyword,
+				EnableProperty:  enable.enableProperty,
+			})
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Session < sessions[j].Session
+	})
+	return sessions
+}
+
+// runProviderDetails implements show provider with only a GUID.
+func runProviderDetails(guid, format string) error {
+	details, err := collectProviderDetails(guid)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(details)
+	}
+	displayProviderDetails(os.Stdout, details)
+	return nil
+}
+
+// displayProviderDetails prints everything known about a provider.
+func displayProviderDetails(w io.Writer, d *ProviderDetails) {
+	fmt.Fprintf(w, "Provider %s (%s)\n", d.Name, d.GUID)
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	if d.Description != "" {
+		fmt.Fprintf(w, "Description: %s\n", d.Description)
+	}
+	fmt.Fprintf(w, "Type:        %s\n", getProviderTypeLabel(d.ProviderType))
+	fmt.Fprintf(w, "Vendor:      %s\n", getVendorLabel(d.Vendor))
+	if d.Binary != nil {
+		fmt.Fprintf(w, "Binary:      %s\n", getBinaryDescription(d.Binary))
+	} else {
+		fmt.Fprintf(w, "Binary:      -\n")
+	}
+	for _, r := range d.Resources {
+		fmt.Fprintf(w, "Resource:    %s\n", getResourceDescription(r))
+	}
+
+	if d.Events > 0 || len(d.Keywords) > 0 || len(d.Levels) > 0 || len(d.Channels) > 0 {
+		fmt.Fprintf(w, "\nManifest:\n")
+		fmt.Fprintf(w, "Events:      %d\n", d.Events)
+		if len(d.Levels) > 0 {
+			fmt.Fprintf(w, "Levels:      %s\n", getManifestFieldsDescription(d.Levels))
+		}
+		if len(d.Channels) > 0 {
+			fmt.Fprintf(w, "Channels:    %s\n", getManifestFieldsDescription(d.Channels))
+		}
+		for _, k := range d.Keywords {
+			fmt.Fprintf(w, "Keyword:     0x%X %s\n", k.Value, k.Name)
+		}
+	}
+
+	fmt.Fprintf(w, "\nReferenced by %d autologger(s):\n\n", len(d.Autologgers))
+	if len(d.Autologgers) > 0 {
+		writeProviderUsageTable(w, d.Autologgers)
+	}
+
+	fmt.Fprintf(w, "\nEnabled by %d live session(s):\n", len(d.Sessions))
+	for _, s := range d.Sessions {
+		fmt.Fprintf(w, "- %s\n", getSessionEnableDescription(s))
+	}
+}
+
+// getSessionEnableDescription renders a live session enabling a provider,
+// e.g. "EventLog-System (logger 9): level 4, MatchAnyKeyword 0x8000000000000000,
+// MatchAllKeyword 0x0, EnableProperty -".
+func getSessionEnableDescription(s SessionEnable) string {
+	session := fmt.Sprintf("%s (logger %d)", s.Session, s.LoggerID)
+	if s.ThisAutologger {
+		session = fmt.Sprintf("%s (logger %d, this autologger)", s.Session, s.LoggerID)
+	}
+	return fmt.Sprintf("%s: level %d, MatchAnyKeyword %s, MatchAllKeyword %s, EnableProperty %s",
+		session, s.Level, formatKeyword(s.MatchAnyKeyword), formatKeyword(s.MatchAllKeyword),
+		getEnablePropertyDescription(uint64(s.EnableProperty)))
+}
+
+// otherSessions returns the live sessions enabling a provider besides the
+// one of the autologger it was analyzed in.
+func otherSessions(p ETWProvider) []SessionEnable {
+	var result []SessionEnable
+	for _, s := range p.Sessions {
+		if !s.ThisAutologger {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// providersWithSessions returns the providers that live sessions enable.
+func providersWithSessions(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if len(p.Sessions) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displayProviderSessions lists the live sessions that enable each provider
+// of an autologger, showing which other sessions compete for it.
+func displayProviderSessions(w io.Writer, providers []ETWProvider) {
+	providers = providersWithSessions(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nLive Sessions:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		fmt.Fprintf(w, "\n%s (%s): %d other session(s)\n", p.Name, p.GUID, len(otherSessions(p)))
+		for _, s := range p.Sessions {
+			fmt.Fprintf(w, "- %s\n", getSessionEnableDescription(s))
+		}
+	}
+}
+
+// compareLiveEnable marks the session of the autologger among the ones
+// enabling a provider and compares the parameters it enabled the provider
+// with against the registry. Providers whose sessions weren't resolved,
+// provider groups and disabled providers aren't compared.
+func compareLiveEnable(p *ETWProvider, loggerID uint16) {
+	if p.Sessions == nil {
+		return
+	}
+	var own *SessionEnable
+	for i := range p.Sessions {
+		if p.Sessions[i].LoggerID == loggerID {
+			p.Sessions[i].ThisAutologger = true
+			own = &p.Sessions[i]
+		}
+	}
+	if p.ProviderType == providerTypeGroup || p.EnabledState == enabledOff {
+		return
+	}
+	p.LiveMismatches = compareProviderEnable(*p, own)
+}
+
+// providersWithLiveMismatches returns the providers the running autologger
+// session enables differently from the registry.
+func providersWithLiveMismatches(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if len(p.LiveMismatches) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// getLiveMismatchDescription renders a difference between the registry and
+// the live session, e.g. "EnableLevel: registry Verbose (5), live Info
+// (4)".
+func getLiveMismatchDescription(d Drift) string {
+	return fmt.Sprintf("%s: registry %s, live %s", d.Setting, d.Registry, d.Live)
+}
+
+// displayLiveMismatches lists the providers whose level or keywords in the
+// running session differ from the registry, which points at the session
+// being reconfigured after it started.
+func displayLiveMismatches(w io.Writer, providers []ETWProvider, pal palette) {
+	providers = providersWithLiveMismatches(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nLive Enable Parameters:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		fmt.Fprintf(w, "\n%s (%s):\n", p.Name, p.GUID)
+		for _, d := range p.LiveMismatches {
+			fmt.Fprintf(w, "- %s\n", pal.red(getLiveMismatchDescription(d)))
+		}
+	}
+}
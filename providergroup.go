@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// enablePropertyProviderGroup is EVENT_ENABLE_PROPERTY_PROVIDER_GROUP, which
+// makes ETW enable the GUID as a provider group instead of a provider.
+const enablePropertyProviderGroup = 0x20
+
+// providerGroupName replaces the placeholder name of a group GUID that no
+// provider database knows.
+const providerGroupName = "(Provider Group)"
+
+// GroupMember is a registered provider that belongs to a provider group.
+type GroupMember struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+// isProviderGroup reports whether the GUID of an autologger entry is a
+// provider group: it is enabled with PROVIDER_GROUP, or registered
+// providers name it as their group.
+func isProviderGroup(p ETWProvider) bool {
+	return p.EnableProperty&enablePropertyProviderGroup != 0 ||
+		slices.Contains(getProviderGroupGUIDs(), canonicalGUID(p.GUID))
+}
+
+// groupEnabled reports whether a provider group entry is enabled as a group.
+func groupEnabled(p ETWProvider) bool {
+	return p.EnableProperty&enablePropertyProviderGroup != 0
+}
+
+// resolveGroupMembers returns the registered providers that belong to a
+// provider group, sorted by GUID.
+func resolveGroupMembers(guid string) []GroupMember {
+	guids, err := getProviderGroupMembers(canonicalGUID(guid))
+	if err != nil {
+		slog.Debug("cannot query provider group", "group", guid, "error", err)
+		return nil
+	}
+	slices.Sort(guids)
+	members := make([]GroupMember, 0, len(guids))
+	for _, member := range slices.Compact(guids) {
+		members = append(members, GroupMember{GUID: member, Name: resolveProviderName(member)})
+	}
+	return members
+}
+
+// getProviderGroupNote explains a provider group entry: which providers it
+// enables, or why it doesn't enable them.
+func getProviderGroupNote(p ETWProvider) string {
+	if !groupEnabled(p) {
+		return "provider group, but EnableProperty lacks PROVIDER_GROUP, so ETW enables it as a provider and none of its members"
+	}
+	if len(p.GroupMembers) == 0 {
+		return "provider group without registered members"
+	}
+	return fmt.Sprintf("provider group enabling %d registered providers", len(p.GroupMembers))
+}
+
+// providersGroups returns the entries that are provider groups.
+func providersGroups(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if p.ProviderType == providerTypeGroup {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displayProviderGroups lists the provider groups of an autologger with the
+// providers they expand to.
+func displayProviderGroups(w io.Writer, providers []ETWProvider, pal palette) {
+	groups := providersGroups(providers)
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nProvider Groups:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range groups {
+		note := getProviderGroupNote(p)
+		if !groupEnabled(p) {
+			note = pal.yellow(note)
+		}
+		fmt.Fprintf(w, "\n%s (%s): %s\n", p.Name, p.GUID, note)
+		for _, m := range p.GroupMembers {
+			fmt.Fprintf(w, "- %s (%s)\n", m.Name, m.GUID)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// providerSort orders the providers of a report by one of their columns.
+type providerSort struct {
+	spec string
+	key  string
+	desc bool
+}
+
+var providerSortKeys = []string{"guid", "name", "enabled", "events"}
+
+func (s *providerSort) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.spec, "sort", "guid", "Sort providers by guid, name, enabled or events (event ID count); append :desc to reverse")
+}
+
+func (s *providerSort) validate() error {
+	key, order, _ := strings.Cut(strings.ToLower(s.spec), ":")
+	switch order {
+	case "", "asc":
+	case "desc":
+		s.desc = true
+	default:
+		return fmt.Errorf("invalid -sort order %q (expected asc or desc)", order)
+	}
+	for _, k := range providerSortKeys {
+		if key == k {
+			s.key = key
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid -sort key %q (expected %s)", key, strings.Join(providerSortKeys, ", "))
+}
+
+func (s *providerSort) apply(report *AutologgerReport) {
+	providers := report.Providers
+	less := func(a, b ETWProvider) bool {
+		switch s.key {
+		case "name":
+			if !strings.EqualFold(a.Name, b.Name) {
+				return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+			}
+		case "enabled":
+			if a.Enabled != b.Enabled {
+				return !a.Enabled
+			}
+		case "events":
+			if len(a.EventIDs) != len(b.EventIDs) {
+				return len(a.EventIDs) < len(b.EventIDs)
+			}
+		}
+		return a.GUID < b.GUID
+	}
+
+	sort.SliceStable(providers, func(i, j int) bool {
+		if s.desc {
+			return less(providers[j], providers[i])
+		}
+		return less(providers[i], providers[j])
+	})
+}
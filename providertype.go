@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Kinds of providers, which decide how ETW applies the enable parameters.
+const (
+	providerTypeManifest     = "manifest"
+	providerTypeMOF          = "mof"
+	providerTypeTraceLogging = "tracelogging"
+	// providerTypeWPP is a registered provider without a schema, which is
+	// a WPP provider or a TraceLogging provider with a GUID not derived
+	// from its name, so it is labeled WPP/TL.
+	providerTypeWPP     = "wpp_tracelogging"
+	providerTypeGroup   = "group"
+	providerTypeUnknown = "unknown"
+)
+
+// traceLoggingNamespace is the namespace TraceLogging providers hash their
+// name in to derive their GUID.
+var traceLoggingNamespace = []byte{
+	0x48, 0x2C, 0x2D, 0xB2, 0xC3, 0x90, 0x47, 0xC8,
+	0x87, 0xF8, 0x1A, 0x15, 0xBF, 0xC1, 0x30, 0xFB,
+}
+
+// traceLoggingGUID returns the GUID TraceLogging and EventSource derive
+// from a provider name: a SHA-1 over the namespace and the upper case name
+// in big-endian UTF-16, turned into a version 5 GUID.
+func traceLoggingGUID(name string) string {
+	h := sha1.New()
+	h.Write(traceLoggingNamespace)
+	for _, c := range utf16.Encode([]rune(strings.ToUpper(name))) {
+		h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	b := h.Sum(nil)[:16]
+	b[7] = b[7]&0x0F | 0x50
+	guid := windows.GUID{
+		Data1: binary.LittleEndian.Uint32(b[0:]),
+		Data2: binary.LittleEndian.Uint16(b[4:]),
+		Data3: binary.LittleEndian.Uint16(b[6:]),
+	}
+	copy(guid.Data4[:], b[8:])
+	return canonicalGUID(guid.String())
+}
+
+// classifyProviderType tells how a provider describes its events, or that
+// the GUID is a provider group. Manifest providers have a Publishers entry or a manifest schema in TDH, MOF
+// providers a MOF schema. Providers without a schema whose GUID is the hash
+// of their name are TraceLogging providers, other registered ones are
+// taken for WPP.
+func classifyProviderType(p ETWProvider) string {
+	if isProviderGroup(p) {
+		return providerTypeGroup
+	}
+	guid := canonicalGUID(p.GUID)
+	if key, err := openKey(registry.LOCAL_MACHINE, publishersPath+`\`+guid, registry.READ); err == nil {
+		key.Close()
+		return providerTypeManifest
+	}
+	if live, ok := getLiveProviders()[guid]; ok {
+		switch live.schemaSource {
+		case schemaSourceManifest:
+			return providerTypeManifest
+		case schemaSourceMOF:
+			return providerTypeMOF
+		}
+	}
+	if p.Name != "" && !strings.HasPrefix(p.Name, "(") && traceLoggingGUID(p.Name) == guid {
+		return providerTypeTraceLogging
+	}
+	if slices.Contains(getRegisteredProviderGUIDs(), guid) {
+		return providerTypeWPP
+	}
+	return providerTypeUnknown
+}
+
+// getProviderTypeLabel renders the type of a provider for tables.
+func getProviderTypeLabel(providerType string) string {
+	switch providerType {
+	case providerTypeManifest:
+		return "Manifest"
+	case providerTypeMOF:
+		return "MOF"
+	case providerTypeTraceLogging:
+		return "TraceLogging"
+	case providerTypeWPP:
+		return "WPP/TL"
+	case providerTypeGroup:
+		return "Group"
+	case providerTypeUnknown:
+		return "Unknown"
+	}
+	return "-"
+}
+
+// getProviderTypeNote explains how the enable parameters of a provider
+// differ from the manifest semantics the rest of the output assumes, or
+// returns "" for manifest providers.
+func getProviderTypeNote(p ETWProvider) string {
+	switch p.ProviderType {
+	case providerTypeMOF, providerTypeWPP:
+		note := "classic provider, the low 32 bits of MatchAnyKeyword are passed as its EnableFlags"
+		if p.MatchAllKeyword != 0 {
+			note += ", MatchAllKeyword is ignored"
+		}
+		if p.HasFilters && len(p.EventIDs) > 0 {
+			note += ", event ID filters don't apply"
+		}
+		return note
+	case providerTypeTraceLogging:
+		note := "TraceLogging provider, events are selected by level and keywords"
+		if p.HasFilters && len(p.EventIDs) > 0 {
+			note += ", event ID filters don't apply as events usually have ID 0"
+		}
+		return note
+	}
+	return ""
+}
+
+// providersWithTypeNotes returns the providers whose enable parameters don't
+// follow manifest semantics.
+func providersWithTypeNotes(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if getProviderTypeNote(p) != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displayProviderTypes lists the providers that aren't manifest based, with
+// how ETW applies their enable parameters.
+func displayProviderTypes(w io.Writer, providers []ETWProvider) {
+	providers = providersWithTypeNotes(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nProvider Types:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		fmt.Fprintf(w, "- %s (%s): %s\n", p.Name, p.GUID, getProviderTypeNote(p))
+	}
+}
+
+// isOrphanedProvider reports whether a provider doesn't exist on this host:
+// it has no publisher entry, schema or WMI key, and no process has it
+// registered. Such entries are typically left behind by uninstalled
+// products or copied from another Windows build. TraceLogging providers,
+// recognized by their GUID, only register while their binary runs, so they
+// are never reported; a WPP provider that isn't loaded can't be told apart
+// and is, with a message saying so.
+func isOrphanedProvider(p ETWProvider) bool {
+	switch p.ProviderType {
+	case providerTypeManifest, providerTypeMOF, providerTypeGroup, providerTypeTraceLogging, providerTypeWPP:
+		return false
+	}
+	guid := canonicalGUID(p.GUID)
+	if key, err := openKey(registry.LOCAL_MACHINE, wmiPath+`\`+guid, registry.READ); err == nil {
+		key.Close()
+		return false
+	}
+	if !slices.Contains(getRegisteredProviderGUIDs(), guid) {
+		return true
+	}
+	registered, err := providerHasInstances(guid)
+	if err != nil {
+		slog.Debug("cannot query provider instances", "provider", guid, "error", err)
+		return false
+	}
+	return !registered
+}
+
+// providersOrphaned returns the providers that don't exist on this host.
+func providersOrphaned(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if p.Orphaned {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displayOrphanedProviders lists the providers that don't exist on this host.
+func displayOrphanedProviders(w io.Writer, providers []ETWProvider, pal palette) {
+	providers = providersOrphaned(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nOrphaned Providers:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		fmt.Fprintf(w, "- %s (%s): %s\n", p.Name, p.GUID, pal.yellow("not registered on this host"))
+	}
+}
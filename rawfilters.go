@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// RawFilterValue is a value of a provider's Filters key as stored in the
+// registry, kept with -raw-filters so blobs can be verified by hand.
+type RawFilterValue struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Data    []byte `json:"data"`
+	valType uint32
+}
+
+// readRawFilterValues reads every value of a Filters key.
+func readRawFilterValues(filtersKey registry.Key) ([]RawFilterValue, error) {
+	names, err := filtersKey.ReadValueNames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("listing filter values: %v", err)
+	}
+	slices.Sort(names)
+
+	var values []RawFilterValue
+	for _, name := range names {
+		data, valType, err := readRawValue(filtersKey, name)
+		if err != nil {
+			return values, fmt.Errorf("reading filter value %s: %v", name, err)
+		}
+		values = append(values, RawFilterValue{Name: name, Type: regTypeName(valType), Data: data, valType: valType})
+	}
+	return values, nil
+}
+
+// hexField is a line of an annotated hex dump: the bytes at offset and what
+// they mean.
+type hexField struct {
+	offset int
+	data   []byte
+	note   string
+}
+
+// annotateFilterValue splits a filter value into annotated fields. Event ID
+// filters are decoded field by field, everything else is dumped in rows of 16
+// bytes with the printable characters as note.
+func annotateFilterValue(v RawFilterValue) []hexField {
+	switch {
+	case v.valType == registry.DWORD && len(v.Data) == 4:
+		return []hexField{{0, v.Data, fmt.Sprintf("%s = %d", v.Name, binary.LittleEndian.Uint32(v.Data))}}
+	case v.valType == registry.BINARY && isEventIDValue(v.Name):
+		return annotateEventIDFilter(v.Data)
+	}
+	return hexRows(v.Data, 0)
+}
+
+// annotateEventIDFilter annotates an EVENT_FILTER_EVENT_ID, see
+// parseEventIDFilter.
+func annotateEventIDFilter(data []byte) []hexField {
+	if len(data) < 4 {
+		return append(hexRows(data, 0), hexField{len(data), nil, "too short for an EVENT_FILTER_EVENT_ID header"})
+	}
+
+	mode := eventIDFilterExclude
+	if data[0] != 0 {
+		mode = eventIDFilterInclude
+	}
+	count := int(binary.LittleEndian.Uint16(data[2:4]))
+	countNote := fmt.Sprintf("Count = %d", count)
+	if need := 4 + 2*count; len(data) < need {
+		countNote += fmt.Sprintf(" (needs %d bytes, have %d)", need, len(data))
+	}
+	fields := []hexField{
+		{0, data[0:1], fmt.Sprintf("FilterIn = %d (%s)", data[0], mode)},
+		{1, data[1:2], "Reserved"},
+		{2, data[2:4], countNote},
+	}
+
+	offset := 4
+	for i := 0; i < count && offset+2 <= len(data); i++ {
+		fields = append(fields, hexField{offset, data[offset : offset+2], fmt.Sprintf("Events[%d] = %d", i, binary.LittleEndian.Uint16(data[offset:]))})
+		offset += 2
+	}
+	if offset < len(data) {
+		rows := hexRows(data[offset:], offset)
+		rows[0].note = "trailing bytes: " + rows[0].note
+		fields = append(fields, rows...)
+	}
+	return fields
+}
+
+// hexRows splits data into rows of 16 bytes, noting the printable ASCII
+// characters like hexdump -C.
+func hexRows(data []byte, base int) []hexField {
+	var rows []hexField
+	for i := 0; i < len(data); i += 16 {
+		row := data[i:min(i+16, len(data))]
+		var text strings.Builder
+		for _, b := range row {
+			if b >= 0x20 && b < 0x7F {
+				text.WriteByte(b)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		rows = append(rows, hexField{base + i, row, "|" + text.String() + "|"})
+	}
+	return rows
+}
+
+// isEventIDValue reports whether a Filters value holds event IDs.
+func isEventIDValue(name string) bool {
+	return slices.ContainsFunc(eventIDValueNames, func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// writeHexDump writes the annotated hex dump of a filter value, indented by
+// indent.
+func writeHexDump(w io.Writer, v RawFilterValue, indent string) {
+	fmt.Fprintf(w, "%s%s (%s, %d bytes):\n", indent, v.Name, v.Type, len(v.Data))
+	writeHexFields(w, indent, annotateFilterValue(v))
+}
+
+// writeHexFields writes one line per field: offset, bytes and note.
+func writeHexFields(w io.Writer, indent string, fields []hexField) {
+	for _, f := range fields {
+		hex := make([]string, len(f.data))
+		for i, b := range f.data {
+			hex[i] = fmt.Sprintf("%02x", b)
+		}
+		fmt.Fprintf(w, "%s  %04x  %-47s  %s\n", indent, f.offset, strings.Join(hex, " "), f.note)
+	}
+}
+
+// displayRawFilters prints the hex dumps of the Filters values of every
+// provider read with -raw-filters.
+func displayRawFilters(w io.Writer, providers []ETWProvider) {
+	header := false
+	for _, provider := range providers {
+		if len(provider.RawFilters) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\n\nRaw Filters:\n")
+			fmt.Fprintln(w, strings.Repeat("=", 80))
+			header = true
+		}
+		fmt.Fprintf(w, "\n%s (%s):\n", provider.Name, provider.GUID)
+		for _, v := range provider.RawFilters {
+			writeHexDump(w, v, "")
+		}
+	}
+}
@@ -0,0 +1,661 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procTdhGetEventInformation = modtdh.NewProc("TdhGetEventInformation")
+	procTdhGetPropertySize     = modtdh.NewProc("TdhGetPropertySize")
+	procTdhGetProperty         = modtdh.NewProc("TdhGetProperty")
+)
+
+const (
+	registryTraceSessionName = "autologgerAnalyzer-Registry"
+
+	kernelRegistryGUID = "{70EB4F03-C1DE-4F73-A051-33D13D5413BD}"
+	kernelProcessGUID  = "{22FB2CD6-0E7B-422B-A0C7-2FAD1FD0E716}"
+
+	// kernelProcessKeywordProcess is WINEVENT_KEYWORD_PROCESS of
+	// Microsoft-Windows-Kernel-Process, which has the process start and
+	// stop events.
+	kernelProcessKeywordProcess = 0x10
+
+	// kernelRegistryKeywords are the CreateKey, OpenKey, DeleteKey,
+	// SetValueKey and DeleteValueKey keywords of
+	// Microsoft-Windows-Kernel-Registry. OpenKey is needed to name the
+	// keys of later events that only carry the key object.
+	kernelRegistryKeywords = 0x1000 | 0x2000 | 0x4000 | 0x10000 | 0x20000
+
+	// regCreatedNewKey is the Disposition of a CreateKey event that
+	// created the key instead of opening an existing one.
+	regCreatedNewKey = 1
+)
+
+// Event IDs of Microsoft-Windows-Kernel-Registry and
+// Microsoft-Windows-Kernel-Process.
+const (
+	kernelRegistryCreateKey      = 1
+	kernelRegistryOpenKey        = 2
+	kernelRegistryDeleteKey      = 3
+	kernelRegistrySetValueKey    = 5
+	kernelRegistryDeleteValueKey = 6
+
+	kernelProcessStart = 1
+	kernelProcessStop  = 2
+)
+
+// RegistryWrite is a write to a key under the Autologger key, reported by
+// Microsoft-Windows-Kernel-Registry with the process that made it.
+type RegistryWrite struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Autologger string    `json:"autologger"`
+	Key        string    `json:"key"`
+	Value      string    `json:"value,omitempty"`
+	// Operation is CreateKey, DeleteKey, SetValueKey or DeleteValueKey.
+	Operation string `json:"operation"`
+	Type      string `json:"type,omitempty"`
+	ProcessID uint32 `json:"pid"`
+	// Image is the path of the process's executable, or "" if it exited
+	// before its write was delivered and it was already running when the
+	// watch started.
+	Image string `json:"image,omitempty"`
+}
+
+// registryTrace is the state of the record callback while the Autologger key
+// is traced.
+type registryTrace struct {
+	registryProvider, processProvider windows.GUID
+
+	done   <-chan struct{}
+	writes chan<- RegistryWrite
+	// keys names the key objects of keys under the Autologger key that
+	// were created or opened while tracing.
+	keys map[uint64]string
+	// images holds the executables of processes started while tracing.
+	images map[uint32]string
+	// layouts caches the layout of every event handled, by provider, ID
+	// and version.
+	layouts map[eventLayoutKey]*eventLayout
+}
+
+// registryTracer routes the events of the registry session to the active
+// trace. Callbacks created with NewCallback are never freed, so the process
+// has a single one.
+var registryTracer struct {
+	sync.Mutex
+	callback uintptr
+	trace    *registryTrace
+}
+
+func onRegistryEventRecord(record *eventRecord) uintptr {
+	registryTracer.Lock()
+	defer registryTracer.Unlock()
+	if t := registryTracer.trace; t != nil {
+		t.handle(record)
+	}
+	return 0
+}
+
+// handle tracks keys and processes, and sends the writes under the
+// Autologger key. Only the properties an event needs are decoded, and only
+// for the event IDs that are handled.
+func (t *registryTrace) handle(record *eventRecord) {
+	h := &record.EventHeader
+	id := h.EventDescriptor.Id
+
+	if h.ProviderId == t.processProvider {
+		switch id {
+		case kernelProcessStart:
+			ev := t.properties(record)
+			t.images[uint32(ev.uint("ProcessID"))] = devicePathToDOS(ev.string("ImageName"))
+		case kernelProcessStop:
+			delete(t.images, uint32(t.properties(record).uint("ProcessID")))
+		}
+		return
+	}
+	if h.ProviderId != t.registryProvider {
+		return
+	}
+
+	w := RegistryWrite{Event: "registry_write", ProcessID: h.ProcessId}
+	switch id {
+	case kernelRegistryCreateKey, kernelRegistryOpenKey:
+		ev := t.properties(record)
+		name := ev.string("RelativeName")
+		if base := ev.string("BaseName"); base != "" {
+			name = strings.TrimSuffix(base, `\`) + `\` + name
+		}
+		if !isAutologgerRegistryPath(name) {
+			// The object may have been reused for another key.
+			delete(t.keys, ev.uint("KeyObject"))
+			return
+		}
+		if ev.uint("Status") != 0 {
+			return
+		}
+		t.keys[ev.uint("KeyObject")] = name
+		if id != kernelRegistryCreateKey || ev.uint("Disposition") != regCreatedNewKey {
+			return
+		}
+		w.Key, w.Operation = name, "CreateKey"
+	case kernelRegistryDeleteKey, kernelRegistrySetValueKey, kernelRegistryDeleteValueKey:
+		ev := t.properties(record)
+		w.Key = ev.string("KeyName")
+		if w.Key == "" {
+			w.Key = t.keys[ev.uint("KeyObject")]
+		}
+		if !isAutologgerRegistryPath(w.Key) || ev.uint("Status") != 0 {
+			return
+		}
+		switch id {
+		case kernelRegistryDeleteKey:
+			w.Operation = "DeleteKey"
+			delete(t.keys, ev.uint("KeyObject"))
+		case kernelRegistrySetValueKey:
+			w.Operation = "SetValueKey"
+			w.Value = ev.string("ValueName")
+			w.Type = regTypeName(uint32(ev.uint("Type")))
+		default:
+			w.Operation = "DeleteValueKey"
+			w.Value = ev.string("ValueName")
+		}
+	default:
+		return
+	}
+
+	w.Time = filetimeToTime(h.TimeStamp).Local()
+	w.Key = getRegistryDisplayPath(w.Key)
+	w.Autologger = getAutologgerFromRegistryPath(w.Key)
+	w.Image = t.images[w.ProcessID]
+	if w.Image == "" {
+		w.Image = getProcessImagePath(w.ProcessID)
+	}
+	select {
+	case t.writes <- w:
+	case <-t.done:
+	}
+}
+
+// properties returns the reader of the properties of an event, with the
+// layout of the event looked up once per ID and version.
+func (t *registryTrace) properties(record *eventRecord) eventProperties {
+	h := &record.EventHeader
+	key := eventLayoutKey{provider: h.ProviderId, id: h.EventDescriptor.Id, version: h.EventDescriptor.Version}
+	layout, ok := t.layouts[key]
+	if !ok {
+		var err error
+		if layout, err = getEventLayout(record); err != nil {
+			slog.Debug("cannot read event layout, decoding its properties through TDH", "provider", h.ProviderId, "event", key.id, "error", err)
+		}
+		t.layouts[key] = layout
+	}
+	return eventProperties{record: record, layout: layout}
+}
+
+// isAutologgerRegistryPath reports whether a kernel registry path, e.g.
+// \REGISTRY\MACHINE\SYSTEM\ControlSet001\Control\WMI\Autologger\EventLog-System,
+// is the Autologger key or below it, in any control set.
+func isAutologgerRegistryPath(path string) bool {
+	path = strings.ToLower(path)
+	i := strings.Index(path, `\control\wmi\autologger`)
+	if i < 0 {
+		return false
+	}
+	rest := path[i+len(`\control\wmi\autologger`):]
+	return rest == "" || rest[0] == '\\'
+}
+
+// getRegistryDisplayPath replaces the \REGISTRY\MACHINE root of a kernel
+// registry path with HKLM.
+func getRegistryDisplayPath(path string) string {
+	const root = `\REGISTRY\MACHINE`
+	if len(path) >= len(root) && strings.EqualFold(path[:len(root)], root) {
+		return "HKLM" + path[len(root):]
+	}
+	return path
+}
+
+// getAutologgerFromRegistryPath returns the autologger a path under the
+// Autologger key belongs to, or "" for the Autologger key itself.
+func getAutologgerFromRegistryPath(path string) string {
+	i := strings.Index(strings.ToLower(path), `\control\wmi\autologger\`)
+	if i < 0 {
+		return ""
+	}
+	rest := path[i+len(`\control\wmi\autologger\`):]
+	name, _, _ := strings.Cut(rest, `\`)
+	return name
+}
+
+// eventLayoutKey identifies the events that share a layout.
+type eventLayoutKey struct {
+	provider windows.GUID
+	id       uint16
+	version  uint8
+}
+
+// eventLayout is the order and size of the top-level properties of an event,
+// from its TRACE_EVENT_INFO. Events with structures, arrays or properties
+// whose length is given by another property can't be walked and have no
+// layout.
+type eventLayout struct {
+	fields []layoutField
+}
+
+// layoutField is a top-level property of an event. size is its fixed size
+// in bytes, or 0 if it depends on the pointer size or the data.
+type layoutField struct {
+	name   string
+	inType uint16
+	size   int
+}
+
+// TDH_INTYPE values of the properties an event layout can be walked past.
+const (
+	tdhInTypeUnicodeString = 1
+	tdhInTypeAnsiString    = 2
+	tdhInTypeBinary        = 14
+	tdhInTypePointer       = 16
+	tdhInTypeSID           = 19
+	tdhInTypeSizeT         = 23
+)
+
+// tdhInTypeSizes are the sizes of the fixed-size TDH_INTYPE values.
+var tdhInTypeSizes = map[uint16]int{
+	3: 1, 4: 1, 13: 4, // Int8, UInt8, Boolean
+	5: 2, 6: 2, // Int16, UInt16
+	7: 4, 8: 4, 11: 4, 20: 4, // Int32, UInt32, Float, HexInt32
+	9: 8, 10: 8, 12: 8, 17: 8, 21: 8, // Int64, UInt64, Double, FILETIME, HexInt64
+	15: 16, 18: 16, // GUID, SYSTEMTIME
+}
+
+// getEventLayout reads the layout of an event from its TRACE_EVENT_INFO.
+// It returns nil if the event's properties can't be walked.
+func getEventLayout(record *eventRecord) (*eventLayout, error) {
+	if err := procTdhGetEventInformation.Find(); err != nil {
+		return nil, err
+	}
+	buf, err := tdhCall(func(buf *byte, size *uint32) uintptr {
+		r, _, _ := procTdhGetEventInformation.Call(uintptr(unsafe.Pointer(record)), 0, 0,
+			uintptr(unsafe.Pointer(buf)), uintptr(unsafe.Pointer(size)))
+		return r
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < traceEventInfoMinSize {
+		return nil, fmt.Errorf("TRACE_EVENT_INFO of %d bytes is too short", len(buf))
+	}
+
+	count := int(binary.LittleEndian.Uint32(buf[traceEventInfoPropertyCount:]))
+	count = min(count, (len(buf)-traceEventInfoMinSize)/eventPropertyInfoSize)
+	topLevel := min(int(binary.LittleEndian.Uint32(buf[traceEventInfoTopLevelCount:])), count)
+	layout := &eventLayout{}
+	for i := range topLevel {
+		info := buf[traceEventInfoMinSize+i*eventPropertyInfoSize:]
+		flags := binary.LittleEndian.Uint32(info)
+		if flags&(propertyStruct|propertyParamLength|propertyParamCount) != 0 || binary.LittleEndian.Uint16(info[16:]) > 1 {
+			return nil, nil
+		}
+		field := layoutField{
+			name:   tdhString(buf, binary.LittleEndian.Uint32(info[4:])),
+			inType: binary.LittleEndian.Uint16(info[8:]),
+		}
+		length := int(binary.LittleEndian.Uint16(info[18:]))
+		switch field.inType {
+		case tdhInTypeUnicodeString:
+			field.size = 2 * length
+		case tdhInTypeAnsiString:
+			field.size = length
+		case tdhInTypeBinary:
+			if length == 0 {
+				return nil, nil
+			}
+			field.size = length
+		case tdhInTypePointer, tdhInTypeSizeT, tdhInTypeSID:
+		default:
+			size, ok := tdhInTypeSizes[field.inType]
+			if !ok {
+				return nil, nil
+			}
+			field.size = size
+		}
+		layout.fields = append(layout.fields, field)
+	}
+	return layout, nil
+}
+
+// eventProperties reads the top-level properties of an event record. With a
+// layout, the payload is walked up to the property; otherwise TDH looks it
+// up.
+type eventProperties struct {
+	record *eventRecord
+	layout *eventLayout
+}
+
+// get returns the raw data of a property, or nil if the event has no such
+// property.
+func (p eventProperties) get(name string) []byte {
+	if p.layout == nil {
+		return getEventProperty(p.record, name)
+	}
+	if p.record.UserData == nil {
+		return nil
+	}
+	data := unsafe.Slice((*byte)(p.record.UserData), p.record.UserDataLength)
+	pointerSize := 8
+	if p.record.EventHeader.Flags&eventHeaderFlag32BitHeader != 0 {
+		pointerSize = 4
+	}
+	for _, f := range p.layout.fields {
+		size := f.size
+		switch {
+		case size > 0:
+		case f.inType == tdhInTypePointer || f.inType == tdhInTypeSizeT:
+			size = pointerSize
+		case f.inType == tdhInTypeUnicodeString:
+			size = len(data)
+			for i := 0; i+1 < len(data); i += 2 {
+				if data[i] == 0 && data[i+1] == 0 {
+					size = i + 2
+					break
+				}
+			}
+		case f.inType == tdhInTypeAnsiString:
+			size = len(data)
+			if i := slices.Index(data, 0); i >= 0 {
+				size = i + 1
+			}
+		case f.inType == tdhInTypeSID:
+			// A SID is 8 bytes followed by its sub-authorities.
+			if len(data) < 2 {
+				return nil
+			}
+			size = 8 + 4*int(data[1])
+		}
+		if size > len(data) {
+			return nil
+		}
+		if f.name == name {
+			return data[:size]
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// string returns a string property of the event.
+func (p eventProperties) string(name string) string {
+	return decodeUTF16(p.get(name))
+}
+
+// uint returns an unsigned integer or pointer property of the event, 0 if
+// it has none.
+func (p eventProperties) uint(name string) uint64 {
+	return decodeUint(p.get(name))
+}
+
+// propertyDataDescriptor mirrors PROPERTY_DATA_DESCRIPTOR.
+type propertyDataDescriptor struct {
+	PropertyName uint64
+	ArrayIndex   uint32
+	Reserved     uint32
+}
+
+// getEventProperty returns the raw data of a top-level property of an
+// event, or nil if the event has no such property.
+func getEventProperty(record *eventRecord, name string) []byte {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil
+	}
+	desc := propertyDataDescriptor{PropertyName: uint64(uintptr(unsafe.Pointer(namePtr))), ArrayIndex: ^uint32(0)}
+	defer runtime.KeepAlive(namePtr)
+
+	var size uint32
+	r, _, _ := procTdhGetPropertySize.Call(uintptr(unsafe.Pointer(record)), 0, 0, 1,
+		uintptr(unsafe.Pointer(&desc)), uintptr(unsafe.Pointer(&size)))
+	if r != 0 || size == 0 {
+		return nil
+	}
+	buf := make([]byte, size)
+	r, _, _ = procTdhGetProperty.Call(uintptr(unsafe.Pointer(record)), 0, 0, 1,
+		uintptr(unsafe.Pointer(&desc)), uintptr(size), uintptr(unsafe.Pointer(&buf[0])))
+	if r != 0 {
+		return nil
+	}
+	return buf
+}
+
+// decodeUint decodes a little-endian unsigned integer or pointer property,
+// 0 if it has an unexpected size.
+func decodeUint(data []byte) uint64 {
+	switch len(data) {
+	case 1:
+		return uint64(data[0])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(data))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(data))
+	case 8:
+		return binary.LittleEndian.Uint64(data)
+	}
+	return 0
+}
+
+// getProcessImagePath returns the path of a running process's executable,
+// or "" if it can't be opened.
+func getProcessImagePath(pid uint32) string {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(h)
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return ""
+	}
+	return windows.UTF16ToString(buf[:size])
+}
+
+// dosDevices caches the device each drive letter maps to, e.g.
+// \Device\HarddiskVolume3 for C:.
+var dosDevices struct {
+	once    sync.Once
+	devices map[string]string
+}
+
+// devicePathToDOS turns a device path, e.g.
+// \Device\HarddiskVolume3\Windows\System32\reg.exe, into one with a drive
+// letter. Paths on devices without a drive letter are returned as they are.
+func devicePathToDOS(path string) string {
+	dosDevices.once.Do(func() {
+		dosDevices.devices = make(map[string]string)
+		buf := make([]uint16, windows.MAX_PATH)
+		for letter := 'A'; letter <= 'Z'; letter++ {
+			drive := string(letter) + ":"
+			name, err := windows.UTF16PtrFromString(drive)
+			if err != nil {
+				continue
+			}
+			if n, err := windows.QueryDosDevice(name, &buf[0], uint32(len(buf))); err == nil && n > 0 {
+				dosDevices.devices[drive] = windows.UTF16ToString(buf[:n])
+			}
+		}
+	})
+	for drive, device := range dosDevices.devices {
+		if len(path) > len(device) && strings.EqualFold(path[:len(device)], device) && path[len(device)] == '\\' {
+			return drive + path[len(device):]
+		}
+	}
+	return path
+}
+
+// startRegistryTrace starts the real-time session that receives the
+// registry operations and process starts.
+func startRegistryTrace() (uint64, error) {
+	props := newTraceProperties()
+	props.Wnode.ClientContext = 1
+	props.LogFileMode = eventTraceRealTimeMode
+	props.LogFileNameOffset = 0
+	// Deliver events promptly instead of when buffers fill up.
+	props.FlushTimer = 1
+
+	handle, err := startTrace(registryTraceSessionName, props)
+	if err == windows.ERROR_ALREADY_EXISTS {
+		// Left over from an interrupted watch.
+		if _, err := stopTrace(registryTraceSessionName); err != nil {
+			return 0, fmt.Errorf("failed to stop leftover session %s: %v", registryTraceSessionName, err)
+		}
+		handle, err = startTrace(registryTraceSessionName, props)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to start session %s: %v", registryTraceSessionName, err)
+	}
+
+	if err := enableTrace(handle, kernelRegistryGUID, 5, kernelRegistryKeywords, 0, 0); err != nil {
+		stopTrace(registryTraceSessionName)
+		return 0, fmt.Errorf("failed to enable Microsoft-Windows-Kernel-Registry: %v", err)
+	}
+	if err := enableTrace(handle, kernelProcessGUID, 5, kernelProcessKeywordProcess, 0, 0); err != nil {
+		slog.Warn("cannot enable Microsoft-Windows-Kernel-Process, processes that exit quickly won't be named", "error", err)
+	}
+	return handle, nil
+}
+
+// watchRegistryWrites sends the writes under the Autologger key to writes
+// until ctx is done, and stops its session before it returns.
+func watchRegistryWrites(ctx context.Context, writes chan<- RegistryWrite) error {
+	if _, err := startRegistryTrace(); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := stopTrace(registryTraceSessionName); err != nil {
+			slog.Warn("cannot stop registry session", "session", registryTraceSessionName, "error", err)
+		}
+	}()
+
+	name, err := windows.UTF16PtrFromString(registryTraceSessionName)
+	if err != nil {
+		return err
+	}
+	registryProvider, err := windows.GUIDFromString(kernelRegistryGUID)
+	if err != nil {
+		return err
+	}
+	processProvider, err := windows.GUIDFromString(kernelProcessGUID)
+	if err != nil {
+		return err
+	}
+	registryTracer.Lock()
+	if registryTracer.callback == 0 {
+		registryTracer.callback = windows.NewCallback(onRegistryEventRecord)
+	}
+	registryTracer.trace = &registryTrace{
+		registryProvider: registryProvider,
+		processProvider:  processProvider,
+		done:             ctx.Done(),
+		writes:           writes,
+		keys:             make(map[uint64]string),
+		images:           make(map[uint32]string),
+		layouts:          make(map[eventLayoutKey]*eventLayout),
+	}
+	registryTracer.Unlock()
+	defer func() {
+		registryTracer.Lock()
+		registryTracer.trace = nil
+		registryTracer.Unlock()
+	}()
+
+	logfile := &eventTraceLogfile{
+		LoggerName:          name,
+		ProcessTraceMode:    processTraceModeRealTime | processTraceModeEventRecord,
+		EventRecordCallback: registryTracer.callback,
+	}
+	handle, err := openTrace(logfile, "session "+registryTraceSessionName)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- processTrace(&handle)
+	}()
+	select {
+	case <-ctx.Done():
+		// Closing the handle makes ProcessTrace return.
+		closeTrace(handle)
+		<-done
+		return nil
+	case err := <-done:
+		closeTrace(handle)
+		if err != nil {
+			return fmt.Errorf("failed to consume session %s: %v", registryTraceSessionName, err)
+		}
+		return fmt.Errorf("session %s stopped", registryTraceSessionName)
+	}
+}
+
+// registryWriteFinding describes a registry write for the event log.
+func registryWriteFinding(w RegistryWrite) Finding {
+	target := "key " + w.Key
+	if w.Value != "" {
+		target = fmt.Sprintf("value %s of key %s", w.Value, w.Key)
+	}
+	image := w.Image
+	if image == "" {
+		image = "unknown image"
+	}
+	return Finding{
+		ID:         "AUTOLOGGER_REGISTRY_WRITE",
+		Severity:   SeverityMedium,
+		Autologger: w.Autologger,
+		Message:    fmt.Sprintf("Process %d (%s) performed %s on the registry %s", w.ProcessID, image, w.Operation, target),
+	}
+}
+
+// displayRegistryWrite prints a registry write as a single line, e.g.
+// "08:02:13 EventLog-System\{...}: SetValueKey Enabled (REG_DWORD) by
+// PID 4242 C:\Windows\System32\reg.exe". Deletions are highlighted in red,
+// other writes in yellow.
+func displayRegistryWrite(w io.Writer, write RegistryWrite, pal palette) {
+	path := write.Key
+	if i := strings.Index(strings.ToLower(path), `\control\wmi\autologger`); i >= 0 {
+		path = strings.TrimPrefix(path[i+len(`\control\wmi\autologger`):], `\`)
+	}
+	if path == "" {
+		path = "Autologger"
+	}
+	what := write.Operation
+	if write.Value != "" {
+		what += " " + write.Value
+	}
+	if write.Type != "" {
+		what += " (" + write.Type + ")"
+	}
+	if strings.HasPrefix(write.Operation, "Delete") {
+		what = pal.red(what)
+	} else {
+		what = pal.yellow(what)
+	}
+	image := write.Image
+	if image == "" {
+		image = "(exited)"
+	}
+	fmt.Fprintf(w, "%s %s: %s by PID %d %s\n", write.Time.Format("15:04:05"), path, what, write.ProcessID, image)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const regFileHeader = "Windows Registry Editor Version 5.00"
+
+// exportAutologgerReg writes the registry subtree of an autologger, including
+// its provider subkeys and Filters values, to path in the format produced by
+// regedit so it can be re-imported with reg.exe import.
+func exportAutologgerReg(autologgerName, path string) error {
+	keyPath := baseAutologgerPath + `\` + autologgerName
+	key, err := openKey(registry.LOCAL_MACHINE, keyPath, registry.READ)
+	if err != nil {
+		return fmt.Errorf("failed to open autologger key: %v", err)
+	}
+	defer key.Close()
+
+	var sb strings.Builder
+	sb.WriteString(regFileHeader + "\r\n")
+	if err := writeRegKey(&sb, key, `HKEY_LOCAL_MACHINE\`+keyPath); err != nil {
+		return err
+	}
+
+	// regedit writes .reg files as UTF-16LE with a byte order mark.
+	encoded := utf16.Encode([]rune(sb.String()))
+	buf := make([]byte, 2+2*len(encoded))
+	buf[0], buf[1] = 0xFF, 0xFE
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(buf[2+2*i:], c)
+	}
+
+	return writeAtomically(path, buf)
+}
+
+func writeRegKey(sb *strings.Builder, key registry.Key, fullPath string) error {
+	fmt.Fprintf(sb, "\r\n[%s]\r\n", fullPath)
+
+	valueNames, err := key.ReadValueNames(-1)
+	if err != nil {
+		return fmt.Errorf("failed to read values of %s: %v", fullPath, err)
+	}
+	sort.Strings(valueNames)
+
+	for _, name := range valueNames {
+		data, valType, err := readRawValue(key, name)
+		if err != nil {
+			return fmt.Errorf("failed to read value %s of %s: %v", name, fullPath, err)
+		}
+		sb.WriteString(formatRegValue(name, valType, data))
+	}
+
+	subkeyNames, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return fmt.Errorf("failed to read subkeys of %s: %v", fullPath, err)
+	}
+	sort.Strings(subkeyNames)
+
+	for _, name := range subkeyNames {
+		subkey, err := openKey(key, name, registry.READ)
+		if err != nil {
+			return fmt.Errorf("failed to open %s\\%s: %v", fullPath, name, err)
+		}
+		err = writeRegKey(sb, subkey, fullPath+`\`+name)
+		subkey.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readRawValue returns the undecoded bytes and type of a registry value.
+func readRawValue(key registry.Key, name string) ([]byte, uint32, error) {
+	n, valType, err := key.GetValue(name, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n == 0 {
+		return nil, valType, nil
+	}
+
+	data := make([]byte, n)
+	n, valType, err = key.GetValue(name, data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return data[:n], valType, nil
+}
+
+func formatRegValue(name string, valType uint32, data []byte) string {
+	prefix := "@="
+	if name != "" {
+		prefix = `"` + regEscape(name) + `"=`
+	}
+
+	switch valType {
+	case registry.SZ:
+		return prefix + `"` + regEscape(decodeUTF16(data)) + "\"\r\n"
+	case registry.DWORD:
+		if len(data) == 4 {
+			return prefix + fmt.Sprintf("dword:%08x\r\n", binary.LittleEndian.Uint32(data))
+		}
+	case registry.BINARY:
+		return formatRegHex(prefix+"hex:", data)
+	}
+
+	return formatRegHex(prefix+fmt.Sprintf("hex(%x):", valType), data)
+}
+
+// formatRegHex renders data as comma separated hex bytes, wrapped the same way
+// regedit wraps long values.
+func formatRegHex(prefix string, data []byte) string {
+	var sb strings.Builder
+	sb.WriteString(prefix)
+
+	lineLen := len(prefix)
+	for i, b := range data {
+		part := fmt.Sprintf("%02x", b)
+		if i < len(data)-1 {
+			part += ","
+		}
+		if lineLen+len(part) > 77 && i > 0 {
+			sb.WriteString("\\\r\n  ")
+			lineLen = 2
+		}
+		sb.WriteString(part)
+		lineLen += len(part)
+	}
+	sb.WriteString("\r\n")
+
+	return sb.String()
+}
+
+func regEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func decodeUTF16(data []byte) string {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	for len(u) > 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-1]
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// RegistryValue is a registry value the analyzer doesn't interpret, kept so
+// new or vendor-specific settings aren't silently dropped.
+type RegistryValue struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// Values interpreted by getAutologgerConfig and getETWProviders. Names are
+// lower case, registry value names are case-insensitive.
+var (
+	knownAutologgerValues = map[string]bool{
+		"age": true, "buffersize": true, "clocktype": true, "flushtimer": true, "guid": true,
+		"logfilemode": true, "maximumbuffers": true, "minimumbuffers": true, "start": true,
+		"status": true, "enableflags": true, "filename": true, "maxfilesize": true, "filemax": true,
+		"filecounter": true,
+	}
+	knownProviderValues = map[string]bool{
+		"enabled": true, "enablelevel": true, "matchanykeyword": true, "matchallkeyword": true,
+		"enableproperty": true,
+	}
+)
+
+// readOtherValues returns the values of key that are not in known, sorted by
+// name.
+func readOtherValues(key registry.Key, known map[string]bool) []RegistryValue {
+	names, err := key.ReadValueNames(-1)
+	if err != nil {
+		slog.Debug("cannot enumerate values", "error", err)
+		return nil
+	}
+	sort.Strings(names)
+
+	var values []RegistryValue
+	for _, name := range names {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+		data, valType, err := readRawValue(key, name)
+		if err != nil {
+			slog.Debug("cannot read value", "value", name, "error", err)
+			continue
+		}
+		values = append(values, RegistryValue{Name: name, Type: regTypeName(valType), Data: formatRawValue(valType, data)})
+	}
+	return values
+}
+
+func regTypeName(valType uint32) string {
+	switch valType {
+	case registry.NONE:
+		return "REG_NONE"
+	case registry.SZ:
+		return "REG_SZ"
+	case registry.EXPAND_SZ:
+		return "REG_EXPAND_SZ"
+	case registry.BINARY:
+		return "REG_BINARY"
+	case registry.DWORD:
+		return "REG_DWORD"
+	case registry.DWORD_BIG_ENDIAN:
+		return "REG_DWORD_BIG_ENDIAN"
+	case registry.MULTI_SZ:
+		return "REG_MULTI_SZ"
+	case registry.QWORD:
+		return "REG_QWORD"
+	default:
+		return fmt.Sprintf("REG_TYPE_%d", valType)
+	}
+}
+
+// formatRawValue renders value data for display: numbers in decimal and hex,
+// strings as text and everything else as hex bytes.
+func formatRawValue(valType uint32, data []byte) string {
+	switch {
+	case valType == registry.DWORD && len(data) == 4:
+		v := binary.LittleEndian.Uint32(data)
+		return fmt.Sprintf("%d (0x%X)", v, v)
+	case valType == registry.DWORD_BIG_ENDIAN && len(data) == 4:
+		v := binary.BigEndian.Uint32(data)
+		return fmt.Sprintf("%d (0x%X)", v, v)
+	case valType == registry.QWORD && len(data) == 8:
+		v := binary.LittleEndian.Uint64(data)
+		return fmt.Sprintf("%d (0x%X)", v, v)
+	case valType == registry.SZ || valType == registry.EXPAND_SZ:
+		return decodeUTF16(data)
+	case valType == registry.MULTI_SZ:
+		return strings.Join(decodeMultiSZ(data), "; ")
+	}
+
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, " ")
+}
+
+func decodeMultiSZ(data []byte) []string {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	var items []string
+	start := 0
+	for i, c := range u {
+		if c == 0 {
+			if i > start {
+				items = append(items, string(utf16.Decode(u[start:i])))
+			}
+			start = i + 1
+		}
+	}
+	return items
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// wow64View32 is set by -wow64-32. The 64-bit view is the default, so a
+// 32-bit build or a process under WOW64 sees the same keys as a native 64-bit
+// one.
+var wow64View32 bool
+
+// registerRegistryFlags adds the registry view flag to a command.
+func registerRegistryFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&wow64View32, "wow64-32", false, "Read the 32-bit (WOW6432Node) registry view instead of the 64-bit one")
+}
+
+// getRegistryView returns the WOW64 view every registry key is opened in.
+func getRegistryView() uint32 {
+	if wow64View32 {
+		return registry.WOW64_32KEY
+	}
+	return registry.WOW64_64KEY
+}
+
+// registryViewName describes the registry view in use.
+func registryViewName() string {
+	if wow64View32 {
+		return "32-bit"
+	}
+	return "64-bit"
+}
+
+// openKey opens a registry key in the selected registry view.
+func openKey(k registry.Key, path string, access uint32) (registry.Key, error) {
+	return registry.OpenKey(k, path, access|getRegistryView())
+}
+
+// createKey creates or opens a registry key in the selected registry view.
+func createKey(k registry.Key, path string, access uint32) (registry.Key, bool, error) {
+	return registry.CreateKey(k, path, access|getRegistryView())
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// registrySettleDelay is how long the watcher waits after a notification
+// before it reads the tree, so the values an installer or reg import writes
+// in a burst are reported together.
+const registrySettleDelay = 250 * time.Millisecond
+
+// RegistryChange is a key or value under the Autologger key that was added,
+// removed or modified.
+type RegistryChange struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Autologger string    `json:"autologger"`
+	Key        string    `json:"key"`
+	// Value is the name of the value that changed, or "" if the key was
+	// added or removed.
+	Value  string `json:"value,omitempty"`
+	Change string `json:"change"`
+	Type   string `json:"type,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// registryValueState is a value as it is compared between two reads.
+type registryValueState struct {
+	valType string
+	data    string
+}
+
+// registryState maps every key under the Autologger key, relative to it, to
+// its values.
+type registryState map[string]map[string]registryValueState
+
+// readRegistryState reads every key and value under the Autologger key.
+func readRegistryState() (registryState, error) {
+	state := make(registryState)
+	if err := readRegistryTree(state, ""); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// readRegistryTree adds a key and its subkeys to state. Subkeys that are
+// deleted while the tree is read are skipped; the next notification reports
+// them. Subkeys that can't be read, e.g. because their ACL denies access,
+// are logged and skipped, so the other autologgers are still watched.
+func readRegistryTree(state registryState, path string) error {
+	fullPath := baseAutologgerPath
+	if path != "" {
+		fullPath += `\` + path
+	}
+	key, err := openKey(registry.LOCAL_MACHINE, fullPath, registry.QUERY_VALUE|registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	values := make(map[string]registryValueState)
+	names, err := key.ReadValueNames(0)
+	if err != nil {
+		return fmt.Errorf("failed to read values of %s: %v", fullPath, err)
+	}
+	for _, name := range names {
+		data, valType, err := readRawValue(key, name)
+		if err != nil {
+			continue
+		}
+		values[name] = registryValueState{valType: regTypeName(valType), data: formatRawValue(valType, data)}
+	}
+	state[path] = values
+
+	subkeys, err := key.ReadSubKeyNames(0)
+	if err != nil {
+		return fmt.Errorf("failed to read subkeys of %s: %v", fullPath, err)
+	}
+	for _, name := range subkeys {
+		sub := name
+		if path != "" {
+			sub = path + `\` + name
+		}
+		if err := readRegistryTree(state, sub); err != nil && err != registry.ErrNotExist {
+			slog.Warn("skipping registry key", "key", `HKLM\`+baseAutologgerPath+`\`+sub, "error", err)
+		}
+	}
+	return nil
+}
+
+// diffRegistryState returns the changes from before to after, sorted by key
+// and value. The values of a key that was added or removed are reported as
+// well, so the changes hold what it was configured with.
+func diffRegistryState(before, after registryState, now time.Time) []RegistryChange {
+	var changes []RegistryChange
+	change := func(path, value, kind string, was, is *registryValueState) {
+		c := RegistryChange{
+			Event:      "registry",
+			Time:       now,
+			Autologger: strings.SplitN(path, `\`, 2)[0],
+			Key:        `HKLM\` + baseAutologgerPath,
+			Value:      value,
+			Change:     kind,
+		}
+		if path != "" {
+			c.Key += `\` + path
+		}
+		if was != nil {
+			c.Type, c.Old = was.valType, was.data
+		}
+		if is != nil {
+			c.Type, c.New = is.valType, is.data
+		}
+		changes = append(changes, c)
+	}
+
+	paths := make(map[string]bool)
+	for path := range before {
+		paths[path] = true
+	}
+	for path := range after {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	for _, path := range sorted {
+		oldValues, hadKey := before[path]
+		newValues, hasKey := after[path]
+		switch {
+		case !hadKey:
+			change(path, "", "added", nil, nil)
+		case !hasKey:
+			change(path, "", "removed", nil, nil)
+		}
+
+		names := make([]string, 0, len(oldValues)+len(newValues))
+		for name := range oldValues {
+			names = append(names, name)
+		}
+		for name := range newValues {
+			if _, ok := oldValues[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			was, hadValue := oldValues[name]
+			is, hasValue := newValues[name]
+			switch {
+			case !hadValue:
+				change(path, name, "added", nil, &is)
+			case !hasValue:
+				change(path, name, "removed", &was, nil)
+			case was != is:
+				change(path, name, "modified", &was, &is)
+			}
+		}
+	}
+	return changes
+}
+
+// watchRegistry sends the changes under the Autologger key to changes until
+// ctx is done. RegNotifyChangeKeyValue only tells that something changed,
+// so the tree is read again and compared with the previous read. The
+// notification is tied to the thread that requested it, so the goroutine
+// keeps its thread.
+func watchRegistry(ctx context.Context, changes chan<- []RegistryChange) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	key, err := openKey(registry.LOCAL_MACHINE, baseAutologgerPath, registry.NOTIFY)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", baseAutologgerPath, err)
+	}
+	defer key.Close()
+	event, err := windows.CreateEvent(nil, 0, 0, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create event: %v", err)
+	}
+	defer windows.CloseHandle(event)
+
+	notify := func() error {
+		const filter = windows.REG_NOTIFY_CHANGE_NAME | windows.REG_NOTIFY_CHANGE_LAST_SET
+		if err := windows.RegNotifyChangeKeyValue(windows.Handle(key), true, filter, event, true); err != nil {
+			return fmt.Errorf("failed to watch %s: %v", baseAutologgerPath, err)
+		}
+		return nil
+	}
+	// The notification is requested before each read, so nothing that
+	// changes while the tree is read is missed.
+	if err := notify(); err != nil {
+		return err
+	}
+	state, err := readRegistryState()
+	if err != nil {
+		return err
+	}
+	for {
+		if signaled, err := waitForEvent(ctx, event); !signaled {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(registrySettleDelay):
+		}
+		if err := notify(); err != nil {
+			return err
+		}
+		current, err := readRegistryState()
+		if err != nil {
+			slog.Warn("cannot read autologger registry", "error", err)
+			continue
+		}
+		diff := diffRegistryState(state, current, time.Now())
+		state = current
+		if len(diff) == 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case changes <- diff:
+		}
+	}
+}
+
+// waitForEvent waits until event is signaled, and returns false if ctx is
+// done first.
+func waitForEvent(ctx context.Context, event windows.Handle) (bool, error) {
+	for ctx.Err() == nil {
+		status, err := windows.WaitForSingleObject(event, 500)
+		if err != nil {
+			return false, fmt.Errorf("failed to wait for registry notification: %v", err)
+		}
+		if status == windows.WAIT_OBJECT_0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// registryChangeFinding describes a registry change for the event log.
+func registryChangeFinding(c RegistryChange) Finding {
+	target := "key " + c.Key
+	if c.Value != "" {
+		target = fmt.Sprintf("value %s of key %s", c.Value, c.Key)
+	}
+	message := fmt.Sprintf("The registry %s was %s", target, c.Change)
+	switch c.Change {
+	case "added":
+		if c.Value != "" {
+			message += fmt.Sprintf(" as %s %s", c.Type, c.New)
+		}
+	case "removed":
+		if c.Value != "" {
+			message += fmt.Sprintf(", it was %s %s", c.Type, c.Old)
+		}
+	case "modified":
+		message += fmt.Sprintf(" from %s to %s", c.Old, c.New)
+	}
+	return Finding{
+		ID:         "AUTOLOGGER_REGISTRY_CHANGED",
+		Severity:   SeverityMedium,
+		Autologger: c.Autologger,
+		Message:    message,
+	}
+}
+
+// displayRegistryChange prints a registry change as a single line, e.g.
+// "08:02:13 EventLog-System\{...}: Enabled modified: 1 (0x1) -> 0 (0x0)".
+// Removals are highlighted in red, other changes in yellow.
+func displayRegistryChange(w io.Writer, c RegistryChange, pal palette) {
+	path := strings.TrimPrefix(strings.TrimPrefix(c.Key, `HKLM\`+baseAutologgerPath), `\`)
+	if path == "" {
+		path = `Autologger`
+	}
+	var what string
+	switch {
+	case c.Value == "":
+		what = "key " + c.Change
+	case c.Change == "added":
+		what = fmt.Sprintf("%s added: %s %s", c.Value, c.Type, c.New)
+	case c.Change == "removed":
+		what = fmt.Sprintf("%s removed, was %s %s", c.Value, c.Type, c.Old)
+	default:
+		what = fmt.Sprintf("%s modified: %s -> %s", c.Value, c.Old, c.New)
+	}
+	if c.Change == "removed" {
+		what = pal.red(what)
+	} else {
+		what = pal.yellow(what)
+	}
+	fmt.Fprintf(w, "%s %s: %s\n", c.Time.Format("15:04:05"), path, what)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "sync"
+
+// nameResolveWorkers bounds the concurrent name lookups. Each lookup opens a
+// few registry keys, so a handful of workers hide most of the latency
+// without flooding the registry.
+const nameResolveWorkers = 8
+
+// providerName is the result of a name lookup.
+type providerName struct {
+	name string
+	err  error
+}
+
+// providerNames caches provider names by normalized GUID for the whole run,
+// as the EventLog-* autologgers and -all share most of their providers.
+var providerNames onceCache[providerName]
+
+// lookupProviderName resolves the name of a provider like resolveProviderName
+// and also reports when the Publishers key exists but can't be read, in which
+// case the name may be wrong. Every GUID is only resolved once per run.
+func lookupProviderName(guid string) (string, error) {
+	guid = canonicalGUID(guid)
+	result := providerNames.get(guid, func() providerName {
+		name, err := readProviderName(guid)
+		return providerName{name: name, err: err}
+	})
+	return result.name, result.err
+}
+
+// resolveProviderNames resolves the names of several providers concurrently
+// so later lookups are served from the cache.
+func resolveProviderNames(guids []string) {
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for range min(nameResolveWorkers, len(guids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for guid := range work {
+				lookupProviderName(guid)
+			}
+		}()
+	}
+	for _, guid := range guids {
+		work <- guid
+	}
+	close(work)
+	wg.Wait()
+}
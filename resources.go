@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// resourceValues are the values of a publisher entry naming the files event
+// consumers render its events from.
+var resourceValues = []string{"ResourceFileName", "MessageFileName"}
+
+// Reasons a resource file can't be used to render events.
+const (
+	resourceMissing     = "missing"
+	resourceUnloadable  = "unloadable"
+	resourceNoTemplates = "no_templates"
+)
+
+// ProviderResource is a resource or message file of a provider's publisher
+// entry.
+type ProviderResource struct {
+	// Source is the registry value naming the file.
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	// Problem is "missing", "unloadable" or, for a ResourceFileName without
+	// an event template, "no_templates". It is empty if the file is usable.
+	Problem string `json:"problem,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// resourceChecks caches the state of every file checked, as many providers
+// share their resource files.
+var resourceChecks onceCache[ProviderResource]
+
+// resolveProviderResources returns the resource and message files of a
+// provider's publisher entry with whether they can be used, or nil if the
+// provider has no publisher entry.
+func resolveProviderResources(guid string) []ProviderResource {
+	key, err := openKey(registry.LOCAL_MACHINE, publishersPath+`\`+canonicalGUID(guid), registry.READ)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+
+	var resources []ProviderResource
+	for _, name := range resourceValues {
+		value, _, err := key.GetStringValue(name)
+		if err != nil {
+			continue
+		}
+		// Several files may be listed, events are rendered from any of
+		// them.
+		for _, file := range strings.Split(value, ";") {
+			if file = strings.TrimSpace(file); file != "" {
+				resources = append(resources, checkResourceFile(name, expandBinaryPath(file)))
+			}
+		}
+	}
+	return resources
+}
+
+// checkResourceFile tells whether a resource file exists and can be loaded
+// the way event consumers load it, and whether a ResourceFileName holds the
+// WEVT_TEMPLATE resource of the manifest.
+func checkResourceFile(source, path string) ProviderResource {
+	return resourceChecks.get(source+"|"+strings.ToLower(path), func() ProviderResource {
+		return loadResourceFile(source, path)
+	})
+}
+
+func loadResourceFile(source, path string) ProviderResource {
+	result := ProviderResource{Source: source, Path: path}
+	if _, err := os.Stat(path); err != nil {
+		result.Problem = resourceMissing
+		if !os.IsNotExist(err) {
+			result.Error = err.Error()
+		}
+	} else if module, err := windows.LoadLibraryEx(path, 0,
+		windows.LOAD_LIBRARY_AS_DATAFILE|windows.LOAD_LIBRARY_AS_IMAGE_RESOURCE); err != nil {
+		result.Problem = resourceUnloadable
+		result.Error = err.Error()
+	} else {
+		// Message tables usually live in a MUI file next to the binary,
+		// so only the templates are looked for.
+		if source == "ResourceFileName" {
+			if _, err := windows.FindResource(module, windows.ResourceID(1), "WEVT_TEMPLATE"); err != nil {
+				result.Problem = resourceNoTemplates
+			}
+		}
+		windows.FreeLibrary(module)
+	}
+	return result
+}
+
+// unusableResources returns the resource files of a provider that events
+// can't be rendered from.
+func unusableResources(p ETWProvider) []ProviderResource {
+	var result []ProviderResource
+	for _, r := range p.Resources {
+		if r.Problem != "" {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// getResourceDescription renders an unusable resource file, e.g.
+// "ResourceFileName C:\Program Files\Contoso\sensor.dll is missing".
+func getResourceDescription(r ProviderResource) string {
+	var state string
+	switch r.Problem {
+	case resourceMissing:
+		state = "is missing"
+	case resourceUnloadable:
+		state = "can't be loaded"
+	case resourceNoTemplates:
+		state = "has no event templates"
+	default:
+		state = "is usable"
+	}
+	desc := fmt.Sprintf("%s %s %s", r.Source, r.Path, state)
+	if r.Error != "" {
+		desc += ": " + r.Error
+	}
+	return desc
+}
+
+// providersWithUnusableResources returns the providers whose events can't
+// be rendered from all of their resource files.
+func providersWithUnusableResources(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if len(unusableResources(p)) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displayResources lists the resource files of providers that are missing
+// or can't be loaded, which leaves their events unrenderable.
+func displayResources(w io.Writer, providers []ETWProvider, pal palette) {
+	providers = providersWithUnusableResources(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nProvider Resources:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		fmt.Fprintf(w, "\n%s (%s):\n", p.Name, p.GUID)
+		for _, r := range unusableResources(p) {
+			fmt.Fprintf(w, "- %s\n", pal.yellow(getResourceDescription(r)))
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// sampleSessionName is the temporary session that stands in for autologgers
+// whose own session can't be consumed.
+const sampleSessionName = "autologgerAnalyzer-Sample"
+
+// SampleReport is the event rate an autologger produced while it was
+// sampled.
+type SampleReport struct {
+	Autologger string `json:"autologger"`
+	Session    string `json:"session"`
+	// Clone tells that a temporary session enabling the autologger's
+	// providers was consumed instead of its own session.
+	Clone           bool    `json:"clone"`
+	Seconds         float64 `json:"seconds"`
+	Events          uint64  `json:"events"`
+	EventsPerSecond float64 `json:"events_per_second"`
+	EventsLost      uint32  `json:"events_lost"`
+	// Bytes is the size of the events received, headers included.
+	Bytes uint64 `json:"bytes"`
+	// ClassicEvents are the MOF and WPP events received, which can't be
+	// attributed to a provider. They are included in Events and Bytes.
+	ClassicEvents uint64           `json:"classic_events"`
+	Providers     []ProviderSample `json:"providers"`
+}
+
+// ProviderSample is the event rate of a provider during a sample. Providers
+// of the autologger that logged nothing are included with 0 events.
+type ProviderSample struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+	// Configured tells that the autologger has a subkey for the provider.
+	Configured      bool          `json:"configured"`
+	Events          uint64        `json:"events"`
+	EventsPerSecond float64       `json:"events_per_second"`
+	Bytes           uint64        `json:"bytes"`
+	EventIDs        []EventSample `json:"event_ids,omitempty"`
+}
+
+// EventSample is the rate of an event ID of a provider during a sample.
+type EventSample struct {
+	ID              int     `json:"id"`
+	Name            string  `json:"name,omitempty"`
+	Events          uint64  `json:"events"`
+	EventsPerSecond float64 `json:"events_per_second"`
+	Bytes           uint64  `json:"bytes"`
+}
+
+// sampleAutologger consumes the live session of an autologger, or a
+// temporary clone of its providers, and measures the events it produces.
+func sampleAutologger(ctx context.Context, name string, duration time.Duration, clone bool) (*SampleReport, error) {
+	report, err := analyzeAutologger(name, analyzeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	config := report.Config
+	result := &SampleReport{Autologger: name, Session: sampleSessionName}
+
+	realTime := config.Session != nil && config.Session.LogFileMode&eventTraceRealTimeMode != 0
+	switch {
+	case realTime && !clone:
+		result.Session = config.Session.Name
+	case isKernelSession(config):
+		return nil, fmt.Errorf("kernel session %s can only be sampled while it runs in real-time mode", name)
+	default:
+		result.Clone = true
+		if err := startSampleSession(config, report.Providers); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if _, err := stopTrace(sampleSessionName); err != nil {
+				slog.Warn("cannot stop sample session", "session", sampleSessionName, "error", err)
+			}
+		}()
+	}
+
+	var lostBefore uint32
+	if stats, err := querySession(result.Session); err == nil {
+		lostBefore = stats.EventsLost
+	}
+	tally, elapsed, err := countSessionEvents(ctx, result.Session, duration)
+	if err != nil {
+		return nil, err
+	}
+	if stats, err := querySession(result.Session); err == nil && stats.EventsLost >= lostBefore {
+		result.EventsLost = stats.EventsLost - lostBefore
+	}
+
+	result.Seconds = elapsed.Seconds()
+	result.Providers = buildProviderSamples(report.Providers, tally.counts, result.Seconds)
+	result.ClassicEvents = tally.classic.events
+	result.Events, result.Bytes = tally.classic.events, tally.classic.bytes
+	for _, p := range result.Providers {
+		result.Events += p.Events
+		result.Bytes += p.Bytes
+	}
+	result.EventsPerSecond = eventRate(result.Events, result.Seconds)
+	return result, nil
+}
+
+// startSampleSession starts the temporary real-time session that enables
+// the providers of an autologger with their level and keywords. Filters
+// aren't applied, so the rates are an upper bound for filtered providers.
+func startSampleSession(config *AutologgerConfig, providers []ETWProvider) error {
+	props := newTraceProperties()
+	props.Wnode.ClientContext = 1
+	props.LogFileMode = eventTraceRealTimeMode
+	props.LogFileNameOffset = 0
+	props.BufferSize = uint32(config.BufferSize)
+	props.MinimumBuffers = uint32(config.MinimumBuffers)
+	props.MaximumBuffers = uint32(config.MaximumBuffers)
+	// Deliver events promptly instead of when buffers fill up.
+	props.FlushTimer = 1
+
+	handle, err := startTrace(sampleSessionName, props)
+	if err == windows.ERROR_ALREADY_EXISTS {
+		// Left over from an interrupted sample.
+		if _, err := stopTrace(sampleSessionName); err != nil {
+			return fmt.Errorf("failed to stop leftover session %s: %v", sampleSessionName, err)
+		}
+		handle, err = startTrace(sampleSessionName, props)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start session %s: %v", sampleSessionName, err)
+	}
+
+	enableProviders(handle, providers)
+	return nil
+}
+
+// buildProviderSamples turns the event counts of a sample into per
+// provider and per event ID rates, busiest first.
+func buildProviderSamples(providers []ETWProvider, counts map[eventKey]eventCount, seconds float64) []ProviderSample {
+	byGUID := make(map[string]*ProviderSample)
+	var order []string
+	get := func(guid, name string) *ProviderSample {
+		if s, ok := byGUID[guid]; ok {
+			return s
+		}
+		if name == "" {
+			name = resolveProviderName(guid)
+		}
+		byGUID[guid] = &ProviderSample{GUID: guid, Name: name}
+		order = append(order, guid)
+		return byGUID[guid]
+	}
+	for _, p := range providers {
+		if p.EnabledState != enabledOff && p.ProviderType != providerTypeGroup {
+			get(canonicalGUID(p.GUID), p.Name).Configured = true
+		}
+	}
+	for key, n := range counts {
+		guid := canonicalGUID(key.provider.String())
+		s := get(guid, "")
+		s.Events += n.events
+		s.Bytes += n.bytes
+		s.EventIDs = append(s.EventIDs, EventSample{
+			ID:              int(key.id),
+			Name:            getProviderManifest(guid).names[int(key.id)].Name,
+			Events:          n.events,
+			EventsPerSecond: eventRate(n.events, seconds),
+			Bytes:           n.bytes,
+		})
+	}
+
+	samples := make([]ProviderSample, 0, len(order))
+	for _, guid := range order {
+		s := byGUID[guid]
+		s.EventsPerSecond = eventRate(s.Events, seconds)
+		sort.Slice(s.EventIDs, func(i, j int) bool {
+			if s.EventIDs[i].Events != s.EventIDs[j].Events {
+				return s.EventIDs[i].Events > s.EventIDs[j].Events
+			}
+			return s.EventIDs[i].ID < s.EventIDs[j].ID
+		})
+		samples = append(samples, *s)
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Events > samples[j].Events
+	})
+	return samples
+}
+
+// eventRate returns events per second, or 0 for an empty sample.
+func eventRate(events uint64, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(events) / seconds
+}
+
+func runSample(cmd *command, args []string) error {
+	var autologger, format string
+	var duration time.Duration
+	var clone bool
+	var top int
+	var output outputOptions
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&autologger, "autologger", "", "Autologger to sample")
+	fs.DurationVar(&duration, "duration", 30*time.Second, "How long to consume events")
+	fs.BoolVar(&clone, "clone", false, "Consume a temporary session with the autologger's providers even if its own session runs in real-time mode")
+	fs.IntVar(&top, "top", 10, "Event IDs to show per provider, 0 for all")
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if autologger == "" {
+		fs.Usage()
+		return fmt.Errorf("an autologger name is required")
+	}
+	if duration <= 0 {
+		return fmt.Errorf("invalid duration %s", duration)
+	}
+
+	// Ctrl+C ends the sample early; the temporary session is still
+	// stopped and the events so far are reported.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	slog.Info("sampling autologger", "autologger", autologger, "duration", duration)
+	report, err := sampleAutologger(ctx, autologger, duration, clone)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	displaySample(os.Stdout, report, top, newPalette(os.Stdout, output.color))
+	return nil
+}
+
+// displaySample prints the rates of a sample, providers that logged nothing
+// and lost events highlighted.
+func displaySample(w io.Writer, report *SampleReport, top int, pal palette) {
+	session := report.Session
+	if report.Clone {
+		session = "temporary session " + session
+	}
+	fmt.Fprintf(w, "Sample of %s (%s) over %.1fs: %d events, %.1f events/s\n",
+		report.Autologger, session, report.Seconds, report.Events, report.EventsPerSecond)
+	if report.EventsLost > 0 {
+		fmt.Fprintln(w, pal.yellow(fmt.Sprintf("%d events were lost while sampling", report.EventsLost)))
+	}
+	if report.ClassicEvents > 0 {
+		fmt.Fprintf(w, "%d MOF or WPP events can't be attributed to a provider\n", report.ClassicEvents)
+	}
+	fmt.Fprintln(w)
+
+	writeProviderSampleTable(w, report.Providers, pal)
+	writeEventSamples(w, report.Providers, top)
+}
+
+// writeProviderSampleTable prints the events of each provider, highlighting
+// configured providers that logged nothing.
+func writeProviderSampleTable(w io.Writer, providers []ProviderSample, pal palette) {
+	fmt.Fprintf(w, "| %-45s | %-38s | %-10s | %-10s | %-10s |\n", "Provider", "GUID", "Configured", "Events", "Events/s")
+	fmt.Fprintf(w, "|%s|%s|%s|%s|%s|\n",
+		strings.Repeat("-", 47),
+		strings.Repeat("-", 40),
+		strings.Repeat("-", 12),
+		strings.Repeat("-", 12),
+		strings.Repeat("-", 12))
+	for _, p := range providers {
+		configured := "No"
+		if p.Configured {
+			configured = "Yes"
+		}
+		events := fmt.Sprintf("%-10d", p.Events)
+		if p.Events == 0 {
+			events = pal.yellow(events)
+		}
+		fmt.Fprintf(w, "| %-45s | %-38s | %-10s | %s | %-10.1f |\n",
+			truncateString(p.Name, 45), p.GUID, configured, events, p.EventsPerSecond)
+	}
+}
+
+// writeEventSamples lists the busiest event IDs of each provider, at most
+// top of them unless top is 0.
+func writeEventSamples(w io.Writer, providers []ProviderSample, top int) {
+	header := false
+	for _, p := range providers {
+		if len(p.EventIDs) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\n\nEvent IDs:\n")
+			fmt.Fprintln(w, strings.Repeat("=", 80))
+			header = true
+		}
+		fmt.Fprintf(w, "\n%s (%s):\n", p.Name, p.GUID)
+		ids := p.EventIDs
+		if top > 0 && len(ids) > top {
+			ids = ids[:top]
+		}
+		for _, e := range ids {
+			id := fmt.Sprintf("%d", e.ID)
+			if e.Name != "" {
+				id += " = " + e.Name
+			}
+			fmt.Fprintf(w, "- %s: %d events, %.1f/s\n", id, e.Events, e.EventsPerSecond)
+		}
+		if more := len(p.EventIDs) - len(ids); more > 0 {
+			fmt.Fprintf(w, "- %d more event ID(s)\n", more)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+
+	"golang.org/x/sys/windows"
+)
+
+// exportSchemaCommand describes providers export-schema for its help text.
+var exportSchemaCommand = &command{
+	name:    "providers export-schema",
+	args:    "[flags] <guid>",
+	summary: "Write the manifest schema of a provider as JSON: events, fields, keywords, levels, channels and tasks",
+}
+
+// ProviderSchema is the schema TDH derives from the manifest of a provider.
+type ProviderSchema struct {
+	GUID     string          `json:"guid"`
+	Name     string          `json:"name"`
+	Host     *HostMetadata   `json:"host"`
+	Keywords []ManifestField `json:"keywords,omitempty"`
+	Levels   []ManifestField `json:"levels,omitempty"`
+	Channels []ManifestField `json:"channels,omitempty"`
+	Tasks    []ManifestField `json:"tasks,omitempty"`
+	Events   []SchemaEvent   `json:"events"`
+}
+
+// SchemaEvent is one version of an event in a provider's manifest.
+type SchemaEvent struct {
+	ID       uint16 `json:"id"`
+	Version  uint8  `json:"version"`
+	Name     string `json:"name,omitempty"`
+	Task     uint16 `json:"task"`
+	TaskName string `json:"task_name,omitempty"`
+	Opcode   uint8  `json:"opcode"`
+	// OpcodeName is the opcode's name, e.g. win:Start.
+	OpcodeName   string   `json:"opcode_name,omitempty"`
+	Level        uint8    `json:"level"`
+	LevelName    string   `json:"level_name,omitempty"`
+	Channel      uint8    `json:"channel"`
+	ChannelName  string   `json:"channel_name,omitempty"`
+	Keyword      uint64   `json:"keyword"`
+	KeywordNames []string `json:"keyword_names,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	// Fields are the fields of the event's template, in payload order.
+	// TDH doesn't expose the template name.
+	Fields []SchemaField `json:"fields"`
+}
+
+// SchemaField is a field of an event template. Structures have Members
+// instead of a type.
+type SchemaField struct {
+	Name string `json:"name"`
+	// InType is the manifest input type, e.g. win:UnicodeString.
+	InType string `json:"in_type,omitempty"`
+	// OutType is the manifest output type, e.g. win:PID, if the field has
+	// one other than the default of its input type.
+	OutType string `json:"out_type,omitempty"`
+	// Map is the name of the value map or bitmap that names the values.
+	Map string `json:"map,omitempty"`
+	// Count is the fixed number of elements of an array, CountField the
+	// field that holds the number.
+	Count      uint16 `json:"count,omitempty"`
+	CountField string `json:"count_field,omitempty"`
+	// Length is the fixed length of a string or binary field, LengthField
+	// the field that holds the length.
+	Length      uint16        `json:"length,omitempty"`
+	LengthField string        `json:"length_field,omitempty"`
+	Members     []SchemaField `json:"members,omitempty"`
+}
+
+// PROPERTY_FLAGS of EVENT_PROPERTY_INFO.
+const (
+	propertyStruct           = 0x1
+	propertyParamLength      = 0x2
+	propertyParamCount       = 0x4
+	propertyParamFixedLength = 0x10
+	propertyParamFixedCount  = 0x20
+)
+
+// eventPropertyInfoSize is the size of EVENT_PROPERTY_INFO.
+const eventPropertyInfoSize = 24
+
+// tdhInTypes are the manifest names of the TDH_INTYPE values.
+var tdhInTypes = map[uint16]string{
+	1:   "win:UnicodeString",
+	2:   "win:AnsiString",
+	3:   "win:Int8",
+	4:   "win:UInt8",
+	5:   "win:Int16",
+	6:   "win:UInt16",
+	7:   "win:Int32",
+	8:   "win:UInt32",
+	9:   "win:Int64",
+	10:  "win:UInt64",
+	11:  "win:Float",
+	12:  "win:Double",
+	13:  "win:Boolean",
+	14:  "win:Binary",
+	15:  "win:GUID",
+	16:  "win:Pointer",
+	17:  "win:FILETIME",
+	18:  "win:SYSTEMTIME",
+	19:  "win:SID",
+	20:  "win:HexInt32",
+	21:  "win:HexInt64",
+	22:  "win:CountedUnicodeString",
+	23:  "win:CountedAnsiString",
+	25:  "win:CountedBinary",
+	300: "CountedString",
+	301: "CountedAnsiString",
+	302: "ReversedCountedString",
+	303: "ReversedCountedAnsiString",
+	304: "NonNullTerminatedString",
+	305: "NonNullTerminatedAnsiString",
+	306: "UnicodeChar",
+	307: "AnsiChar",
+	308: "SizeT",
+	309: "HexDump",
+	310: "WbemSID",
+}
+
+// tdhOutTypes are the manifest names of the TDH_OUTTYPE values.
+var tdhOutTypes = map[uint16]string{
+	1:  "xs:string",
+	2:  "xs:dateTime",
+	3:  "xs:byte",
+	4:  "xs:unsignedByte",
+	5:  "xs:short",
+	6:  "xs:unsignedShort",
+	7:  "xs:int",
+	8:  "xs:unsignedInt",
+	9:  "xs:long",
+	10: "xs:unsignedLong",
+	11: "xs:float",
+	12: "xs:double",
+	13: "xs:boolean",
+	14: "xs:GUID",
+	15: "xs:hexBinary",
+	16: "win:HexInt8",
+	17: "win:HexInt16",
+	18: "win:HexInt32",
+	19: "win:HexInt64",
+	20: "win:PID",
+	21: "win:TID",
+	22: "win:Port",
+	23: "win:IPv4",
+	24: "win:IPv6",
+	25: "win:SocketAddress",
+	26: "win:CIMDateTime",
+	27: "win:ETWTIME",
+	28: "win:Xml",
+	29: "win:ErrorCode",
+	30: "win:Win32Error",
+	31: "win:NTSTATUS",
+	32: "win:HResult",
+	33: "win:DateTimeCultureInsensitive",
+	34: "win:Json",
+	35: "win:Utf8",
+	36: "win:Pkcs7WithTypeInfo",
+	37: "win:CodePointer",
+	38: "win:DateTimeUtc",
+}
+
+// getTypeName returns the manifest name of a TDH type, or its number if it
+// has none.
+func getTypeName(names map[uint16]string, t uint16) string {
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", t)
+}
+
+// runExportSchema implements providers export-schema.
+func runExportSchema(args []string) error {
+	var outPath string
+
+	fs := newFlagSet(exportSchemaCommand)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&outPath, "out", "", "Write the schema to this file instead of stdout")
+	positional := parseArgs(fs, args)
+
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("a provider GUID is required")
+	}
+	guid, ok := normalizeGUID(positional[0])
+	if !ok {
+		return fmt.Errorf("%q is not a GUID", positional[0])
+	}
+
+	schema, err := collectProviderSchema(guid)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	var out *atomicFile
+	if outPath != "" {
+		if out, err = createAtomicFile(outPath, false); err != nil {
+			return err
+		}
+		w = out
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema); err != nil {
+		if out != nil {
+			out.Abort()
+		}
+		return fmt.Errorf("failed to write schema: %v", err)
+	}
+	if out != nil {
+		if err := out.Commit(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", outPath, err)
+		}
+	}
+	slog.Info("wrote provider schema", "provider", schema.Name, "events", len(schema.Events))
+	return nil
+}
+
+// collectProviderSchema reads the manifest of a provider through TDH, with
+// every version of every event.
+func collectProviderSchema(providerGUID string) (*ProviderSchema, error) {
+	guid, err := windows.GUIDFromString(providerGUID)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a GUID", providerGUID)
+	}
+	descriptors, err := enumerateManifestEvents(&guid)
+	if err == windows.ERROR_NOT_FOUND || err == nil && len(descriptors) == 0 {
+		return nil, fmt.Errorf("provider %s has no manifest on this host", providerGUID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate manifest events: %v", err)
+	}
+	slices.SortFunc(descriptors, func(a, b eventDescriptor) int {
+		return cmp.Or(cmp.Compare(a.Id, b.Id), cmp.Compare(a.Version, b.Version))
+	})
+
+	schema := &ProviderSchema{
+		GUID:     providerGUID,
+		Name:     resolveProviderName(providerGUID),
+		Host:     collectHostMetadata(),
+		Keywords: resolveManifestFields(providerGUID, eventKeywordInformation),
+		Levels:   resolveManifestFields(providerGUID, eventLevelInformation),
+		Channels: resolveManifestFields(providerGUID, eventChannelInformation),
+		Tasks:    resolveManifestFields(providerGUID, eventTaskInformation),
+		Events:   make([]SchemaEvent, 0, len(descriptors)),
+	}
+	for _, d := range descriptors {
+		buf, err := getManifestEventInfo(&guid, &d)
+		if err != nil {
+			slog.Warn("cannot read manifest event", "provider", providerGUID, "event", d.Id, "version", d.Version, "error", err)
+			continue
+		}
+		schema.Events = append(schema.Events, parseSchemaEvent(buf, d))
+	}
+	return schema, nil
+}
+
+// parseSchemaEvent decodes the TRACE_EVENT_INFO of an event.
+func parseSchemaEvent(buf []byte, d eventDescriptor) SchemaEvent {
+	offset := func(at int) uint32 { return binary.LittleEndian.Uint32(buf[at:]) }
+	event := SchemaEvent{
+		ID:           d.Id,
+		Version:      d.Version,
+		Name:         tdhString(buf, offset(traceEventInfoEventNameOffset)),
+		Task:         d.Task,
+		TaskName:     tdhString(buf, offset(traceEventInfoTaskNameOffset)),
+		Opcode:       d.Opcode,
+		OpcodeName:   tdhString(buf, offset(traceEventInfoOpcodeNameOffset)),
+		Level:        d.Level,
+		LevelName:    tdhString(buf, offset(traceEventInfoLevelNameOffset)),
+		Channel:      d.Channel,
+		ChannelName:  tdhString(buf, offset(traceEventInfoChannelNameOffset)),
+		Keyword:      d.Keyword,
+		KeywordNames: tdhStrings(buf, offset(traceEventInfoKeywordsNameOffset)),
+		Message:      tdhString(buf, offset(traceEventInfoEventMessageOffset)),
+		Fields:       []SchemaField{},
+	}
+
+	// The EVENT_PROPERTY_INFO array holds the top-level fields first,
+	// followed by the members of structures.
+	count := int(offset(traceEventInfoPropertyCount))
+	count = min(count, (len(buf)-traceEventInfoMinSize)/eventPropertyInfoSize)
+	topLevel := min(int(offset(traceEventInfoTopLevelCount)), count)
+	for i := range topLevel {
+		event.Fields = append(event.Fields, parseSchemaField(buf, i, count, 0))
+	}
+	return event
+}
+
+// parseSchemaField decodes the EVENT_PROPERTY_INFO at index. depth guards
+// against structures that contain themselves in a corrupt schema.
+func parseSchemaField(buf []byte, index, count, depth int) SchemaField {
+	info := buf[traceEventInfoMinSize+index*eventPropertyInfoSize:]
+	flags := binary.LittleEndian.Uint32(info)
+	field := SchemaField{Name: tdhString(buf, binary.LittleEndian.Uint32(info[4:]))}
+	propertyName := func(i uint16) string {
+		if int(i) >= count {
+			return fmt.Sprintf("%d", i)
+		}
+		at := traceEventInfoMinSize + int(i)*eventPropertyInfoSize
+		return tdhString(buf, binary.LittleEndian.Uint32(buf[at+4:]))
+	}
+
+	if flags&propertyStruct != 0 {
+		start := int(binary.LittleEndian.Uint16(info[8:]))
+		members := int(binary.LittleEndian.Uint16(info[10:]))
+		field.Members = []SchemaField{}
+		for i := start; i < start+members && i < count && depth < 8; i++ {
+			field.Members = append(field.Members, parseSchemaField(buf, i, count, depth+1))
+		}
+	} else {
+		field.InType = getTypeName(tdhInTypes, binary.LittleEndian.Uint16(info[8:]))
+		if outType := binary.LittleEndian.Uint16(info[10:]); outType != 0 {
+			field.OutType = getTypeName(tdhOutTypes, outType)
+		}
+		field.Map = tdhString(buf, binary.LittleEndian.Uint32(info[12:]))
+	}
+
+	countValue := binary.LittleEndian.Uint16(info[16:])
+	switch {
+	case flags&propertyParamCount != 0:
+		field.CountField = propertyName(countValue)
+	case flags&propertyParamFixedCount != 0 || countValue > 1:
+		field.Count = countValue
+	}
+	lengthValue := binary.LittleEndian.Uint16(info[18:])
+	switch {
+	case flags&propertyParamLength != 0:
+		field.LengthField = propertyName(lengthValue)
+	case flags&propertyParamFixedLength != 0 || lengthValue > 0 && isVariableLengthType(field.InType):
+		field.Length = lengthValue
+	}
+	return field
+}
+
+// isVariableLengthType reports whether a field of this input type has a
+// length of its own, rather than the size of its type.
+func isVariableLengthType(inType string) bool {
+	switch inType {
+	case "win:UnicodeString", "win:AnsiString", "win:Binary":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// ProviderMatch is a provider whose name matched a search, with the
+// autologgers that reference it.
+type ProviderMatch struct {
+	GUID        string          `json:"guid"`
+	Name        string          `json:"name"`
+	Autologgers []ProviderUsage `json:"autologgers"`
+}
+
+// searchProviders returns every provider whose resolved name contains query
+// (case-insensitive). Registered publishers, the providers TDH knows, the
+// provider database and providers referenced by autologgers are considered,
+// so providers nobody collects yet show up too.
+func searchProviders(query string) ([]ProviderMatch, error) {
+	usages, err := collectProviderUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	matches := make(map[string]*ProviderMatch)
+	add := func(guid, name string) *ProviderMatch {
+		key := canonicalGUID(guid)
+		if m, ok := matches[key]; ok {
+			return m
+		}
+		m := &ProviderMatch{GUID: guid, Name: name}
+		matches[key] = m
+		return m
+	}
+
+	for _, u := range usages {
+		if strings.Contains(strings.ToLower(u.Provider.Name), query) {
+			m := add(u.Provider.GUID, u.Provider.Name)
+			m.Autologgers = append(m.Autologgers, u)
+		}
+	}
+
+	// The publishers are the main name source, the providers TDH knows and
+	// the provider database cover publishers that can't be listed.
+	guids, err := getPublisherGUIDs()
+	if err != nil {
+		slog.Warn("cannot list registered publishers, searching the other name sources", "error", err)
+	}
+	for guid := range getLiveProviders() {
+		guids = append(guids, guid)
+	}
+	for guid := range getProviderDB() {
+		guids = append(guids, guid)
+	}
+	seen := make(map[string]bool)
+	for _, guid := range guids {
+		if seen[canonicalGUID(guid)] {
+			continue
+		}
+		seen[canonicalGUID(guid)] = true
+		name := resolveProviderName(guid)
+		if strings.Contains(strings.ToLower(name), query) {
+			add(guid, name)
+		}
+	}
+
+	result := make([]ProviderMatch, 0, len(matches))
+	for _, m := range matches {
+		if m.Autologgers == nil {
+			m.Autologgers = []ProviderUsage{}
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !strings.EqualFold(result[i].Name, result[j].Name) {
+			return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+		}
+		return strings.ToLower(result[i].GUID) < strings.ToLower(result[j].GUID)
+	})
+	return result, nil
+}
+
+// getPublisherGUIDs lists the providers registered under WINEVT\Publishers.
+func getPublisherGUIDs() ([]string, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, publishersPath, registry.READ)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open publishers registry key: %v", err)
+	}
+	defer key.Close()
+
+	names, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read publishers: %v", err)
+	}
+	var guids []string
+	for _, name := range names {
+		if guid, ok := normalizeGUID(name); ok {
+			guids = append(guids, guid)
+		}
+	}
+	return guids, nil
+}
+
+func runSearch(cmd *command, args []string) error {
+	var format string
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	queries := parseArgs(fs, args)
+
+	if len(queries) != 1 || queries[0] == "" {
+		fs.Usage()
+		return fmt.Errorf("exactly one provider name substring is required")
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+
+	matches, err := searchProviders(queries[0])
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(matches)
+	}
+
+	fmt.Printf("%d provider(s) matching %q\n", len(matches), queries[0])
+	for _, m := range matches {
+		fmt.Printf("\n%s %s\n", m.GUID, m.Name)
+		if len(m.Autologgers) == 0 {
+			fmt.Println("  Not referenced by any autologger")
+			continue
+		}
+		for _, u := range m.Autologgers {
+			enabled := "disabled"
+			if u.Provider.Enabled {
+				enabled = "enabled"
+			}
+			fmt.Printf("  %-35s %s\n", u.Autologger, enabled)
+		}
+	}
+	return nil
+}
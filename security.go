@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	// wmiSecurityPath holds the security descriptors of ETW providers and
+	// sessions, one binary value per GUID without braces.
+	wmiSecurityPath = wmiPath + `\Security`
+
+	// defaultTraceSecurityGUID names the descriptor that applies to GUIDs
+	// without a value of their own.
+	defaultTraceSecurityGUID = "0811c1af-7a07-4a06-82ed-869455cdf713"
+)
+
+// Sources of a security descriptor.
+const (
+	securitySourceGUID    = "guid"
+	securitySourceDefault = "default"
+)
+
+// SecurityDescriptor is the decoded security descriptor of a provider or
+// session from WMI\Security.
+type SecurityDescriptor struct {
+	// Source tells whether the GUID has a descriptor of its own ("guid")
+	// or the default one applies ("default").
+	Source string `json:"source"`
+	SDDL   string `json:"sddl"`
+	Owner  string `json:"owner,omitempty"`
+	// NullDACL is set when the descriptor has no DACL or a NULL one, which
+	// grants every account full access. ACEs is empty then.
+	NullDACL bool          `json:"null_dacl,omitempty"`
+	ACEs     []SecurityACE `json:"aces"`
+}
+
+// SecurityACE is an access control entry of a DACL.
+type SecurityACE struct {
+	// Type is "allow", "deny" or the ACE type number.
+	Type    string `json:"type"`
+	SID     string `json:"sid"`
+	Account string `json:"account,omitempty"`
+	Mask    uint32 `json:"mask"`
+	// Rights are the WMIGUID_*, TRACELOG_* and standard rights in Mask.
+	Rights []string `json:"rights"`
+}
+
+// traceRights are the access rights of WMI GUIDs and trace sessions, with
+// the standard rights that matter for them.
+var traceRights = []struct {
+	mask uint32
+	name string
+}{
+	{0x00000001, "WMIGUID_QUERY"},
+	{0x00000002, "WMIGUID_SET"},
+	{0x00000004, "WMIGUID_NOTIFICATION"},
+	{0x00000008, "WMIGUID_READ_DESCRIPTION"},
+	{0x00000010, "WMIGUID_EXECUTE"},
+	{0x00000020, "TRACELOG_CREATE_REALTIME"},
+	{0x00000040, "TRACELOG_CREATE_ONDISK"},
+	{0x00000080, "TRACELOG_GUID_ENABLE"},
+	{0x00000100, "TRACELOG_ACCESS_KERNEL_LOGGER"},
+	{0x00000200, "TRACELOG_LOG_EVENT"},
+	{0x00000400, "TRACELOG_ACCESS_REALTIME"},
+	{0x00000800, "TRACELOG_REGISTER_GUIDS"},
+	{0x00001000, "TRACELOG_JOIN_GROUP"},
+	{0x00010000, "DELETE"},
+	{0x00020000, "READ_CONTROL"},
+	{0x00040000, "WRITE_DAC"},
+	{0x00080000, "WRITE_OWNER"},
+	{0x00100000, "SYNCHRONIZE"},
+}
+
+// traceRightNames lists the rights set in an access mask. Unknown bits are
+// appended in hex.
+func traceRightNames(mask uint32) []string {
+	var names []string
+	for _, right := range traceRights {
+		if mask&right.mask != 0 {
+			names = append(names, right.name)
+			mask &^= right.mask
+		}
+	}
+	if mask != 0 {
+		names = append(names, fmt.Sprintf("0x%X", mask))
+	}
+	return names
+}
+
+// readGUIDSecurity returns the security descriptor that applies to a
+// provider or session GUID: its own value under WMI\Security, or the
+// default descriptor if it has none.
+func readGUIDSecurity(guid string) (*SecurityDescriptor, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, wmiSecurityPath, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WMI security key: %v", err)
+	}
+	defer key.Close()
+
+	source := securitySourceGUID
+	data, _, err := key.GetBinaryValue(strings.Trim(canonicalGUID(guid), "{}"))
+	if err == registry.ErrNotExist {
+		source = securitySourceDefault
+		data, _, err = key.GetBinaryValue(defaultTraceSecurityGUID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading security descriptor: %v", err)
+	}
+	sd, err := decodeSecurityDescriptor(data)
+	if err != nil {
+		return nil, err
+	}
+	sd.Source = source
+	return sd, nil
+}
+
+// decodeSecurityDescriptor decodes a self-relative security descriptor into
+// its SDDL form, owner and DACL entries.
+func decodeSecurityDescriptor(data []byte) (*SecurityDescriptor, error) {
+	if len(data) < 20 {
+		return nil, fmt.Errorf("security descriptor is truncated (%d bytes)", len(data))
+	}
+	// Copy into a buffer of the descriptor's alignment.
+	buf := make([]uint64, (len(data)+7)/8)
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&buf[0])), len(data)), data)
+	raw := (*windows.SECURITY_DESCRIPTOR)(unsafe.Pointer(&buf[0]))
+	if !raw.IsValid() {
+		return nil, fmt.Errorf("security descriptor is invalid")
+	}
+
+	sd := &SecurityDescriptor{SDDL: raw.String(), ACEs: []SecurityACE{}}
+	if owner, _, err := raw.Owner(); err == nil && owner != nil {
+		sd.Owner = getAccountName(owner)
+		if sd.Owner == "" {
+			sd.Owner = owner.String()
+		}
+	}
+	dacl, _, err := raw.DACL()
+	if err == windows.ERROR_OBJECT_NOT_FOUND || (err == nil && dacl == nil) {
+		sd.NullDACL = true
+		return sd, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading DACL: %v", err)
+	}
+	for i := uint32(0); i < uint32(dacl.AceCount); i++ {
+		var ace *windows.ACCESS_ALLOWED_ACE
+		if err := windows.GetAce(dacl, i, &ace); err != nil {
+			return nil, fmt.Errorf("reading ACE %d: %v", i, err)
+		}
+		entry := SecurityACE{Mask: uint32(ace.Mask), Rights: traceRightNames(uint32(ace.Mask))}
+		switch ace.Header.AceType {
+		case windows.ACCESS_ALLOWED_ACE_TYPE:
+			entry.Type = "allow"
+		case windows.ACCESS_DENIED_ACE_TYPE:
+			entry.Type = "deny"
+		default:
+			// Object and callback ACEs lay out their SID differently.
+			entry.Type = fmt.Sprintf("%d", ace.Header.AceType)
+			sd.ACEs = append(sd.ACEs, entry)
+			continue
+		}
+		sid := (*windows.SID)(unsafe.Pointer(&ace.SidStart))
+		entry.SID = sid.String()
+		entry.Account = getAccountName(sid)
+		sd.ACEs = append(sd.ACEs, entry)
+	}
+	return sd, nil
+}
+
+// accountNames caches the names of SIDs, as the same few accounts appear in
+// most descriptors.
+var accountNames onceCache[string]
+
+// getAccountName returns the DOMAIN\name of a SID, or "" if it can't be
+// looked up.
+func getAccountName(sid *windows.SID) string {
+	return accountNames.get(sid.String(), func() string {
+		account, domain, _, err := sid.LookupAccount("")
+		if err != nil {
+			return ""
+		}
+		if domain != "" {
+			return domain + `\` + account
+		}
+		return account
+	})
+}
+
+// nullDACLACE stands for what a missing or NULL DACL grants: full access to
+// everyone.
+var nullDACLACE = SecurityACE{Type: "allow", SID: "S-1-1-0", Account: "Everyone", Mask: 0xFFFFFFFF, Rights: []string{"full access (no DACL)"}}
+
+// getEffectiveACEs returns the entries of a descriptor, or the entry standing
+// for a NULL DACL, so it is shown and checked like the ACE it amounts to.
+func getEffectiveACEs(sd *SecurityDescriptor) []SecurityACE {
+	if sd.NullDACL {
+		return []SecurityACE{nullDACLACE}
+	}
+	return sd.ACEs
+}
+
+// getACEDescription renders an ACE, e.g. "Allow BUILTIN\Administrators:
+// TRACELOG_GUID_ENABLE | TRACELOG_LOG_EVENT".
+func getACEDescription(ace SecurityACE) string {
+	who := ace.Account
+	if who == "" {
+		who = ace.SID
+	}
+	var kind string
+	switch ace.Type {
+	case "allow":
+		kind = "Allow"
+	case "deny":
+		kind = "Deny"
+	default:
+		kind = "ACE type " + ace.Type
+	}
+	return fmt.Sprintf("%s %s: %s", kind, who, strings.Join(ace.Rights, " | "))
+}
+
+// getSecuritySourceLabel describes where a descriptor comes from.
+func getSecuritySourceLabel(sd *SecurityDescriptor) string {
+	if sd.Source == securitySourceDefault {
+		return "default descriptor"
+	}
+	return "own descriptor"
+}
+
+// providersWithSecurity returns the providers whose security descriptor was
+// read.
+func providersWithSecurity(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if p.Security != nil {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displaySecurity lists the security descriptor of every provider: who may
+// enable it, log to it or change its descriptor.
+func displaySecurity(w io.Writer, providers []ETWProvider) {
+	providers = providersWithSecurity(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nProvider Security:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		fmt.Fprintf(w, "\n%s (%s): %s\n", p.Name, p.GUID, getSecuritySourceLabel(p.Security))
+		fmt.Fprintf(w, "SDDL: %s\n", p.Security.SDDL)
+		for _, ace := range getEffectiveACEs(p.Security) {
+			fmt.Fprintf(w, "- %s\n", getACEDescription(ace))
+		}
+	}
+}
+
+// sessionControlRights are the rights on a session that let their holder
+// enable providers into it, consume it in real time, or take it over.
+const sessionControlRights = 0x00000080 | // TRACELOG_GUID_ENABLE
+	0x00000020 | // TRACELOG_CREATE_REALTIME
+	0x00000040 | // TRACELOG_CREATE_ONDISK
+	0x00000400 | // TRACELOG_ACCESS_REALTIME
+	0x00010000 | // DELETE
+	0x00040000 | // WRITE_DAC
+	0x00080000 // WRITE_OWNER
+
+// administrativeSIDs are the accounts expected to control trace sessions:
+// SYSTEM, LocalService, NetworkService, Administrators and Performance Log
+// Users, the group Windows grants trace control to by design.
+var administrativeSIDs = []string{
+	"S-1-5-18",
+	"S-1-5-19",
+	"S-1-5-20",
+	"S-1-5-32-544",
+	"S-1-5-32-559",
+}
+
+// isAdministrativeSID reports whether a SID is one of the administrative
+// accounts or a service SID, such as TrustedInstaller's.
+func isAdministrativeSID(sid string) bool {
+	return slices.Contains(administrativeSIDs, sid) || strings.HasPrefix(sid, "S-1-5-80-")
+}
+
+// weakSessionACEs returns the entries of a session descriptor that grant
+// session control rights to non-administrative accounts, which would let
+// low-privileged code tamper with the telemetry the session collects.
+func weakSessionACEs(sd *SecurityDescriptor) []SecurityACE {
+	if sd == nil {
+		return nil
+	}
+	var weak []SecurityACE
+	for _, ace := range getEffectiveACEs(sd) {
+		if isWeakACE(ace) {
+			weak = append(weak, ace)
+		}
+	}
+	return weak
+}
+
+// isWeakACE reports whether an entry of a session descriptor grants session
+// control rights to a non-administrative account.
+func isWeakACE(ace SecurityACE) bool {
+	return ace.Type == "allow" && ace.Mask&sessionControlRights != 0 && !isAdministrativeSID(ace.SID)
+}
+
+// displaySessionSecurity shows the security descriptor of an autologger
+// session, highlighting entries that give non-administrative accounts
+// control over it.
+func displaySessionSecurity(w io.Writer, config *AutologgerConfig, pal palette) {
+	sd := config.Security
+	if sd == nil {
+		return
+	}
+	fmt.Fprintf(w, "\nSession Security (%s):\n", getSecuritySourceLabel(sd))
+	fmt.Fprintf(w, "SDDL: %s\n", sd.SDDL)
+	for _, ace := range getEffectiveACEs(sd) {
+		desc := getACEDescription(ace)
+		if isWeakACE(ace) {
+			desc = pal.red(desc)
+		}
+		fmt.Fprintf(w, "- %s\n", desc)
+	}
+	fmt.Fprintln(w)
+}
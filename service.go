@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	serviceName        = "autologgerAnalyzer"
+	serviceDisplayName = "Autologger Analyzer"
+	serviceDescription = "Scans the ETW autologgers on a schedule and reports changes and findings."
+
+	// serviceStopTimeout is how long service uninstall waits for the
+	// service to stop.
+	serviceStopTimeout = 30 * time.Second
+)
+
+// serviceInstallCommand and serviceUninstallCommand describe the service
+// subcommands for their help text.
+var (
+	serviceInstallCommand = &command{
+		name:    "service install",
+		args:    "[flags] [-- daemon flags]",
+		summary: "Register daemon as a Windows service that starts automatically",
+	}
+	serviceUninstallCommand = &command{
+		name:    "service uninstall",
+		args:    "[flags]",
+		summary: "Stop and remove the Windows service",
+	}
+)
+
+func runService(cmd *command, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "install":
+			return runServiceInstall(args[1:])
+		case "uninstall":
+			return runServiceUninstall(args[1:])
+		}
+	}
+	fs := newFlagSet(cmd)
+	fs.Usage()
+	return fmt.Errorf("a service subcommand is required: install or uninstall")
+}
+
+// runServiceInstall implements service install. The daemon flags are
+// checked here, so a typo fails the install instead of the service.
+func runServiceInstall(args []string) error {
+	fs := newFlagSet(serviceInstallCommand)
+	daemonArgs := parseArgs(fs, args)
+
+	var opts daemonOptions
+	daemonFlags := newFlagSet(lookupCommand("daemon"))
+	opts.register(daemonFlags)
+	if rest := parseArgs(daemonFlags, daemonArgs); len(rest) > 0 {
+		return fmt.Errorf("unexpected daemon argument %q", rest[0])
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot find the executable: %v", err)
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %v", err)
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed, uninstall it first", serviceName)
+	}
+
+	config := mgr.Config{
+		DisplayName:      serviceDisplayName,
+		Description:      serviceDescription,
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true,
+	}
+	s, err := m.CreateService(serviceName, exe, config, append([]string{"daemon"}, daemonArgs...)...)
+	if err != nil {
+		return fmt.Errorf("failed to create service %s: %v", serviceName, err)
+	}
+	defer s.Close()
+	// Restart after a crash, but not in a tight loop.
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Minute},
+		{Type: mgr.NoAction},
+	}
+	if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		slog.Warn("cannot set the recovery actions of the service", "error", err)
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("installed service %s, but failed to start it: %v", serviceName, err)
+	}
+
+	fmt.Printf("Installed and started service %s: %s daemon %s\n", serviceName, exe, strings.Join(daemonArgs, " "))
+	fmt.Printf("Logs are written to %s\n", filepath.Join(opts.stateDir, "daemon.log"))
+	return nil
+}
+
+// runServiceUninstall implements service uninstall.
+func runServiceUninstall(args []string) error {
+	fs := newFlagSet(serviceUninstallCommand)
+	parseArgs(fs, args)
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %v", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %v", serviceName, err)
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service %s: %v", serviceName, err)
+	}
+	if status.State != svc.Stopped {
+		if status, err = s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service %s: %v", serviceName, err)
+		}
+		deadline := time.Now().Add(serviceStopTimeout)
+		for status.State != svc.Stopped {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("service %s didn't stop within %s", serviceName, serviceStopTimeout)
+			}
+			time.Sleep(500 * time.Millisecond)
+			if status, err = s.Query(); err != nil {
+				return fmt.Errorf("failed to query service %s: %v", serviceName, err)
+			}
+		}
+	}
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service %s: %v", serviceName, err)
+	}
+	fmt.Printf("Uninstalled service %s\n", serviceName)
+	return nil
+}
+
+// daemonService runs daemon under the service control manager.
+type daemonService struct {
+	opts *daemonOptions
+}
+
+// runDaemonService runs daemon as a service. A service has no stderr, so
+// the log goes to daemon.log in the state directory.
+func runDaemonService(opts *daemonOptions) error {
+	if err := createStateDir(opts.stateDir); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(opts.stateDir, "daemon.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open the service log: %v", err)
+	}
+	defer logFile.Close()
+	logOutput = logFile
+	if logLevel.Level() > slog.LevelInfo {
+		logLevel.Set(slog.LevelInfo)
+	}
+	configureLogger()
+
+	return svc.Run(serviceName, &daemonService{opts: opts})
+}
+
+func (d *daemonService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- runDaemonLoop(ctx, d.opts)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				slog.Error("daemon failed", "error", err)
+				return true, 1
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
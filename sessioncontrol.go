@@ -0,0 +1,431 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// sessionStartCommand describes session start for its help text.
+var sessionStartCommand = &command{
+	name:    "session start",
+	args:    "[flags] <autologger>",
+	summary: "Start the session of an autologger with its registry settings, without a reboot",
+}
+
+// sessionStopCommand describes session stop for its help text.
+var sessionStopCommand = &command{
+	name:    "session stop",
+	args:    "<autologger>",
+	summary: "Stop the running session of an autologger",
+}
+
+// sessionFlushCommand describes session flush for its help text.
+var sessionFlushCommand = &command{
+	name:    "session flush",
+	args:    "[flags] <autologger...>",
+	summary: "Write the buffers of running autologger sessions to their log files",
+}
+
+// sessionTuneCommand describes session tune for its help text.
+var sessionTuneCommand = &command{
+	name:    "session tune",
+	args:    "[flags] <autologger>",
+	summary: "Change the buffer settings of an autologger in the registry and its running session",
+}
+
+func runSession(cmd *command, args []string) error {
+	if len(args) > 0 && args[0] == "start" {
+		return runSessionStart(args[1:])
+	}
+	if len(args) > 0 && args[0] == "stop" {
+		return runSessionStop(args[1:])
+	}
+	if len(args) > 0 && args[0] == "flush" {
+		return runSessionFlush(args[1:])
+	}
+	if len(args) > 0 && args[0] == "tune" {
+		return runSessionTune(args[1:])
+	}
+	fs := newFlagSet(cmd)
+	fs.Usage()
+	return fmt.Errorf("a session subcommand is required: start, stop, flush, tune")
+}
+
+// defaultLogFile is where ETW writes the log file of an autologger without
+// a FileName value.
+func defaultLogFile(name string) string {
+	return filepath.Join(os.Getenv("SystemRoot"), `System32\LogFiles\WMI`, name+".etl")
+}
+
+// newAutologgerProperties builds the EVENT_TRACE_PROPERTIES an autologger's
+// registry values describe, with the log file to write to or "" for a
+// real-time only session.
+func newAutologgerProperties(config *AutologgerConfig, logFile string) (*eventTraceProperties, error) {
+	props := newTraceProperties()
+	if config.GUID != "" {
+		guid, err := windows.GUIDFromString(canonicalGUID(config.GUID))
+		if err != nil {
+			return nil, fmt.Errorf("invalid session GUID %q: %v", config.GUID, err)
+		}
+		props.Wnode.Guid = guid
+	}
+	props.Wnode.ClientContext = uint32(config.ClockType)
+	props.BufferSize = uint32(config.BufferSize)
+	props.MinimumBuffers = uint32(config.MinimumBuffers)
+	props.MaximumBuffers = uint32(config.MaximumBuffers)
+	props.FlushTimer = uint32(config.FlushTimer)
+	props.LogFileMode = uint32(config.LogFileMode)
+	props.MaximumFileSize = uint32(config.MaxFileSize)
+	props.EnableFlags = uint32(config.EnableFlags)
+	if logFile == "" {
+		props.LogFileNameOffset = 0
+	} else if err := setTraceString(props, props.LogFileNameOffset, logFile); err != nil {
+		return nil, fmt.Errorf("invalid log file: %v", err)
+	}
+	return props, nil
+}
+
+// enableProviders enables the enabled providers of an autologger in a
+// session with their level, keywords and enable property, and returns how
+// many could not be enabled. Filters aren't applied.
+func enableProviders(handle uint64, providers []ETWProvider) int {
+	failed := 0
+	for _, p := range providers {
+		if p.EnabledState == enabledOff {
+			continue
+		}
+		err := enableTrace(handle, canonicalGUID(p.GUID), uint8(p.EnableLevel),
+			p.MatchAnyKeyword, p.MatchAllKeyword, uint32(p.EnableProperty))
+		if err != nil {
+			slog.Warn("cannot enable provider", "provider", p.GUID, "error", err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// runSessionStart implements session start: it starts the session of an
+// autologger the way ETW does at boot, so a new or changed autologger can be
+// exercised without rebooting.
+func runSessionStart(args []string) error {
+	var logFile string
+	var overwrite bool
+
+	fs := newFlagSet(sessionStartCommand)
+	fs.StringVar(&logFile, "file", "", "Write to this log file instead of the one FileName names")
+	fs.BoolVar(&overwrite, "overwrite", false, "Overwrite an existing log file")
+	names := parseArgs(fs, args)
+	if len(names) != 1 {
+		fs.Usage()
+		return fmt.Errorf("an autologger name is required")
+	}
+	name := names[0]
+
+	report, err := analyzeAutologger(name, analyzeOptions{noResolve: true})
+	if err != nil {
+		return err
+	}
+	config := report.Config
+	if config.SessionState == sessionRunning {
+		return fmt.Errorf("session %s is already running as logger %d", config.Session.Name, config.Session.LoggerID)
+	}
+
+	if logFile == "" {
+		logFile = config.FilePath
+		if logFile == "" && config.LogFileMode&eventTraceRealTimeMode == 0 {
+			logFile = defaultLogFile(name)
+		}
+	}
+	if logFile != "" && !overwrite {
+		// A sequential log file would be truncated, which destroys the
+		// events of the last boot.
+		if _, err := os.Stat(logFile); err == nil {
+			return fmt.Errorf("log file %s exists, use -overwrite to replace it or -file to write elsewhere", logFile)
+		}
+	}
+
+	props, err := newAutologgerProperties(config, logFile)
+	if err != nil {
+		return err
+	}
+	handle, err := startTrace(name, props)
+	if err != nil {
+		return fmt.Errorf("failed to start session %s: %v", name, err)
+	}
+
+	var summary reportSummary
+	if !isKernelSession(config) {
+		summary.partial = enableProviders(handle, report.Providers)
+	}
+
+	state := fmt.Sprintf("Started %s", name)
+	if stats, err := querySession(name); err == nil {
+		state += fmt.Sprintf(" as logger %d", stats.LoggerID)
+	}
+	if isKernelSession(config) {
+		state += fmt.Sprintf(" with kernel groups %s", strings.Join(getKernelGroups(config), " | "))
+	} else {
+		state += fmt.Sprintf(", %d of %d providers enabled", len(enabledProviders(report.Providers))-summary.partial, len(report.Providers))
+	}
+	fmt.Println(state)
+	if logFile != "" {
+		fmt.Printf("Writing to %s\n", logFile)
+	}
+	return summary.exitStatus()
+}
+
+// enabledProviders returns the providers of an autologger that aren't
+// disabled.
+func enabledProviders(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if p.EnabledState != enabledOff {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// runSessionStop implements session stop. The session is looked up like
+// show does, so sessions started under another name are found by GUID.
+func runSessionStop(args []string) error {
+	fs := newFlagSet(sessionStopCommand)
+	names := parseArgs(fs, args)
+	if len(names) != 1 {
+		fs.Usage()
+		return fmt.Errorf("an autologger name is required")
+	}
+	name := names[0]
+
+	config, err := getAutologgerConfig(name)
+	if err != nil {
+		return fmt.Errorf("reading autologger config: %v", err)
+	}
+	session, err := findAutologgerSession(config)
+	if err == errSessionNotRunning {
+		return fmt.Errorf("session %s is not running", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query session: %v", err)
+	}
+	stats, err := stopTrace(session.Name)
+	if err != nil {
+		return fmt.Errorf("failed to stop session %s: %v", session.Name, err)
+	}
+	fmt.Printf("Stopped %s (logger %d): %s\n", session.Name, session.LoggerID, getSessionStatsDescription(stats))
+	return nil
+}
+
+// runSessionFlush implements session flush: the events still in the
+// buffers of a session are written to its log file, so the file holds
+// everything logged so far before it is collected.
+func runSessionFlush(args []string) error {
+	var all bool
+
+	fs := newFlagSet(sessionFlushCommand)
+	fs.BoolVar(&all, "all", false, "Flush every running autologger session")
+	names := parseArgs(fs, args)
+	if all && len(names) > 0 {
+		return fmt.Errorf("-all can't be combined with autologger names")
+	}
+	if all {
+		var err error
+		if names, err = getAutologgerNames(); err != nil {
+			return err
+		}
+	}
+	if len(names) == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one autologger name or -all is required")
+	}
+
+	var summary reportSummary
+	for _, name := range names {
+		config, err := getAutologgerConfig(name)
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			summary.skipped++
+			continue
+		}
+		session, err := findAutologgerSession(config)
+		if err == errSessionNotRunning {
+			// With -all, most autologgers aren't running.
+			if !all {
+				slog.Warn("session is not running", "autologger", name)
+				summary.skipped++
+			}
+			continue
+		}
+		if err != nil {
+			slog.Warn("cannot query session", "autologger", name, "error", err)
+			summary.skipped++
+			continue
+		}
+		props, err := flushTrace(session.Name)
+		if err != nil {
+			slog.Warn("cannot flush session", "session", session.Name, "error", err)
+			summary.skipped++
+			continue
+		}
+		logFile := traceString(props, props.LogFileNameOffset)
+		if logFile == "" {
+			logFile = "no log file, real-time only"
+		}
+		fmt.Printf("Flushed %s (logger %d): %s\n", session.Name, session.LoggerID, logFile)
+	}
+	return summary.exitStatus()
+}
+
+// bufferSetting is a buffer value of an autologger that session tune
+// changes.
+type bufferSetting struct {
+	name  string // registry value
+	flag  string
+	value uint64
+	// live is whether ControlTrace can change the setting of a running
+	// session.
+	live bool
+}
+
+// runSessionTune implements session tune: it writes new buffer settings to
+// the registry and applies those ETW can change at runtime to the running
+// session, so a session that loses events can be fixed without a reboot.
+func runSessionTune(args []string) error {
+	var noRegistry, noLive bool
+	settings := []*bufferSetting{
+		{name: "BufferSize", flag: "buffer-size"},
+		{name: "MinimumBuffers", flag: "min-buffers"},
+		{name: "MaximumBuffers", flag: "max-buffers", live: true},
+		{name: "FlushTimer", flag: "flush-timer", live: true},
+	}
+
+	fs := newFlagSet(sessionTuneCommand)
+	fs.Uint64Var(&settings[0].value, "buffer-size", 0, "New BufferSize in KB")
+	fs.Uint64Var(&settings[1].value, "min-buffers", 0, "New MinimumBuffers")
+	fs.Uint64Var(&settings[2].value, "max-buffers", 0, "New MaximumBuffers")
+	fs.Uint64Var(&settings[3].value, "flush-timer", 0, "New FlushTimer in seconds")
+	fs.BoolVar(&noRegistry, "no-registry", false, "Only tune the running session, the registry keeps its values")
+	fs.BoolVar(&noLive, "no-live", false, "Only change the registry, the running session keeps its values")
+	names := parseArgs(fs, args)
+	if len(names) != 1 {
+		fs.Usage()
+		return fmt.Errorf("an autologger name is required")
+	}
+	name := names[0]
+	if noRegistry && noLive {
+		return fmt.Errorf("-no-registry and -no-live exclude each other")
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var changed []*bufferSetting
+	for _, s := range settings {
+		if set[s.flag] {
+			changed = append(changed, s)
+		}
+	}
+	if len(changed) == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one of -buffer-size, -min-buffers, -max-buffers or -flush-timer is required")
+	}
+
+	config, err := getAutologgerConfig(name)
+	if err != nil {
+		return fmt.Errorf("reading autologger config: %v", err)
+	}
+	current := map[string]uint64{
+		"BufferSize":     config.BufferSize,
+		"MinimumBuffers": config.MinimumBuffers,
+		"MaximumBuffers": config.MaximumBuffers,
+		"FlushTimer":     config.FlushTimer,
+	}
+	tuned := make(map[string]uint64)
+	for k, v := range current {
+		tuned[k] = v
+	}
+	for _, s := range changed {
+		tuned[s.name] = s.value
+	}
+	if tuned["BufferSize"] > 1024 {
+		return fmt.Errorf("BufferSize %d KB exceeds the 1024 KB ETW allows", tuned["BufferSize"])
+	}
+	if tuned["MaximumBuffers"] != 0 && tuned["MinimumBuffers"] > tuned["MaximumBuffers"] {
+		return fmt.Errorf("MinimumBuffers %d is larger than MaximumBuffers %d", tuned["MinimumBuffers"], tuned["MaximumBuffers"])
+	}
+
+	var summary reportSummary
+	if !noRegistry {
+		var values []regValue
+		var desc []string
+		for _, s := range changed {
+			values = append(values, dwordValue(s.name, s.value))
+			desc = append(desc, fmt.Sprintf("%s %d -> %d", s.name, current[s.name], s.value))
+		}
+		change := regKeyChange{path: baseAutologgerPath + `\` + config.Name, values: values}
+		if err := applyRegChanges([]regKeyChange{change}); err != nil {
+			return err
+		}
+		fmt.Printf("Updated registry of %s: %s\n", config.Name, strings.Join(desc, ", "))
+	}
+	if noLive {
+		return nil
+	}
+
+	session, err := findAutologgerSession(config)
+	if err == errSessionNotRunning {
+		fmt.Printf("Session %s is not running, the settings apply when it next starts\n", config.Name)
+		if noRegistry {
+			summary.skipped++
+		}
+		return summary.exitStatus()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query session: %v", err)
+	}
+
+	var live, deferred []string
+	for _, s := range changed {
+		if s.live {
+			live = append(live, s.name)
+		} else {
+			deferred = append(deferred, s.name)
+		}
+	}
+	if len(live) > 0 {
+		maximumBuffers := session.MaximumBuffers
+		if set["max-buffers"] {
+			maximumBuffers = uint32(tuned["MaximumBuffers"])
+		}
+		flushTimer := session.FlushTimer
+		if set["flush-timer"] {
+			flushTimer = uint32(tuned["FlushTimer"])
+		}
+		props, err := updateTrace(session.Name, maximumBuffers, flushTimer)
+		if err != nil {
+			slog.Warn("cannot update session", "session", session.Name, "error", err)
+			summary.partial++
+			deferred = append(deferred, live...)
+		} else {
+			after := newSessionStats(props)
+			fmt.Printf("Updated session %s (logger %d): MaximumBuffers %d -> %d, FlushTimer %d -> %d\n",
+				session.Name, session.LoggerID, session.MaximumBuffers, after.MaximumBuffers, session.FlushTimer, after.FlushTimer)
+		}
+	}
+	if len(deferred) > 0 {
+		// ETW allocates the buffers when the session starts, so their size
+		// and minimum count are fixed until it is restarted.
+		if noRegistry {
+			fmt.Printf("Skipped %s, which the running session can't change\n", strings.Join(deferred, ", "))
+			summary.partial++
+		} else {
+			fmt.Printf("%s apply when the session next starts\n", strings.Join(deferred, ", "))
+		}
+	}
+	return summary.exitStatus()
+}
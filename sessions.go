@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// States of an autologger's session.
+const (
+	sessionRunning = "running"
+	sessionStopped = "stopped"
+)
+
+// lowSessionHeadroom is the number of free trace sessions below which the
+// session count is reported as close to the limit.
+const lowSessionHeadroom = 8
+
+// etwMaxLoggersPath holds EtwMaxLoggers, which raises the session limit.
+const etwMaxLoggersPath = `SYSTEM\CurrentControlSet\Control\WMI`
+
+// SessionCount is how many of the trace sessions Windows allows are taken.
+type SessionCount struct {
+	Live int `json:"live"`
+	// Pending are the autologgers started at boot whose session isn't
+	// running; each takes a session at the next boot.
+	Pending int `json:"pending"`
+	Limit   int `json:"limit"`
+}
+
+// Free is the number of sessions that can still be started.
+func (c *SessionCount) Free() int {
+	return c.Limit - c.Live - c.Pending
+}
+
+// sessionCount caches the session count, so every report doesn't query the
+// sessions again.
+var sessionCount struct {
+	once  sync.Once
+	count *SessionCount
+}
+
+// countSessions counts the running sessions and the autologgers started at
+// boot that aren't running against the session limit, or returns nil if the
+// sessions could not be queried.
+func countSessions() *SessionCount {
+	sessionCount.once.Do(func() {
+		traces, err := queryAllTraces()
+		if err != nil {
+			slog.Warn("cannot count trace sessions", "error", err)
+			return
+		}
+		count := &SessionCount{Live: len(traces), Limit: getSessionLimit()}
+		running := make(map[string]bool)
+		for _, props := range traces {
+			running[strings.ToLower(newSessionStats(props).Name)] = true
+			running[canonicalGUID(props.Wnode.Guid.String())] = true
+		}
+		names, err := getAutologgerNames()
+		if err != nil {
+			slog.Warn("cannot count autologgers started at boot", "error", err)
+		}
+		for _, name := range names {
+			config, err := getAutologgerConfig(name)
+			if err != nil || config.Start == 0 {
+				continue
+			}
+			if !running[strings.ToLower(name)] && (config.GUID == "" || !running[canonicalGUID(config.GUID)]) {
+				count.Pending++
+			}
+		}
+		sessionCount.count = count
+	})
+	return sessionCount.count
+}
+
+// getSessionLimit returns the number of sessions Windows allows, 64 unless
+// EtwMaxLoggers raises or lowers it.
+func getSessionLimit() int {
+	key, err := openKey(registry.LOCAL_MACHINE, etwMaxLoggersPath, registry.QUERY_VALUE)
+	if err != nil {
+		return maxLoggers
+	}
+	defer key.Close()
+	limit, _, err := key.GetIntegerValue("EtwMaxLoggers")
+	if err != nil || limit == 0 {
+		return maxLoggers
+	}
+	return int(limit)
+}
+
+// getSessionCountDescription renders how many trace sessions are taken, e.g.
+// "52 of 64 running, 4 more autologgers start at boot, 8 free".
+func getSessionCountDescription(c *SessionCount) string {
+	return fmt.Sprintf("%d of %d running, %d more autologgers start at boot, %d free",
+		c.Live, c.Limit, c.Pending, max(c.Free(), 0))
+}
+
+// sessionLimitWarning explains that the trace sessions are about to run out,
+// or returns "" if there is enough headroom or it is unknown.
+func sessionLimitWarning() string {
+	c := countSessions()
+	switch {
+	case c == nil:
+		return ""
+	case c.Free() <= 0:
+		return fmt.Sprintf("all %d trace sessions are taken (%s), new sessions such as those of an EDR agent will fail to start", c.Limit, getSessionCountDescription(c))
+	case c.Free() < lowSessionHeadroom:
+		return fmt.Sprintf("only %d of %d trace sessions are free (%s), new sessions may fail to start", c.Free(), c.Limit, getSessionCountDescription(c))
+	}
+	return ""
+}
+
+// LiveSession is a trace session running on this host.
+type LiveSession struct {
+	GUID    string `json:"guid"`
+	LogFile string `json:"log_file,omitempty"`
+	// Autologger is the autologger the session was started from, or ""
+	// if it was started at runtime.
+	Autologger string `json:"autologger,omitempty"`
+	SessionStats
+}
+
+// collectLiveSessions lists the running trace sessions, sorted by name, and
+// matches them with the configured autologgers by name.
+func collectLiveSessions() ([]LiveSession, error) {
+	traces, err := queryAllTraces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trace sessions: %v", err)
+	}
+	autologgers := make(map[string]string)
+	if names, err := getAutologgerNames(); err == nil {
+		for _, name := range names {
+			autologgers[strings.ToLower(name)] = name
+		}
+	}
+
+	sessions := make([]LiveSession, 0, len(traces))
+	for _, props := range traces {
+		stats := newSessionStats(props)
+		sessions = append(sessions, LiveSession{
+			GUID:         canonicalGUID(props.Wnode.Guid.String()),
+			LogFile:      traceString(props, props.LogFileNameOffset),
+			Autologger:   autologgers[strings.ToLower(stats.Name)],
+			SessionStats: *stats,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return strings.ToLower(sessions[i].Name) < strings.ToLower(sessions[j].Name)
+	})
+	return sessions, nil
+}
+
+func runSessions(cmd *command, args []string) error {
+	var format string
+	var output outputOptions
+
+	fs := newFlagSet(cmd)
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+
+	sessions, err := collectLiveSessions()
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sessions)
+	}
+
+	displayLiveSessions(os.Stdout, sessions, newPalette(os.Stdout, output.color))
+	return nil
+}
+
+// displayLiveSessions prints the running sessions with their buffer usage
+// and losses. Sessions that lost events or buffers are highlighted.
+func displayLiveSessions(w io.Writer, sessions []LiveSession, pal palette) {
+	autologgers := 0
+	for _, s := range sessions {
+		if s.Autologger != "" {
+			autologgers++
+		}
+	}
+	fmt.Fprintf(w, "Live Trace Sessions: %d running, %d started from an autologger\n\n", len(sessions), autologgers)
+
+	fmt.Fprintf(w, "| %-35s | %-3s | %-10s | %-11s | %-10s | %-11s | %-12s | %-12s |\n",
+		"Name", "ID", "Autologger", "Buffer Size", "Buffers", "Events Lost", "Buffers Lost", "Written")
+	fmt.Fprintf(w, "|%s|%s|%s|%s|%s|%s|%s|%s|\n",
+		strings.Repeat("-", 37),
+		strings.Repeat("-", 5),
+		strings.Repeat("-", 12),
+		strings.Repeat("-", 13),
+		strings.Repeat("-", 12),
+		strings.Repeat("-", 13),
+		strings.Repeat("-", 14),
+		strings.Repeat("-", 14))
+	for _, s := range sessions {
+		autologger := "No"
+		if s.Autologger != "" {
+			autologger = "Yes"
+		}
+		buffers := fmt.Sprintf("%d/%d", s.NumberOfBuffers-min(s.FreeBuffers, s.NumberOfBuffers), s.NumberOfBuffers)
+		lost := fmt.Sprintf("%-11d", s.EventsLost)
+		if s.EventsLost > 0 {
+			lost = pal.yellow(lost)
+		}
+		buffersLost := fmt.Sprintf("%-12d", s.LogBuffersLost+s.RealTimeBuffersLost)
+		if s.LogBuffersLost+s.RealTimeBuffersLost > 0 {
+			buffersLost = pal.yellow(buffersLost)
+		}
+		fmt.Fprintf(w, "| %-35s | %-3d | %-10s | %-11s | %-10s | %s | %s | %-12d |\n",
+			truncateString(s.Name, 35), s.LoggerID, autologger, formatKB(uint64(s.BufferSize)), buffers,
+			lost, buffersLost, s.BuffersWritten)
+	}
+
+	header := false
+	for _, s := range sessions {
+		if s.LogFile == "" {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "\nLog Files:\n")
+			header = true
+		}
+		fmt.Fprintf(w, "- %s: %s\n", s.Name, s.LogFile)
+	}
+}
+
+// getSessionStatsDescription renders the runtime statistics of a session,
+// e.g. "3/4 buffers in use, 1234 buffers written, 0 events lost, 0 buffers
+// lost (0 log file, 0 real-time)".
+func getSessionStatsDescription(s *SessionStats) string {
+	return fmt.Sprintf("%d/%d buffers in use, %d buffers written, %d events lost, %d buffers lost (%d log file, %d real-time)",
+		s.NumberOfBuffers-min(s.FreeBuffers, s.NumberOfBuffers), s.NumberOfBuffers, s.BuffersWritten, s.EventsLost,
+		s.LogBuffersLost+s.RealTimeBuffersLost, s.LogBuffersLost, s.RealTimeBuffersLost)
+}
+
+// sessionLosing reports whether a session has lost events or buffers since
+// it started, typically because its buffers are too small or too few for
+// the rate of its providers.
+func sessionLosing(s *SessionStats) bool {
+	return s.EventsLost > 0 || s.LogBuffersLost > 0 || s.RealTimeBuffersLost > 0
+}
+
+// findAutologgerSession returns the live session of an autologger, looked up
+// by name and, for sessions started under another name, by the GUID of the
+// autologger. It returns errSessionNotRunning if neither matches.
+func findAutologgerSession(config *AutologgerConfig) (*SessionStats, error) {
+	stats, err := querySession(config.Name)
+	if err != errSessionNotRunning || config.GUID == "" {
+		return stats, err
+	}
+	traces, err := queryAllTraces()
+	if err != nil {
+		return nil, err
+	}
+	for _, props := range traces {
+		if sameGUID(props.Wnode.Guid.String(), config.GUID) {
+			return newSessionStats(props), nil
+		}
+	}
+	return nil, errSessionNotRunning
+}
+
+// getSessionStateDescription tells whether an autologger's session is
+// running, e.g. "Yes, as logger 14 since 2026-10-17 08:00:01 UTC", and
+// points out a Status value that contradicts it.
+func getSessionStateDescription(config *AutologgerConfig) string {
+	switch config.SessionState {
+	case sessionRunning:
+		s := config.Session
+		desc := fmt.Sprintf("Yes, as logger %d", s.LoggerID)
+		if !strings.EqualFold(s.Name, config.Name) {
+			desc += fmt.Sprintf(" (session %s)", s.Name)
+		}
+		if config.Status != 0 {
+			desc += ", Status is stale"
+		}
+		return desc
+	case sessionStopped:
+		if config.Start != 0 && config.Status == 0 {
+			return "No, although Status reports a successful start"
+		}
+		return "No"
+	}
+	return "Unknown, the session could not be queried"
+}
+
+// sessionStateFlagged reports whether an autologger that starts at boot
+// has no running session.
+func sessionStateFlagged(config *AutologgerConfig) bool {
+	return config.Start != 0 && config.SessionState == sessionStopped
+}
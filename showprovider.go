@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// showProviderCommand describes show provider for its help text.
+var showProviderCommand = &command{
+	name:    "show provider",
+	args:    "[flags] [<autologger>] <guid>",
+	summary: "Show everything known about a provider, or a single provider of an autologger and its registry values with -raw",
+}
+
+// runShowProvider implements show provider. Given only a GUID, it aggregates
+// what the host knows about the provider across autologgers and live
+// sessions. Given an autologger too, it shows the provider's entry in it;
+// with -raw it dumps every value of
+// the provider subkey and its subkeys, such as Filters, with type and raw
+// bytes, as an escape hatch when the structured views hide something.
+func runShowProvider(args []string) error {
+	var raw bool
+	var format string
+	var output outputOptions
+
+	fs := newFlagSet(showProviderCommand)
+	registerProviderDBFlags(fs)
+	fs.BoolVar(&raw, "raw", false, "Print every registry value of the provider subkey and its Filters subkey with type and raw bytes")
+	fs.BoolVar(&output.wide, "wide", false, "Don't truncate the provider table to the terminal width")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	positional := parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if raw && format != "table" {
+		return fmt.Errorf("-raw prints the registry values as they are and can't be combined with -format %s", format)
+	}
+	if len(positional) == 1 {
+		if raw {
+			return fmt.Errorf("-raw needs an autologger name")
+		}
+		guid, ok := normalizeGUID(positional[0])
+		if !ok {
+			return fmt.Errorf("%q is not a GUID", positional[0])
+		}
+		return runProviderDetails(guid, format)
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return fmt.Errorf("a provider GUID, optionally preceded by an autologger name, is required")
+	}
+	autologgerName := positional[0]
+	guid, ok := normalizeGUID(positional[1])
+	if !ok {
+		return fmt.Errorf("%q is not a GUID", positional[1])
+	}
+
+	subkey, err := findProviderSubkey(autologgerName, guid)
+	if err != nil {
+		return err
+	}
+
+	if raw {
+		return dumpProviderSubkey(os.Stdout, autologgerName, subkey)
+	}
+
+	report, err := analyzeAutologger(autologgerName, analyzeOptions{})
+	if err != nil {
+		return err
+	}
+	filter := providerFilter{guids: stringList{guid}}
+	filter.apply(report)
+	if len(report.Providers) == 0 {
+		return fmt.Errorf("autologger %s has no provider %s", autologgerName, guid)
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report.Providers[0])
+	}
+	pal := newPalette(os.Stdout, output.color)
+	displayETWProviders(os.Stdout, report.Providers, autologgerName, output.tableWidth(os.Stdout), pal)
+	displayChannels(os.Stdout, report.Providers, pal)
+	return nil
+}
+
+// findProviderSubkey returns the subkey of an autologger for a provider GUID,
+// including subkeys that differ only in formatting.
+func findProviderSubkey(autologgerName, guid string) (providerSubkey, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, baseAutologgerPath+`\`+autologgerName, registry.READ)
+	if err != nil {
+		return providerSubkey{}, fmt.Errorf("failed to open autologger key: %v", err)
+	}
+	defer key.Close()
+
+	names, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return providerSubkey{}, fmt.Errorf("failed to read subkey names: %v", err)
+	}
+	subkeys, _ := groupProviderSubkeys(names)
+	for _, subkey := range subkeys {
+		if subkey.guid == guid {
+			return subkey, nil
+		}
+	}
+	return providerSubkey{}, fmt.Errorf("autologger %s has no provider %s", autologgerName, guid)
+}
+
+// dumpProviderSubkey prints the values of a provider subkey and of the
+// subkeys with the same GUID in another format.
+func dumpProviderSubkey(w io.Writer, autologgerName string, subkey providerSubkey) error {
+	for i, name := range append([]string{subkey.key}, subkey.duplicates...) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		path := baseAutologgerPath + `\` + autologgerName + `\` + name
+		key, err := openKey(registry.LOCAL_MACHINE, path, registry.READ)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", path, err)
+		}
+		err = dumpRegistryKey(w, key, `HKLM\`+path)
+		key.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumpRegistryKey prints every value of a key with its type, interpretation
+// and bytes, followed by its subkeys.
+func dumpRegistryKey(w io.Writer, key registry.Key, fullPath string) error {
+	fmt.Fprintf(w, "[%s]\n", fullPath)
+
+	valueNames, err := key.ReadValueNames(-1)
+	if err != nil {
+		return fmt.Errorf("failed to read values of %s: %v", fullPath, err)
+	}
+	sort.Strings(valueNames)
+	for _, name := range valueNames {
+		data, valType, err := readRawValue(key, name)
+		if err != nil {
+			return fmt.Errorf("failed to read value %s of %s: %v", name, fullPath, err)
+		}
+		label := name
+		if label == "" {
+			label = "(Default)"
+		}
+		fmt.Fprintf(w, "  %s (%s, %d bytes): %s\n", label, regTypeName(valType), len(data), formatRawValue(valType, data))
+		writeHexFields(w, "  ", hexRows(data, 0))
+	}
+
+	subkeyNames, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return fmt.Errorf("failed to read subkeys of %s: %v", fullPath, err)
+	}
+	sort.Strings(subkeyNames)
+	for _, name := range subkeyNames {
+		subkey, err := openKey(key, name, registry.READ)
+		if err != nil {
+			return fmt.Errorf("failed to open %s\\%s: %v", fullPath, name, err)
+		}
+		fmt.Fprintln(w)
+		err = dumpRegistryKey(w, subkey, fullPath+`\`+name)
+		subkey.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modwintrust = windows.NewLazySystemDLL("wintrust.dll")
+	modcrypt32  = windows.NewLazySystemDLL("crypt32.dll")
+
+	procCryptCATAdminAcquireContext2         = modwintrust.NewProc("CryptCATAdminAcquireContext2")
+	procCryptCATAdminReleaseContext          = modwintrust.NewProc("CryptCATAdminReleaseContext")
+	procCryptCATAdminCalcHashFromFileHandle2 = modwintrust.NewProc("CryptCATAdminCalcHashFromFileHandle2")
+	procCryptCATAdminEnumCatalogFromHash     = modwintrust.NewProc("CryptCATAdminEnumCatalogFromHash")
+	procCryptCATAdminReleaseCatalogContext   = modwintrust.NewProc("CryptCATAdminReleaseCatalogContext")
+	procCryptCATCatalogInfoFromContext       = modwintrust.NewProc("CryptCATCatalogInfoFromContext")
+	procCryptMsgGetParam                     = modcrypt32.NewProc("CryptMsgGetParam")
+	procCryptMsgClose                        = modcrypt32.NewProc("CryptMsgClose")
+)
+
+const cmsgSignerInfoParam = 6 // CMSG_SIGNER_INFO_PARAM
+
+// Signature states of a binary.
+const (
+	signatureSigned   = "signed"
+	signatureUnsigned = "unsigned"
+	signatureInvalid  = "invalid"
+	signatureMissing  = "missing"
+	// signatureUnreadable is a binary that exists, but can't be checked,
+	// e.g. for lack of rights.
+	signatureUnreadable = "unreadable"
+)
+
+// signatureInfo is the Authenticode state of a binary. Windows binaries are
+// mostly signed through a catalog instead of an embedded signature, so both
+// are checked.
+type signatureInfo struct {
+	status  string
+	signer  string // subject of the signing certificate
+	catalog bool   // signed through a catalog file
+	err     error  // why the signature didn't verify
+}
+
+// catalogInfo mirrors CATALOG_INFO.
+type catalogInfo struct {
+	Size        uint32
+	CatalogFile [windows.MAX_PATH]uint16
+}
+
+// wintrustCatalogInfo mirrors WINTRUST_CATALOG_INFO.
+type wintrustCatalogInfo struct {
+	Size                 uint32
+	CatalogVersion       uint32
+	CatalogFilePath      *uint16
+	MemberTag            *uint16
+	MemberFilePath       *uint16
+	MemberFile           windows.Handle
+	CalculatedFileHash   *byte
+	CalculatedFileHashSz uint32
+	CatalogContext       uintptr
+	CatAdmin             windows.Handle
+}
+
+// signatures caches the signature of every binary checked, as many
+// providers share a binary.
+var signatures onceCache[signatureInfo]
+
+// getSignature returns the Authenticode state of a binary.
+func getSignature(path string) signatureInfo {
+	return signatures.get(strings.ToLower(path), func() signatureInfo {
+		return verifySignature(path)
+	})
+}
+
+func verifySignature(path string) signatureInfo {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return signatureInfo{status: signatureMissing, err: err}
+	} else if err != nil {
+		return signatureInfo{status: signatureUnreadable, err: err}
+	}
+
+	err := verifyEmbedded(path)
+	if err == nil {
+		return signatureInfo{status: signatureSigned, signer: getSignerName(path)}
+	}
+	if err != windows.Errno(windows.TRUST_E_NOSIGNATURE) {
+		return signatureInfo{status: signatureInvalid, err: err}
+	}
+
+	catalog, err := verifyCatalog(path)
+	switch {
+	case err == nil:
+		return signatureInfo{status: signatureSigned, signer: getSignerName(catalog), catalog: true}
+	case catalog == "":
+		return signatureInfo{status: signatureUnsigned}
+	default:
+		return signatureInfo{status: signatureInvalid, catalog: true, err: err}
+	}
+}
+
+// winVerifyTrust verifies the file or catalog member in data and releases
+// the state WinVerifyTrust keeps.
+func winVerifyTrust(data *windows.WinTrustData) error {
+	data.Size = uint32(unsafe.Sizeof(*data))
+	data.UIChoice = windows.WTD_UI_NONE
+	data.RevocationChecks = windows.WTD_REVOKE_NONE
+	data.StateAction = windows.WTD_STATEACTION_VERIFY
+	err := windows.WinVerifyTrustEx(windows.InvalidHWND, &windows.WINTRUST_ACTION_GENERIC_VERIFY_V2, data)
+	data.StateAction = windows.WTD_STATEACTION_CLOSE
+	windows.WinVerifyTrustEx(windows.InvalidHWND, &windows.WINTRUST_ACTION_GENERIC_VERIFY_V2, data)
+	return err
+}
+
+// verifyEmbedded checks the signature embedded in a binary.
+func verifyEmbedded(path string) error {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	return winVerifyTrust(&windows.WinTrustData{
+		UnionChoice: windows.WTD_CHOICE_FILE,
+		FileOrCatalogOrBlobOrSgnrOrCert: unsafe.Pointer(&windows.WinTrustFileInfo{
+			Size:     uint32(unsafe.Sizeof(windows.WinTrustFileInfo{})),
+			FilePath: path16,
+		}),
+	})
+}
+
+// verifyCatalog looks for a catalog that contains the hash of a binary and
+// verifies the binary against it. It returns the path of the catalog, or ""
+// if no catalog contains the binary.
+func verifyCatalog(path string) (string, error) {
+	if err := procCryptCATAdminAcquireContext2.Find(); err != nil {
+		return "", err
+	}
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+	sha256, _ := windows.UTF16PtrFromString("SHA256")
+
+	var catAdmin windows.Handle
+	if r, _, err := procCryptCATAdminAcquireContext2.Call(uintptr(unsafe.Pointer(&catAdmin)), 0, uintptr(unsafe.Pointer(sha256)), 0, 0); r == 0 {
+		return "", fmt.Errorf("CryptCATAdminAcquireContext2: %v", err)
+	}
+	defer procCryptCATAdminReleaseContext.Call(uintptr(catAdmin), 0)
+
+	file, err := windows.CreateFile(path16, windows.GENERIC_READ, windows.FILE_SHARE_READ, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(file)
+
+	var hashSize uint32
+	procCryptCATAdminCalcHashFromFileHandle2.Call(uintptr(catAdmin), uintptr(file), uintptr(unsafe.Pointer(&hashSize)), 0, 0)
+	if hashSize == 0 {
+		return "", fmt.Errorf("cannot hash %s", path)
+	}
+	hash := make([]byte, hashSize)
+	if r, _, err := procCryptCATAdminCalcHashFromFileHandle2.Call(uintptr(catAdmin), uintptr(file), uintptr(unsafe.Pointer(&hashSize)), uintptr(unsafe.Pointer(&hash[0])), 0); r == 0 {
+		return "", fmt.Errorf("CryptCATAdminCalcHashFromFileHandle2: %v", err)
+	}
+
+	catInfo, _, _ := procCryptCATAdminEnumCatalogFromHash.Call(uintptr(catAdmin), uintptr(unsafe.Pointer(&hash[0])), uintptr(hashSize), 0, 0)
+	if catInfo == 0 {
+		return "", nil
+	}
+	defer procCryptCATAdminReleaseCatalogContext.Call(uintptr(catAdmin), catInfo, 0)
+
+	info := catalogInfo{Size: uint32(unsafe.Sizeof(catalogInfo{}))}
+	if r, _, err := procCryptCATCatalogInfoFromContext.Call(catInfo, uintptr(unsafe.Pointer(&info)), 0); r == 0 {
+		return "", fmt.Errorf("CryptCATCatalogInfoFromContext: %v", err)
+	}
+
+	tag, _ := windows.UTF16PtrFromString(strings.ToUpper(hex.EncodeToString(hash)))
+	member := &wintrustCatalogInfo{
+		Size:                 uint32(unsafe.Sizeof(wintrustCatalogInfo{})),
+		CatalogFilePath:      &info.CatalogFile[0],
+		MemberTag:            tag,
+		MemberFilePath:       path16,
+		MemberFile:           file,
+		CalculatedFileHash:   &hash[0],
+		CalculatedFileHashSz: hashSize,
+		CatAdmin:             catAdmin,
+	}
+	catalog := windows.UTF16ToString(info.CatalogFile[:])
+	return catalog, winVerifyTrust(&windows.WinTrustData{
+		UnionChoice:                     windows.WTD_CHOICE_CATALOG,
+		FileOrCatalogOrBlobOrSgnrOrCert: unsafe.Pointer(member),
+	})
+}
+
+// getSignerName returns the subject of the certificate that signed a binary
+// or catalog, or "" if it can't be read.
+func getSignerName(path string) string {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return ""
+	}
+	var encoding, contentType, formatType uint32
+	var store, msg windows.Handle
+	err = windows.CryptQueryObject(windows.CERT_QUERY_OBJECT_FILE, unsafe.Pointer(path16),
+		windows.CERT_QUERY_CONTENT_FLAG_ALL, windows.CERT_QUERY_FORMAT_FLAG_ALL, 0,
+		&encoding, &contentType, &formatType, &store, &msg, nil)
+	if err != nil {
+		return ""
+	}
+	defer windows.CertCloseStore(store, 0)
+	defer procCryptMsgClose.Call(uintptr(msg))
+
+	// CMSG_SIGNER_INFO starts with dwVersion, then the Issuer and
+	// SerialNumber blobs that identify the signing certificate.
+	var size uint32
+	procCryptMsgGetParam.Call(uintptr(msg), cmsgSignerInfoParam, 0, 0, uintptr(unsafe.Pointer(&size)))
+	if size == 0 {
+		return ""
+	}
+	buf := make([]byte, size)
+	if r, _, _ := procCryptMsgGetParam.Call(uintptr(msg), cmsgSignerInfoParam, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); r == 0 {
+		return ""
+	}
+	signer := (*struct {
+		Version      uint32
+		Issuer       windows.CertNameBlob
+		SerialNumber windows.CryptIntegerBlob
+	})(unsafe.Pointer(&buf[0]))
+
+	cert, err := windows.CertFindCertificateInStore(store, windows.X509_ASN_ENCODING|windows.PKCS_7_ASN_ENCODING, 0,
+		windows.CERT_FIND_SUBJECT_CERT, unsafe.Pointer(&windows.CertInfo{Issuer: signer.Issuer, SerialNumber: signer.SerialNumber}), nil)
+	if err != nil {
+		return ""
+	}
+	defer windows.CertFreeCertificateContext(cert)
+
+	name := make([]uint16, 256)
+	n := windows.CertGetNameString(cert, windows.CERT_NAME_SIMPLE_DISPLAY_TYPE, 0, nil, &name[0], uint32(len(name)))
+	if n <= 1 {
+		return ""
+	}
+	return windows.UTF16ToString(name[:n])
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Record types shipped to log sinks.
+const (
+	recordAutologger = "autologger"
+	recordProvider   = "provider"
+	recordFinding    = "finding"
+)
+
+// sinkRecord is a single flat event sent to a log sink. A report is split into
+// one autologger record, one record per provider and one per finding so each
+// can be searched on its own.
+type sinkRecord struct {
+	Type       string            `json:"record_type"`
+	Host       *HostMetadata     `json:"host"`
+	Autologger string            `json:"autologger"`
+	Config     *AutologgerConfig `json:"config,omitempty"`
+	Provider   *ETWProvider      `json:"provider,omitempty"`
+	Finding    *Finding          `json:"finding,omitempty"`
+}
+
+func reportRecords(host *HostMetadata, report *AutologgerReport) []sinkRecord {
+	name := report.Config.Name
+	records := []sinkRecord{{Type: recordAutologger, Host: host, Autologger: name, Config: report.Config}}
+
+	for i := range report.Providers {
+		records = append(records, sinkRecord{Type: recordProvider, Host: host, Autologger: name, Provider: &report.Providers[i]})
+	}
+	for i := range report.Findings {
+		records = append(records, sinkRecord{Type: recordFinding, Host: host, Autologger: name, Finding: &report.Findings[i]})
+	}
+
+	return records
+}
+
+const (
+	sinkMaxAttempts = 4
+	sinkRetryDelay  = time.Second
+)
+
+// postWithRetry sends body to url, retrying with exponential backoff on
+// network errors, 429 and 5xx responses.
+func postWithRetry(client *http.Client, url string, header http.Header, body []byte) error {
+	_, err := postWithRetryResponse(client, url, header, body)
+	return err
+}
+
+// postWithRetryResponse is postWithRetry for callers that need to inspect the
+// body of the successful response.
+func postWithRetryResponse(client *http.Client, url string, header http.Header, body []byte) ([]byte, error) {
+	delay := sinkRetryDelay
+	var lastErr error
+
+	for attempt := 1; attempt <= sinkMaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return respBody, nil
+		}
+		if len(respBody) > 512 {
+			respBody = respBody[:512]
+		}
+		lastErr = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(respBody))
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return nil, lastErr
+		}
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %v", sinkMaxAttempts, lastErr)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// snapshotVersion is the format version of snapshot files.
+const snapshotVersion = 1
+
+// Snapshot is the registry state of every autologger on a host, written by
+// snapshot and compared by diff-snapshots.
+type Snapshot struct {
+	Version     int                 `json:"version"`
+	Host        *HostMetadata       `json:"host"`
+	Autologgers []*AutologgerReport `json:"autologgers"`
+}
+
+// SnapshotDiff is what changed between two snapshots.
+type SnapshotDiff struct {
+	Old     *HostMetadata    `json:"old"`
+	New     *HostMetadata    `json:"new"`
+	Added   []string         `json:"added"`
+	Removed []string         `json:"removed"`
+	Changed []SnapshotChange `json:"changed"`
+}
+
+// SnapshotChange is an autologger in both snapshots with the settings that
+// differ, the providers that were added or removed included.
+type SnapshotChange struct {
+	Autologger  string    `json:"autologger"`
+	Differences []DiffRow `json:"differences"`
+}
+
+// takeSnapshot reads every autologger.
+func takeSnapshot() (*Snapshot, int, error) {
+	names, err := getAutologgerNames()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var reports []*AutologgerReport
+	skipped := 0
+	for _, name := range names {
+		report, err := analyzeAutologger(name, analyzeOptions{noResolve: true})
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			skipped++
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return newSnapshot(collectHostMetadata(), reports), skipped, nil
+}
+
+// newSnapshot builds a snapshot from reports, which it modifies. The
+// snapshot only holds what the registry configures. The live session, the
+// state of the log file, the findings and what was resolved from manifests,
+// binaries and live sessions change while nothing is reconfigured. They are
+// left out, so snapshots of an unchanged host are identical however the
+// reports were analyzed. Autologgers and providers are sorted, so the files
+// can be compared with any text diff as well.
+func newSnapshot(host *HostMetadata, reports []*AutologgerReport) *Snapshot {
+	snapshot := &Snapshot{Version: snapshotVersion, Host: host, Autologgers: []*AutologgerReport{}}
+	for _, report := range reports {
+		config := report.Config
+		config.SessionState = ""
+		config.Session = nil
+		config.FileExists = false
+		config.FileSize = 0
+		report.Host = nil
+		report.Findings = nil
+		for i := range report.Providers {
+			p := &report.Providers[i]
+			p.GUID = canonicalGUID(p.GUID)
+			p.Name = resolveProviderName(p.GUID)
+			stripResolvedFields(p)
+		}
+		sort.Slice(report.Providers, func(i, j int) bool {
+			return report.Providers[i].GUID < report.Providers[j].GUID
+		})
+		snapshot.Autologgers = append(snapshot.Autologgers, report)
+	}
+	sort.Slice(snapshot.Autologgers, func(i, j int) bool {
+		return strings.ToLower(snapshot.Autologgers[i].Config.Name) < strings.ToLower(snapshot.Autologgers[j].Config.Name)
+	})
+	return snapshot
+}
+
+// stripResolvedFields clears what the analysis resolved for a provider
+// beyond its name, and its live session state.
+func stripResolvedFields(p *ETWProvider) {
+	p.Description = ""
+	p.Vendor = ""
+	p.ProviderType = ""
+	p.GroupMembers = nil
+	p.Orphaned = false
+	p.Binary = nil
+	p.Resources = nil
+	p.EventNames = nil
+	p.EventChannels = nil
+	p.MatchAnyKeywordNames = nil
+	p.MatchAllKeywordNames = nil
+	p.ManifestLevels = nil
+	p.ManifestChannels = nil
+	p.Coverage = nil
+	p.Sessions = nil
+	p.LiveMismatches = nil
+}
+
+// loadSnapshot reads a snapshot file.
+func loadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	return parseSnapshot(data, path)
+}
+
+// parseSnapshot decodes a snapshot read from path.
+func parseSnapshot(data []byte, path string) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %v", path, err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has version %d, expected %d", path, snapshot.Version, snapshotVersion)
+	}
+	return &snapshot, nil
+}
+
+// diffSnapshots compares two snapshots, matching autologgers by name.
+func diffSnapshots(before, after *Snapshot) *SnapshotDiff {
+	result := &SnapshotDiff{Old: before.Host, New: after.Host, Added: []string{}, Removed: []string{}, Changed: []SnapshotChange{}}
+	oldByName := make(map[string]*AutologgerReport)
+	for _, report := range before.Autologgers {
+		oldByName[strings.ToLower(report.Config.Name)] = report
+	}
+	seen := make(map[string]bool)
+	for _, report := range after.Autologgers {
+		key := strings.ToLower(report.Config.Name)
+		seen[key] = true
+		previous, ok := oldByName[key]
+		if !ok {
+			result.Added = append(result.Added, report.Config.Name)
+			continue
+		}
+		if rows := diffAutologgers(previous, report, false); len(rows) > 0 {
+			result.Changed = append(result.Changed, SnapshotChange{Autologger: report.Config.Name, Differences: rows})
+		}
+	}
+	for _, report := range before.Autologgers {
+		if !seen[strings.ToLower(report.Config.Name)] {
+			result.Removed = append(result.Removed, report.Config.Name)
+		}
+	}
+	return result
+}
+
+func runSnapshot(cmd *command, args []string) error {
+	var outPath string
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&outPath, "out", "", "Write the snapshot to this file instead of stdout")
+	parseArgs(fs, args)
+
+	snapshot, skipped, err := takeSnapshot()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	var out *atomicFile
+	if outPath != "" {
+		if out, err = createAtomicFile(outPath, false); err != nil {
+			return err
+		}
+		w = out
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snapshot); err != nil {
+		if out != nil {
+			out.Abort()
+		}
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if out != nil {
+		if err := out.Commit(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", outPath, err)
+		}
+	}
+	slog.Info("wrote snapshot", "autologgers", len(snapshot.Autologgers), "skipped", skipped)
+	return reportSummary{skipped: skipped}.exitStatus()
+}
+
+func runDiffSnapshots(cmd *command, args []string) error {
+	var format, goldPath string
+	var output outputOptions
+
+	fs := newFlagSet(cmd)
+	fs.StringVar(&goldPath, "gold", "", "Compare the snapshots of many hosts, files or directories of them, with this snapshot of a gold image")
+	fs.StringVar(&format, "format", "table", "Output format: table, json, unified or json-patch")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	paths := parseArgs(fs, args)
+
+	if !slices.Contains(diffFormats, format) {
+		return fmt.Errorf("unknown output format %q (expected table, json, unified or json-patch)", format)
+	}
+	if goldPath != "" {
+		return runDiffGold(goldPath, paths, format, output)
+	}
+	if len(paths) != 2 {
+		fs.Usage()
+		return fmt.Errorf("an old and a new snapshot are required")
+	}
+
+	before, err := loadSnapshot(paths[0])
+	if err != nil {
+		return err
+	}
+	after, err := loadSnapshot(paths[1])
+	if err != nil {
+		return err
+	}
+	result := diffSnapshots(before, after)
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	case "unified", "json-patch":
+		a, err := canonicalSnapshot(before)
+		if err != nil {
+			return err
+		}
+		b, err := canonicalSnapshot(after)
+		if err != nil {
+			return err
+		}
+		if err := writeDocumentDiff(os.Stdout, format, paths[0], paths[1], a, b); err != nil {
+			return err
+		}
+	default:
+		displaySnapshotDiff(os.Stdout, result, newPalette(os.Stdout, output.color))
+	}
+
+	summary := reportSummary{findings: len(result.Added) + len(result.Removed) + len(result.Changed)}
+	return summary.exitStatus()
+}
+
+// runDiffGold implements diff-snapshots -gold.
+func runDiffGold(goldPath string, paths []string, format string, output outputOptions) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("-gold requires host snapshots to compare")
+	}
+	gold, err := loadSnapshot(goldPath)
+	if err != nil {
+		return err
+	}
+	if paths, err = expandSnapshotPaths(paths); err != nil {
+		return err
+	}
+	var hosts []snapshotFile
+	var summary reportSummary
+	for _, path := range paths {
+		// A directory of snapshots may hold the gold image as well.
+		if same, _ := sameFile(path, goldPath); same {
+			continue
+		}
+		snapshot, err := loadSnapshot(path)
+		if err != nil {
+			slog.Warn("skipping snapshot", "path", path, "error", err)
+			summary.skipped++
+			continue
+		}
+		hosts = append(hosts, snapshotFile{path: path, snapshot: snapshot})
+	}
+
+	report := buildDriftReport(gold, hosts)
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	case "unified", "json-patch":
+		if err := writeDriftPatches(os.Stdout, format, goldPath, gold, hosts); err != nil {
+			return err
+		}
+	default:
+		displayDriftReport(os.Stdout, report, newPalette(os.Stdout, output.color))
+	}
+
+	for _, h := range report.Hosts {
+		if h.drifted() {
+			summary.findings++
+		}
+	}
+	return summary.exitStatus()
+}
+
+// sameFile reports whether two paths name the same file.
+func sameFile(a, b string) (bool, error) {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	ib, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(ia, ib), nil
+}
+
+// getSnapshotLabel names a snapshot by its host and time, e.g.
+// "WS042 2026-10-17 08:00".
+func getSnapshotLabel(host *HostMetadata) string {
+	if host == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s %s", host.Hostname, host.CollectedAt.Format("2006-01-02 15:04"))
+}
+
+// displaySnapshotDiff prints the autologgers that were added and removed,
+// and a diff of those that changed.
+func displaySnapshotDiff(w io.Writer, diff *SnapshotDiff, pal palette) {
+	oldLabel, newLabel := getSnapshotLabel(diff.Old), getSnapshotLabel(diff.New)
+	// Snapshots of two hosts are compared rather than changed.
+	added, removed := "added", "removed"
+	if diff.Old != nil && diff.New != nil && !strings.EqualFold(diff.Old.Hostname, diff.New.Hostname) {
+		fmt.Fprintf(w, "Differences between %s and %s:\n", oldLabel, newLabel)
+		added, removed = "only on "+diff.New.Hostname, "only on "+diff.Old.Hostname
+	} else {
+		fmt.Fprintf(w, "Changes from %s to %s:\n", oldLabel, newLabel)
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+		fmt.Fprintln(w, "No autologger was added, removed or changed")
+		return
+	}
+	for _, name := range diff.Added {
+		fmt.Fprintf(w, "+ %s (%s)\n", name, added)
+	}
+	for _, name := range diff.Removed {
+		fmt.Fprintf(w, "- %s\n", pal.red(name+" ("+removed+")"))
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(w, "~ %s (%d changes)\n", change.Autologger, len(change.Differences))
+	}
+
+	for _, change := range diff.Changed {
+		fmt.Fprintf(w, "\n%s:\n\n", change.Autologger)
+		displayDiff(w, &AutologgerDiff{A: oldLabel, B: newLabel, Differences: change.Differences}, pal)
+	}
+}
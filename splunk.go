@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const splunkEventPath = "/services/collector/event"
+
+type splunkHECEvent struct {
+	Time       float64    `json:"time"`
+	Host       string     `json:"host,omitempty"`
+	Source     string     `json:"source"`
+	Sourcetype string     `json:"sourcetype,omitempty"`
+	Index      string     `json:"index,omitempty"`
+	Event      sinkRecord `json:"event"`
+}
+
+// splunkHECSink posts every record of a report to a Splunk HTTP Event
+// Collector as one batched request.
+type splunkHECSink struct {
+	url        string
+	token      string
+	index      string
+	sourcetype string
+	host       *HostMetadata
+	client     *http.Client
+}
+
+func newSplunkHECSink(url, token, index, sourcetype string, host *HostMetadata) *splunkHECSink {
+	if !strings.Contains(url, "/services/collector") {
+		url = strings.TrimRight(url, "/") + splunkEventPath
+	}
+	return &splunkHECSink{
+		url:        url,
+		token:      token,
+		index:      index,
+		sourcetype: sourcetype,
+		host:       host,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *splunkHECSink) WriteReport(report *AutologgerReport) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	ts := float64(s.host.CollectedAt.UnixMilli()) / 1000
+
+	for _, record := range reportRecords(s.host, report) {
+		event := splunkHECEvent{
+			Time:       ts,
+			Host:       s.host.Hostname,
+			Source:     "autologgerAnalyzer",
+			Sourcetype: s.sourcetype,
+			Index:      s.index,
+			Event:      record,
+		}
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Splunk "+s.token)
+	header.Set("Content-Type", "application/json")
+
+	if err := postWithRetry(s.client, s.url, header, body.Bytes()); err != nil {
+		return fmt.Errorf("splunk HEC: %v", err)
+	}
+	return nil
+}
+
+func (s *splunkHECSink) Close() error { return nil }
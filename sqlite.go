@@ -0,0 +1,246 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id           INTEGER PRIMARY KEY,
+	hostname     TEXT,
+	os_name      TEXT,
+	os_build     TEXT,
+	collected_at TEXT
+);
+CREATE TABLE IF NOT EXISTS autologgers (
+	id              INTEGER PRIMARY KEY,
+	snapshot_id     INTEGER NOT NULL REFERENCES snapshots(id),
+	name            TEXT NOT NULL,
+	guid            TEXT,
+	age             INTEGER,
+	buffer_size     INTEGER,
+	clock_type      INTEGER,
+	flush_timer     INTEGER,
+	log_file_mode   INTEGER,
+	maximum_buffers INTEGER,
+	minimum_buffers INTEGER,
+	start           INTEGER,
+	status          INTEGER
+);
+CREATE TABLE IF NOT EXISTS providers (
+	id            INTEGER PRIMARY KEY,
+	autologger_id INTEGER NOT NULL REFERENCES autologgers(id),
+	guid          TEXT NOT NULL,
+	name          TEXT,
+	enabled       INTEGER,
+	has_filters   INTEGER
+);
+CREATE TABLE IF NOT EXISTS filters (
+	provider_id INTEGER NOT NULL REFERENCES providers(id),
+	event_id    INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS changes (
+	id            INTEGER PRIMARY KEY,
+	snapshot_id   INTEGER NOT NULL REFERENCES snapshots(id),
+	autologger    TEXT NOT NULL,
+	change        TEXT NOT NULL,
+	setting       TEXT,
+	provider      TEXT,
+	provider_name TEXT,
+	old_value     TEXT,
+	new_value     TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_autologgers_name ON autologgers(name);
+CREATE INDEX IF NOT EXISTS idx_providers_guid ON providers(guid);
+CREATE INDEX IF NOT EXISTS idx_changes_autologger ON changes(autologger);
+CREATE INDEX IF NOT EXISTS idx_autologgers_snapshot ON autologgers(snapshot_id);
+CREATE INDEX IF NOT EXISTS idx_providers_autologger ON providers(autologger_id);
+CREATE INDEX IF NOT EXISTS idx_filters_provider ON filters(provider_id);
+CREATE INDEX IF NOT EXISTS idx_changes_snapshot ON changes(snapshot_id);
+`
+
+// sqliteMigrations add columns introduced after the initial schema to
+// databases created by older versions. Errors for columns that already exist
+// are ignored, any other error is returned.
+var sqliteMigrations = []string{
+	`ALTER TABLE snapshots ADD COLUMN domain TEXT`,
+	`ALTER TABLE snapshots ADD COLUMN tool_version TEXT`,
+	`ALTER TABLE snapshots ADD COLUMN elevated INTEGER`,
+	`ALTER TABLE snapshots ADD COLUMN registry_view TEXT`,
+	`ALTER TABLE autologgers ADD COLUMN enable_flags INTEGER`,
+	`ALTER TABLE autologgers ADD COLUMN file_name TEXT`,
+	`ALTER TABLE autologgers ADD COLUMN max_file_size INTEGER`,
+	`ALTER TABLE autologgers ADD COLUMN file_exists INTEGER`,
+	`ALTER TABLE autologgers ADD COLUMN file_size INTEGER`,
+	`ALTER TABLE providers ADD COLUMN enable_level INTEGER`,
+	`ALTER TABLE providers ADD COLUMN match_any_keyword TEXT`,
+	`ALTER TABLE providers ADD COLUMN match_all_keyword TEXT`,
+	`ALTER TABLE providers ADD COLUMN enable_property INTEGER`,
+	`ALTER TABLE providers ADD COLUMN event_id_filter TEXT`,
+	`ALTER TABLE providers ADD COLUMN enabled_state TEXT`,
+	`ALTER TABLE providers ADD COLUMN vendor TEXT`,
+	`ALTER TABLE providers ADD COLUMN provider_type TEXT`,
+	`ALTER TABLE autologgers ADD COLUMN events_lost INTEGER`,
+	`ALTER TABLE autologgers ADD COLUMN buffers_lost INTEGER`,
+	`ALTER TABLE autologgers ADD COLUMN buffers_written INTEGER`,
+	`ALTER TABLE autologgers ADD COLUMN session_state TEXT`,
+}
+
+// sqliteReportWriter stores every run as a snapshot row in a SQLite database.
+// Repeated runs against the same file accumulate snapshots, so results from
+// many hosts or points in time can be queried together.
+type sqliteReportWriter struct {
+	db         *sql.DB
+	tx         *sql.Tx
+	snapshotID int64
+}
+
+func newSQLiteReportWriter(path string, host *HostMetadata) (*sqliteReportWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %v", err)
+	}
+	for _, stmt := range sqliteMigrations {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate schema: %v", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	res, err := tx.Exec(`INSERT INTO snapshots (hostname, domain, os_name, os_build, collected_at, tool_version, elevated, registry_view)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		host.Hostname, host.Domain, host.OSName, host.OSBuild, host.CollectedAt.Format("2006-01-02T15:04:05Z"),
+		host.ToolVersion, host.Elevated, host.RegistryView)
+	if err != nil {
+		tx.Rollback()
+		db.Close()
+		return nil, fmt.Errorf("failed to insert snapshot: %v", err)
+	}
+	snapshotID, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteReportWriter{db: db, tx: tx, snapshotID: snapshotID}, nil
+}
+
+func (s *sqliteReportWriter) WriteReport(report *AutologgerReport) error {
+	c := report.Config
+	// The session counters are NULL for autologgers that aren't running.
+	var eventsLost, buffersLost, buffersWritten sql.NullInt64
+	if s := c.Session; s != nil {
+		eventsLost = sql.NullInt64{Int64: int64(s.EventsLost), Valid: true}
+		buffersLost = sql.NullInt64{Int64: int64(s.LogBuffersLost) + int64(s.RealTimeBuffersLost), Valid: true}
+		buffersWritten = sql.NullInt64{Int64: int64(s.BuffersWritten), Valid: true}
+	}
+	res, err := s.tx.Exec(`INSERT INTO autologgers (snapshot_id, name, guid, age, buffer_size, clock_type,
+		flush_timer, log_file_mode, maximum_buffers, minimum_buffers, start, status, enable_flags,
+		file_name, max_file_size, file_exists, file_size, events_lost, buffers_lost, buffers_written, session_state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.snapshotID, c.Name, c.GUID, int64(c.Age), int64(c.BufferSize), int64(c.ClockType),
+		int64(c.FlushTimer), int64(c.LogFileMode), int64(c.MaximumBuffers), int64(c.MinimumBuffers),
+		int64(c.Start), int64(c.Status), int64(c.EnableFlags),
+		c.FileName, int64(c.MaxFileSize), c.FileExists, c.FileSize, eventsLost, buffersLost, buffersWritten, c.SessionState)
+	if err != nil {
+		return fmt.Errorf("failed to insert autologger %s: %v", c.Name, err)
+	}
+	autologgerID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, provider := range report.Providers {
+		res, err := s.tx.Exec(`INSERT INTO providers (autologger_id, guid, name, enabled, has_filters, enable_level, match_any_keyword, match_all_keyword, enable_property, event_id_filter, enabled_state, vendor, provider_type)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			autologgerID, provider.GUID, provider.Name, provider.Enabled, provider.HasFilters, int64(provider.EnableLevel),
+			formatKeyword(provider.MatchAnyKeyword), formatKeyword(provider.MatchAllKeyword), int64(provider.EnableProperty), provider.EventIDFilter, provider.EnabledState, provider.Vendor, provider.ProviderType)
+		if err != nil {
+			return fmt.Errorf("failed to insert provider %s: %v", provider.GUID, err)
+		}
+		providerID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, eventID := range provider.EventIDs {
+			if _, err := s.tx.Exec(`INSERT INTO filters (provider_id, event_id) VALUES (?, ?)`, providerID, eventID); err != nil {
+				return fmt.Errorf("failed to insert filter for %s: %v", provider.GUID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteChanges stores the changes since the previous snapshot with this
+// snapshot: a row per autologger added or removed, and a row per setting
+// that changed.
+func (s *sqliteReportWriter) WriteChanges(diff *SnapshotDiff) error {
+	insert := func(autologger, change string, row DiffRow) error {
+		_, err := s.tx.Exec(`INSERT INTO changes (snapshot_id, autologger, change, setting, provider, provider_name, old_value, new_value)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.snapshotID, autologger, change, row.Setting, row.Provider, row.ProviderName, row.A, row.B)
+		if err != nil {
+			return fmt.Errorf("failed to insert change of %s: %v", autologger, err)
+		}
+		return nil
+	}
+	for _, name := range diff.Added {
+		if err := insert(name, "added", DiffRow{}); err != nil {
+			return err
+		}
+	}
+	for _, name := range diff.Removed {
+		if err := insert(name, "removed", DiffRow{}); err != nil {
+			return err
+		}
+	}
+	for _, change := range diff.Changed {
+		for _, row := range change.Differences {
+			if err := insert(change.Autologger, "changed", row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prune deletes all but the newest keep snapshots, with their autologgers,
+// providers, filters and changes.
+func (s *sqliteReportWriter) prune(keep int) error {
+	const old = `SELECT id FROM snapshots ORDER BY id DESC LIMIT -1 OFFSET ?`
+	for _, stmt := range []string{
+		`DELETE FROM filters WHERE provider_id IN (SELECT p.id FROM providers p
+			JOIN autologgers a ON a.id = p.autologger_id WHERE a.snapshot_id IN (` + old + `))`,
+		`DELETE FROM providers WHERE autologger_id IN (SELECT id FROM autologgers WHERE snapshot_id IN (` + old + `))`,
+		`DELETE FROM autologgers WHERE snapshot_id IN (` + old + `)`,
+		`DELETE FROM changes WHERE snapshot_id IN (` + old + `)`,
+		`DELETE FROM snapshots WHERE id IN (` + old + `)`,
+	} {
+		if _, err := s.tx.Exec(stmt, keep); err != nil {
+			return fmt.Errorf("failed to prune history: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteReportWriter) Close() error {
+	defer s.db.Close()
+	return s.tx.Commit()
+}
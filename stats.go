@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// AutologgerStats aggregates the configuration of all autologgers.
+type AutologgerStats struct {
+	Sessions            int            `json:"sessions"`
+	StartedSessions     int            `json:"started_sessions"`
+	UniqueProviders     int            `json:"unique_providers"`
+	SharedProviders     int            `json:"shared_providers"`
+	BufferMemoryKB      uint64         `json:"buffer_memory_kb"`
+	LargestSessions     []SessionSize  `json:"largest_sessions"`
+	MostSharedProviders []SharedCounts `json:"most_shared_providers,omitempty"`
+	// SystemLoggerSlots are the running sessions that take a system logger
+	// slot, and StartedSystemLoggers the autologgers started at boot that
+	// need one.
+	SystemLoggerSlots []SystemLoggerSlot `json:"system_logger_slots"`
+	// SessionCount is nil if the running sessions could not be queried.
+	SessionCount         *SessionCount `json:"session_count,omitempty"`
+	StartedSystemLoggers []string      `json:"started_system_loggers"`
+}
+
+// SessionSize is the estimated buffer memory of one autologger.
+type SessionSize struct {
+	Name           string `json:"name"`
+	Started        bool   `json:"started"`
+	Providers      int    `json:"providers"`
+	BufferMemoryKB uint64 `json:"buffer_memory_kb"`
+}
+
+// SharedCounts is a provider enabled in more than one autologger.
+type SharedCounts struct {
+	GUID     string `json:"guid"`
+	Sessions int    `json:"sessions"`
+}
+
+// bufferMemoryKB estimates the memory a session can use for buffers: the
+// buffer size times the maximum number of buffers, or the minimum when no
+// maximum is configured.
+func bufferMemoryKB(config *AutologgerConfig) uint64 {
+	return config.BufferSize * max(config.MaximumBuffers, config.MinimumBuffers)
+}
+
+// getMemoryDescription renders the buffer memory a session allocates from the
+// non-paged pool when it starts, and the most it can grow to.
+func getMemoryDescription(config *AutologgerConfig) string {
+	return fmt.Sprintf("%s minimum, %s maximum",
+		formatKB(config.BufferSize*config.MinimumBuffers), formatKB(bufferMemoryKB(config)))
+}
+
+// memoryTotals sums the buffer memory of several autologgers.
+type memoryTotals struct {
+	sessions     int
+	minKB        uint64
+	maxKB        uint64
+	startedMaxKB uint64 // maximum of the autologgers started at boot
+}
+
+func (m *memoryTotals) add(config *AutologgerConfig) {
+	m.sessions++
+	m.minKB += config.BufferSize * config.MinimumBuffers
+	m.maxKB += bufferMemoryKB(config)
+	if config.Start != 0 {
+		m.startedMaxKB += bufferMemoryKB(config)
+	}
+}
+
+func (m memoryTotals) String() string {
+	return fmt.Sprintf("%s minimum, %s maximum across %d autologgers (%s maximum for those started at boot)",
+		formatKB(m.minKB), formatKB(m.maxKB), m.sessions, formatKB(m.startedMaxKB))
+}
+
+// totalMemory sums the buffer memory of the autologgers in reports.
+func totalMemory(reports []*AutologgerReport) memoryTotals {
+	var totals memoryTotals
+	for _, report := range reports {
+		totals.add(report.Config)
+	}
+	return totals
+}
+
+// collectStats reads the configuration and provider GUIDs of every
+// autologger. Provider names are not resolved, which keeps it fast.
+func collectStats() (*AutologgerStats, error) {
+	names, err := getAutologgerNames()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &AutologgerStats{}
+	sessionsPerProvider := make(map[string]int)
+	for _, name := range names {
+		config, err := getAutologgerConfig(name)
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			continue
+		}
+		guids, err := getProviderGUIDs(name)
+		if err != nil {
+			slog.Warn("cannot list providers", "autologger", name, "error", err)
+		}
+
+		stats.Sessions++
+		if config.Start != 0 {
+			stats.StartedSessions++
+		}
+		memory := bufferMemoryKB(config)
+		stats.BufferMemoryKB += memory
+		stats.LargestSessions = append(stats.LargestSessions, SessionSize{
+			Name:           name,
+			Started:        config.Start != 0,
+			Providers:      len(guids),
+			BufferMemoryKB: memory,
+		})
+		for _, guid := range guids {
+			sessionsPerProvider[guid]++
+		}
+	}
+
+	stats.UniqueProviders = len(sessionsPerProvider)
+	for guid, n := range sessionsPerProvider {
+		if n > 1 {
+			stats.SharedProviders++
+			stats.MostSharedProviders = append(stats.MostSharedProviders, SharedCounts{GUID: guid, Sessions: n})
+		}
+	}
+
+	sort.Slice(stats.LargestSessions, func(i, j int) bool {
+		a, b := stats.LargestSessions[i], stats.LargestSessions[j]
+		if a.BufferMemoryKB != b.BufferMemoryKB {
+			return a.BufferMemoryKB > b.BufferMemoryKB
+		}
+		return a.Name < b.Name
+	})
+	if len(stats.LargestSessions) > 10 {
+		stats.LargestSessions = stats.LargestSessions[:10]
+	}
+
+	sort.Slice(stats.MostSharedProviders, func(i, j int) bool {
+		a, b := stats.MostSharedProviders[i], stats.MostSharedProviders[j]
+		if a.Sessions != b.Sessions {
+			return a.Sessions > b.Sessions
+		}
+		return a.GUID < b.GUID
+	})
+	if len(stats.MostSharedProviders) > 10 {
+		stats.MostSharedProviders = stats.MostSharedProviders[:10]
+	}
+
+	stats.SystemLoggerSlots, _ = systemLoggerSlotsInUse()
+	stats.StartedSystemLoggers = startedSystemLoggers()
+	stats.SessionCount = countSessions()
+
+	return stats, nil
+}
+
+// getProviderGUIDs lists the normalized provider GUIDs of an autologger.
+// Subkeys that aren't GUIDs are skipped.
+func getProviderGUIDs(autologgerName string) ([]string, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, baseAutologgerPath+`\`+autologgerName, registry.READ)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry key: %v", err)
+	}
+	defer key.Close()
+
+	names, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subkey names: %v", err)
+	}
+	subkeys, _ := groupProviderSubkeys(names)
+	guids := make([]string, len(subkeys))
+	for i, subkey := range subkeys {
+		guids[i] = subkey.guid
+	}
+	return guids, nil
+}
+
+func runStats(cmd *command, args []string) error {
+	var format string
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	if rest := parseArgs(fs, args); len(rest) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+
+	stats, err := collectStats()
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	displayStats(os.Stdout, stats)
+	return nil
+}
+
+func displayStats(w io.Writer, stats *AutologgerStats) {
+	fmt.Fprintln(w, "Autologger Statistics")
+	fmt.Fprintln(w, strings.Repeat("=", 60))
+	fmt.Fprintf(w, "- Sessions: %d (%d started at boot)\n", stats.Sessions, stats.StartedSessions)
+	fmt.Fprintf(w, "- Unique providers: %d\n", stats.UniqueProviders)
+	fmt.Fprintf(w, "- Providers in multiple sessions: %d\n", stats.SharedProviders)
+	fmt.Fprintf(w, "- Estimated buffer memory: %s\n", formatKB(stats.BufferMemoryKB))
+	if stats.SessionCount != nil {
+		fmt.Fprintf(w, "- Trace sessions: %s\n", getSessionCountDescription(stats.SessionCount))
+		if warning := sessionLimitWarning(); warning != "" {
+			fmt.Fprintf(w, "  Warning: %s\n", warning)
+		}
+	}
+
+	fmt.Fprintf(w, "\nLargest Sessions (by buffer memory):\n\n")
+	fmt.Fprintf(w, "| %-35s | %-7s | %-9s | %-12s |\n", "Name", "Started", "Providers", "Buffers")
+	fmt.Fprintf(w, "|%s|%s|%s|%s|\n",
+		strings.Repeat("-", 37),
+		strings.Repeat("-", 9),
+		strings.Repeat("-", 11),
+		strings.Repeat("-", 14))
+	for _, s := range stats.LargestSessions {
+		started := "No"
+		if s.Started {
+			started = "Yes"
+		}
+		fmt.Fprintf(w, "| %-35s | %-7s | %-9d | %12s |\n",
+			truncateString(s.Name, 35), started, s.Providers, formatKB(s.BufferMemoryKB))
+	}
+
+	fmt.Fprintf(w, "\nSystem Logger Slots: %d of %d in use, %d needed by autologgers started at boot\n\n",
+		len(stats.SystemLoggerSlots), maxSystemLoggers, len(stats.StartedSystemLoggers))
+	for _, slot := range stats.SystemLoggerSlots {
+		from := "started at runtime"
+		if slot.Autologger != "" {
+			from = "autologger"
+		}
+		fmt.Fprintf(w, "- %s (logger %d, %s, %s)\n", slot.Session, slot.LoggerID, slot.Kind, from)
+	}
+	if len(stats.StartedSystemLoggers) > maxSystemLoggers {
+		fmt.Fprintf(w, "- Warning: more autologgers started at boot need a slot than there are, some of them will fail to start\n")
+	}
+
+	if len(stats.MostSharedProviders) > 0 {
+		fmt.Fprintf(w, "\nMost Shared Providers:\n\n")
+		for _, p := range stats.MostSharedProviders {
+			fmt.Fprintf(w, "- %s %s: %d sessions\n", p.GUID, resolveProviderName(p.GUID), p.Sessions)
+		}
+	}
+}
+
+// formatKB renders a size given in kilobytes with a readable unit.
+func formatKB(kb uint64) string {
+	switch {
+	case kb >= 1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(kb)/(1024*1024))
+	case kb >= 1024:
+		return fmt.Sprintf("%.1f MB", float64(kb)/1024)
+	default:
+		return fmt.Sprintf("%d KB", kb)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// statusNames are the symbolic names of start failures commonly found in the
+// Status value of an autologger.
+var statusNames = map[uint32]string{
+	2:          "ERROR_FILE_NOT_FOUND",
+	3:          "ERROR_PATH_NOT_FOUND",
+	5:          "ERROR_ACCESS_DENIED",
+	8:          "ERROR_NOT_ENOUGH_MEMORY",
+	87:         "ERROR_INVALID_PARAMETER",
+	112:        "ERROR_DISK_FULL",
+	161:        "ERROR_BAD_PATHNAME",
+	183:        "ERROR_ALREADY_EXISTS",
+	1450:       "ERROR_NO_SYSTEM_RESOURCES",
+	0xC0000001: "STATUS_UNSUCCESSFUL",
+	0xC000000D: "STATUS_INVALID_PARAMETER",
+	0xC0000022: "STATUS_ACCESS_DENIED",
+	0xC0000034: "STATUS_OBJECT_NAME_NOT_FOUND",
+	0xC0000035: "STATUS_OBJECT_NAME_COLLISION",
+	0xC000003A: "STATUS_OBJECT_PATH_NOT_FOUND",
+	0xC000007F: "STATUS_DISK_FULL",
+	0xC000009A: "STATUS_INSUFFICIENT_RESOURCES",
+	0xC00000BB: "STATUS_NOT_SUPPORTED",
+}
+
+var ntdll = windows.NewLazySystemDLL("ntdll.dll")
+
+// getStatusDescription decodes the Status value of an autologger, which holds
+// the result of the last start attempt as a Win32 error or NTSTATUS code.
+func getStatusDescription(status uint64) string {
+	if status == 0 {
+		return "0"
+	}
+
+	code := uint32(status)
+	var parts []string
+	if name, ok := statusNames[code]; ok {
+		parts = append(parts, name)
+	}
+	if msg := formatStatusMessage(code); msg != "" {
+		parts = append(parts, msg)
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Unknown (0x%08X)", code)
+	}
+	return fmt.Sprintf("%s (0x%08X)", strings.Join(parts, ": "), code)
+}
+
+// formatStatusMessage looks up the system message for a Win32 error, or for
+// an NTSTATUS in ntdll's message table when the severity bits are set.
+func formatStatusMessage(code uint32) string {
+	flags := uint32(windows.FORMAT_MESSAGE_IGNORE_INSERTS)
+	var source uintptr
+	if code&0xC0000000 != 0 {
+		if err := ntdll.Load(); err != nil {
+			return ""
+		}
+		flags |= windows.FORMAT_MESSAGE_FROM_HMODULE
+		source = ntdll.Handle()
+	} else {
+		flags |= windows.FORMAT_MESSAGE_FROM_SYSTEM
+	}
+
+	buf := make([]uint16, 512)
+	n, err := windows.FormatMessage(flags, source, code, 0, buf, nil)
+	if err != nil || n == 0 {
+		return ""
+	}
+	msg := windows.UTF16ToString(buf[:n])
+	// NTSTATUS messages often start with "{Title}" followed by the text.
+	if i := strings.Index(msg, "}"); strings.HasPrefix(msg, "{") && i > 0 {
+		msg = msg[i+1:]
+	}
+	return strings.Join(strings.Fields(msg), " ")
+}
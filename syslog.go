@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// syslogFacility is local0; findings use it for every message.
+const syslogFacility = 16
+
+// syslogSeverity maps finding severities onto RFC 5424 severity levels.
+var syslogSeverity = map[string]int{
+	SeverityLow:    5, // notice
+	SeverityMedium: 4, // warning
+	SeverityHigh:   3, // error
+}
+
+// syslogSink forwards findings as RFC 5424 messages over UDP, TCP or TLS.
+// Stream transports use octet-counting framing (RFC 6587).
+type syslogSink struct {
+	conn   net.Conn
+	framed bool
+	host   *HostMetadata
+}
+
+// newSyslogSink dials target, which is given as udp://host:port,
+// tcp://host:port or tls://host:port.
+func newSyslogSink(target, caCertPath string, host *HostMetadata) (*syslogSink, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog target %q (expected udp://, tcp:// or tls://host:port)", target)
+	}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "udp", "tcp":
+		conn, err = net.DialTimeout(u.Scheme, u.Host, 10*time.Second)
+	case "tls":
+		tlsConfig := &tls.Config{ServerName: u.Hostname()}
+		if caCertPath != "" {
+			pem, err := os.ReadFile(caCertPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", caCertPath)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", u.Host, tlsConfig)
+	default:
+		return nil, fmt.Errorf("unsupported syslog transport %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog server: %v", err)
+	}
+
+	return &syslogSink{conn: conn, framed: u.Scheme != "udp", host: host}, nil
+}
+
+func (s *syslogSink) WriteReport(report *AutologgerReport) error {
+	for _, f := range report.Findings {
+		params := [][2]string{
+			{"autologger", f.Autologger},
+			{"severity", f.Severity},
+		}
+		if f.ProviderGUID != "" {
+			params = append(params, [2]string{"provider_guid", f.ProviderGUID}, [2]string{"provider_name", f.ProviderName})
+		}
+		if err := s.send(syslogSeverity[f.Severity], f.ID, params, f.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send writes a single RFC 5424 message with one structured data element.
+func (s *syslogSink) send(severity int, msgID string, params [][2]string, msg string) error {
+	var sd strings.Builder
+	sd.WriteString("[autologger@32473")
+	for _, p := range params {
+		fmt.Fprintf(&sd, ` %s="%s"`, p[0], syslogSDEscape(p[1]))
+	}
+	sd.WriteString("]")
+
+	hostname := s.host.Hostname
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		syslogFacility*8+severity,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		hostname,
+		siemProduct,
+		os.Getpid(),
+		msgID,
+		sd.String(),
+		msg)
+	if s.framed {
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		return fmt.Errorf("syslog: %v", err)
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.conn.Close()
+}
+
+func syslogSDEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modtdh                                 = windows.NewLazySystemDLL("tdh.dll")
+	procTdhEnumerateManifestProviderEvents = modtdh.NewProc("TdhEnumerateManifestProviderEvents")
+	procTdhGetManifestEventInformation     = modtdh.NewProc("TdhGetManifestEventInformation")
+	procTdhEnumerateProviderFieldInfo      = modtdh.NewProc("TdhEnumerateProviderFieldInformation")
+	procTdhEnumerateProviders              = modtdh.NewProc("TdhEnumerateProviders")
+)
+
+// EVENT_FIELD_TYPE values of TdhEnumerateProviderFieldInformation.
+const (
+	eventKeywordInformation = 0
+	eventLevelInformation   = 1
+	eventChannelInformation = 2
+	eventTaskInformation    = 3
+)
+
+// eventDescriptor mirrors EVENT_DESCRIPTOR.
+type eventDescriptor struct {
+	Id      uint16
+	Version uint8
+	Channel uint8
+	Level   uint8
+	Opcode  uint8
+	Task    uint16
+	Keyword uint64
+}
+
+// Offsets of the name offsets in TRACE_EVENT_INFO. The structure is followed
+// by variable-length data, so it is read from the buffer instead of being
+// mirrored.
+const (
+	traceEventInfoLevelNameOffset    = 56
+	traceEventInfoChannelNameOffset  = 60
+	traceEventInfoKeywordsNameOffset = 64
+	traceEventInfoTaskNameOffset     = 68
+	traceEventInfoOpcodeNameOffset   = 72
+	traceEventInfoEventMessageOffset = 76
+	traceEventInfoEventNameOffset    = 92
+	traceEventInfoPropertyCount      = 100
+	traceEventInfoTopLevelCount      = 104
+	traceEventInfoMinSize            = 112
+)
+
+// EventName is the manifest name of a filtered event ID, with the task and
+// opcode it belongs to.
+type EventName struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Task        string `json:"task,omitempty"`
+	Opcode      string `json:"opcode,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// tdhCall calls a TDH function that fills a caller-allocated buffer, growing
+// the buffer until it fits.
+func tdhCall(call func(buf *byte, size *uint32) uintptr) ([]byte, error) {
+	var size uint32
+	var buf []byte
+	for {
+		var ptr *byte
+		if len(buf) > 0 {
+			ptr = &buf[0]
+		}
+		r := call(ptr, &size)
+		switch windows.Errno(r) {
+		case 0:
+			return buf[:size], nil
+		case windows.ERROR_INSUFFICIENT_BUFFER:
+			buf = make([]byte, size)
+		default:
+			return nil, windows.Errno(r)
+		}
+	}
+}
+
+// tdhString reads the NUL-terminated UTF-16 string at offset in a TDH buffer.
+// An offset of 0 means the string is absent.
+func tdhString(buf []byte, offset uint32) string {
+	if offset == 0 || int(offset) >= len(buf) {
+		return ""
+	}
+	var chars []uint16
+	for i := int(offset); i+1 < len(buf); i += 2 {
+		c := binary.LittleEndian.Uint16(buf[i:])
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return strings.TrimSpace(windows.UTF16ToString(chars))
+}
+
+// tdhStrings reads the list of NUL-terminated UTF-16 strings at offset in a
+// TDH buffer, which ends with an empty string.
+func tdhStrings(buf []byte, offset uint32) []string {
+	var list []string
+	for offset != 0 && int(offset) < len(buf) {
+		s := tdhString(buf, offset)
+		if s == "" {
+			break
+		}
+		list = append(list, s)
+		// Skip the raw string, which may have had spaces trimmed.
+		for int(offset)+1 < len(buf) && binary.LittleEndian.Uint16(buf[offset:]) != 0 {
+			offset += 2
+		}
+		offset += 2
+	}
+	return list
+}
+
+// enumerateManifestEvents returns the descriptors of every event in the
+// manifest of a provider, one per version of each event.
+func enumerateManifestEvents(guid *windows.GUID) ([]eventDescriptor, error) {
+	if err := procTdhEnumerateManifestProviderEvents.Find(); err != nil {
+		return nil, err
+	}
+	buf, err := tdhCall(func(buf *byte, size *uint32) uintptr {
+		r, _, _ := procTdhEnumerateManifestProviderEvents.Call(
+			uintptr(unsafe.Pointer(guid)),
+			uintptr(unsafe.Pointer(buf)),
+			uintptr(unsafe.Pointer(size)))
+		return r
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// PROVIDER_EVENT_INFO: NumberOfEvents, Reserved, EVENT_DESCRIPTOR[].
+	if len(buf) < 8 {
+		return nil, nil
+	}
+	count := int(binary.LittleEndian.Uint32(buf))
+	size := int(unsafe.Sizeof(eventDescriptor{}))
+	descriptors := make([]eventDescriptor, 0, count)
+	for i := 0; i < count && 8+(i+1)*size <= len(buf); i++ {
+		descriptors = append(descriptors, *(*eventDescriptor)(unsafe.Pointer(&buf[8+i*size])))
+	}
+	return descriptors, nil
+}
+
+// getManifestEventInfo returns the TRACE_EVENT_INFO of an event in the
+// manifest of a provider.
+func getManifestEventInfo(guid *windows.GUID, descriptor *eventDescriptor) ([]byte, error) {
+	buf, err := tdhCall(func(buf *byte, size *uint32) uintptr {
+		r, _, _ := procTdhGetManifestEventInformation.Call(
+			uintptr(unsafe.Pointer(guid)),
+			uintptr(unsafe.Pointer(descriptor)),
+			uintptr(unsafe.Pointer(buf)),
+			uintptr(unsafe.Pointer(size)))
+		return r
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < traceEventInfoMinSize {
+		return nil, fmt.Errorf("TRACE_EVENT_INFO of %d bytes is too short", len(buf))
+	}
+	return buf, nil
+}
+
+// getManifestEventName returns the name, task, opcode and message of an
+// event. Manifests name events with the name attribute since Windows 10
+// 1709, older ones only have the task name.
+func getManifestEventName(guid *windows.GUID, descriptor *eventDescriptor) (EventName, error) {
+	buf, err := getManifestEventInfo(guid, descriptor)
+	if err != nil {
+		return EventName{}, err
+	}
+
+	event := EventName{ID: int(descriptor.Id)}
+	event.Task = tdhString(buf, binary.LittleEndian.Uint32(buf[traceEventInfoTaskNameOffset:]))
+	event.Opcode = tdhString(buf, binary.LittleEndian.Uint32(buf[traceEventInfoOpcodeNameOffset:]))
+	event.Name = tdhString(buf, binary.LittleEndian.Uint32(buf[traceEventInfoEventNameOffset:]))
+	if event.Name == "" {
+		event.Name = event.Task
+	}
+	message := tdhString(buf, binary.LittleEndian.Uint32(buf[traceEventInfoEventMessageOffset:]))
+	event.Description, _, _ = strings.Cut(message, "\r")
+	return event, nil
+}
+
+// providerManifest holds the events of a provider's manifest.
+type providerManifest struct {
+	events []eventDescriptor // latest version of each event
+	names  map[int]EventName // named events, keyed by event ID
+}
+
+// manifestEvents caches the manifest events of every provider looked up, as
+// the same providers are enabled in several autologgers.
+var manifestEvents onceCache[*providerManifest]
+
+// getProviderManifest returns the events of a provider's manifest. Providers
+// without a manifest, such as MOF and WPP providers, have none.
+func getProviderManifest(providerGUID string) *providerManifest {
+	providerGUID = canonicalGUID(providerGUID)
+	return manifestEvents.get(providerGUID, func() *providerManifest {
+		return lookupProviderManifest(providerGUID)
+	})
+}
+
+func lookupProviderManifest(providerGUID string) *providerManifest {
+	manifest := &providerManifest{names: make(map[int]EventName)}
+	guid, err := windows.GUIDFromString(canonicalGUID(providerGUID))
+	if err != nil {
+		return manifest
+	}
+	descriptors, err := enumerateManifestEvents(&guid)
+	if err != nil {
+		slog.Debug("cannot enumerate manifest events", "provider", providerGUID, "error", err)
+		return manifest
+	}
+
+	// Keep the latest version of each event, its name is the current one.
+	latest := make(map[uint16]eventDescriptor)
+	for _, d := range descriptors {
+		if prev, ok := latest[d.Id]; !ok || d.Version > prev.Version {
+			latest[d.Id] = d
+		}
+	}
+
+	for _, d := range latest {
+		manifest.events = append(manifest.events, d)
+		event, err := getManifestEventName(&guid, &d)
+		if err != nil {
+			slog.Debug("cannot read manifest event", "provider", providerGUID, "event", d.Id, "error", err)
+			continue
+		}
+		if event.Name != "" || event.Opcode != "" || event.Description != "" {
+			manifest.names[event.ID] = event
+		}
+	}
+	slices.SortFunc(manifest.events, func(a, b eventDescriptor) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return manifest
+}
+
+// resolveEventNames returns the manifest names of the filtered event IDs of
+// a provider, in the order of the IDs. IDs the manifest doesn't name are left
+// out.
+func resolveEventNames(providerGUID string, ids []int) []EventName {
+	if len(ids) == 0 {
+		return nil
+	}
+	names := getProviderManifest(providerGUID).names
+	var events []EventName
+	for _, id := range removeDuplicates(ids) {
+		if event, ok := names[id]; ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// getEventNameDescription renders a resolved event ID, e.g.
+// "1 = ProcessStart [Task: Process, Opcode: Start] (Process %1 started ...)".
+func getEventNameDescription(event EventName) string {
+	desc := fmt.Sprintf("%d = %s", event.ID, event.Name)
+	if event.Name == "" {
+		desc = fmt.Sprintf("%d", event.ID)
+	}
+	if taskOpcode := getTaskOpcodeDescription(event); taskOpcode != "" {
+		desc += fmt.Sprintf(" [%s]", taskOpcode)
+	}
+	if event.Description != "" {
+		desc += fmt.Sprintf(" (%s)", truncateString(event.Description, 80))
+	}
+	return desc
+}
+
+// getTaskOpcodeDescription renders the task and opcode of an event, e.g.
+// "Task: Connect, Opcode: Start", or "" if the manifest names neither.
+func getTaskOpcodeDescription(event EventName) string {
+	var parts []string
+	if event.Task != "" {
+		parts = append(parts, "Task: "+event.Task)
+	}
+	if event.Opcode != "" {
+		parts = append(parts, "Opcode: "+event.Opcode)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeEventNames lists the resolved event IDs of a provider below its event
+// ID filter.
+func writeEventNames(w io.Writer, events []EventName) {
+	for _, event := range events {
+		fmt.Fprintf(w, "- %s\n", getEventNameDescription(event))
+	}
+}
+
+// providersWithEventNames returns the providers with resolved event IDs.
+func providersWithEventNames(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if len(p.EventNames) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// providerField is a keyword, level, channel, task or opcode defined by a
+// provider's manifest.
+type providerField struct {
+	name        string
+	description string
+	value       uint64
+}
+
+// manifestFields caches the fields of every provider looked up, keyed by
+// GUID and field type.
+var manifestFields onceCache[[]providerField]
+
+// getProviderFields returns the fields of one type a provider's manifest
+// defines, or nil if the provider has no manifest.
+func getProviderFields(providerGUID string, fieldType uint32) []providerField {
+	providerGUID = canonicalGUID(providerGUID)
+	cacheKey := fmt.Sprintf("%s/%d", providerGUID, fieldType)
+	return manifestFields.get(cacheKey, func() []providerField {
+		fields, err := enumerateProviderFields(providerGUID, fieldType)
+		if err != nil {
+			slog.Debug("cannot enumerate provider fields", "provider", providerGUID, "type", fieldType, "error", err)
+		}
+		return fields
+	})
+}
+
+func enumerateProviderFields(providerGUID string, fieldType uint32) ([]providerField, error) {
+	if err := procTdhEnumerateProviderFieldInfo.Find(); err != nil {
+		return nil, err
+	}
+	guid, err := windows.GUIDFromString(canonicalGUID(providerGUID))
+	if err != nil {
+		return nil, err
+	}
+	buf, err := tdhCall(func(buf *byte, size *uint32) uintptr {
+		r, _, _ := procTdhEnumerateProviderFieldInfo.Call(
+			uintptr(unsafe.Pointer(&guid)),
+			uintptr(fieldType),
+			uintptr(unsafe.Pointer(buf)),
+			uintptr(unsafe.Pointer(size)))
+		return r
+	})
+	if err != nil {
+		if err == windows.ERROR_NOT_FOUND {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// PROVIDER_FIELD_INFOARRAY: NumberOfElements, FieldType, then
+	// PROVIDER_FIELD_INFO entries of NameOffset, DescriptionOffset and Value.
+	if len(buf) < 8 {
+		return nil, nil
+	}
+	count := int(binary.LittleEndian.Uint32(buf))
+	var fields []providerField
+	for i := 0; i < count && 8+(i+1)*16 <= len(buf); i++ {
+		entry := buf[8+i*16:]
+		fields = append(fields, providerField{
+			name:        tdhString(buf, binary.LittleEndian.Uint32(entry)),
+			description: tdhString(buf, binary.LittleEndian.Uint32(entry[4:])),
+			value:       binary.LittleEndian.Uint64(entry[8:]),
+		})
+	}
+	return fields, nil
+}
+
+// resolveKeywordNames lists the manifest keywords a keyword mask selects.
+// Bits no keyword is defined for are appended in hex. Masks of 0 and of all
+// bits select every keyword and aren't resolved.
+func resolveKeywordNames(providerGUID string, mask uint64) []string {
+	if mask == 0 || mask == ^uint64(0) {
+		return nil
+	}
+	keywords := getProviderFields(providerGUID, eventKeywordInformation)
+	if len(keywords) == 0 {
+		return nil
+	}
+
+	var names []string
+	var named uint64
+	for _, k := range keywords {
+		if k.value != 0 && mask&k.value == k.value {
+			names = append(names, k.name)
+			named |= k.value
+		}
+	}
+	if rest := mask &^ named; rest != 0 {
+		names = append(names, formatKeyword(rest))
+	}
+	return names
+}
+
+// providersWithKeywordNames returns the providers with resolved keyword
+// masks.
+func providersWithKeywordNames(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if len(p.MatchAnyKeywordNames) > 0 || len(p.MatchAllKeywordNames) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// getKeywordNamesDescription renders a keyword mask with the keywords it
+// selects, e.g. "0x30: WINEVENT_KEYWORD_PROCESS, WINEVENT_KEYWORD_THREAD".
+func getKeywordNamesDescription(mask uint64, names []string) string {
+	return formatKeyword(mask) + ": " + strings.Join(names, ", ")
+}
+
+// ManifestField is a keyword, level, channel or task a provider's manifest
+// defines.
+type ManifestField struct {
+	Value uint64 `json:"value"`
+	Name  string `json:"name"`
+}
+
+// resolveManifestFields returns the fields of one type a provider's
+// manifest, ordered by value.
+func resolveManifestFields(providerGUID string, fieldType uint32) []ManifestField {
+	var fields []ManifestField
+	for _, f := range getProviderFields(providerGUID, fieldType) {
+		fields = append(fields, ManifestField{Value: f.value, Name: f.name})
+	}
+	slices.SortFunc(fields, func(a, b ManifestField) int {
+		return cmp.Compare(a.Value, b.Value)
+	})
+	return fields
+}
+
+// getManifestFieldsDescription renders manifest levels or channels, e.g.
+// "Error (2), Warning (3), Information (4)".
+func getManifestFieldsDescription(fields []ManifestField) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = fmt.Sprintf("%s (%d)", f.Name, f.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// getProviderLevelName renders the EnableLevel of a provider with the name
+// its manifest gives the level, falling back to the standard ETW level
+// name.
+func getProviderLevelName(p ETWProvider) string {
+	for _, level := range p.ManifestLevels {
+		if level.Value == p.EnableLevel && level.Name != "" {
+			return fmt.Sprintf("%s (%d)", level.Name, level.Value)
+		}
+	}
+	return getLevelName(p.EnableLevel)
+}
+
+// providersWithManifestFields returns the providers with manifest levels or
+// channels.
+func providersWithManifestFields(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if len(p.ManifestLevels) > 0 || len(p.ManifestChannels) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Schema sources of TRACE_PROVIDER_INFO.
+const (
+	schemaSourceManifest = 0
+	schemaSourceMOF      = 1
+)
+
+// tdhProvider is a provider TDH has a schema for.
+type tdhProvider struct {
+	name         string
+	schemaSource uint32
+}
+
+// liveProviders caches the providers TDH knows about, which are enumerated
+// once per run.
+var liveProviders struct {
+	once      sync.Once
+	providers map[string]tdhProvider
+}
+
+// getLiveProviders returns the manifest and MOF providers TDH knows about,
+// keyed by normalized GUID. This includes providers registered at runtime
+// that never appear under WINEVT\Publishers.
+func getLiveProviders() map[string]tdhProvider {
+	liveProviders.once.Do(func() {
+		providers, err := enumerateTDHProviders()
+		if err != nil {
+			slog.Warn("cannot enumerate providers through TDH", "error", err)
+		}
+		liveProviders.providers = providers
+	})
+	return liveProviders.providers
+}
+
+func enumerateTDHProviders() (map[string]tdhProvider, error) {
+	if err := procTdhEnumerateProviders.Find(); err != nil {
+		return nil, err
+	}
+	buf, err := tdhCall(func(buf *byte, size *uint32) uintptr {
+		r, _, _ := procTdhEnumerateProviders.Call(
+			uintptr(unsafe.Pointer(buf)),
+			uintptr(unsafe.Pointer(size)))
+		return r
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// PROVIDER_ENUMERATION_INFO: NumberOfProviders, Reserved, then
+	// TRACE_PROVIDER_INFO entries of ProviderGuid, SchemaSource and
+	// ProviderNameOffset.
+	if len(buf) < 8 {
+		return nil, nil
+	}
+	count := int(binary.LittleEndian.Uint32(buf))
+	providers := make(map[string]tdhProvider, count)
+	for i := 0; i < count && 8+(i+1)*24 <= len(buf); i++ {
+		entry := buf[8+i*24:]
+		guid := (*windows.GUID)(unsafe.Pointer(&entry[0]))
+		providers[canonicalGUID(guid.String())] = tdhProvider{
+			name:         tdhString(buf, binary.LittleEndian.Uint32(entry[20:])),
+			schemaSource: binary.LittleEndian.Uint32(entry[16:]),
+		}
+	}
+	return providers, nil
+}
+
+// resolveFromLiveProviders is the last resort of name resolution, for
+// providers that are neither registered publishers nor in the WMI key. TDH
+// names the providers it has a schema for, other providers that are
+// registered right now are at least reported as such.
+func resolveFromLiveProviders(guid string) string {
+	guid = canonicalGUID(guid)
+	if p, ok := getLiveProviders()[guid]; ok && p.name != "" {
+		return p.name
+	}
+	if slices.Contains(getRegisteredProviderGUIDs(), guid) {
+		slog.Info("provider is registered, but has no name", "provider", guid)
+		return "(Unnamed Registered Provider)"
+	}
+	slog.Info("cannot resolve provider name", "provider", guid)
+	return "(Unknown Provider)"
+}
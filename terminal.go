@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultTableWidth is used for table output that doesn't go to a console,
+// e.g. when redirected to a file.
+const defaultTableWidth = 120
+
+// terminalWidth returns the width of the console w writes to, or 0 if w is
+// not a console. COLUMNS takes precedence, for terminals that don't expose a
+// console buffer such as mintty.
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info); err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
+
+// isConsole reports whether w is a console rather than a file or pipe.
+func isConsole(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(f.Fd()), &mode) == nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Vendors a provider is classified as.
+const (
+	vendorMicrosoft  = "microsoft"
+	vendorThirdParty = "third_party"
+	vendorUnknown    = "unknown"
+)
+
+// ProviderBinary is the binary that owns a provider, with its Authenticode
+// state.
+type ProviderBinary struct {
+	Path string `json:"path"`
+	// Source is the registry value the path was read from.
+	Source string `json:"source"`
+	// Signature is "signed", "unsigned", "invalid" or "missing".
+	Signature string `json:"signature"`
+	Signer    string `json:"signer,omitempty"`
+	// Catalog tells whether the binary is signed through a catalog file
+	// instead of an embedded signature.
+	Catalog bool   `json:"catalog,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// binaryValues are the values naming a provider's binary, most specific
+// first.
+var binaryValues = []string{"ResourceFileName", "MessageFileName", "ParameterFileName"}
+
+// getProviderBinary returns the binary a provider's publisher entry, or its
+// WMI key, points to for its resources or messages, and the value it was
+// read from. It returns "" if neither names a binary.
+func getProviderBinary(guid string) (path, source string) {
+	for _, parent := range []string{publishersPath, wmiPath} {
+		key, err := openKey(registry.LOCAL_MACHINE, parent+`\`+canonicalGUID(guid), registry.READ)
+		if err != nil {
+			continue
+		}
+		for _, name := range binaryValues {
+			value, _, err := key.GetStringValue(name)
+			if err != nil || value == "" {
+				continue
+			}
+			key.Close()
+			// Several files may be listed, the first one is the
+			// provider's own.
+			value, _, _ = strings.Cut(value, ";")
+			return expandBinaryPath(value), name
+		}
+		key.Close()
+	}
+	return "", ""
+}
+
+// expandBinaryPath turns a path as found in the registry into one that can
+// be opened: environment variables are expanded, the \SystemRoot\ and \??\
+// prefixes of driver paths are resolved and bare file names are looked up in
+// System32.
+func expandBinaryPath(path string) string {
+	if expanded, err := registry.ExpandString(path); err == nil {
+		path = expanded
+	}
+	systemRoot := os.Getenv("SystemRoot")
+	switch {
+	case len(path) > len(`\SystemRoot\`) && strings.EqualFold(path[:len(`\SystemRoot\`)], `\SystemRoot\`):
+		path = filepath.Join(systemRoot, path[len(`\SystemRoot\`):])
+	case strings.HasPrefix(path, `\??\`):
+		path = path[len(`\??\`):]
+	case strings.HasPrefix(strings.ToLower(path), `system32\`):
+		path = filepath.Join(systemRoot, path)
+	case filepath.Dir(path) == ".":
+		path = filepath.Join(systemRoot, "System32", path)
+	}
+	return path
+}
+
+// resolveProviderBinary returns the binary of a provider with its signature,
+// or nil if the registry doesn't name one.
+func resolveProviderBinary(guid string) *ProviderBinary {
+	path, source := getProviderBinary(guid)
+	if path == "" {
+		return nil
+	}
+	sig := getSignature(path)
+	binary := &ProviderBinary{Path: path, Source: source, Signature: sig.status, Signer: sig.signer, Catalog: sig.catalog}
+	if sig.err != nil {
+		binary.Error = sig.err.Error()
+	}
+	return binary
+}
+
+// getBinaryDescription renders the path and signature of a provider's
+// binary, e.g. "C:\Windows\system32\wevtapi.dll (signed by Microsoft
+// Windows, catalog)".
+func getBinaryDescription(b *ProviderBinary) string {
+	var state string
+	switch b.Signature {
+	case signatureSigned:
+		state = "signed"
+		if b.Signer != "" {
+			state += " by " + b.Signer
+		}
+		if b.Catalog {
+			state += ", catalog"
+		}
+	case signatureMissing:
+		state = "file is missing"
+	case signatureUnreadable:
+		state = "cannot be checked"
+		if b.Error != "" {
+			state += ": " + b.Error
+		}
+	case signatureInvalid:
+		state = "signature is invalid"
+		if b.Error != "" {
+			state += ": " + b.Error
+		}
+	default:
+		state = "not signed"
+	}
+	return fmt.Sprintf("%s (%s)", b.Path, state)
+}
+
+// binaryFlagged reports whether a provider's binary is missing or not
+// validly signed. A binary that can't be checked isn't flagged.
+func binaryFlagged(b *ProviderBinary) bool {
+	return b != nil && b.Signature != signatureSigned && b.Signature != signatureUnreadable
+}
+
+// providersWithBinaries returns the providers whose binary is known.
+func providersWithBinaries(providers []ETWProvider) []ETWProvider {
+	var result []ETWProvider
+	for _, p := range providers {
+		if p.Binary != nil {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// displayBinaries lists the binaries of the providers with their signature,
+// highlighting missing and unsigned ones.
+func displayBinaries(w io.Writer, providers []ETWProvider, pal palette) {
+	providers = providersWithBinaries(providers)
+	if len(providers) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\nProvider Binaries:\n")
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, p := range providers {
+		desc := getBinaryDescription(p.Binary)
+		if binaryFlagged(p.Binary) {
+			desc = pal.yellow(desc)
+		}
+		fmt.Fprintf(w, "- %s (%s): %s\n", p.Name, p.GUID, desc)
+	}
+}
+
+// isMicrosoftSigner reports whether a certificate subject is one of the
+// Microsoft code signing identities.
+func isMicrosoftSigner(signer string) bool {
+	return strings.HasPrefix(signer, "Microsoft ")
+}
+
+// isMicrosoftName reports whether a provider name follows Microsoft's naming,
+// such as Microsoft-Windows-Kernel-Process or Microsoft.Windows.Security.
+func isMicrosoftName(name string) bool {
+	for _, prefix := range []string{"Microsoft-", "Microsoft.", "Microsoft "} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyVendor tells whether a provider is part of Windows or comes from a
+// third party such as an EDR. The signer of the owning binary is the most
+// reliable indicator, then the provider name and, for binaries outside the
+// Windows directory, their location.
+func classifyVendor(p ETWProvider) string {
+	if b := p.Binary; b != nil && b.Signature == signatureSigned && b.Signer != "" {
+		if isMicrosoftSigner(b.Signer) {
+			return vendorMicrosoft
+		}
+		return vendorThirdParty
+	}
+	if isMicrosoftName(p.Name) {
+		return vendorMicrosoft
+	}
+	if p.Binary != nil {
+		windir := strings.ToLower(os.Getenv("SystemRoot"))
+		if windir != "" && !strings.HasPrefix(strings.ToLower(p.Binary.Path), windir+`\`) {
+			return vendorThirdParty
+		}
+	}
+	return vendorUnknown
+}
+
+// getVendorLabel renders the vendor of a provider for tables.
+func getVendorLabel(vendor string) string {
+	switch vendor {
+	case vendorMicrosoft:
+		return "Microsoft"
+	case vendorThirdParty:
+		return "Third-party"
+	case vendorUnknown:
+		return "Unknown"
+	}
+	return "-"
+}
+
+// getVendorSummary counts the providers of an autologger by vendor, e.g.
+// "12 Microsoft, 2 third-party, 1 unknown". It is "" when vendors weren't
+// classified.
+func getVendorSummary(providers []ETWProvider) string {
+	counts := make(map[string]int)
+	for _, p := range providers {
+		counts[p.Vendor]++
+	}
+	var parts []string
+	for _, v := range []struct{ vendor, label string }{
+		{vendorMicrosoft, "Microsoft"},
+		{vendorThirdParty, "third-party"},
+		{vendorUnknown, "unknown"},
+	} {
+		if counts[v.vendor] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[v.vendor], v.label))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
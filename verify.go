@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Drift is a setting of an autologger whose live session differs from the
+// registry.
+type Drift struct {
+	Setting string `json:"setting"`
+	// Provider is the GUID of the provider the setting belongs to, or ""
+	// for session settings.
+	Provider     string `json:"provider,omitempty"`
+	ProviderName string `json:"provider_name,omitempty"`
+	Registry     string `json:"registry"`
+	Live         string `json:"live"`
+}
+
+// VerifyReport compares the registry configuration of an autologger with
+// its live session.
+type VerifyReport struct {
+	Autologger string  `json:"autologger"`
+	Running    bool    `json:"running"`
+	LoggerID   uint16  `json:"logger_id,omitempty"`
+	Drift      []Drift `json:"drift"`
+}
+
+// verifyAutologger compares an autologger's buffers, log file mode, kernel
+// flags and providers with what its live session actually uses.
+func verifyAutologger(name string) (*VerifyReport, error) {
+	report, err := analyzeAutologger(name, analyzeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := &VerifyReport{Autologger: name, Drift: []Drift{}}
+	props, err := querySessionProperties(name)
+	if err == errSessionNotRunning {
+		if report.Config.Start != 0 {
+			result.Drift = append(result.Drift, Drift{Setting: "Session", Registry: "started at boot", Live: "not running"})
+		}
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session: %v", err)
+	}
+	result.Running = true
+	result.LoggerID = uint16(props.Wnode.HistoricalContext)
+	result.Drift = append(result.Drift, compareSessionSettings(report.Config, props)...)
+	if !isKernelSession(report.Config) {
+		result.Drift = append(result.Drift, compareSessionProviders(report.Providers, getSessionEnables(result.LoggerID))...)
+	}
+	return result, nil
+}
+
+// compareSessionSettings compares the session settings the registry sets
+// with the live ones. ETW raises the buffer counts to what the machine
+// needs, so only fewer buffers than configured are drift; settings the
+// registry leaves at 0 take the ETW default and aren't compared.
+func compareSessionSettings(config *AutologgerConfig, props *eventTraceProperties) []Drift {
+	var drift []Drift
+	add := func(setting string, registry, live uint64) {
+		drift = append(drift, Drift{Setting: setting, Registry: fmt.Sprintf("%d", registry), Live: fmt.Sprintf("%d", live)})
+	}
+	if config.BufferSize != 0 && config.BufferSize != uint64(props.BufferSize) {
+		add("BufferSize", config.BufferSize, uint64(props.BufferSize))
+	}
+	if config.MinimumBuffers != 0 && uint64(props.MinimumBuffers) < config.MinimumBuffers {
+		add("MinimumBuffers", config.MinimumBuffers, uint64(props.MinimumBuffers))
+	}
+	if config.MaximumBuffers != 0 && uint64(props.MaximumBuffers) < config.MaximumBuffers {
+		add("MaximumBuffers", config.MaximumBuffers, uint64(props.MaximumBuffers))
+	}
+	if config.FlushTimer != 0 && config.FlushTimer != uint64(props.FlushTimer) {
+		add("FlushTimer", config.FlushTimer, uint64(props.FlushTimer))
+	}
+	// ETW adds mode flags of its own, only configured flags that are gone
+	// count.
+	if missing := config.LogFileMode &^ uint64(props.LogFileMode); missing != 0 {
+		drift = append(drift, Drift{
+			Setting:  "LogFileMode",
+			Registry: getLogFileModeDescription(config.LogFileMode),
+			Live:     getLogFileModeDescription(uint64(props.LogFileMode)),
+		})
+	}
+	if config.EnableFlags != 0 && config.EnableFlags != uint64(props.EnableFlags) {
+		drift = append(drift, Drift{
+			Setting:  "EnableFlags",
+			Registry: fmt.Sprintf("0x%08X", config.EnableFlags),
+			Live:     fmt.Sprintf("0x%08X", props.EnableFlags),
+		})
+	}
+	return drift
+}
+
+// compareSessionProviders compares the providers of an autologger with the
+// ones its live session enables: configured providers the session doesn't
+// enable, providers it enables that aren't configured, and differing levels
+// and keywords. Provider groups are expected to enable their members.
+func compareSessionProviders(providers []ETWProvider, live map[string]traceEnableInfo) []Drift {
+	var drift []Drift
+	expected := make(map[string]bool)
+	for _, p := range providers {
+		guid := canonicalGUID(p.GUID)
+		expected[guid] = true
+		if p.ProviderType == providerTypeGroup {
+			for _, m := range p.GroupMembers {
+				expected[m.GUID] = true
+			}
+			continue
+		}
+		if p.EnabledState == enabledOff {
+			continue
+		}
+		var session *SessionEnable
+		if enable, ok := live[guid]; ok {
+			session = &SessionEnable{
+				Level:           enable.level,
+				MatchAnyKeyword: enable.matchAnyKeyword,
+				MatchAllKeyword: enable.matchAllKeyword,
+			}
+		}
+		drift = append(drift, compareProviderEnable(p, session)...)
+	}
+
+	var extra []string
+	for guid := range live {
+		if !expected[guid] {
+			extra = append(extra, guid)
+		}
+	}
+	slices.Sort(extra)
+	for _, guid := range extra {
+		drift = append(drift, Drift{Setting: "Provider", Provider: guid, ProviderName: resolveProviderName(guid), Registry: "not configured", Live: "enabled"})
+	}
+	return drift
+}
+
+// compareProviderEnable compares the level and keywords of a provider in the
+// registry with the ones its live session enabled it with. A nil session
+// means the session doesn't enable the provider at all.
+func compareProviderEnable(p ETWProvider, session *SessionEnable) []Drift {
+	if session == nil {
+		return []Drift{{Setting: "Provider", Provider: p.GUID, ProviderName: p.Name, Registry: "enabled", Live: "not enabled"}}
+	}
+	var drift []Drift
+	if p.EnableLevel != uint64(session.Level) {
+		drift = append(drift, Drift{Setting: "EnableLevel", Provider: p.GUID, ProviderName: p.Name,
+			Registry: getLevelName(p.EnableLevel), Live: getLevelName(uint64(session.Level))})
+	}
+	if p.MatchAnyKeyword != session.MatchAnyKeyword {
+		drift = append(drift, Drift{Setting: "MatchAnyKeyword", Provider: p.GUID, ProviderName: p.Name,
+			Registry: formatKeyword(p.MatchAnyKeyword), Live: formatKeyword(session.MatchAnyKeyword)})
+	}
+	if p.MatchAllKeyword != session.MatchAllKeyword {
+		drift = append(drift, Drift{Setting: "MatchAllKeyword", Provider: p.GUID, ProviderName: p.Name,
+			Registry: formatKeyword(p.MatchAllKeyword), Live: formatKeyword(session.MatchAllKeyword)})
+	}
+	return drift
+}
+
+func runVerify(cmd *command, args []string) error {
+	var format string
+	var output outputOptions
+
+	fs := newFlagSet(cmd)
+	registerProviderDBFlags(fs)
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	names := parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if len(names) == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one autologger name is required")
+	}
+
+	var reports []*VerifyReport
+	var summary reportSummary
+	for _, name := range names {
+		report, err := verifyAutologger(name)
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			summary.skipped++
+			continue
+		}
+		summary.findings += len(report.Drift)
+		reports = append(reports, report)
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			return err
+		}
+	} else {
+		pal := newPalette(os.Stdout, output.color)
+		for _, report := range reports {
+			displayVerifyReport(os.Stdout, report, pal)
+		}
+	}
+	return summary.exitStatus()
+}
+
+// displayVerifyReport prints the drift of an autologger from its registry
+// configuration.
+func displayVerifyReport(w io.Writer, report *VerifyReport, pal palette) {
+	state := "not running"
+	if report.Running {
+		state = fmt.Sprintf("running as logger %d", report.LoggerID)
+	}
+	fmt.Fprintf(w, "%s (%s):\n", report.Autologger, state)
+	if len(report.Drift) == 0 {
+		fmt.Fprintf(w, "The live session matches the registry\n\n")
+		return
+	}
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	for _, d := range report.Drift {
+		setting := d.Setting
+		if d.Provider != "" {
+			setting = fmt.Sprintf("%s of %s (%s)", d.Setting, d.ProviderName, d.Provider)
+		}
+		fmt.Fprintf(w, "- %s: registry %s, live %s\n", setting, d.Registry, pal.yellow(d.Live))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	procNtOpenDirectoryObject  = ntdll.NewProc("NtOpenDirectoryObject")
+	procNtQueryDirectoryObject = ntdll.NewProc("NtQueryDirectoryObject")
+	procRegLoadAppKeyW         = modadvapi32.NewProc("RegLoadAppKeyW")
+)
+
+const (
+	directoryQuery = 0x0001
+	// regProcessAppKey makes a hive loaded by RegLoadAppKey private to the
+	// process, so it is unloaded when its key is closed.
+	regProcessAppKey = 0x0001
+	// hiveRemoveAttempts is how many times removeHiveCopy tries to remove
+	// a hive copy, waiting up to a second and a half in total.
+	hiveRemoveAttempts = 6
+
+	shadowCopyPrefix = "HarddiskVolumeShadowCopy"
+	// currentSource is the source of the timeline point of the live
+	// registry.
+	currentSource = "current"
+)
+
+// vssListCommand and vssTimelineCommand describe the vss subcommands for
+// their help text.
+var (
+	vssListCommand = &command{
+		name:    "vss list",
+		args:    "[flags]",
+		summary: "List the volume shadow copies that hold a SYSTEM hive",
+	}
+	vssTimelineCommand = &command{
+		name:    "vss timeline",
+		args:    "[flags] <autologger>",
+		summary: "Show how an autologger's configuration changed across the volume shadow copies",
+	}
+)
+
+// ShadowCopy is a volume shadow copy with a SYSTEM hive.
+type ShadowCopy struct {
+	// Device is the name of the shadow copy device, e.g.
+	// "HarddiskVolumeShadowCopy3".
+	Device string `json:"device"`
+	// HiveModTime is when the SYSTEM hive was last written before the
+	// shadow copy was taken. It isn't the creation time of the shadow copy,
+	// which can be hours later on an idle host.
+	HiveModTime time.Time `json:"hive_modified"`
+}
+
+// AutologgerTimeline is the configuration of an autologger in every shadow
+// copy and the live registry, and what changed from one to the next.
+type AutologgerTimeline struct {
+	Autologger string           `json:"autologger"`
+	Points     []TimelinePoint  `json:"points"`
+	Changes    []TimelineChange `json:"changes"`
+}
+
+// TimelinePoint is the state of an autologger in a shadow copy or the live
+// registry.
+type TimelinePoint struct {
+	// Source is the shadow copy device, or "current" for the live registry.
+	Source string `json:"source"`
+	// Time is when the hive of a shadow copy was last written, or when the
+	// live registry was read.
+	Time      time.Time `json:"time"`
+	Present   bool      `json:"present"`
+	Providers int       `json:"providers"`
+	Error     string    `json:"error,omitempty"`
+
+	report *AutologgerReport
+}
+
+// TimelineChange is what changed between two points that could be read.
+type TimelineChange struct {
+	From     string    `json:"from"`
+	FromTime time.Time `json:"from_time"`
+	To       string    `json:"to"`
+	ToTime   time.Time `json:"to_time"`
+	// Change is "added", "removed" or "changed".
+	Change      string    `json:"change"`
+	Differences []DiffRow `json:"differences,omitempty"`
+}
+
+// objectDirectoryInformation is OBJECT_DIRECTORY_INFORMATION.
+type objectDirectoryInformation struct {
+	Name     windows.NTUnicodeString
+	TypeName windows.NTUnicodeString
+}
+
+// listShadowCopyDevices returns the shadow copy devices in the object
+// manager's \Device directory, oldest first.
+func listShadowCopyDevices() ([]string, error) {
+	name, err := windows.NewNTUnicodeString(`\Device`)
+	if err != nil {
+		return nil, err
+	}
+	oa := windows.OBJECT_ATTRIBUTES{ObjectName: name}
+	oa.Length = uint32(unsafe.Sizeof(oa))
+	var dir windows.Handle
+	r, _, _ := procNtOpenDirectoryObject.Call(uintptr(unsafe.Pointer(&dir)), directoryQuery, uintptr(unsafe.Pointer(&oa)))
+	if r != 0 {
+		return nil, fmt.Errorf("failed to open \\Device: %v", windows.NTStatus(r))
+	}
+	defer windows.CloseHandle(dir)
+
+	var devices []string
+	buf := make([]byte, 64*1024)
+	var context, length uint32
+	restart := uintptr(1)
+	for {
+		r, _, _ := procNtQueryDirectoryObject.Call(uintptr(dir), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)),
+			0, restart, uintptr(unsafe.Pointer(&context)), uintptr(unsafe.Pointer(&length)))
+		restart = 0
+		if windows.NTStatus(r) == windows.STATUS_NO_MORE_ENTRIES {
+			break
+		}
+		if r != 0 && windows.NTStatus(r) != windows.STATUS_MORE_ENTRIES {
+			return nil, fmt.Errorf("failed to list \\Device: %v", windows.NTStatus(r))
+		}
+		// The entries end with one that is all zero.
+		entrySize := unsafe.Sizeof(objectDirectoryInformation{})
+		for offset := uintptr(0); offset+entrySize <= uintptr(len(buf)); offset += entrySize {
+			entry := (*objectDirectoryInformation)(unsafe.Pointer(&buf[offset]))
+			if entry.Name.Length == 0 {
+				break
+			}
+			if name := entry.Name.String(); strings.HasPrefix(name, shadowCopyPrefix) {
+				devices = append(devices, name)
+			}
+		}
+		if windows.NTStatus(r) != windows.STATUS_MORE_ENTRIES {
+			break
+		}
+	}
+	slices.SortFunc(devices, func(a, b string) int {
+		na, _ := strconv.Atoi(strings.TrimPrefix(a, shadowCopyPrefix))
+		nb, _ := strconv.Atoi(strings.TrimPrefix(b, shadowCopyPrefix))
+		return na - nb
+	})
+	return devices, nil
+}
+
+// shadowHivePath is the path of the SYSTEM hive in a shadow copy of the
+// system volume.
+func shadowHivePath(device string) string {
+	systemRoot := cmp.Or(os.Getenv("SystemRoot"), `C:\Windows`)
+	systemRoot = strings.TrimPrefix(systemRoot, filepath.VolumeName(systemRoot))
+	return `\\?\GLOBALROOT\Device\` + device + systemRoot + `\System32\config\SYSTEM`
+}
+
+// listShadowCopies returns the shadow copies that hold a SYSTEM hive, which
+// are those of the system volume, oldest first.
+func listShadowCopies() ([]ShadowCopy, error) {
+	devices, err := listShadowCopyDevices()
+	if err != nil {
+		return nil, err
+	}
+	var copies []ShadowCopy
+	for _, device := range devices {
+		info, err := os.Stat(shadowHivePath(device))
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("cannot read shadow copy", "device", device, "error", err)
+			}
+			continue
+		}
+		copies = append(copies, ShadowCopy{Device: device, HiveModTime: info.ModTime()})
+	}
+	slices.SortStableFunc(copies, func(a, b ShadowCopy) int { return a.HiveModTime.Compare(b.HiveModTime) })
+	return copies, nil
+}
+
+// loadShadowHive copies the SYSTEM hive of a shadow copy, with its
+// transaction logs so that pending writes are applied, and loads it as an
+// application hive. The returned function unloads the hive and removes the
+// copy.
+func loadShadowHive(device string) (registry.Key, func(), error) {
+	dir, err := os.MkdirTemp("", "autologgerAnalyzer-")
+	if err != nil {
+		return 0, nil, err
+	}
+	cleanup := func() { removeHiveCopy(dir) }
+	hive := shadowHivePath(device)
+	for _, suffix := range []string{"", ".LOG1", ".LOG2"} {
+		if err := copyFile(hive+suffix, filepath.Join(dir, "SYSTEM"+suffix)); err != nil {
+			if suffix != "" && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			cleanup()
+			return 0, nil, fmt.Errorf("failed to copy the SYSTEM hive: %v", err)
+		}
+	}
+
+	path, err := windows.UTF16PtrFromString(filepath.Join(dir, "SYSTEM"))
+	if err != nil {
+		cleanup()
+		return 0, nil, err
+	}
+	var key registry.Key
+	r, _, _ := procRegLoadAppKeyW.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&key)), registry.READ, regProcessAppKey, 0)
+	if r != 0 {
+		cleanup()
+		return 0, nil, fmt.Errorf("failed to load the SYSTEM hive: %v", windows.Errno(r))
+	}
+	return key, func() {
+		key.Close()
+		cleanup()
+	}, nil
+}
+
+// removeHiveCopy removes the copy of a hive. The system releases the files
+// of an application hive shortly after its last key is closed, so removing
+// them is retried for a while before giving up.
+func removeHiveCopy(dir string) {
+	var err error
+	for attempt := 1; attempt <= hiveRemoveAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * 100 * time.Millisecond)
+		}
+		if err = os.RemoveAll(dir); err == nil {
+			return
+		}
+	}
+	slog.Warn("cannot remove hive copy", "path", dir, "error", err)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// readHiveAutologger reads an autologger from the current control set of
+// an offline SYSTEM hive. It returns nil if the autologger doesn't exist.
+func readHiveAutologger(hive registry.Key, name string) (*AutologgerReport, error) {
+	sel, err := openKey(hive, "Select", registry.QUERY_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Select: %v", err)
+	}
+	current, _, err := sel.GetIntegerValue("Current")
+	sel.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the current control set: %v", err)
+	}
+
+	path := fmt.Sprintf(`ControlSet%03d\Control\WMI\Autologger\%s`, current, name)
+	key, err := openKey(hive, path, registry.READ)
+	if err == registry.ErrNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open autologger key: %v", err)
+	}
+	defer key.Close()
+	config := readAutologgerConfig(key, name)
+	providers, invalid, err := readETWProviders(key, name, analyzeOptions{noResolve: true})
+	if err != nil {
+		return nil, fmt.Errorf("reading ETW providers: %v", err)
+	}
+	return &AutologgerReport{Config: config, Providers: providers, InvalidSubkeys: invalid}, nil
+}
+
+// readLiveAutologger reads an autologger from the live registry. It returns
+// nil if the autologger doesn't exist.
+func readLiveAutologger(name string) (*AutologgerReport, error) {
+	key, err := openKey(registry.LOCAL_MACHINE, baseAutologgerPath+`\`+name, registry.READ)
+	if err == registry.ErrNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open autologger key: %v", err)
+	}
+	key.Close()
+	return analyzeAutologger(name, analyzeOptions{noResolve: true})
+}
+
+// buildTimeline reads an autologger from every shadow copy and the live
+// registry, and compares each point with the previous one that could be
+// read.
+func buildTimeline(name string, copies []ShadowCopy) *AutologgerTimeline {
+	timeline := &AutologgerTimeline{Autologger: name, Points: []TimelinePoint{}, Changes: []TimelineChange{}}
+	for _, c := range copies {
+		point := TimelinePoint{Source: c.Device, Time: c.HiveModTime}
+		hive, unload, err := loadShadowHive(c.Device)
+		if err == nil {
+			point.report, err = readHiveAutologger(hive, name)
+			unload()
+		}
+		if err != nil {
+			slog.Warn("skipping shadow copy", "device", c.Device, "error", err)
+			point.Error = err.Error()
+		}
+		timeline.Points = append(timeline.Points, point)
+	}
+	live := TimelinePoint{Source: currentSource, Time: time.Now()}
+	var err error
+	if live.report, err = readLiveAutologger(name); err != nil {
+		live.Error = err.Error()
+	}
+	timeline.Points = append(timeline.Points, live)
+
+	var previous *TimelinePoint
+	for i := range timeline.Points {
+		point := &timeline.Points[i]
+		if point.Error != "" {
+			continue
+		}
+		// Snapshots hold only what the registry configures, so the
+		// points compare like snapshots do.
+		if point.report != nil {
+			point.report = newSnapshot(nil, []*AutologgerReport{point.report}).Autologgers[0]
+			point.Present = true
+			point.Providers = len(point.report.Providers)
+		}
+		if previous != nil {
+			change := TimelineChange{From: previous.Source, FromTime: previous.Time, To: point.Source, ToTime: point.Time}
+			switch {
+			case previous.report == nil && point.report != nil:
+				change.Change = "added"
+			case previous.report != nil && point.report == nil:
+				change.Change = "removed"
+			case previous.report != nil:
+				if change.Differences = diffAutologgers(previous.report, point.report, false); len(change.Differences) > 0 {
+					change.Change = "changed"
+				}
+			}
+			if change.Change != "" {
+				timeline.Changes = append(timeline.Changes, change)
+			}
+		}
+		previous = point
+	}
+	return timeline
+}
+
+func runVSS(cmd *command, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runVSSList(args[1:])
+		case "timeline":
+			return runVSSTimeline(args[1:])
+		}
+	}
+	fs := newFlagSet(cmd)
+	fs.Usage()
+	return fmt.Errorf("a vss subcommand is required: list or timeline")
+}
+
+// runVSSList implements vss list.
+func runVSSList(args []string) error {
+	var format string
+
+	fs := newFlagSet(vssListCommand)
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	copies, err := listShadowCopies()
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if copies == nil {
+			copies = []ShadowCopy{}
+		}
+		return enc.Encode(copies)
+	}
+	if len(copies) == 0 {
+		fmt.Println("No shadow copy of the system volume found")
+		return nil
+	}
+	fmt.Printf("| %-32s | %-19s |\n", "Shadow Copy", "Hive Last Written")
+	fmt.Printf("|%s|%s|\n", strings.Repeat("-", 34), strings.Repeat("-", 21))
+	for _, c := range copies {
+		fmt.Printf("| %-32s | %-19s |\n", c.Device, c.HiveModTime.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// runVSSTimeline implements vss timeline.
+func runVSSTimeline(args []string) error {
+	var format string
+	var output outputOptions
+
+	fs := newFlagSet(vssTimelineCommand)
+	fs.StringVar(&format, "format", "table", "Output format: table or json")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	names := parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if len(names) != 1 {
+		fs.Usage()
+		return fmt.Errorf("an autologger name is required")
+	}
+
+	copies, err := listShadowCopies()
+	if err != nil {
+		return err
+	}
+	if len(copies) == 0 {
+		slog.Warn("no shadow copy of the system volume found, the timeline only has the live registry")
+	}
+	timeline := buildTimeline(names[0], copies)
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(timeline); err != nil {
+			return err
+		}
+	} else {
+		displayTimeline(os.Stdout, timeline, newPalette(os.Stdout, output.color))
+	}
+
+	summary := reportSummary{findings: len(timeline.Changes)}
+	for _, point := range timeline.Points {
+		if point.Error != "" {
+			summary.skipped++
+		}
+	}
+	return summary.exitStatus()
+}
+
+// getTimelineLabel names a point of a timeline for the column of a diff,
+// e.g. "2026-10-17 08:00 (ShadowCopy3)".
+func getTimelineLabel(source string, t time.Time) string {
+	return fmt.Sprintf("%s (%s)", t.Format("2006-01-02 15:04"), strings.TrimPrefix(source, "HarddiskVolume"))
+}
+
+// displayTimeline prints a line per point and a diff per change.
+func displayTimeline(w io.Writer, timeline *AutologgerTimeline, pal palette) {
+	fmt.Fprintf(w, "Timeline of %s across %d shadow copies:\n", timeline.Autologger, len(timeline.Points)-1)
+	fmt.Fprintln(w, strings.Repeat("=", 80))
+	changed := make(map[string]string)
+	for _, c := range timeline.Changes {
+		changed[c.To] = c.Change
+	}
+	for _, point := range timeline.Points {
+		state := fmt.Sprintf("present, %d providers", point.Providers)
+		switch {
+		case point.Error != "":
+			state = pal.yellow("unreadable: " + point.Error)
+		case !point.Present:
+			state = "not configured"
+		}
+		switch changed[point.Source] {
+		case "added":
+			state += " (added)"
+		case "removed":
+			state = pal.red(state + " (removed)")
+		case "changed":
+			state = pal.yellow(state + " (changed)")
+		}
+		fmt.Fprintf(w, "%s  %-32s %s\n", point.Time.Format("2006-01-02 15:04"), point.Source, state)
+	}
+
+	for _, c := range timeline.Changes {
+		if c.Change != "changed" {
+			continue
+		}
+		fmt.Fprintln(w)
+		displayDiff(w, &AutologgerDiff{A: getTimelineLabel(c.From, c.FromTime), B: getTimelineLabel(c.To, c.ToTime), Differences: c.Differences}, pal)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// SessionDelta is what changed in the live session of an autologger between
+// two polls.
+type SessionDelta struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Autologger string    `json:"autologger"`
+	Session    string    `json:"session,omitempty"`
+	LoggerID   uint16    `json:"logger_id,omitempty"`
+	// State is "running", "started", "restarted" or "stopped". The deltas
+	// of a session that started or restarted since the last poll are
+	// counted from 0.
+	State             string `json:"state"`
+	EventsLost        uint32 `json:"events_lost"`
+	BuffersLost       uint32 `json:"buffers_lost"`
+	BuffersWritten    uint32 `json:"buffers_written"`
+	NumberOfBuffers   uint32 `json:"number_of_buffers"`
+	BufferGrowth      int64  `json:"buffer_growth"`
+	TotalEventsLost   uint32 `json:"total_events_lost"`
+	ThresholdExceeded bool   `json:"threshold_exceeded,omitempty"`
+}
+
+// sessionWatcher remembers the statistics of the last poll per autologger.
+type sessionWatcher struct {
+	configs  map[string]*AutologgerConfig
+	last     map[string]*SessionStats
+	maxLoss  int64
+	eventLog *eventLogSink
+	webhook  *webhookQueue
+}
+
+// seed records the sessions running when the watch starts without reporting
+// them, so the first poll reports what changed since then instead of every
+// running session as started.
+func (sw *sessionWatcher) seed(names []string) {
+	for _, name := range names {
+		stats, err := findAutologgerSession(sw.configs[name])
+		if err != nil {
+			if err != errSessionNotRunning {
+				slog.Warn("cannot query session", "autologger", name, "error", err)
+			}
+			continue
+		}
+		sw.last[name] = stats
+	}
+	slog.Info("watching sessions", "autologgers", len(names), "running", len(sw.last))
+}
+
+// poll queries the session of every watched autologger and returns the
+// deltas since the previous poll. Autologgers whose session isn't running
+// and wasn't running before are left out.
+func (sw *sessionWatcher) poll(names []string) []SessionDelta {
+	var deltas []SessionDelta
+	now := time.Now()
+	for _, name := range names {
+		prev := sw.last[name]
+		stats, err := findAutologgerSession(sw.configs[name])
+		if err != nil && err != errSessionNotRunning {
+			slog.Warn("cannot query session", "autologger", name, "error", err)
+			continue
+		}
+		if stats == nil {
+			delete(sw.last, name)
+			if prev != nil {
+				deltas = append(deltas, SessionDelta{Event: "session", Time: now, Autologger: name, Session: prev.Name, LoggerID: prev.LoggerID, State: "stopped"})
+			}
+			continue
+		}
+		sw.last[name] = stats
+
+		d := SessionDelta{
+			Event:           "session",
+			Time:            now,
+			Autologger:      name,
+			Session:         stats.Name,
+			LoggerID:        stats.LoggerID,
+			State:           "running",
+			NumberOfBuffers: stats.NumberOfBuffers,
+			TotalEventsLost: stats.EventsLost,
+		}
+		// Counters that went backwards belong to a new session.
+		base := prev
+		switch {
+		case prev == nil:
+			d.State = "started"
+			base = &SessionStats{}
+		case prev.LoggerID != stats.LoggerID || stats.EventsLost < prev.EventsLost || stats.BuffersWritten < prev.BuffersWritten:
+			d.State = "restarted"
+			base = &SessionStats{}
+		}
+		d.EventsLost = stats.EventsLost - base.EventsLost
+		buffersLost := stats.LogBuffersLost + stats.RealTimeBuffersLost
+		d.BuffersLost = buffersLost - min(base.LogBuffersLost+base.RealTimeBuffersLost, buffersLost)
+		d.BuffersWritten = stats.BuffersWritten - base.BuffersWritten
+		d.BufferGrowth = int64(stats.NumberOfBuffers) - int64(base.NumberOfBuffers)
+		d.ThresholdExceeded = sw.maxLoss >= 0 && prev != nil && int64(d.EventsLost) > sw.maxLoss
+		if d.ThresholdExceeded {
+			sw.alert(d)
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// alert reports a session that lost more events than -max-loss allows since
+// the last poll.
+func (sw *sessionWatcher) alert(d SessionDelta) {
+	slog.Warn("session is losing events", "autologger", d.Autologger, "session", d.Session,
+		"events_lost", d.EventsLost, "buffers_lost", d.BuffersLost, "threshold", sw.maxLoss)
+	sw.notify(Finding{
+		ID:         "SESSION_EVENTS_LOST",
+		Severity:   SeverityMedium,
+		Autologger: d.Autologger,
+		Message: fmt.Sprintf("The live session of autologger %s lost %d events and %d buffers since the last poll, more than the threshold of %d",
+			d.Autologger, d.EventsLost, d.BuffersLost, sw.maxLoss),
+	})
+}
+
+// notify writes an alert, registry change or registry write to the event
+// log and the webhook, if enabled.
+func (sw *sessionWatcher) notify(f Finding) {
+	if sw.eventLog != nil {
+		if err := sw.eventLog.writeFinding(f); err != nil {
+			slog.Warn("cannot write finding to event log", "id", f.ID, "error", err)
+		}
+	}
+	if sw.webhook != nil {
+		sw.webhook.add(f)
+	}
+}
+
+const (
+	// webhookQueueSize is how many findings wait for the webhook before
+	// new ones are dropped.
+	webhookQueueSize = 1024
+	// webhookCoalesceDelay is how long findings are collected after the
+	// first one before they are posted together.
+	webhookCoalesceDelay = 2 * time.Second
+	// webhookDrainTimeout is how long watch waits for queued findings to
+	// be posted when it ends.
+	webhookDrainTimeout = 10 * time.Second
+)
+
+// webhookQueue posts the findings of watch from a goroutine, so a slow or
+// unreachable webhook doesn't hold up polling and registry events. Findings
+// that arrive in a burst are posted as one message per autologger.
+type webhookQueue struct {
+	sink    *webhookSink
+	pending chan Finding
+	done    chan struct{}
+}
+
+func newWebhookQueue(sink *webhookSink) *webhookQueue {
+	q := &webhookQueue{sink: sink, pending: make(chan Finding, webhookQueueSize), done: make(chan struct{})}
+	go q.run()
+	return q
+}
+
+// add queues a finding, or drops it with a warning when the queue is full.
+func (q *webhookQueue) add(f Finding) {
+	select {
+	case q.pending <- f:
+	default:
+		slog.Warn("webhook queue is full, dropping finding", "id", f.ID, "autologger", f.Autologger)
+	}
+}
+
+func (q *webhookQueue) run() {
+	defer close(q.done)
+	for f := range q.pending {
+		batch := []Finding{f}
+		timer := time.NewTimer(webhookCoalesceDelay)
+	collect:
+		for {
+			select {
+			case f, ok := <-q.pending:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, f)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		q.post(batch)
+	}
+}
+
+// post sends a batch of findings, one message per autologger.
+func (q *webhookQueue) post(batch []Finding) {
+	var order []string
+	byAutologger := make(map[string][]Finding)
+	for _, f := range batch {
+		if _, ok := byAutologger[f.Autologger]; !ok {
+			order = append(order, f.Autologger)
+		}
+		byAutologger[f.Autologger] = append(byAutologger[f.Autologger], f)
+	}
+	for _, autologger := range order {
+		if err := q.sink.writeFindings(autologger, byAutologger[autologger]); err != nil {
+			slog.Warn("cannot send findings to webhook", "autologger", autologger, "findings", len(byAutologger[autologger]), "error", err)
+		}
+	}
+}
+
+// Close posts the queued findings and waits for them, at most
+// webhookDrainTimeout.
+func (q *webhookQueue) Close() {
+	close(q.pending)
+	select {
+	case <-q.done:
+	case <-time.After(webhookDrainTimeout):
+		slog.Warn("abandoning webhook posts still pending at exit")
+	}
+}
+
+func runWatch(cmd *command, args []string) error {
+	var interval time.Duration
+	var maxLoss int64
+	var format string
+	var eventLog, watchReg, traceReg bool
+	var output outputOptions
+	var webhook webhookOptions
+
+	fs := newFlagSet(cmd)
+	fs.DurationVar(&interval, "interval", 10*time.Second, "Poll interval, 0 to only watch the registry")
+	fs.BoolVar(&watchReg, "registry", false, "Also report changes to the Autologger registry key as they happen")
+	fs.BoolVar(&traceReg, "registry-etw", false, "Also report which process writes to the Autologger registry key, from Microsoft-Windows-Kernel-Registry events")
+	fs.Int64Var(&maxLoss, "max-loss", -1, "Alert when a session loses more events than this between polls, -1 to disable")
+	fs.BoolVar(&eventLog, "eventlog", false, "Also write alerts, registry changes and registry writes to the Application event log")
+	webhook.register(fs)
+	fs.StringVar(&format, "format", "table", "Output format: table or json (one object per line)")
+	fs.StringVar(&output.color, "color", "auto", "Color output: auto, always or never")
+	names := parseArgs(fs, args)
+
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", format)
+	}
+	if interval < 0 || interval == 0 && !watchReg && !traceReg {
+		return fmt.Errorf("-interval must be positive")
+	}
+	hook, err := webhook.build(collectHostMetadata())
+	if err != nil {
+		return fmt.Errorf("configuring webhook sink: %v", err)
+	}
+	// Registry changes are only filtered when autologgers are named, so
+	// autologgers created while watching are reported too.
+	filter := make(map[string]bool)
+	for _, name := range names {
+		filter[strings.ToLower(name)] = true
+	}
+	if len(names) == 0 {
+		var err error
+		if names, err = getAutologgerNames(); err != nil {
+			return err
+		}
+	}
+	if interval == 0 {
+		names = nil
+	}
+
+	sw := &sessionWatcher{
+		configs: make(map[string]*AutologgerConfig),
+		last:    make(map[string]*SessionStats),
+		maxLoss: maxLoss,
+	}
+	var summary reportSummary
+	var watched []string
+	for _, name := range names {
+		config, err := getAutologgerConfig(name)
+		if err != nil {
+			slog.Warn("skipping autologger", "autologger", name, "error", err)
+			summary.skipped++
+			continue
+		}
+		sw.configs[name] = config
+		watched = append(watched, name)
+	}
+	if len(watched) == 0 && !watchReg && !traceReg {
+		return summary.exitStatus()
+	}
+	if hook != nil {
+		sw.webhook = newWebhookQueue(hook)
+		defer sw.webhook.Close()
+	}
+	if eventLog {
+		sink, err := newEventLogSink()
+		if err != nil {
+			return fmt.Errorf("configuring event log sink: %v", err)
+		}
+		defer sink.Close()
+		sw.eventLog = sink
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pal := newPalette(os.Stdout, output.color)
+	enc := json.NewEncoder(os.Stdout)
+	poll := func() error {
+		for _, d := range sw.poll(watched) {
+			if d.ThresholdExceeded {
+				summary.findings++
+			}
+			if format == "json" {
+				if err := enc.Encode(d); err != nil {
+					return err
+				}
+			} else {
+				displaySessionDelta(os.Stdout, d, pal)
+			}
+		}
+		return nil
+	}
+	report := func(changes []RegistryChange) error {
+		for _, c := range changes {
+			if len(filter) > 0 && !filter[strings.ToLower(c.Autologger)] {
+				continue
+			}
+			summary.findings++
+			if format == "json" {
+				if err := enc.Encode(c); err != nil {
+					return err
+				}
+			} else {
+				displayRegistryChange(os.Stdout, c, pal)
+			}
+			sw.notify(registryChangeFinding(c))
+		}
+		return nil
+	}
+	reportWrite := func(w RegistryWrite) error {
+		if len(filter) > 0 && !filter[strings.ToLower(w.Autologger)] {
+			return nil
+		}
+		summary.findings++
+		if format == "json" {
+			if err := enc.Encode(w); err != nil {
+				return err
+			}
+		} else {
+			displayRegistryWrite(os.Stdout, w, pal)
+		}
+		sw.notify(registryWriteFinding(w))
+		return nil
+	}
+
+	var changes chan []RegistryChange
+	errc := make(chan error, 1)
+	if watchReg {
+		changes = make(chan []RegistryChange)
+		go func() { errc <- watchRegistry(ctx, changes) }()
+	}
+	// The registry session is stopped when the watch ends, so it has to be
+	// waited for instead of being abandoned with the process.
+	var writes chan RegistryWrite
+	traceDone := make(chan error, 1)
+	if traceReg {
+		writes = make(chan RegistryWrite)
+		traceCtx, cancel := context.WithCancel(ctx)
+		go func() { traceDone <- watchRegistryWrites(traceCtx, writes) }()
+		defer func() {
+			cancel()
+			<-traceDone
+		}()
+	}
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+		sw.seed(watched)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return summary.exitStatus()
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+			return summary.exitStatus()
+		case err := <-traceDone:
+			// Let the deferred wait return.
+			traceDone <- nil
+			if err != nil {
+				return err
+			}
+			return summary.exitStatus()
+		case w := <-writes:
+			if err := reportWrite(w); err != nil {
+				return err
+			}
+		case batch := <-changes:
+			if err := report(batch); err != nil {
+				return err
+			}
+		case <-tick:
+			if err := poll(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// displaySessionDelta prints a poll of a session as a single line, e.g.
+// "08:00:10 DefenderApiLogger (logger 14): +0 events lost, +0 buffers lost,
+// +12 buffers written, 6 buffers (+2)". Losses are highlighted.
+func displaySessionDelta(w io.Writer, d SessionDelta, pal palette) {
+	line := fmt.Sprintf("%s %s", d.Time.Format("15:04:05"), d.Autologger)
+	if d.State == "stopped" {
+		fmt.Fprintf(w, "%s (logger %d): stopped\n", line, d.LoggerID)
+		return
+	}
+	line += fmt.Sprintf(" (logger %d): ", d.LoggerID)
+	if d.State != "running" {
+		line += d.State + ", "
+	}
+	lost := fmt.Sprintf("+%d events lost, +%d buffers lost", d.EventsLost, d.BuffersLost)
+	switch {
+	case d.ThresholdExceeded:
+		lost = pal.red(lost)
+	case d.EventsLost > 0 || d.BuffersLost > 0:
+		lost = pal.yellow(lost)
+	}
+	fmt.Fprintf(w, "%s%s, +%d buffers written, %d buffers (%+d)\n", line, lost, d.BuffersWritten, d.NumberOfBuffers, d.BufferGrowth)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// webhookFormats are the values accepted by -webhook-format.
+var webhookFormats = []string{"json", "slack", "teams"}
+
+// webhookOptions are the flags of the webhook sink, which watch shares with
+// the other sinks.
+type webhookOptions struct {
+	url    string
+	format string
+	rules  alertRules
+}
+
+func (o *webhookOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.url, "webhook", "", "Also POST the findings of every autologger to this URL")
+	fs.StringVar(&o.format, "webhook-format", "json", "Webhook payload: json, slack or teams")
+	fs.Var(&o.rules, "alert", "Only send findings matching this rule to -webhook, e.g. autologger=*Defender*,severity=medium; repeat for several")
+}
+
+// build returns the webhook sink, or nil without -webhook.
+func (o *webhookOptions) build(host *HostMetadata) (*webhookSink, error) {
+	if o.url == "" {
+		return nil, nil
+	}
+	if !slices.Contains(webhookFormats, o.format) {
+		return nil, fmt.Errorf("unknown webhook format %q (expected json, slack or teams)", o.format)
+	}
+	return &webhookSink{url: o.url, format: o.format, rules: o.rules, host: host, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// webhookPayload is the JSON body posted for the findings of an autologger.
+type webhookPayload struct {
+	Host       *HostMetadata `json:"host"`
+	Autologger string        `json:"autologger"`
+	Findings   []Finding     `json:"findings"`
+}
+
+// webhookSink posts the findings of every report with findings to a URL as
+// one document: JSON, a Slack message or a Teams card. Reports without
+// findings that match the alert rules aren't posted.
+type webhookSink struct {
+	url    string
+	format string
+	rules  alertRules
+	host   *HostMetadata
+	client *http.Client
+}
+
+func (s *webhookSink) WriteReport(report *AutologgerReport) error {
+	return s.writeFindings(report.Config.Name, report.Findings)
+}
+
+// writeFindings posts the findings of an autologger that match the alert
+// rules.
+func (s *webhookSink) writeFindings(autologger string, findings []Finding) error {
+	findings = s.rules.filter(findings)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	var payload any
+	switch s.format {
+	case "slack":
+		payload = slackMessage(s.host, autologger, findings)
+	case "teams":
+		payload = teamsMessage(s.host, autologger, findings)
+	default:
+		payload = webhookPayload{Host: s.host, Autologger: autologger, Findings: findings}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if err := postWithRetry(s.client, s.url, header, body); err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error { return nil }
+
+// alertTitle is the headline of a Slack or Teams message, e.g. "2 findings
+// for autologger DefenderApiLogger on WS01".
+func alertTitle(host *HostMetadata, autologger string, findings []Finding) string {
+	title := fmt.Sprintf("%d findings", len(findings))
+	if len(findings) == 1 {
+		title = "1 finding"
+	}
+	if autologger != "" {
+		title += " for autologger " + autologger
+	}
+	if host != nil && host.Hostname != "" {
+		title += " on " + host.Hostname
+	}
+	return title
+}
+
+// slackEscaper escapes the characters Slack treats as markup.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessage is the body of a Slack incoming webhook: a line per finding
+// under a bold title.
+func slackMessage(host *HostMetadata, autologger string, findings []Finding) map[string]any {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*autologgerAnalyzer: %s*", slackEscaper.Replace(alertTitle(host, autologger, findings)))
+	for _, f := range findings {
+		fmt.Fprintf(&b, "\n• [%s] `%s` %s", f.Severity, f.ID, slackEscaper.Replace(f.Message))
+	}
+	return map[string]any{"text": b.String()}
+}
+
+// teamsMessage is the body of a Teams incoming webhook or workflow: an
+// Adaptive Card with a block per finding.
+func teamsMessage(host *HostMetadata, autologger string, findings []Finding) map[string]any {
+	body := []map[string]any{{
+		"type":   "TextBlock",
+		"text":   "autologgerAnalyzer: " + alertTitle(host, autologger, findings),
+		"weight": "Bolder",
+		"size":   "Medium",
+		"wrap":   true,
+	}}
+	for _, f := range findings {
+		color := "Default"
+		switch f.Severity {
+		case SeverityHigh:
+			color = "Attention"
+		case SeverityMedium:
+			color = "Warning"
+		}
+		body = append(body,
+			map[string]any{"type": "TextBlock", "text": fmt.Sprintf("%s (%s)", f.ID, f.Severity), "weight": "Bolder", "color": color, "spacing": "Medium", "wrap": true},
+			map[string]any{"type": "TextBlock", "text": f.Message, "spacing": "None", "wrap": true},
+		)
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}
+}